@@ -1,7 +1,9 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
+	"os"
 
 	"autopr/internal/git"
 	"autopr/internal/pipeline"
@@ -9,7 +11,10 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var approveDraft bool
+var (
+	approveDraft        bool
+	approveAllowSecrets bool
+)
 
 var approveCmd = &cobra.Command{
 	Use:   "approve <job-id>",
@@ -20,6 +25,7 @@ var approveCmd = &cobra.Command{
 
 func init() {
 	approveCmd.Flags().BoolVar(&approveDraft, "draft", false, "create PR as draft")
+	approveCmd.Flags().BoolVar(&approveAllowSecrets, "allow-secrets", false, "push even if the secret scan finds suspected secrets")
 	rootCmd.AddCommand(approveCmd)
 }
 
@@ -44,8 +50,8 @@ func runApprove(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	if job.State != "ready" {
-		return fmt.Errorf("job %s is in state %q, must be 'ready' to approve", jobID, job.State)
+	if err := checkApprovable(jobID, job.State, approveAllowSecrets); err != nil {
+		return err
 	}
 
 	// Look up project config and issue for PR creation.
@@ -63,6 +69,14 @@ func runApprove(cmd *cobra.Command, args []string) error {
 	if err := pipeline.RebaseBeforePush(cmd.Context(), store, job.ID, job.AutoPRIssueID, proj.BaseBranch, job.WorktreePath, job.Iteration, cfg.GitTokenForProject(proj)); err != nil {
 		return fmt.Errorf("rebase before push: %w", err)
 	}
+	if job.State == "blocked" {
+		fmt.Fprintf(os.Stderr, "warning: pushing despite the secret scan findings (--allow-secrets)\n")
+	} else if err := pipeline.ScanBeforePush(cmd.Context(), store, job.ID, job.AutoPRIssueID, proj.BaseBranch, job.WorktreePath, job.Iteration); err != nil {
+		if errors.Is(err, pipeline.ErrSecretsFound) {
+			return fmt.Errorf("%w; job %s is now blocked, review `ap logs %s` and re-run with --allow-secrets to push anyway", err, jobID, jobID)
+		}
+		return err
+	}
 
 	pushRemote := "origin"
 	pushHead := job.BranchName
@@ -101,7 +115,7 @@ func runApprove(cmd *cobra.Command, args []string) error {
 	}
 
 	// Transition to approved.
-	if err := store.TransitionState(cmd.Context(), jobID, "ready", "approved"); err != nil {
+	if err := store.TransitionState(cmd.Context(), jobID, job.State, "approved"); err != nil {
 		return err
 	}
 
@@ -119,3 +133,21 @@ func runApprove(cmd *cobra.Command, args []string) error {
 	}
 	return nil
 }
+
+// checkApprovable reports whether a job in state can be approved.
+// --allow-secrets overrides the secret scan of a job it already blocked; it
+// does not skip the scan of a ready job.
+func checkApprovable(jobID, state string, allowSecrets bool) error {
+	switch {
+	case state == "blocked" && !allowSecrets:
+		return fmt.Errorf("job %s is blocked by the secret scan (see `ap logs %s`); re-run with --allow-secrets to push anyway", jobID, jobID)
+	case state == "blocked":
+		return nil
+	case state != "ready":
+		return fmt.Errorf("job %s is in state %q, must be 'ready' to approve", jobID, state)
+	case allowSecrets:
+		return fmt.Errorf("job %s is not blocked by the secret scan; --allow-secrets only applies to blocked jobs", jobID)
+	default:
+		return nil
+	}
+}
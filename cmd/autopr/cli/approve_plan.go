@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"fmt"
+
+	"autopr/internal/pipeline"
+
+	"github.com/spf13/cobra"
+)
+
+var approvePlanCmd = &cobra.Command{
+	Use:   "approve-plan <job-id>",
+	Short: "Approve the plan of a job awaiting plan approval",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runApprovePlan,
+}
+
+func init() {
+	rootCmd.AddCommand(approvePlanCmd)
+}
+
+func runApprovePlan(cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	store, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	jobID, err := resolveJob(store, args[0])
+	if err != nil {
+		return err
+	}
+
+	if err := pipeline.ApprovePlan(cmd.Context(), store, jobID); err != nil {
+		return err
+	}
+
+	if jsonOut {
+		printJSON(map[string]string{"job_id": jobID, "state": "queued"})
+		return nil
+	}
+	fmt.Printf("Plan for job %s approved; the job is queued to implement it.\n", jobID)
+	return nil
+}
@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckApprovableAllowSecretsOnlyOverridesBlockedJobs(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		state        string
+		allowSecrets bool
+		wantErr      string
+	}{
+		{state: "ready"},
+		{state: "ready", allowSecrets: true, wantErr: "only applies to blocked jobs"},
+		{state: "blocked", wantErr: "blocked by the secret scan"},
+		{state: "blocked", allowSecrets: true},
+		{state: "testing", wantErr: "must be 'ready'"},
+	}
+	for _, tc := range cases {
+		err := checkApprovable("job-1", tc.state, tc.allowSecrets)
+		if tc.wantErr == "" && err != nil {
+			t.Errorf("%s allow=%v: unexpected error %v", tc.state, tc.allowSecrets, err)
+		}
+		if tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)) {
+			t.Errorf("%s allow=%v: expected error containing %q, got %v", tc.state, tc.allowSecrets, tc.wantErr, err)
+		}
+	}
+}
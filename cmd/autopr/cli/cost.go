@@ -0,0 +1,287 @@
+package cli
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"autopr/internal/cost"
+	"autopr/internal/db"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	costProject string
+	costSince   string
+	costUntil   string
+	costBy      string
+	costCSV     bool
+)
+
+var costCmd = &cobra.Command{
+	Use:   "cost",
+	Short: "Report estimated LLM cost by project, day, week, step, provider or outcome",
+	RunE:  runCost,
+}
+
+func init() {
+	costCmd.Flags().StringVar(&costProject, "project", "", "filter by project name")
+	costCmd.Flags().StringVar(&costSince, "since", "", "first day to include (YYYY-MM-DD, UTC)")
+	costCmd.Flags().StringVar(&costUntil, "until", "", "last day to include (YYYY-MM-DD, UTC)")
+	costCmd.Flags().StringVar(&costBy, "by", "project", "group by: project, day, week, step, provider, or outcome")
+	costCmd.Flags().BoolVar(&costCSV, "csv", false, "output CSV")
+	rootCmd.AddCommand(costCmd)
+}
+
+// costRow is one group of a cost report. Merged PRs are the distinct jobs in
+// the group whose PR was merged; cost per merged PR divides the whole group's
+// cost, failed attempts included, by that count.
+type costRow struct {
+	Key                 string  `json:"key"`
+	Sessions            int     `json:"sessions"`
+	Jobs                int     `json:"jobs"`
+	InputTokens         int     `json:"input_tokens"`
+	OutputTokens        int     `json:"output_tokens"`
+	CacheReadTokens     int     `json:"cache_read_tokens"`
+	CacheCreationTokens int     `json:"cache_creation_tokens"`
+	CostUSD             float64 `json:"cost_usd"`
+	MergedPRs           int     `json:"merged_prs"`
+	CostPerMergedPR     float64 `json:"cost_per_merged_pr,omitempty"`
+}
+
+type costReport struct {
+	GroupBy string    `json:"group_by"`
+	Since   string    `json:"since,omitempty"`
+	Until   string    `json:"until,omitempty"`
+	Groups  []costRow `json:"groups"`
+	Total   costRow   `json:"total"`
+}
+
+func runCost(cmd *cobra.Command, args []string) error {
+	if jsonOut && costCSV {
+		return fmt.Errorf("--json and --csv cannot be used together")
+	}
+	keyFn, err := costGroupKey(costBy)
+	if err != nil {
+		return err
+	}
+	filter := db.UsageFilter{Project: costProject}
+	if costSince != "" {
+		if filter.Since, err = time.Parse(time.DateOnly, costSince); err != nil {
+			return fmt.Errorf("invalid --since %q (expected YYYY-MM-DD)", costSince)
+		}
+	}
+	if costUntil != "" {
+		until, err := time.Parse(time.DateOnly, costUntil)
+		if err != nil {
+			return fmt.Errorf("invalid --until %q (expected YYYY-MM-DD)", costUntil)
+		}
+		filter.Until = until.AddDate(0, 0, 1)
+	}
+	if !filter.Since.IsZero() && !filter.Until.IsZero() && !filter.Since.Before(filter.Until) {
+		return fmt.Errorf("--since %s is after --until %s", costSince, costUntil)
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	store, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	usage, err := store.ListSessionUsage(cmd.Context(), filter)
+	if err != nil {
+		return err
+	}
+	report := buildCostReport(usage, cost.NewTable(cfg.Cost), costBy, keyFn)
+	report.Since = costSince
+	report.Until = costUntil
+
+	switch {
+	case jsonOut:
+		printJSON(report)
+		return nil
+	case costCSV:
+		return writeCostCSV(report)
+	default:
+		return writeCostTable(report)
+	}
+}
+
+func costGroupKey(by string) (func(db.SessionUsage) string, error) {
+	switch by {
+	case "project":
+		return func(u db.SessionUsage) string { return u.Project }, nil
+	case "day":
+		return func(u db.SessionUsage) string { return u.Day }, nil
+	case "week":
+		return func(u db.SessionUsage) string { return isoWeek(u.Day) }, nil
+	case "step":
+		return func(u db.SessionUsage) string { return u.Step }, nil
+	case "provider":
+		return func(u db.SessionUsage) string { return u.Provider }, nil
+	case "outcome":
+		return func(u db.SessionUsage) string { return jobOutcome(u.JobState, u.PRMergedAt, u.PRClosedAt) }, nil
+	default:
+		return nil, fmt.Errorf("invalid --by %q (expected one of: project, day, week, step, provider, outcome)", by)
+	}
+}
+
+// isoWeek returns the ISO week of a YYYY-MM-DD day, e.g. "2026-W42".
+func isoWeek(day string) string {
+	t, err := time.Parse(time.DateOnly, day)
+	if err != nil {
+		return day
+	}
+	year, week := t.ISOWeek()
+	return fmt.Sprintf("%d-W%02d", year, week)
+}
+
+// jobOutcome buckets a job as merged, rejected (rejected in AutoPR or PR
+// closed unmerged), failed (failed, cancelled or needs_human) or open.
+func jobOutcome(state, prMergedAt, prClosedAt string) string {
+	switch {
+	case prMergedAt != "":
+		return "merged"
+	case prClosedAt != "" || state == "rejected":
+		return "rejected"
+	case state == "failed" || state == "cancelled" || state == "needs_human":
+		return "failed"
+	default:
+		return "open"
+	}
+}
+
+// modelCost prices a job's sessions on one provider/model.
+func modelCost(costs *cost.Table, m db.ModelUsage) float64 {
+	return costs.Cost(m.Provider, m.Model, cost.Usage{
+		Input:      m.InputTokens,
+		Output:     m.OutputTokens,
+		CacheRead:  m.CacheReadTokens,
+		CacheWrite: m.CacheCreationTokens,
+	})
+}
+
+// jobCost prices a job's sessions, each provider/model at its own rate.
+func jobCost(costs *cost.Table, ts db.TokenSummary) float64 {
+	total := 0.0
+	for _, m := range ts.Models {
+		total += modelCost(costs, m)
+	}
+	return total
+}
+
+func buildCostReport(usage []db.SessionUsage, costs *cost.Table, by string, keyFn func(db.SessionUsage) string) costReport {
+	type group struct {
+		row    costRow
+		jobs   map[string]struct{}
+		merged map[string]struct{}
+	}
+	groups := make(map[string]*group)
+	total := &group{row: costRow{Key: "total"}, jobs: map[string]struct{}{}, merged: map[string]struct{}{}}
+	for _, u := range usage {
+		key := keyFn(u)
+		g, ok := groups[key]
+		if !ok {
+			g = &group{row: costRow{Key: key}, jobs: map[string]struct{}{}, merged: map[string]struct{}{}}
+			groups[key] = g
+		}
+		usd := modelCost(costs, u.ModelUsage)
+		for _, acc := range []*group{g, total} {
+			acc.row.Sessions += u.Sessions
+			acc.row.InputTokens += u.InputTokens
+			acc.row.OutputTokens += u.OutputTokens
+			acc.row.CacheReadTokens += u.CacheReadTokens
+			acc.row.CacheCreationTokens += u.CacheCreationTokens
+			acc.row.CostUSD += usd
+			acc.jobs[u.JobID] = struct{}{}
+			if u.PRMergedAt != "" {
+				acc.merged[u.JobID] = struct{}{}
+			}
+		}
+	}
+
+	finish := func(g *group) costRow {
+		g.row.Jobs = len(g.jobs)
+		g.row.MergedPRs = len(g.merged)
+		if g.row.MergedPRs > 0 {
+			g.row.CostPerMergedPR = g.row.CostUSD / float64(g.row.MergedPRs)
+		}
+		return g.row
+	}
+	report := costReport{GroupBy: by, Groups: make([]costRow, 0, len(groups)), Total: finish(total)}
+	for _, g := range groups {
+		report.Groups = append(report.Groups, finish(g))
+	}
+	// Time groups read chronologically; the rest by cost, highest first.
+	sort.Slice(report.Groups, func(i, j int) bool {
+		a, b := report.Groups[i], report.Groups[j]
+		if by != "day" && by != "week" && a.CostUSD != b.CostUSD {
+			return a.CostUSD > b.CostUSD
+		}
+		return a.Key < b.Key
+	})
+	return report
+}
+
+func writeCostTable(report costReport) error {
+	if len(report.Groups) == 0 {
+		return writef("No LLM sessions found.\n")
+	}
+	const format = "%-20s %8s %6s %12s %12s %12s %10s %7s %12s\n"
+	if err := writef(format, strings.ToUpper(report.GroupBy), "SESSIONS", "JOBS", "INPUT", "OUTPUT", "CACHE READ", "COST", "MERGED", "COST/MERGED"); err != nil {
+		return err
+	}
+	if err := writef("%s\n", strings.Repeat("-", 107)); err != nil {
+		return err
+	}
+	row := func(r costRow) error {
+		perMerged := "-"
+		if r.MergedPRs > 0 {
+			perMerged = cost.FormatUSD(r.CostPerMergedPR)
+		}
+		return writef(format, truncate(r.Key, 20), strconv.Itoa(r.Sessions), strconv.Itoa(r.Jobs),
+			strconv.Itoa(r.InputTokens), strconv.Itoa(r.OutputTokens), strconv.Itoa(r.CacheReadTokens),
+			cost.FormatUSD(r.CostUSD), strconv.Itoa(r.MergedPRs), perMerged)
+	}
+	for _, r := range report.Groups {
+		if err := row(r); err != nil {
+			return err
+		}
+	}
+	if err := writef("%s\n", strings.Repeat("-", 107)); err != nil {
+		return err
+	}
+	total := report.Total
+	total.Key = "TOTAL"
+	return row(total)
+}
+
+func writeCostCSV(report costReport) error {
+	w := csv.NewWriter(os.Stdout)
+	_ = w.Write([]string{report.GroupBy, "sessions", "jobs", "input_tokens", "output_tokens",
+		"cache_read_tokens", "cache_creation_tokens", "cost_usd", "merged_prs", "cost_per_merged_pr"})
+	for _, r := range append(report.Groups, report.Total) {
+		perMerged := ""
+		if r.MergedPRs > 0 {
+			perMerged = strconv.FormatFloat(r.CostPerMergedPR, 'f', 4, 64)
+		}
+		_ = w.Write([]string{r.Key, strconv.Itoa(r.Sessions), strconv.Itoa(r.Jobs),
+			strconv.Itoa(r.InputTokens), strconv.Itoa(r.OutputTokens),
+			strconv.Itoa(r.CacheReadTokens), strconv.Itoa(r.CacheCreationTokens),
+			strconv.FormatFloat(r.CostUSD, 'f', 4, 64), strconv.Itoa(r.MergedPRs), perMerged})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return fmt.Errorf("write output: %w", err)
+	}
+	return nil
+}
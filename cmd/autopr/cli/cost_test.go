@@ -0,0 +1,154 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"autopr/internal/db"
+
+	"github.com/spf13/cobra"
+)
+
+// seedCostJobs creates one job per outcome, each with a single codex session
+// of 1M input tokens ($3.00 at the default rate). The rejected job's session
+// was cancelled, which still counts.
+func seedCostJobs(t *testing.T, dbPath string) {
+	t.Helper()
+	store, err := db.Open(dbPath)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	seeds := []struct {
+		state, merged, step, day, status string
+	}{
+		{state: "approved", merged: "2026-10-05T00:00:00Z", step: "implement", day: "2026-10-05"},
+		{state: "approved", merged: "2026-10-06T00:00:00Z", step: "implement", day: "2026-10-06"},
+		{state: "failed", step: "plan", day: "2026-10-06"},
+		{state: "rejected", step: "code_review", day: "2026-09-30", status: "cancelled"},
+	}
+	for i, seed := range seeds {
+		issueID, err := store.UpsertIssue(ctx, db.IssueUpsert{
+			ProjectName:   "project",
+			Source:        "github",
+			SourceIssueID: fmt.Sprintf("cost-%d", i),
+			Title:         fmt.Sprintf("cost issue %d", i),
+			URL:           fmt.Sprintf("https://example.com/%d", i),
+			State:         "open",
+		})
+		if err != nil {
+			t.Fatalf("upsert issue: %v", err)
+		}
+		jobID, err := store.CreateJob(ctx, issueID, "project", 3)
+		if err != nil {
+			t.Fatalf("create job: %v", err)
+		}
+		if _, err := store.Writer.ExecContext(ctx, `UPDATE jobs SET state = ?, pr_merged_at = NULLIF(?, '') WHERE id = ?`, seed.state, seed.merged, jobID); err != nil {
+			t.Fatalf("update job: %v", err)
+		}
+		sessionID, err := store.CreateSession(ctx, jobID, seed.step, 0, "codex", "")
+		if err != nil {
+			t.Fatalf("create session: %v", err)
+		}
+		status := seed.status
+		if status == "" {
+			status = "completed"
+		}
+		if err := store.CompleteSession(ctx, sessionID, status, "ok", "", "", "", "", "", 1_000_000, 0, 1); err != nil {
+			t.Fatalf("complete session: %v", err)
+		}
+		if _, err := store.Writer.ExecContext(ctx, `UPDATE llm_sessions SET created_at = ? WHERE id = ?`, seed.day+"T12:00:00Z", sessionID); err != nil {
+			t.Fatalf("date session: %v", err)
+		}
+	}
+}
+
+func runCostWithTestConfig(t *testing.T, configPath string, asJSON, asCSV bool, by, since, until string) string {
+	t.Helper()
+	prevCfgPath, prevJSON := cfgPath, jsonOut
+	prevProject, prevSince, prevUntil, prevBy, prevCSV := costProject, costSince, costUntil, costBy, costCSV
+	t.Cleanup(func() {
+		cfgPath, jsonOut = prevCfgPath, prevJSON
+		costProject, costSince, costUntil, costBy, costCSV = prevProject, prevSince, prevUntil, prevBy, prevCSV
+	})
+
+	cfgPath = configPath
+	jsonOut = asJSON
+	costProject = ""
+	costSince = since
+	costUntil = until
+	costBy = by
+	costCSV = asCSV
+	cmd := &cobra.Command{}
+	cmd.SetContext(context.Background())
+
+	return captureStdout(t, func() error {
+		return runCost(cmd, nil)
+	})
+}
+
+func TestRunCostJSONReportsCostPerMergedPR(t *testing.T) {
+	tmp := t.TempDir()
+	cfg := writeStatusConfig(t, tmp)
+	seedCostJobs(t, filepath.Join(tmp, "autopr.db"))
+
+	out := runCostWithTestConfig(t, cfg, true, false, "outcome", "2026-10-01", "2026-10-31")
+	var report costReport
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		t.Fatalf("decode JSON: %v\n%s", err, out)
+	}
+
+	// The September session of the rejected job is outside the range.
+	if report.Total.Sessions != 3 || report.Total.Jobs != 3 || report.Total.MergedPRs != 2 {
+		t.Fatalf("unexpected total: %+v", report.Total)
+	}
+	if report.Total.CostUSD != 9 || report.Total.CostPerMergedPR != 4.5 {
+		t.Fatalf("expected $9.00 total and $4.50 per merged PR, got %+v", report.Total)
+	}
+	if len(report.Groups) != 2 || report.Groups[0].Key != "merged" || report.Groups[0].CostUSD != 6 || report.Groups[1].Key != "failed" {
+		t.Fatalf("unexpected groups: %+v", report.Groups)
+	}
+}
+
+func TestRunCostCSVGroupsByWeek(t *testing.T) {
+	tmp := t.TempDir()
+	cfg := writeStatusConfig(t, tmp)
+	seedCostJobs(t, filepath.Join(tmp, "autopr.db"))
+
+	out := runCostWithTestConfig(t, cfg, false, true, "week", "", "")
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	want := []string{
+		"week,sessions,jobs,input_tokens,output_tokens,cache_read_tokens,cache_creation_tokens,cost_usd,merged_prs,cost_per_merged_pr",
+		"2026-W40,1,1,1000000,0,0,0,3.0000,0,",
+		"2026-W41,3,3,3000000,0,0,0,9.0000,2,4.5000",
+		"total,4,4,4000000,0,0,0,12.0000,2,6.0000",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected CSV:\n%s", out)
+	}
+}
+
+func TestRunCostTableShowsTotal(t *testing.T) {
+	tmp := t.TempDir()
+	cfg := writeStatusConfig(t, tmp)
+	seedCostJobs(t, filepath.Join(tmp, "autopr.db"))
+
+	out := runCostWithTestConfig(t, cfg, false, false, "step", "", "")
+	for _, want := range []string{"STEP", "implement", "code_review", "TOTAL", "$12.00", "$6.00"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in output:\n%s", want, out)
+		}
+	}
+}
+
+func TestRunCostRejectsUnknownGrouping(t *testing.T) {
+	if _, err := costGroupKey("repo"); err == nil || !strings.Contains(err.Error(), "invalid --by") {
+		t.Fatalf("expected invalid --by error, got %v", err)
+	}
+}
@@ -231,13 +231,16 @@ ci_check_timeout = "30m"        # max wait for CI checks before rejecting
 # base_url = "https://sentry.io"  # uncomment for self-hosted Sentry
 
 [llm]
-provider = "codex"              # codex|claude
+provider = "codex"              # codex|claude|openai|anthropic
+# base_url = "http://localhost:11434/v1"  # openai/anthropic only
+# model = "qwen2.5-coder:32b"             # openai/anthropic only
+# allowed_commands = ["go", "make"]       # commands the HTTP agent may run
 
 [notifications]
 # webhook_url = "https://example.com/hook"                     # generic JSON webhook
 # slack_webhook = "https://hooks.slack.com/services/..."       # Slack incoming webhook
 # desktop = true                                                # macOS desktop notifications
-# triggers = ["needs_pr", "failed", "pr_created", "pr_merged"]
+# triggers = ["needs_pr", "failed", "pr_created", "pr_merged", "budget_threshold", "plan_approval"]
 # Set triggers = [] to disable all notifications.
 
 # Issue gating: by default, only issues labeled "autopr" (GitHub/GitLab) are
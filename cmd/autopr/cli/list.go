@@ -75,7 +75,11 @@ func runList(cmd *cobra.Command, args []string) error {
 	page := listPage
 	pageSize := listPageSize
 	snapshot := func(ctx context.Context) (listSnapshot, error) {
-		return collectListSnapshot(ctx, store, listProject, state, sortBy, ascending, paginate, page, pageSize, listCost)
+		var costs *cost.Table
+		if listCost {
+			costs = cost.NewTable(cfg.Cost)
+		}
+		return collectListSnapshot(ctx, store, listProject, state, sortBy, ascending, paginate, page, pageSize, costs)
 	}
 
 	render := func(ctx context.Context, snapshot listSnapshot, iteration int64) error {
@@ -108,10 +112,10 @@ type listSnapshot struct {
 	Page     int
 	PageSize int
 	Paginate bool
-	Cost     map[string]db.TokenSummary
+	Cost     map[string]float64 // estimated USD per job ID, when requested
 }
 
-func collectListSnapshot(ctx context.Context, store *db.Store, project, state, sortBy string, ascending bool, paginate bool, page int, pageSize int, withCost bool) (listSnapshot, error) {
+func collectListSnapshot(ctx context.Context, store *db.Store, project, state, sortBy string, ascending bool, paginate bool, page int, pageSize int, costs *cost.Table) (listSnapshot, error) {
 	if paginate {
 		if page < 1 {
 			return listSnapshot{}, fmt.Errorf("invalid page value %d; expected >= 1", page)
@@ -138,13 +142,19 @@ func collectListSnapshot(ctx context.Context, store *db.Store, project, state, s
 	}
 
 	// Optionally fetch cost data.
-	var costMap map[string]db.TokenSummary
-	if withCost && len(jobs) > 0 {
+	var costMap map[string]float64
+	if costs != nil && len(jobs) > 0 {
 		ids := make([]string, len(jobs))
 		for i, j := range jobs {
 			ids[i] = j.ID
 		}
-		costMap, _ = store.AggregateTokensForJobs(ctx, ids)
+		summaries, _ := store.AggregateTokensForJobs(ctx, ids)
+		costMap = make(map[string]float64, len(summaries))
+		for id, ts := range summaries {
+			if ts.SessionCount > 0 {
+				costMap[id] = jobCost(costs, ts)
+			}
+		}
 	}
 
 	return listSnapshot{
@@ -232,13 +242,12 @@ func renderListSnapshot(_ context.Context, asJSON bool, compactJSON bool, iterat
 
 		if showCost {
 			costStr := "-"
-			if ts, ok := snapshot.Cost[j.ID]; ok && ts.SessionCount > 0 {
-				c := cost.Calculate(ts.Provider, ts.TotalInputTokens, ts.TotalOutputTokens)
+			if c, ok := snapshot.Cost[j.ID]; ok {
 				costStr = cost.FormatUSD(c)
 			}
 			title := truncate(j.IssueTitle, 45)
 			if err := writef("%-10s %-20s %-13s %-13s %-5s %-8s %-45s %s\n",
-				db.ShortID(j.ID), db.DisplayState(j.State, j.PRMergedAt, j.PRClosedAt), truncate(j.ProjectName, 12), source,
+				db.ShortID(j.ID), db.DisplayJobState(j), truncate(j.ProjectName, 12), source,
 				fmt.Sprintf("%d/%d", j.Iteration, j.MaxIterations),
 				costStr, title, j.UpdatedAt); err != nil {
 				return err
@@ -246,7 +255,7 @@ func renderListSnapshot(_ context.Context, asJSON bool, compactJSON bool, iterat
 		} else {
 			title := truncate(j.IssueTitle, 55)
 			if err := writef("%-10s %-20s %-13s %-13s %-5s %-55s %s\n",
-				db.ShortID(j.ID), db.DisplayState(j.State, j.PRMergedAt, j.PRClosedAt), truncate(j.ProjectName, 12), source,
+				db.ShortID(j.ID), db.DisplayJobState(j), truncate(j.ProjectName, 12), source,
 				fmt.Sprintf("%d/%d", j.Iteration, j.MaxIterations),
 				title, j.UpdatedAt); err != nil {
 				return err
@@ -260,7 +269,7 @@ func renderListSnapshot(_ context.Context, asJSON bool, compactJSON bool, iterat
 			active++
 		}
 		switch j.State {
-		case "failed", "rejected", "cancelled":
+		case "failed", "rejected", "cancelled", "needs_human":
 			failed++
 		}
 		if j.State == "approved" && j.PRMergedAt != "" {
@@ -285,16 +294,16 @@ func normalizeListState(state string) (string, error) {
 	}
 
 	switch state {
-	case "all", "active", "merged", "queued", "planning", "implementing", "reviewing", "testing", "ready", "rebasing", "resolving_conflicts", "awaiting_checks", "approved", "rejected", "failed", "cancelled":
+	case "all", "active", "merged", "queued", "planning", "needs_info", "reviewing_plan", "awaiting_plan_approval", "implementing", "running_command", "reviewing", "testing", "ready", "blocked", "rebasing", "resolving_conflicts", "awaiting_checks", "approved", "rejected", "failed", "cancelled", "needs_human":
 		return state, nil
 	default:
-		return "", fmt.Errorf("invalid --state %q (expected one of: all, active, merged, queued, planning, implementing, reviewing, testing, ready, rebasing, resolving, resolving_conflicts, awaiting_checks, approved, rejected, failed, cancelled)", state)
+		return "", fmt.Errorf("invalid --state %q (expected one of: all, active, merged, queued, planning, needs_info, reviewing_plan, awaiting_plan_approval, implementing, running_command, reviewing, testing, ready, blocked, rebasing, resolving, resolving_conflicts, awaiting_checks, approved, rejected, failed, cancelled, needs_human)", state)
 	}
 }
 
 func isActiveState(state string) bool {
 	switch state {
-	case "planning", "implementing", "reviewing", "testing", "rebasing", "resolving_conflicts", "awaiting_checks":
+	case "planning", "needs_info", "reviewing_plan", "awaiting_plan_approval", "implementing", "running_command", "reviewing", "testing", "rebasing", "resolving_conflicts", "awaiting_checks":
 		return true
 	default:
 		return false
@@ -13,6 +13,7 @@ import (
 
 	"autopr/internal/cost"
 	"autopr/internal/db"
+	"autopr/internal/testreport"
 
 	"github.com/spf13/cobra"
 )
@@ -21,6 +22,7 @@ var logsFollow bool
 var logsSession string
 var logsShowInput bool
 var logsShowOutput bool
+var logsEvents bool
 
 type logsOutputMode string
 
@@ -41,6 +43,7 @@ func init() {
 	logsCmd.Flags().StringVar(&logsSession, "session", "", "select session by 1-based index or numeric session ID")
 	logsCmd.Flags().BoolVar(&logsShowInput, "show-input", false, "show prompt text for selected session")
 	logsCmd.Flags().BoolVar(&logsShowOutput, "show-output", false, "show response text for selected session")
+	logsCmd.Flags().BoolVar(&logsEvents, "events", false, "show commands, file edits and tool calls made by the agent")
 	rootCmd.AddCommand(logsCmd)
 }
 
@@ -78,6 +81,11 @@ func runLogs(cmd *cobra.Command, args []string) error {
 	issue, issueErr := store.GetIssueByAPID(cmd.Context(), job.AutoPRIssueID)
 
 	tokenSummary, _ := store.AggregateTokensByJob(cmd.Context(), jobID)
+	costs := cost.NewTable(cfg.Cost)
+
+	if logsEvents {
+		return runLogsEvents(cmd.Context(), store, jobID, sessions)
+	}
 
 	if logsSession != "" {
 		targetSession, err := resolveLogsSession(sessions, logsSession, jobID)
@@ -104,21 +112,36 @@ func runLogs(cmd *cobra.Command, args []string) error {
 			payload["issue"] = issue
 		}
 		if tokenSummary.SessionCount > 0 {
+			models := make([]map[string]any, 0, len(tokenSummary.Models))
+			for _, m := range tokenSummary.Models {
+				models = append(models, map[string]any{
+					"provider":              m.Provider,
+					"model":                 m.Model,
+					"sessions":              m.Sessions,
+					"input_tokens":          m.InputTokens,
+					"output_tokens":         m.OutputTokens,
+					"cache_read_tokens":     m.CacheReadTokens,
+					"cache_creation_tokens": m.CacheCreationTokens,
+					"estimated_cost":        modelCost(costs, m),
+				})
+			}
 			payload["cost_summary"] = map[string]any{
-				"sessions":      tokenSummary.SessionCount,
-				"input_tokens":  tokenSummary.TotalInputTokens,
-				"output_tokens": tokenSummary.TotalOutputTokens,
-				"duration_ms":   tokenSummary.TotalDurationMS,
-				"estimated_cost": cost.Calculate(tokenSummary.Provider,
-					tokenSummary.TotalInputTokens, tokenSummary.TotalOutputTokens),
-				"provider": tokenSummary.Provider,
+				"sessions":              tokenSummary.SessionCount,
+				"input_tokens":          tokenSummary.TotalInputTokens,
+				"output_tokens":         tokenSummary.TotalOutputTokens,
+				"cache_read_tokens":     tokenSummary.TotalCacheReadTokens,
+				"cache_creation_tokens": tokenSummary.TotalCacheCreationTokens,
+				"duration_ms":           tokenSummary.TotalDurationMS,
+				"estimated_cost":        jobCost(costs, tokenSummary),
+				"provider":              tokenSummary.Provider,
+				"models":                models,
 			}
 		}
 		printJSON(payload)
 		return nil
 	}
 
-	fmt.Printf("Job: %s  State: %s  Retry: %d/%d\n", job.ID, db.DisplayState(job.State, job.PRMergedAt, job.PRClosedAt), job.Iteration, job.MaxIterations)
+	fmt.Printf("Job: %s  State: %s  Retry: %d/%d\n", job.ID, db.DisplayJobState(job), job.Iteration, job.MaxIterations)
 	if issueErr == nil && issue.Source != "" && issue.SourceIssueID != "" {
 		fmt.Printf("Issue: %s #%s  Project: %s\n",
 			strings.ToUpper(issue.Source[:1])+issue.Source[1:], issue.SourceIssueID, job.ProjectName)
@@ -157,8 +180,15 @@ func runLogs(cmd *cobra.Command, args []string) error {
 	if len(sessions) > 0 {
 		fmt.Println("=== LLM Sessions ===")
 		for _, s := range sessions {
-			fmt.Printf("\n--- %s (iter %d) [%s] %s ---\n", s.Step, s.Iteration, s.LLMProvider, s.Status)
-			fmt.Printf("Tokens: %d in / %d out  Duration: %dms\n", s.InputTokens, s.OutputTokens, s.DurationMS)
+			provider := s.LLMProvider
+			if s.Model != "" {
+				provider += "/" + s.Model
+			}
+			fmt.Printf("\n--- %s (iter %d) [%s] %s ---\n", s.Step, s.Iteration, provider, s.Status)
+			fmt.Printf("Tokens: %s  Cost: %s  Duration: %dms\n", formatSessionTokens(s.InputTokens, s.OutputTokens, s.CacheReadTokens, s.CacheCreationTokens),
+				cost.FormatUSD(costs.Cost(s.LLMProvider, s.Model, cost.Usage{
+					Input: s.InputTokens, Output: s.OutputTokens, CacheRead: s.CacheReadTokens, CacheWrite: s.CacheCreationTokens,
+				})), s.DurationMS)
 			if s.JSONLPath != "" {
 				fmt.Printf("JSONL: %s\n", s.JSONLPath)
 			}
@@ -166,7 +196,7 @@ func runLogs(cmd *cobra.Command, args []string) error {
 				fmt.Printf("Commit: %s\n", s.CommitSHA)
 			}
 			if s.ErrorMessage != "" {
-				fmt.Printf("Error: %s\n", s.ErrorMessage)
+				printSessionError(s.ErrorMessage)
 			}
 		}
 	}
@@ -175,6 +205,9 @@ func runLogs(cmd *cobra.Command, args []string) error {
 		fmt.Println("\n=== Artifacts ===")
 		for _, a := range artifacts {
 			fmt.Printf("\n--- %s (iter %d) ---\n", a.Kind, a.Iteration)
+			if counts := testCounts(a); counts != "" {
+				fmt.Printf("Tests: %s\n", counts)
+			}
 			content := a.Content
 			if len(content) > 500 {
 				content = content[:500] + "\n... (truncated)"
@@ -185,14 +218,21 @@ func runLogs(cmd *cobra.Command, args []string) error {
 
 	// Cost summary.
 	if tokenSummary.SessionCount > 0 {
-		estCost := cost.Calculate(tokenSummary.Provider, tokenSummary.TotalInputTokens, tokenSummary.TotalOutputTokens)
 		durationSec := float64(tokenSummary.TotalDurationMS) / 1000.0
 		fmt.Println()
 		fmt.Println("=== Cost Summary ===")
-		fmt.Printf("Sessions: %d  Input: %d tokens  Output: %d tokens\n",
-			tokenSummary.SessionCount, tokenSummary.TotalInputTokens, tokenSummary.TotalOutputTokens)
-		fmt.Printf("Estimated cost: %s (%s @ %s)\n",
-			cost.FormatUSD(estCost), tokenSummary.Provider, cost.FormatRate(tokenSummary.Provider))
+		fmt.Printf("Sessions: %d  Tokens: %s\n", tokenSummary.SessionCount,
+			formatSessionTokens(tokenSummary.TotalInputTokens, tokenSummary.TotalOutputTokens,
+				tokenSummary.TotalCacheReadTokens, tokenSummary.TotalCacheCreationTokens))
+		fmt.Printf("Estimated cost: %s\n", cost.FormatUSD(jobCost(costs, tokenSummary)))
+		for _, m := range tokenSummary.Models {
+			name := m.Provider
+			if m.Model != "" {
+				name += "/" + m.Model
+			}
+			fmt.Printf("  %s: %s (%d sessions @ %s)\n", name,
+				cost.FormatUSD(modelCost(costs, m)), m.Sessions, costs.FormatRate(m.Provider, m.Model))
+		}
 		fmt.Printf("Total duration: %.1fs\n", durationSec)
 	}
 
@@ -204,6 +244,16 @@ func runLogs(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// formatSessionTokens renders token counts, e.g. "1200 in / 300 out" with
+// " / 5000 cache read / 800 cache write" appended when caching was used.
+func formatSessionTokens(in, out, cacheRead, cacheWrite int) string {
+	s := fmt.Sprintf("%d in / %d out", in, out)
+	if cacheRead != 0 || cacheWrite != 0 {
+		s += fmt.Sprintf(" / %d cache read / %d cache write", cacheRead, cacheWrite)
+	}
+	return s
+}
+
 func resolveLogsOutputMode(showInput, showOutput bool) logsOutputMode {
 	if showOutput {
 		return logsOutputModeOutput
@@ -259,6 +309,12 @@ func printSessionText(session db.LLMSession, mode logsOutputMode) {
 	fmt.Printf("Step: %s (iter %d)\n", db.DisplayStep(session.Step), session.Iteration)
 	fmt.Printf("Status: %s\n", session.Status)
 	fmt.Printf("Provider: %s\n", session.LLMProvider)
+	if session.Model != "" {
+		fmt.Printf("Model: %s\n", session.Model)
+	}
+	if session.ErrorMessage != "" {
+		printSessionError(session.ErrorMessage)
+	}
 	fmt.Println()
 
 	switch mode {
@@ -271,10 +327,107 @@ func printSessionText(session db.LLMSession, mode logsOutputMode) {
 	}
 }
 
+// runLogsEvents prints the recorded agent actions per session, optionally
+// limited to the session selected with --session.
+func runLogsEvents(ctx context.Context, store *db.Store, jobID string, sessions []db.LLMSession) error {
+	events, err := store.ListSessionEventsByJob(ctx, jobID)
+	if err != nil {
+		return err
+	}
+	if logsSession != "" {
+		target, err := resolveLogsSession(sessions, logsSession, jobID)
+		if err != nil {
+			return err
+		}
+		sessions = []db.LLMSession{target}
+	}
+
+	bySession := make(map[int64][]db.SessionEvent)
+	for _, ev := range events {
+		bySession[ev.SessionID] = append(bySession[ev.SessionID], ev)
+	}
+
+	if jsonOut {
+		payload := make([]map[string]any, 0, len(sessions))
+		for _, s := range sessions {
+			sessionEvents := bySession[int64(s.ID)]
+			if sessionEvents == nil {
+				sessionEvents = []db.SessionEvent{}
+			}
+			payload = append(payload, map[string]any{
+				"session_id": s.ID,
+				"step":       s.Step,
+				"iteration":  s.Iteration,
+				"events":     sessionEvents,
+			})
+		}
+		printJSON(payload)
+		return nil
+	}
+
+	if len(sessions) == 0 {
+		fmt.Printf("No sessions found for job %s\n", jobID)
+		return nil
+	}
+	for i, s := range sessions {
+		if i > 0 {
+			fmt.Println()
+		}
+		provider := s.LLMProvider
+		if s.Model != "" {
+			provider += "/" + s.Model
+		}
+		fmt.Printf("--- %s (iter %d) [%s] %s ---\n", s.Step, s.Iteration, provider, s.Status)
+		sessionEvents := bySession[int64(s.ID)]
+		if len(sessionEvents) == 0 {
+			fmt.Println("(no recorded events)")
+			continue
+		}
+		for _, ev := range sessionEvents {
+			fmt.Println(formatSessionEvent(ev))
+		}
+	}
+	return nil
+}
+
+// formatSessionEvent renders one event as "time  type  target  [exit N]".
+func formatSessionEvent(ev db.SessionEvent) string {
+	ts := ev.CreatedAt
+	if t, err := time.Parse(time.RFC3339, ev.CreatedAt); err == nil {
+		ts = t.Local().Format("15:04:05")
+	}
+	target := ev.Command
+	if target == "" {
+		target = ev.Path
+	}
+	if ev.EventType == "tool" && ev.Tool != "" {
+		target = strings.TrimSpace(ev.Tool + " " + target)
+	}
+	target = strings.ReplaceAll(target, "\n", " ")
+	if len(target) > 120 {
+		target = target[:117] + "..."
+	}
+	line := fmt.Sprintf("%s  %-9s  %s", ts, ev.EventType, target)
+	if ev.ExitCode != nil && (ev.EventType == "command" || *ev.ExitCode != 0) {
+		line += fmt.Sprintf("  [exit %d]", *ev.ExitCode)
+	}
+	return line
+}
+
+// printSessionError prints a session error; continuation lines (such as the
+// captured stderr tail) are indented under it.
+func printSessionError(msg string) {
+	lines := strings.Split(strings.TrimSpace(msg), "\n")
+	fmt.Printf("Error: %s\n", lines[0])
+	for _, line := range lines[1:] {
+		fmt.Printf("  %s\n", line)
+	}
+}
+
 // isTerminalState returns true if the job state is terminal.
 func isTerminalState(state string) bool {
 	switch state {
-	case "ready", "approved", "rejected", "failed", "cancelled":
+	case "ready", "blocked", "needs_info", "awaiting_plan_approval", "approved", "rejected", "failed", "cancelled", "needs_human":
 		return true
 	default:
 		return false
@@ -314,7 +467,7 @@ func followJob(ctx context.Context, store *db.Store, jobID string, job db.Job) e
 			return err
 		}
 		if isTerminalState(job.State) {
-			fmt.Printf("\nJob reached state: %s\n", db.DisplayState(job.State, job.PRMergedAt, job.PRClosedAt))
+			fmt.Printf("\nJob reached state: %s\n", db.DisplayJobState(job))
 			return nil
 		}
 
@@ -459,3 +612,13 @@ func printJSONLLine(step, line string) {
 		fmt.Printf("[%s] tokens: %d in / %d out\n", step, msg.Usage.InputTokens, msg.Usage.OutputTokens)
 	}
 }
+
+// testCounts returns the pass/fail counts of a parsed test_output artifact
+// (combined or of one test command), or "" when the output was not parsed.
+func testCounts(a db.Artifact) string {
+	var report testreport.Report
+	if a.Kind != "test_output" && !strings.HasPrefix(a.Kind, "test_output:") || a.Data == "" || json.Unmarshal([]byte(a.Data), &report) != nil {
+		return ""
+	}
+	return fmt.Sprintf("%s (%s)", report.Counts(), report.Format)
+}
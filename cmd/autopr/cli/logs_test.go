@@ -90,6 +90,7 @@ func TestResolveLogsOutputMode(t *testing.T) {
 		name       string
 		showInput  bool
 		showOutput bool
+		events     bool
 		want       logsOutputMode
 	}{
 		{name: "default output", showInput: false, showOutput: false, want: logsOutputModeOutput},
@@ -219,6 +220,67 @@ func TestRunLogsWithoutSessionShowsAggregateOutput(t *testing.T) {
 	}
 }
 
+func TestRunLogsShowsSessionStderrTail(t *testing.T) {
+	tmp := t.TempDir()
+	cfg := writeLogsConfig(t, tmp)
+	dbPath := filepath.Join(tmp, "autopr.db")
+
+	jobID, s1, _ := seedLogsJobForTest(t, dbPath)
+	store, err := db.Open(dbPath)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	errMsg := "llm session timed out after 30m0s: codex exited with error: signal: killed\nstderr (tail):\nretrying connection"
+	if err := store.CompleteSession(context.Background(), s1, "failed", "", "", "", "", "", errMsg, 0, 0, 0); err != nil {
+		t.Fatalf("complete session: %v", err)
+	}
+	_ = store.Close()
+
+	out := runLogsForTest(t, cfg, jobID, logsRunOptions{})
+	if !strings.Contains(out, "Error: llm session timed out after 30m0s") {
+		t.Fatalf("expected session timeout error: %q", out)
+	}
+	if !strings.Contains(out, "\n  stderr (tail):\n  retrying connection\n") {
+		t.Fatalf("expected indented stderr tail: %q", out)
+	}
+}
+
+func TestRunLogsEventsShowsAgentActions(t *testing.T) {
+	tmp := t.TempDir()
+	cfg := writeLogsConfig(t, tmp)
+	dbPath := filepath.Join(tmp, "autopr.db")
+
+	jobID, s1, s2 := seedLogsJobForTest(t, dbPath)
+	store, err := db.Open(dbPath)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	exit := 1
+	if err := store.InsertSessionEvents(context.Background(), s2, jobID, []db.SessionEvent{
+		{EventType: "file_edit", Tool: "file_change", Path: "internal/foo.go", CreatedAt: "2026-01-02T03:04:05Z"},
+		{EventType: "command", Tool: "command_execution", Command: "go test ./...", ExitCode: &exit, CreatedAt: "2026-01-02T03:04:06Z"},
+	}); err != nil {
+		t.Fatalf("insert events: %v", err)
+	}
+	_ = store.Close()
+
+	out := runLogsForTest(t, cfg, jobID, logsRunOptions{events: true})
+	if !strings.Contains(out, "--- plan (iter 1)") || !strings.Contains(out, "(no recorded events)") {
+		t.Fatalf("expected empty plan session: %q", out)
+	}
+	if !strings.Contains(out, "file_edit  internal/foo.go") {
+		t.Fatalf("expected file edit event: %q", out)
+	}
+	if !strings.Contains(out, "command    go test ./...  [exit 1]") {
+		t.Fatalf("expected command event with exit code: %q", out)
+	}
+
+	out = runLogsForTest(t, cfg, jobID, logsRunOptions{events: true, session: strconv.FormatInt(s1, 10)})
+	if strings.Contains(out, "go test") {
+		t.Fatalf("expected only selected session events: %q", out)
+	}
+}
+
 func writeLogsConfig(t *testing.T, dir string) string {
 	t.Helper()
 	cfgPath := filepath.Join(dir, "autopr.toml")
@@ -339,6 +401,7 @@ type logsRunOptions struct {
 	showInput  bool
 	showOutput bool
 	jsonOut    bool
+	events     bool
 }
 
 func runLogsForTestResult(t *testing.T, configPath string, jobID string, opts logsRunOptions) (string, error) {
@@ -349,6 +412,7 @@ func runLogsForTestResult(t *testing.T, configPath string, jobID string, opts lo
 	prevShowInput := logsShowInput
 	prevShowOutput := logsShowOutput
 	prevFollow := logsFollow
+	prevEvents := logsEvents
 
 	cfgPath = configPath
 	jsonOut = opts.jsonOut
@@ -356,6 +420,7 @@ func runLogsForTestResult(t *testing.T, configPath string, jobID string, opts lo
 	logsShowInput = opts.showInput
 	logsShowOutput = opts.showOutput
 	logsFollow = false
+	logsEvents = opts.events
 
 	t.Cleanup(func() {
 		cfgPath = prevCfgPath
@@ -364,6 +429,7 @@ func runLogsForTestResult(t *testing.T, configPath string, jobID string, opts lo
 		logsShowInput = prevShowInput
 		logsShowOutput = prevShowOutput
 		logsFollow = prevFollow
+		logsEvents = prevEvents
 	})
 
 	cmd := &cobra.Command{}
@@ -381,3 +447,13 @@ func runLogsForTest(t *testing.T, configPath string, jobID string, opts logsRunO
 	}
 	return out
 }
+
+func TestTestCounts(t *testing.T) {
+	parsed := db.Artifact{Kind: "test_output", Data: `{"format":"pytest","passed":8,"failed":2,"skipped":1,"failures":[]}`}
+	if got := testCounts(parsed); got != "8 passed, 2 failed, 1 skipped (pytest)" {
+		t.Fatalf("unexpected counts %q", got)
+	}
+	if got := testCounts(db.Artifact{Kind: "test_output", Content: "raw"}); got != "" {
+		t.Fatalf("expected no counts for unparsed output, got %q", got)
+	}
+}
@@ -41,11 +41,11 @@ func runReject(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
-	if job.State != "ready" {
-		return fmt.Errorf("job %s is in state %q, must be 'ready' to reject", jobID, job.State)
+	if job.State != "ready" && job.State != "blocked" {
+		return fmt.Errorf("job %s is in state %q, must be 'ready' or 'blocked' to reject", jobID, job.State)
 	}
 
-	if err := store.RejectJob(cmd.Context(), jobID, "ready", rejectReason); err != nil {
+	if err := store.RejectJob(cmd.Context(), jobID, job.State, rejectReason); err != nil {
 		return err
 	}
 
@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"fmt"
+
+	"autopr/internal/pipeline"
+
+	"github.com/spf13/cobra"
+)
+
+var revisePlanNotes string
+
+var revisePlanCmd = &cobra.Command{
+	Use:   "revise-plan <job-id>",
+	Short: "Re-plan a job awaiting plan approval with notes",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runRevisePlan,
+}
+
+func init() {
+	revisePlanCmd.Flags().StringVarP(&revisePlanNotes, "notes", "n", "", "Notes or guidance for the new plan (required)")
+	rootCmd.AddCommand(revisePlanCmd)
+}
+
+func runRevisePlan(cmd *cobra.Command, args []string) error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	store, err := openStore(cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	jobID, err := resolveJob(store, args[0])
+	if err != nil {
+		return err
+	}
+
+	if err := pipeline.RevisePlan(cmd.Context(), store, jobID, revisePlanNotes); err != nil {
+		return err
+	}
+
+	if jsonOut {
+		printJSON(map[string]string{"job_id": jobID, "state": "queued", "notes": revisePlanNotes})
+		return nil
+	}
+	fmt.Printf("Job %s queued to re-plan with your notes.\n", jobID)
+	return nil
+}
@@ -17,11 +17,15 @@ import (
 type statusJobCounts struct {
 	Queued       int `json:"queued"`
 	Planning     int `json:"planning"`
+	PlanApproval int `json:"awaiting_plan_approval"`
+	NeedsInfo    int `json:"needs_info"`
 	Implementing int `json:"implementing"`
 	Reviewing    int `json:"reviewing"`
 	Testing      int `json:"testing"`
 	NeedsPR      int `json:"needs_pr"`
 	Failed       int `json:"failed"`
+	NeedsHuman   int `json:"needs_human"`
+	Blocked      int `json:"blocked"`
 	Cancelled    int `json:"cancelled"`
 	Rejected     int `json:"rejected"`
 	PRCreated    int `json:"pr_created"`
@@ -42,6 +46,9 @@ const (
 type statusSectionEntry struct {
 	label string
 	count int
+	// omitZero leaves the entry out of its line while the count is zero, so
+	// states added later do not change the established lines.
+	omitZero bool
 }
 
 func renderStatusSection(title string, values []statusSectionEntry) (string, bool) {
@@ -50,6 +57,8 @@ func renderStatusSection(title string, values []statusSectionEntry) (string, boo
 	for _, value := range values {
 		if value.count != 0 {
 			hasNonZero = true
+		} else if value.omitZero {
+			continue
 		}
 		parts = append(parts, fmt.Sprintf("%d %s", value.count, value.label))
 	}
@@ -176,18 +185,22 @@ func collectStatusSnapshot(ctx context.Context, store *db.Store, pidFile string)
 	if prCreated < 0 {
 		prCreated = 0
 	}
-	active := counts["planning"] + counts["implementing"] + counts["reviewing"] + counts["testing"] + counts["rebasing"] + counts["resolving_conflicts"]
+	active := counts["planning"] + counts["reviewing_plan"] + counts["implementing"] + counts["running_command"] + counts["reviewing"] + counts["testing"] + counts["rebasing"] + counts["resolving_conflicts"]
 	return statusSnapshot{
 		Running: running,
 		PID:     pidStr,
 		Counts: statusJobCounts{
 			Queued:       counts["queued"],
 			Planning:     counts["planning"],
+			PlanApproval: counts["awaiting_plan_approval"],
+			NeedsInfo:    counts["needs_info"],
 			Implementing: counts["implementing"],
 			Reviewing:    counts["reviewing"],
 			Testing:      counts["testing"],
 			NeedsPR:      counts["ready"],
 			Failed:       counts["failed"],
+			NeedsHuman:   counts["needs_human"],
+			Blocked:      counts["blocked"],
 			Cancelled:    counts["cancelled"],
 			Rejected:     counts["rejected"],
 			PRCreated:    prCreated,
@@ -246,6 +259,13 @@ func renderStatusSnapshot(asJSON bool, asShort bool, compactJSON bool, snapshot
 				{label: "testing", count: snapshot.Counts.Testing},
 			},
 		},
+		{
+			title: "Waiting",
+			values: []statusSectionEntry{
+				{label: "plan_approval", count: snapshot.Counts.PlanApproval},
+				{label: "needs_info", count: snapshot.Counts.NeedsInfo},
+			},
+		},
 		{
 			title: "Output",
 			values: []statusSectionEntry{
@@ -260,6 +280,8 @@ func renderStatusSnapshot(asJSON bool, asShort bool, compactJSON bool, snapshot
 				{label: "failed", count: snapshot.Counts.Failed},
 				{label: "rejected", count: snapshot.Counts.Rejected},
 				{label: "cancelled", count: snapshot.Counts.Cancelled},
+				{label: "needs_human", count: snapshot.Counts.NeedsHuman, omitZero: true},
+				{label: "blocked", count: snapshot.Counts.Blocked, omitZero: true},
 			},
 		},
 	}
@@ -228,6 +228,8 @@ func TestRunStatusTableOutputIncludesAllSections(t *testing.T) {
 		{state: "reviewing", count: 2},
 		{state: "testing", count: 3},
 		{state: "ready", count: 4},
+		{state: "awaiting_plan_approval", count: 1},
+		{state: "needs_info", count: 2},
 		{state: "failed", count: 1},
 		{state: "rejected", count: 2},
 		{state: "cancelled", count: 3},
@@ -241,6 +243,7 @@ func TestRunStatusTableOutputIncludesAllSections(t *testing.T) {
 		"",
 		"Pipeline:  2 queued · 7 active",
 		"Active:    1 planning · 1 implementing · 2 reviewing · 3 testing",
+		"Waiting:   1 plan_approval · 2 needs_info",
 		"Output:    4 needs_pr · 2 merged · 3 pr_created",
 		"Problems:  1 failed · 2 rejected · 3 cancelled",
 	}
@@ -254,6 +257,33 @@ func TestRunStatusTableOutputIncludesAllSections(t *testing.T) {
 	}
 }
 
+func TestRunStatusTableOutputListsNewProblemStatesOnlyWhenNonZero(t *testing.T) {
+	tmp := t.TempDir()
+	cfgPath := writeStatusConfig(t, tmp)
+	dbPath := filepath.Join(tmp, "autopr.db")
+
+	seedStatusJobs(t, dbPath, []statusSeed{
+		{state: "needs_human", count: 2},
+		{state: "blocked", count: 1},
+	})
+
+	out := runStatusWithTestConfig(t, cfgPath, false, false)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	expected := []string{
+		"Daemon: stopped",
+		"",
+		"Problems:  0 failed · 0 rejected · 0 cancelled · 2 needs_human · 1 blocked",
+	}
+	if len(lines) != len(expected) {
+		t.Fatalf("unexpected output lines (%d): %q", len(lines), out)
+	}
+	for i, line := range expected {
+		if lines[i] != line {
+			t.Fatalf("line %d mismatch: expected %q, got %q", i, line, lines[i])
+		}
+	}
+}
+
 func TestRunStatusJSONNoPidFile(t *testing.T) {
 	tmp := t.TempDir()
 	cfgPath := writeStatusConfig(t, tmp)
@@ -310,6 +340,8 @@ func TestRunStatusJSONEmptyDBIncludesZeroCounts(t *testing.T) {
 		"reviewing",
 		"testing",
 		"needs_pr",
+		"awaiting_plan_approval",
+		"needs_info",
 		"failed",
 		"cancelled",
 		"pr_created",
@@ -0,0 +1,206 @@
+// Package budget enforces the [budget] spend limits. Spend is the estimated
+// cost of recorded LLM sessions, priced with the cost package.
+package budget
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"autopr/internal/config"
+	"autopr/internal/cost"
+	"autopr/internal/db"
+)
+
+// ExceededError reports that a job spent more than the per-job limit.
+type ExceededError struct {
+	Spent float64
+	Limit float64
+}
+
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("budget exceeded: job spent %s of its %s limit", cost.FormatUSD(e.Spent), cost.FormatUSD(e.Limit))
+}
+
+// Checker prices recorded sessions against the configured limits. A nil
+// Checker enforces nothing.
+type Checker struct {
+	store    *db.Store
+	cfg      config.BudgetConfig
+	costs    *cost.Table
+	projects []string
+	now      func() time.Time
+}
+
+// New returns a Checker for cfg, or nil when no limit is set.
+func New(store *db.Store, cfg *config.Config) *Checker {
+	if cfg == nil || (cfg.Budget.Job == 0 && cfg.Budget.ProjectDaily == 0 && cfg.Budget.Monthly == 0) {
+		return nil
+	}
+	projects := make([]string, 0, len(cfg.Projects))
+	for _, p := range cfg.Projects {
+		projects = append(projects, p.Name)
+	}
+	return &Checker{
+		store:    store,
+		cfg:      cfg.Budget,
+		costs:    cost.NewTable(cfg.Cost),
+		projects: projects,
+		now:      time.Now,
+	}
+}
+
+// CheckJob prices the spend that counts against jobID's limits, enqueues a
+// budget_threshold notification for each limit that crossed its threshold for
+// the first time in its window, and returns an *ExceededError when the job is
+// over the per-job limit. Project and monthly limits do not stop a running
+// job; Exhausted keeps new jobs from being claimed instead. Like Exhausted, it
+// fails open: when spend cannot be read, the failure is logged and the job
+// goes on.
+func (c *Checker) CheckJob(ctx context.Context, jobID string) error {
+	if c == nil {
+		return nil
+	}
+	err := c.checkJob(ctx, jobID)
+	var exceeded *ExceededError
+	if err != nil && !errors.As(err, &exceeded) {
+		slog.Warn("budget: check job failed", "job", jobID, "err", err)
+		return nil
+	}
+	return err
+}
+
+func (c *Checker) checkJob(ctx context.Context, jobID string) error {
+	job, err := c.store.GetJob(ctx, jobID)
+	if err != nil {
+		return err
+	}
+	now := c.now().UTC()
+
+	var jobSpent float64
+	if c.cfg.Job > 0 {
+		ts, err := c.store.AggregateTokensByJob(ctx, jobID)
+		if err != nil {
+			return err
+		}
+		for _, m := range ts.Models {
+			jobSpent += c.modelCost(m)
+		}
+		c.alert(ctx, jobID, "job:"+jobID, "", jobSpent, c.cfg.Job,
+			"Job budget: %s of %s spent")
+	}
+	if c.cfg.ProjectDaily > 0 {
+		spent, err := c.spentSince(ctx, job.ProjectName, startOfDay(now))
+		if err != nil {
+			return err
+		}
+		c.alert(ctx, jobID, "project:"+job.ProjectName, now.Format("2006-01-02"), spent, c.cfg.ProjectDaily,
+			"Daily budget for "+job.ProjectName+": %s of %s spent")
+	}
+	if c.cfg.Monthly > 0 {
+		spent, err := c.spentSince(ctx, "", startOfMonth(now))
+		if err != nil {
+			return err
+		}
+		c.alert(ctx, jobID, "global", now.Format("2006-01"), spent, c.cfg.Monthly,
+			"Monthly budget: %s of %s spent")
+	}
+
+	if c.cfg.Job > 0 && jobSpent >= c.cfg.Job {
+		return &ExceededError{Spent: jobSpent, Limit: c.cfg.Job}
+	}
+	return nil
+}
+
+// Exhausted returns the projects whose daily budget is spent. all is true
+// when the monthly budget is spent and no job should be claimed. It fails
+// open like CheckJob: when spend cannot be read, the failure is logged and
+// nothing is reported exhausted.
+func (c *Checker) Exhausted(ctx context.Context) (projects []string, all bool) {
+	if c == nil {
+		return nil, false
+	}
+	projects, all, err := c.exhausted(ctx)
+	if err != nil {
+		slog.Warn("budget: check limits failed", "err", err)
+		return nil, false
+	}
+	return projects, all
+}
+
+func (c *Checker) exhausted(ctx context.Context) (projects []string, all bool, err error) {
+	now := c.now().UTC()
+	if c.cfg.Monthly > 0 {
+		spent, err := c.spentSince(ctx, "", startOfMonth(now))
+		if err != nil {
+			return nil, false, err
+		}
+		if spent >= c.cfg.Monthly {
+			return nil, true, nil
+		}
+	}
+	if c.cfg.ProjectDaily > 0 {
+		for _, name := range c.projects {
+			spent, err := c.spentSince(ctx, name, startOfDay(now))
+			if err != nil {
+				return nil, false, err
+			}
+			if spent >= c.cfg.ProjectDaily {
+				projects = append(projects, name)
+			}
+		}
+	}
+	return projects, false, nil
+}
+
+func (c *Checker) spentSince(ctx context.Context, project string, since time.Time) (float64, error) {
+	usage, err := c.store.UsageSince(ctx, project, since)
+	if err != nil {
+		return 0, err
+	}
+	total := 0.0
+	for _, m := range usage {
+		total += c.modelCost(m)
+	}
+	return total, nil
+}
+
+func (c *Checker) modelCost(m db.ModelUsage) float64 {
+	return c.costs.Cost(m.Provider, m.Model, cost.Usage{
+		Input:      m.InputTokens,
+		Output:     m.OutputTokens,
+		CacheRead:  m.CacheReadTokens,
+		CacheWrite: m.CacheCreationTokens,
+	})
+}
+
+// alert enqueues a budget_threshold notification on jobID once spent reaches
+// the threshold of limit. format receives the spent and limit amounts.
+func (c *Checker) alert(ctx context.Context, jobID, scope, period string, spent, limit float64, format string) {
+	if spent < limit*c.cfg.NotifyAt {
+		return
+	}
+	first, err := c.store.RecordBudgetAlert(ctx, scope, period)
+	if err != nil {
+		slog.Warn("budget: record alert failed", "scope", scope, "err", err)
+		return
+	}
+	if !first {
+		return
+	}
+	msg := fmt.Sprintf(format, cost.FormatUSD(spent), cost.FormatUSD(limit))
+	slog.Info("budget threshold crossed", "job", jobID, "scope", scope, "spent", spent, "limit", limit)
+	if _, err := c.store.EnqueueNotificationEventMessage(ctx, jobID, db.NotificationEventBudget, msg); err != nil {
+		slog.Warn("budget: enqueue notification failed", "job", jobID, "scope", scope, "err", err)
+	}
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func startOfMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,209 @@
+package budget
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"autopr/internal/config"
+	"autopr/internal/db"
+)
+
+func setupBudgetTest(t *testing.T, budget config.BudgetConfig) (*Checker, *db.Store) {
+	t.Helper()
+	store, err := db.Open(filepath.Join(t.TempDir(), "autopr.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { _ = store.Close() })
+	cfg := &config.Config{
+		Budget:   budget,
+		Projects: []config.ProjectConfig{{Name: "alpha"}, {Name: "beta"}},
+	}
+	return New(store, cfg), store
+}
+
+// createJobWithSpend creates a job in project with one completed codex session
+// of inputTokens (priced at $3 per 1M by default).
+func createJobWithSpend(t *testing.T, store *db.Store, project, issue string, inputTokens int) string {
+	t.Helper()
+	ctx := context.Background()
+	issueID, err := store.UpsertIssue(ctx, db.IssueUpsert{
+		ProjectName:   project,
+		Source:        "github",
+		SourceIssueID: issue,
+		Title:         "issue " + issue,
+		URL:           "https://github.com/org/repo/issues/" + issue,
+		State:         "open",
+	})
+	if err != nil {
+		t.Fatalf("upsert issue: %v", err)
+	}
+	jobID, err := store.CreateJob(ctx, issueID, project, 3)
+	if err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+	sessionID, err := store.CreateSession(ctx, jobID, "plan", 0, "codex", "")
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	if err := store.CompleteSession(ctx, sessionID, "completed", "ok", "", "", "", "", "", inputTokens, 0, 1); err != nil {
+		t.Fatalf("complete session: %v", err)
+	}
+	return jobID
+}
+
+func budgetEvents(t *testing.T, store *db.Store) []db.NotificationEvent {
+	t.Helper()
+	events, err := store.ListNotificationEvents(context.Background(), "", 0)
+	if err != nil {
+		t.Fatalf("list notification events: %v", err)
+	}
+	var out []db.NotificationEvent
+	for _, ev := range events {
+		if ev.EventType == db.NotificationEventBudget {
+			out = append(out, ev)
+		}
+	}
+	return out
+}
+
+func TestNewWithoutLimitsIsNil(t *testing.T) {
+	t.Parallel()
+	if c := New(nil, &config.Config{}); c != nil {
+		t.Fatalf("expected nil checker without limits, got %+v", c)
+	}
+	var c *Checker
+	if err := c.CheckJob(context.Background(), "job"); err != nil {
+		t.Fatalf("nil checker CheckJob: %v", err)
+	}
+	if projects, all := c.Exhausted(context.Background()); all || len(projects) != 0 {
+		t.Fatalf("nil checker Exhausted = %v %v", projects, all)
+	}
+}
+
+func TestCheckJobStopsJobOverLimitAndAlertsOnce(t *testing.T) {
+	t.Parallel()
+	c, store := setupBudgetTest(t, config.BudgetConfig{Job: 5, NotifyAt: 0.5})
+	ctx := context.Background()
+
+	under := createJobWithSpend(t, store, "alpha", "1", 500_000) // $1.50
+	if err := c.CheckJob(ctx, under); err != nil {
+		t.Fatalf("expected job under threshold to pass, got %v", err)
+	}
+	if events := budgetEvents(t, store); len(events) != 0 {
+		t.Fatalf("expected no alert below threshold, got %+v", events)
+	}
+
+	over := createJobWithSpend(t, store, "alpha", "2", 2_000_000) // $6.00
+	for range 2 {
+		err := c.CheckJob(ctx, over)
+		var exceeded *ExceededError
+		if !errors.As(err, &exceeded) {
+			t.Fatalf("expected ExceededError, got %v", err)
+		}
+		if got := exceeded.Error(); got != "budget exceeded: job spent $6.00 of its $5.00 limit" {
+			t.Fatalf("unexpected error message %q", got)
+		}
+	}
+	events := budgetEvents(t, store)
+	if len(events) != 1 || events[0].JobID != over || events[0].Message != "Job budget: $6.00 of $5.00 spent" {
+		t.Fatalf("expected one job budget alert, got %+v", events)
+	}
+}
+
+func TestCheckJobCountsFailedSessions(t *testing.T) {
+	t.Parallel()
+	c, store := setupBudgetTest(t, config.BudgetConfig{Job: 5, NotifyAt: 0.8})
+	ctx := context.Background()
+
+	job := createJobWithSpend(t, store, "alpha", "1", 0)
+	sessionID, err := store.CreateSession(ctx, job, "implement", 0, "codex", "")
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	// A session that timed out still spent the tokens it used before.
+	if err := store.CompleteSession(ctx, sessionID, "failed", "", "", "", "", "", "timed out", 2_000_000, 0, 1); err != nil {
+		t.Fatalf("complete session: %v", err)
+	}
+	var exceeded *ExceededError
+	if err := c.CheckJob(ctx, job); !errors.As(err, &exceeded) {
+		t.Fatalf("expected the failed session to count against the job budget, got %v", err)
+	}
+}
+
+func TestExhaustedCountsCancelledSessions(t *testing.T) {
+	t.Parallel()
+	c, store := setupBudgetTest(t, config.BudgetConfig{ProjectDaily: 2, NotifyAt: 0.8})
+	ctx := context.Background()
+
+	job := createJobWithSpend(t, store, "alpha", "1", 0)
+	sessionID, err := store.CreateSession(ctx, job, "implement", 0, "codex", "")
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	if err := store.CompleteSession(ctx, sessionID, "cancelled", "", "", "", "", "", "cancelled", 1_000_000, 0, 1); err != nil {
+		t.Fatalf("complete session: %v", err)
+	}
+	if projects, _ := c.Exhausted(ctx); len(projects) != 1 || projects[0] != "alpha" {
+		t.Fatalf("expected the cancelled session's $3.00 to exhaust alpha, got %v", projects)
+	}
+}
+
+func TestExhaustedProjectDailyAndMonthly(t *testing.T) {
+	t.Parallel()
+	c, store := setupBudgetTest(t, config.BudgetConfig{ProjectDaily: 2, Monthly: 10, NotifyAt: 0.8})
+	ctx := context.Background()
+
+	job := createJobWithSpend(t, store, "alpha", "1", 1_000_000) // $3.00
+	projects, all := c.Exhausted(ctx)
+	if all || len(projects) != 1 || projects[0] != "alpha" {
+		t.Fatalf("expected only alpha exhausted, got %v all=%v", projects, all)
+	}
+
+	// Project and monthly limits alert but do not stop the running job.
+	if err := c.CheckJob(ctx, job); err != nil {
+		t.Fatalf("expected project limit not to stop the job, got %v", err)
+	}
+	events := budgetEvents(t, store)
+	if len(events) != 1 || !strings.HasPrefix(events[0].Message, "Daily budget for alpha:") {
+		t.Fatalf("expected daily budget alert, got %+v", events)
+	}
+
+	// The next day the project budget starts over.
+	c.now = func() time.Time { return time.Now().Add(24 * time.Hour) }
+	if projects, _ := c.Exhausted(ctx); len(projects) != 0 {
+		t.Fatalf("expected daily budget to reset, got %v", projects)
+	}
+	c.now = time.Now
+
+	createJobWithSpend(t, store, "beta", "2", 3_000_000) // $9.00
+	if _, all := c.Exhausted(ctx); !all {
+		t.Fatalf("expected monthly budget exhausted, got all=%v", all)
+	}
+}
+
+func TestChecksFailOpenWhenSpendCannotBeRead(t *testing.T) {
+	t.Parallel()
+	c, store := setupBudgetTest(t, config.BudgetConfig{Job: 1, ProjectDaily: 1, Monthly: 1, NotifyAt: 0.8})
+	ctx := context.Background()
+
+	job := createJobWithSpend(t, store, "alpha", "1", 1_000_000) // $3.00
+	if _, all := c.Exhausted(ctx); !all {
+		t.Fatalf("expected monthly budget exhausted before the failure")
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("close db: %v", err)
+	}
+
+	// Neither the worker pool nor a running job is stopped by a failed read.
+	if projects, all := c.Exhausted(ctx); all || len(projects) != 0 {
+		t.Fatalf("expected nothing exhausted when spend cannot be read, got %v all=%v", projects, all)
+	}
+	if err := c.CheckJob(ctx, job); err != nil {
+		t.Fatalf("expected the job to go on when spend cannot be read, got %v", err)
+	}
+}
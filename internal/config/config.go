@@ -7,7 +7,10 @@ import (
 	"log/slog"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"runtime"
 	"slices"
 	"strings"
 	"time"
@@ -87,6 +90,8 @@ type Config struct {
 	Sentry        SentryConfig        `toml:"sentry"`
 	LLM           LLMConfig           `toml:"llm"`
 	Notifications NotificationsConfig `toml:"notifications"`
+	Cost          CostConfig          `toml:"cost"`
+	Budget        BudgetConfig        `toml:"budget"`
 
 	Projects []ProjectConfig `toml:"projects"`
 
@@ -112,12 +117,150 @@ type TokensConfig struct {
 	Sentry string `toml:"sentry"`
 }
 
+// CostConfig overrides the built-in pricing used to estimate LLM cost. Model
+// rates match the session's model exactly or by prefix (the longest prefix
+// wins); provider rates apply to sessions whose model has no rate.
+type CostConfig struct {
+	Models    map[string]CostRate `toml:"models"`
+	Providers map[string]CostRate `toml:"providers"`
+}
+
+// CostRate is the price in USD per 1M tokens.
+type CostRate struct {
+	Input      float64 `toml:"input"`
+	Output     float64 `toml:"output"`
+	CacheRead  float64 `toml:"cache_read"`
+	CacheWrite float64 `toml:"cache_write"`
+}
+
+// BudgetConfig caps the estimated LLM spend in USD, priced with [cost]. A
+// zero limit is unlimited. Days and months are counted in UTC.
+type BudgetConfig struct {
+	Job          float64 `toml:"job"`           // per job, across all of its sessions
+	ProjectDaily float64 `toml:"project_daily"` // per project per day
+	Monthly      float64 `toml:"monthly"`       // across all projects per month
+	// NotifyAt is the fraction of a limit at which the budget_threshold
+	// notification fires.
+	NotifyAt float64 `toml:"notify_at"`
+}
+
 type SentryConfig struct {
 	BaseURL string `toml:"base_url"`
 }
 
 type LLMConfig struct {
 	Provider string `toml:"provider"`
+
+	// HTTP providers (openai, anthropic) only.
+	BaseURL         string   `toml:"base_url"`
+	Model           string   `toml:"model"`
+	APIKeyEnv       string   `toml:"api_key_env"`
+	AllowedCommands []string `toml:"allowed_commands"`
+
+	// Custom CLI providers, selected by setting provider to the table name.
+	Providers map[string]CLIProviderConfig `toml:"providers"`
+
+	// Per-step overrides, keyed by step name (see LLMSteps).
+	Steps map[string]LLMRoute `toml:"steps"`
+
+	// Providers to try, in order, when a step fails transiently (rate limit,
+	// 5xx, expired auth).
+	Fallback []string `toml:"fallback"`
+
+	// Per-invocation limits; steps and projects may override them.
+	Timeout  string `toml:"timeout"`   // wall-clock limit, e.g. "45m"
+	MaxTurns int    `toml:"max_turns"` // agent turn limit (claude, HTTP providers, {{max_turns}})
+
+	// Record-and-replay. RecordDir saves every session's transcript and
+	// worktree patch; the replay provider plays a recording back from ReplayDir.
+	RecordDir string `toml:"record_dir"`
+	ReplayDir string `toml:"replay_dir"`
+}
+
+// LLMRoute selects the provider and model for a pipeline step. Empty fields
+// inherit from the next less specific level.
+type LLMRoute struct {
+	Provider  string `toml:"provider"`
+	Model     string `toml:"model"`
+	BaseURL   string `toml:"base_url"`
+	APIKeyEnv string `toml:"api_key_env"`
+	Timeout   string `toml:"timeout"`
+	MaxTurns  int    `toml:"max_turns"`
+}
+
+// TimeoutDuration returns the parsed timeout, or 0 when unset or invalid.
+// Load rejects invalid timeouts, so 0 only means "no limit" in practice.
+func (r LLMRoute) TimeoutDuration() time.Duration {
+	d, err := time.ParseDuration(r.Timeout)
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+// LLMSteps are the pipeline steps that can be routed to their own provider or model.
+var LLMSteps = []string{"plan", "plan_review", "implement", "code_review", "conflict_resolution"}
+
+// CLIProviderConfig declares a custom CLI provider under [llm.providers.<name>].
+type CLIProviderConfig struct {
+	Binary string `toml:"binary"`
+	// Args may reference {{prompt}} and {{jsonl}}. With prompt_mode = "file",
+	// {{prompt}} expands to the path of a file holding the prompt.
+	Args       []string `toml:"args"`
+	PromptMode string   `toml:"prompt_mode"` // arg (default), stdin or file
+	// Output is how stdout is parsed: claude, codex, jsonl (use the text and
+	// usage rules) or text (stdout is the final text). Defaults to jsonl when
+	// rules are given, text otherwise.
+	Output string           `toml:"output"`
+	Text   []JSONLTextRule  `toml:"text"`
+	Usage  []JSONLUsageRule `toml:"usage"`
+}
+
+// JSONLTextRule extracts final text from JSONL lines whose top-level "type"
+// equals Type (empty matches every line). The last non-empty match wins.
+type JSONLTextRule struct {
+	Type string `toml:"type"`
+	Path string `toml:"path"` // dotted path, e.g. "item.text"
+}
+
+// JSONLUsageRule extracts token counts from matching JSONL lines. Counts are
+// summed across all matching lines.
+type JSONLUsageRule struct {
+	Type                string `toml:"type"`
+	InputTokens         string `toml:"input_tokens"`          // dotted path, uncached input only
+	OutputTokens        string `toml:"output_tokens"`         // dotted path
+	CacheReadTokens     string `toml:"cache_read_tokens"`     // dotted path
+	CacheCreationTokens string `toml:"cache_creation_tokens"` // dotted path
+}
+
+// Supported llm.providers.<name>.prompt_mode values.
+const (
+	PromptModeArg   = "arg"
+	PromptModeStdin = "stdin"
+	PromptModeFile  = "file"
+)
+
+// Supported llm.providers.<name>.output values.
+const (
+	OutputClaude = "claude"
+	OutputCodex  = "codex"
+	OutputJSONL  = "jsonl"
+	OutputText   = "text"
+)
+
+// Supported llm.provider values.
+const (
+	ProviderCodex     = "codex"
+	ProviderClaude    = "claude"
+	ProviderOpenAI    = "openai"    // any OpenAI-compatible /v1/chat/completions endpoint
+	ProviderAnthropic = "anthropic" // Anthropic Messages API
+	ProviderReplay    = "replay"    // replays sessions recorded with llm.record_dir
+)
+
+// IsHTTPProvider reports whether the provider talks to an HTTP API directly
+// instead of shelling out to a vendor CLI.
+func IsHTTPProvider(provider string) bool {
+	return provider == ProviderOpenAI || provider == ProviderAnthropic
 }
 
 type NotificationsConfig struct {
@@ -128,12 +271,17 @@ type NotificationsConfig struct {
 }
 
 const (
-	TriggerNeedsPR   = "needs_pr"
-	TriggerFailed    = "failed"
-	TriggerPRCreated = "pr_created"
-	TriggerPRMerged  = "pr_merged"
+	TriggerNeedsPR      = "needs_pr"
+	TriggerFailed       = "failed"
+	TriggerPRCreated    = "pr_created"
+	TriggerPRMerged     = "pr_merged"
+	TriggerBudget       = "budget_threshold"
+	TriggerPlanApproval = "plan_approval"
 
 	DefaultMaxAutoResolvableConflictLines = 20
+	DefaultMaxReplans                     = 2
+	DefaultClarifyTimeout                 = "72h"
+	DefaultMaxReviewRounds                = 3
 )
 
 var defaultNotificationTriggers = []string{
@@ -141,19 +289,234 @@ var defaultNotificationTriggers = []string{
 	TriggerFailed,
 	TriggerPRCreated,
 	TriggerPRMerged,
+	TriggerBudget,
+	TriggerPlanApproval,
 }
 
 type ProjectConfig struct {
-	Name                           string          `toml:"name"`
-	RepoURL                        string          `toml:"repo_url"`
-	TestCmd                        string          `toml:"test_cmd"`
-	BaseBranch                     string          `toml:"base_branch"`
-	MaxAutoResolvableConflictLines int             `toml:"max_auto_resolvable_conflict_lines"`
-	ExcludeLabels                  []string        `toml:"exclude_labels"`
-	GitLab                         *ProjectGitLab  `toml:"gitlab"`
-	GitHub                         *ProjectGitHub  `toml:"github"`
-	Sentry                         *ProjectSentry  `toml:"sentry"`
-	Prompts                        *ProjectPrompts `toml:"prompts"`
+	Name                           string                 `toml:"name"`
+	RepoURL                        string                 `toml:"repo_url"`
+	TestCmd                        string                 `toml:"test_cmd"`
+	TestCmds                       []TestCommand          `toml:"test_cmds"`      // replaces test_cmd with several commands
+	TestReport                     string                 `toml:"test_report"`    // JUnit XML path or glob in the worktree
+	BaselineTests                  bool                   `toml:"baseline_tests"` // compare failures against the base branch
+	BaseBranch                     string                 `toml:"base_branch"`
+	MaxAutoResolvableConflictLines int                    `toml:"max_auto_resolvable_conflict_lines"`
+	ExcludeLabels                  []string               `toml:"exclude_labels"`
+	GitLab                         *ProjectGitLab         `toml:"gitlab"`
+	GitHub                         *ProjectGitHub         `toml:"github"`
+	Sentry                         *ProjectSentry         `toml:"sentry"`
+	Prompts                        *ProjectPrompts        `toml:"prompts"`
+	LLM                            *ProjectLLM            `toml:"llm"`
+	Sandbox                        *ProjectSandbox        `toml:"sandbox"`
+	PlanReview                     *ProjectPlanReview     `toml:"plan_review"`
+	PlanApproval                   bool                   `toml:"plan_approval"` // wait for approve-plan before implementing
+	Clarify                        *ProjectClarify        `toml:"clarify"`
+	ReviewFeedback                 *ProjectReviewFeedback `toml:"review_feedback"`
+	CodeReview                     *ProjectCodeReview     `toml:"code_review"`
+	Pipeline                       *ProjectPipeline       `toml:"pipeline"`
+	DiffPolicy                     *ProjectDiffPolicy     `toml:"diff_policy"`
+	// Env is added to the allow-listed environment of the project's LLM and
+	// test subprocesses. A leading "~/" in a value expands to the user's home.
+	Env map[string]string `toml:"env"`
+}
+
+// ProjectSandbox runs the LLM agent and the test command inside a bubblewrap
+// sandbox (Linux only). The job worktree is the only writable path by default;
+// the system toolchain is mounted read-only and /tmp and $HOME are private.
+type ProjectSandbox struct {
+	Enabled bool `toml:"enabled"`
+	// DenyNetwork cuts off the network for test commands and HTTP-provider
+	// tool commands. CLI agents keep network access to reach their API.
+	DenyNetwork bool     `toml:"deny_network"`
+	ReadOnly    []string `toml:"read_only"`  // extra read-only paths, e.g. "~/go/pkg/mod"
+	ReadWrite   []string `toml:"read_write"` // extra writable paths, e.g. "~/.claude"
+}
+
+// ProjectPlanReview adds a plan review step between planning and
+// implementing. The reviewer approves the plan, sends it back for a re-plan,
+// or flags the issue as infeasible, which stops the job in needs_human.
+type ProjectPlanReview struct {
+	Enabled    bool `toml:"enabled"`
+	MaxReplans int  `toml:"max_replans"` // re-plans before implementing the latest plan anyway
+}
+
+// ProjectClarify lets the plan step ask clarifying questions on the source
+// GitHub or GitLab issue instead of planning a guess. The job waits in
+// needs_info until the issue author or a maintainer replies, and stops in
+// needs_human when nobody replies within Timeout.
+type ProjectClarify struct {
+	Enabled bool   `toml:"enabled"`
+	Timeout string `toml:"timeout"` // e.g. "72h"
+}
+
+// ClarifyEnabled reports whether the plan step may ask clarifying questions.
+func (p *ProjectConfig) ClarifyEnabled() bool {
+	return p != nil && p.Clarify != nil && p.Clarify.Enabled
+}
+
+// ProjectReviewFeedback addresses human review comments on AutoPR's open
+// PRs/MRs: the syncer picks up new review comments and change requests, and
+// the job runs another implement and test round on the same branch, pushes
+// and replies on the PR with a summary. MaxRounds caps the rounds per job.
+type ProjectReviewFeedback struct {
+	Enabled   bool `toml:"enabled"`
+	MaxRounds int  `toml:"max_rounds"`
+}
+
+// ReviewFeedbackEnabled reports whether review comments on the project's PRs
+// are addressed automatically.
+func (p *ProjectConfig) ReviewFeedbackEnabled() bool {
+	return p != nil && p.ReviewFeedback != nil && p.ReviewFeedback.Enabled
+}
+
+// PlanReviewEnabled reports whether plans are reviewed before implementing.
+// With an explicit pipeline, the plan_review step must be listed.
+func (p *ProjectConfig) PlanReviewEnabled() bool {
+	if p != nil && p.Pipeline != nil {
+		return p.HasPipelineStep(StepPlanReview)
+	}
+	return p != nil && p.PlanReview != nil && p.PlanReview.Enabled
+}
+
+// ProjectCodeReview replaces the single code review with several independent
+// reviewers per iteration, whose verdicts are combined by Policy.
+type ProjectCodeReview struct {
+	Policy    string         `toml:"policy"` // all (default), majority or veto
+	Reviewers []CodeReviewer `toml:"reviewers"`
+}
+
+// CodeReviewer is one [[projects.code_review.reviewers]] entry. Provider and
+// model override the code_review route; persona focuses the review on
+// security, correctness or tests.
+type CodeReviewer struct {
+	Name     string `toml:"name"`
+	Persona  string `toml:"persona"`
+	Prompt   string `toml:"prompt"` // replaces prompts.code_review for this reviewer
+	Provider string `toml:"provider"`
+	Model    string `toml:"model"`
+}
+
+// Code review consensus policies.
+const (
+	ReviewPolicyAll      = "all"      // every reviewer approves
+	ReviewPolicyMajority = "majority" // more than half of the reviewers approve
+	ReviewPolicyVeto     = "veto"     // no reviewer raised a blocker finding
+)
+
+// Code reviewer personas.
+var ReviewPersonas = []string{"security", "correctness", "tests"}
+
+// CodeReviewers returns the project's configured reviewers, or nil when the
+// project uses a single code review.
+func (p *ProjectConfig) CodeReviewers() []CodeReviewer {
+	if p == nil || p.CodeReview == nil {
+		return nil
+	}
+	return p.CodeReview.Reviewers
+}
+
+// ProjectDiffPolicy limits what a job's changes against the base branch may
+// touch. It is checked before the tests of every iteration. Paths use
+// CODEOWNERS-style globs: a pattern without a slash matches a file name in any
+// directory, "**" matches any number of directories and a trailing slash
+// matches everything inside a directory.
+type ProjectDiffPolicy struct {
+	MaxFiles    int      `toml:"max_files"`
+	MaxLines    int      `toml:"max_lines"`    // insertions plus deletions
+	Deny        []string `toml:"deny"`         // no changed path may match
+	Allow       []string `toml:"allow"`        // when set, every changed path must match one
+	CodeOwners  []string `toml:"codeowners"`   // owners whose CODEOWNERS paths need a human, e.g. "@org/security"
+	OnViolation string   `toml:"on_violation"` // loop (default) or fail
+}
+
+// Built-in pipeline step names, in the order they run.
+const (
+	StepPlan       = "plan"
+	StepPlanReview = "plan_review"
+	StepImplement  = "implement"
+	StepCodeReview = "code_review"
+	StepTests      = "tests"
+)
+
+var builtinPipelineSteps = []string{StepPlan, StepPlanReview, StepImplement, StepCodeReview, StepTests}
+
+// Command step failure behaviours.
+const (
+	OnFailureLoop = "loop" // feed the output back to implement, like failing tests
+	OnFailureFail = "fail" // fail the job
+	OnFailureWarn = "warn" // record the output and continue
+)
+
+// ProjectPipeline replaces the default step list (plan, [plan_review],
+// implement, code_review, tests). Built-in steps keep their relative order
+// and may be left out, except plan and implement; command steps run a
+// deterministic command between implement and tests.
+type ProjectPipeline struct {
+	Steps []PipelineStep `toml:"steps"`
+}
+
+// PipelineStep is one [[projects.pipeline.steps]] entry. Cmd is set only for
+// command steps, whose output is stored as a "command:<name>" artifact.
+type PipelineStep struct {
+	Name      string `toml:"name"`
+	Cmd       string `toml:"cmd"`
+	OnFailure string `toml:"on_failure"` // loop (default), fail or warn
+	Timeout   string `toml:"timeout"`    // e.g. "10m"; unset means no limit
+}
+
+// IsCommand reports whether s runs a command rather than a built-in step.
+func (s PipelineStep) IsCommand() bool {
+	return s.Cmd != ""
+}
+
+// ArtifactKind returns the artifact kind a command step's output is stored as.
+func (s PipelineStep) ArtifactKind() string {
+	return "command:" + s.Name
+}
+
+// TimeoutDuration returns the parsed timeout, or 0 when unset or invalid.
+func (s PipelineStep) TimeoutDuration() time.Duration {
+	d, err := time.ParseDuration(s.Timeout)
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+// PipelineSteps returns the project's ordered pipeline steps, falling back to
+// the default list when no pipeline is configured.
+func (p *ProjectConfig) PipelineSteps() []PipelineStep {
+	if p != nil && p.Pipeline != nil {
+		return p.Pipeline.Steps
+	}
+	steps := []PipelineStep{{Name: StepPlan}}
+	if p.PlanReviewEnabled() {
+		steps = append(steps, PipelineStep{Name: StepPlanReview})
+	}
+	return append(steps, PipelineStep{Name: StepImplement}, PipelineStep{Name: StepCodeReview}, PipelineStep{Name: StepTests})
+}
+
+// HasPipelineStep reports whether the named step is part of the pipeline.
+func (p *ProjectConfig) HasPipelineStep(name string) bool {
+	return slices.ContainsFunc(p.PipelineSteps(), func(s PipelineStep) bool { return s.Name == name })
+}
+
+// SandboxEnabled reports whether the project runs subprocesses sandboxed.
+func (p *ProjectConfig) SandboxEnabled() bool {
+	return p != nil && p.Sandbox != nil && p.Sandbox.Enabled
+}
+
+// ProjectLLM overrides the global [llm] routing for one project.
+type ProjectLLM struct {
+	Provider  string              `toml:"provider"`
+	Model     string              `toml:"model"`
+	BaseURL   string              `toml:"base_url"`
+	APIKeyEnv string              `toml:"api_key_env"`
+	Timeout   string              `toml:"timeout"`
+	MaxTurns  int                 `toml:"max_turns"`
+	Steps     map[string]LLMRoute `toml:"steps"`
+	Fallback  []string            `toml:"fallback"` // replaces llm.fallback when set
 }
 
 type ProjectGitLab struct {
@@ -299,11 +662,39 @@ func applyDefaults(cfg *Config) {
 	if cfg.Daemon.CICheckTimeout == "" {
 		cfg.Daemon.CICheckTimeout = "30m"
 	}
+	if cfg.Budget.NotifyAt == 0 {
+		cfg.Budget.NotifyAt = 0.8
+	}
 	if cfg.Sentry.BaseURL == "" {
 		cfg.Sentry.BaseURL = "https://sentry.io"
 	}
 	if cfg.LLM.Provider == "" {
-		cfg.LLM.Provider = "codex"
+		cfg.LLM.Provider = ProviderCodex
+	}
+	if cfg.LLM.Timeout == "" {
+		cfg.LLM.Timeout = "60m"
+	}
+	if cfg.LLM.MaxTurns == 0 {
+		cfg.LLM.MaxTurns = 50
+	}
+	for name, p := range cfg.LLM.Providers {
+		if p.PromptMode == "" {
+			p.PromptMode = PromptModeArg
+		}
+		if p.Output == "" {
+			p.Output = OutputText
+			if len(p.Text) > 0 || len(p.Usage) > 0 {
+				p.Output = OutputJSONL
+			}
+		}
+		cfg.LLM.Providers[name] = p
+	}
+	baseURL, keyEnv := defaultHTTPEndpoint(cfg.LLM.Provider)
+	if cfg.LLM.BaseURL == "" {
+		cfg.LLM.BaseURL = baseURL
+	}
+	if cfg.LLM.APIKeyEnv == "" {
+		cfg.LLM.APIKeyEnv = keyEnv
 	}
 	if cfg.Notifications.Triggers == nil {
 		cfg.Notifications.Triggers = slices.Clone(defaultNotificationTriggers)
@@ -315,6 +706,18 @@ func applyDefaults(cfg *Config) {
 		if cfg.Projects[i].MaxAutoResolvableConflictLines <= 0 {
 			cfg.Projects[i].MaxAutoResolvableConflictLines = DefaultMaxAutoResolvableConflictLines
 		}
+		if cfg.Projects[i].PlanReview != nil && cfg.Projects[i].PlanReview.MaxReplans <= 0 {
+			cfg.Projects[i].PlanReview.MaxReplans = DefaultMaxReplans
+		}
+		if cfg.Projects[i].Clarify != nil && cfg.Projects[i].Clarify.Timeout == "" {
+			cfg.Projects[i].Clarify.Timeout = DefaultClarifyTimeout
+		}
+		if cfg.Projects[i].ReviewFeedback != nil && cfg.Projects[i].ReviewFeedback.MaxRounds == 0 {
+			cfg.Projects[i].ReviewFeedback.MaxRounds = DefaultMaxReviewRounds
+		}
+		if cfg.Projects[i].CodeReview != nil && cfg.Projects[i].CodeReview.Policy == "" {
+			cfg.Projects[i].CodeReview.Policy = ReviewPolicyAll
+		}
 		if (cfg.Projects[i].GitHub != nil || cfg.Projects[i].GitLab != nil) && cfg.Projects[i].ExcludeLabels == nil {
 			cfg.Projects[i].ExcludeLabels = []string{DefaultExcludeLabel}
 		}
@@ -386,10 +789,11 @@ func warnTokensInFile(fileTokens TokensConfig) {
 }
 
 func validate(cfg *Config) error {
-	switch cfg.LLM.Provider {
-	case "claude", "codex":
-	default:
-		return fmt.Errorf("unsupported llm.provider: %q (must be claude or codex)", cfg.LLM.Provider)
+	if err := validateLLMConfig(cfg.LLM); err != nil {
+		return err
+	}
+	if err := validateLLMRouting(cfg); err != nil {
+		return err
 	}
 	switch cfg.LogLevel {
 	case "debug", "info", "warn", "error":
@@ -410,6 +814,12 @@ func validate(cfg *Config) error {
 		return err
 	}
 	cfg.Notifications.Triggers = normalizedTriggers
+	if err := validateCostConfig(cfg.Cost); err != nil {
+		return err
+	}
+	if err := validateBudgetConfig(cfg.Budget); err != nil {
+		return err
+	}
 	if len(cfg.Projects) == 0 {
 		return fmt.Errorf("at least one [[projects]] entry is required")
 	}
@@ -420,12 +830,46 @@ func validate(cfg *Config) error {
 		if p.RepoURL == "" {
 			return fmt.Errorf("project %q: repo_url is required", p.Name)
 		}
-		if p.TestCmd == "" {
+		if err := validatePipeline(&cfg.Projects[i]); err != nil {
+			return fmt.Errorf("project %q pipeline: %w", p.Name, err)
+		}
+		if err := validateCodeReview(p.CodeReview); err != nil {
+			return fmt.Errorf("project %q code_review: %w", p.Name, err)
+		}
+		if err := validateDiffPolicy(p.DiffPolicy); err != nil {
+			return fmt.Errorf("project %q diff_policy: %w", p.Name, err)
+		}
+		if err := validateClarify(p); err != nil {
+			return fmt.Errorf("project %q clarify: %w", p.Name, err)
+		}
+		if err := validateReviewFeedback(p); err != nil {
+			return fmt.Errorf("project %q review_feedback: %w", p.Name, err)
+		}
+		if p.TestCmd == "" && len(p.TestCmds) == 0 && p.HasPipelineStep(StepTests) {
 			return fmt.Errorf("project %q: test_cmd is required", p.Name)
 		}
+		if p.TestCmd != "" && len(p.TestCmds) > 0 {
+			return fmt.Errorf("project %q: set test_cmd or test_cmds, not both", p.Name)
+		}
+		if err := validateTestCommands(p.TestCmds); err != nil {
+			return fmt.Errorf("project %q: %w", p.Name, err)
+		}
+		if p.TestReport != "" {
+			if _, err := filepath.Match(p.TestReport, ""); err != nil || !filepath.IsLocal(p.TestReport) {
+				return fmt.Errorf("project %q: test_report must be a path or glob inside the repository, got %q", p.Name, p.TestReport)
+			}
+		}
 		if p.GitLab == nil && p.GitHub == nil && p.Sentry == nil {
 			return fmt.Errorf("project %q: at least one source (gitlab/github/sentry) is required", p.Name)
 		}
+		if p.SandboxEnabled() && runtime.GOOS != "linux" {
+			return fmt.Errorf("project %q: sandbox is only supported on Linux", p.Name)
+		}
+		for name := range p.Env {
+			if name == "" || strings.ContainsAny(name, "= \t\n") {
+				return fmt.Errorf("project %q: invalid env variable name %q", p.Name, name)
+			}
+		}
 		normalized, err := normalizeLabels(p.ExcludeLabels)
 		if err != nil {
 			return fmt.Errorf("project %q exclude_labels: %w", p.Name, err)
@@ -455,6 +899,325 @@ func validate(cfg *Config) error {
 	return nil
 }
 
+var pipelineStepNameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+func validatePipeline(p *ProjectConfig) error {
+	if p.Pipeline == nil {
+		return nil
+	}
+	if p.PlanReview != nil && p.PlanReview.Enabled && !p.HasPipelineStep(StepPlanReview) {
+		return fmt.Errorf("plan_review.enabled is set but plan_review is not a pipeline step")
+	}
+	seen := make(map[string]bool)
+	lastBuiltin := -1
+	for i := range p.Pipeline.Steps {
+		step := &p.Pipeline.Steps[i]
+		step.Name = strings.TrimSpace(step.Name)
+		if step.Name == "" {
+			return fmt.Errorf("steps[%d]: name is required", i)
+		}
+		if seen[step.Name] {
+			return fmt.Errorf("duplicate step %q", step.Name)
+		}
+		seen[step.Name] = true
+
+		if order := slices.Index(builtinPipelineSteps, step.Name); order >= 0 {
+			if step.Cmd != "" || step.OnFailure != "" || step.Timeout != "" {
+				return fmt.Errorf("built-in step %q does not take cmd, on_failure or timeout", step.Name)
+			}
+			if order < lastBuiltin {
+				return fmt.Errorf("built-in step %q must come before %q", step.Name, builtinPipelineSteps[lastBuiltin])
+			}
+			lastBuiltin = order
+			continue
+		}
+
+		if !pipelineStepNameRe.MatchString(step.Name) {
+			return fmt.Errorf("step %q: name must be lowercase letters, digits, '-' or '_'", step.Name)
+		}
+		if strings.TrimSpace(step.Cmd) == "" {
+			return fmt.Errorf("step %q: cmd is required for a command step", step.Name)
+		}
+		args, err := ParseTestCommand(step.Cmd)
+		if err == nil {
+			err = ValidateTestCommandArgs(args)
+		}
+		if err != nil {
+			return fmt.Errorf("step %q: %w", step.Name, err)
+		}
+		if step.Timeout != "" {
+			if d, err := time.ParseDuration(step.Timeout); err != nil || d <= 0 {
+				return fmt.Errorf("step %q: invalid timeout %q: must be a positive duration", step.Name, step.Timeout)
+			}
+		}
+		if !seen[StepImplement] || seen[StepTests] {
+			return fmt.Errorf("command step %q must come after implement and before tests", step.Name)
+		}
+		switch step.OnFailure {
+		case "":
+			step.OnFailure = OnFailureLoop
+		case OnFailureLoop, OnFailureFail, OnFailureWarn:
+		default:
+			return fmt.Errorf("step %q: unsupported on_failure %q (expected loop, fail or warn)", step.Name, step.OnFailure)
+		}
+	}
+	if !seen[StepPlan] || !seen[StepImplement] {
+		return fmt.Errorf("plan and implement steps are required")
+	}
+	return nil
+}
+
+func validateDiffPolicy(policy *ProjectDiffPolicy) error {
+	if policy == nil {
+		return nil
+	}
+	if policy.MaxFiles < 0 || policy.MaxLines < 0 {
+		return fmt.Errorf("max_files and max_lines must not be negative")
+	}
+	for _, pattern := range slices.Concat(policy.Deny, policy.Allow) {
+		if _, err := path.Match(strings.Trim(pattern, "/"), ""); err != nil || strings.Trim(pattern, "/") == "" {
+			return fmt.Errorf("invalid path pattern %q", pattern)
+		}
+	}
+	for _, owner := range policy.CodeOwners {
+		if strings.TrimSpace(owner) == "" {
+			return fmt.Errorf("codeowners entries must not be empty")
+		}
+	}
+	switch policy.OnViolation {
+	case "":
+		policy.OnViolation = OnFailureLoop
+	case OnFailureLoop, OnFailureFail:
+	default:
+		return fmt.Errorf("unsupported on_violation %q (expected loop or fail)", policy.OnViolation)
+	}
+	return nil
+}
+
+func validateClarify(p ProjectConfig) error {
+	if p.Clarify == nil {
+		return nil
+	}
+	if d, err := time.ParseDuration(p.Clarify.Timeout); err != nil || d <= 0 {
+		return fmt.Errorf("invalid timeout %q", p.Clarify.Timeout)
+	}
+	if p.Clarify.Enabled && p.GitHub == nil && p.GitLab == nil {
+		return fmt.Errorf("requires a github or gitlab source to comment on")
+	}
+	return nil
+}
+
+func validateReviewFeedback(p ProjectConfig) error {
+	if p.ReviewFeedback == nil {
+		return nil
+	}
+	// 0 is replaced by DefaultMaxReviewRounds when the config is loaded.
+	if p.ReviewFeedback.MaxRounds < 0 {
+		return fmt.Errorf("max_rounds must not be negative, got %d", p.ReviewFeedback.MaxRounds)
+	}
+	if p.ReviewFeedback.Enabled && p.GitHub == nil && p.GitLab == nil {
+		return fmt.Errorf("requires a github or gitlab project to read PR reviews from")
+	}
+	return nil
+}
+
+func validateCodeReview(review *ProjectCodeReview) error {
+	if review == nil {
+		return nil
+	}
+	switch review.Policy {
+	case ReviewPolicyAll, ReviewPolicyMajority, ReviewPolicyVeto:
+	default:
+		return fmt.Errorf("unsupported policy %q (expected all, majority or veto)", review.Policy)
+	}
+	seen := make(map[string]bool)
+	for i := range review.Reviewers {
+		reviewer := &review.Reviewers[i]
+		reviewer.Name = strings.TrimSpace(reviewer.Name)
+		if reviewer.Name == "" {
+			return fmt.Errorf("reviewers[%d]: name is required", i)
+		}
+		if seen[reviewer.Name] {
+			return fmt.Errorf("duplicate reviewer %q", reviewer.Name)
+		}
+		seen[reviewer.Name] = true
+		if reviewer.Persona != "" && !slices.Contains(ReviewPersonas, reviewer.Persona) {
+			return fmt.Errorf("reviewer %q: unsupported persona %q (expected %s)", reviewer.Name, reviewer.Persona, strings.Join(ReviewPersonas, ", "))
+		}
+	}
+	return nil
+}
+
+func validateLLMConfig(cfg LLMConfig) error {
+	switch cfg.Provider {
+	case ProviderClaude, ProviderCodex, ProviderReplay:
+	case ProviderOpenAI, ProviderAnthropic:
+		if strings.TrimSpace(cfg.Model) == "" {
+			return fmt.Errorf("llm.model is required for provider %q", cfg.Provider)
+		}
+		if err := validateWebhookURL(cfg.BaseURL); err != nil {
+			return fmt.Errorf("invalid llm.base_url: %w", err)
+		}
+	default:
+		if _, ok := cfg.Providers[cfg.Provider]; !ok {
+			return fmt.Errorf("unsupported llm.provider: %q (must be claude, codex, openai, anthropic, replay or a name from [llm.providers])", cfg.Provider)
+		}
+	}
+	if cfg.RecordDir != "" && cfg.ReplayDir != "" && filepath.Clean(cfg.RecordDir) == filepath.Clean(cfg.ReplayDir) {
+		return fmt.Errorf("llm.record_dir and llm.replay_dir must differ")
+	}
+	for name, p := range cfg.Providers {
+		if err := validateCLIProviderConfig(name, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateLLMRouting checks step names and the fully resolved route of every
+// step in every project.
+func validateLLMRouting(cfg *Config) error {
+	if err := validateLLMStepNames("llm.steps", cfg.LLM.Steps); err != nil {
+		return err
+	}
+	for i := range cfg.Projects {
+		p := &cfg.Projects[i]
+		if p.LLM != nil {
+			if err := validateLLMStepNames(fmt.Sprintf("project %q llm.steps", p.Name), p.LLM.Steps); err != nil {
+				return err
+			}
+		}
+		for _, step := range LLMSteps {
+			route := cfg.LLMRouteForStep(p, step)
+			if err := validateLLMRoute(cfg.LLM, route); err != nil {
+				return fmt.Errorf("project %q llm route for step %s: %w", p.Name, step, err)
+			}
+			for _, fallback := range cfg.LLMFallbackRoutes(p, step) {
+				if err := validateLLMRoute(cfg.LLM, fallback); err != nil {
+					return fmt.Errorf("project %q llm fallback for step %s: %w", p.Name, step, err)
+				}
+			}
+		}
+		for _, reviewer := range p.CodeReviewers() {
+			if err := validateLLMRoute(cfg.LLM, cfg.CodeReviewerRoute(p, reviewer)); err != nil {
+				return fmt.Errorf("project %q llm route for reviewer %s: %w", p.Name, reviewer.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func validateLLMStepNames(label string, steps map[string]LLMRoute) error {
+	for step := range steps {
+		if !slices.Contains(LLMSteps, step) {
+			return fmt.Errorf("%s.%s: unknown step (must be one of %s)", label, step, strings.Join(LLMSteps, ", "))
+		}
+	}
+	return nil
+}
+
+func validateLLMRoute(llm LLMConfig, route LLMRoute) error {
+	if route.Timeout != "" {
+		if d, err := time.ParseDuration(route.Timeout); err != nil || d <= 0 {
+			return fmt.Errorf("invalid timeout %q: must be a positive duration", route.Timeout)
+		}
+	}
+	if route.MaxTurns < 0 {
+		return fmt.Errorf("max_turns must be positive, got %d", route.MaxTurns)
+	}
+	switch route.Provider {
+	case ProviderClaude, ProviderCodex:
+	case ProviderReplay:
+		if strings.TrimSpace(llm.ReplayDir) == "" {
+			return fmt.Errorf("llm.replay_dir is required for provider %q", route.Provider)
+		}
+	case ProviderOpenAI, ProviderAnthropic:
+		if strings.TrimSpace(route.Model) == "" {
+			return fmt.Errorf("model is required for provider %q", route.Provider)
+		}
+		if err := validateWebhookURL(route.BaseURL); err != nil {
+			return fmt.Errorf("invalid base_url: %w", err)
+		}
+	default:
+		if _, ok := llm.Providers[route.Provider]; !ok {
+			return fmt.Errorf("unsupported provider: %q", route.Provider)
+		}
+	}
+	return nil
+}
+
+func validateCLIProviderConfig(name string, p CLIProviderConfig) error {
+	switch name {
+	case ProviderClaude, ProviderCodex, ProviderOpenAI, ProviderAnthropic, ProviderReplay:
+		return fmt.Errorf("llm.providers.%s: name is reserved for the built-in provider", name)
+	}
+	if strings.TrimSpace(p.Binary) == "" {
+		return fmt.Errorf("llm.providers.%s.binary is required", name)
+	}
+	hasPrompt := false
+	for _, arg := range p.Args {
+		if strings.Contains(arg, "{{prompt}}") {
+			hasPrompt = true
+		}
+	}
+	switch p.PromptMode {
+	case PromptModeArg, PromptModeFile:
+		if !hasPrompt {
+			return fmt.Errorf("llm.providers.%s.args must reference {{prompt}} when prompt_mode is %q", name, p.PromptMode)
+		}
+	case PromptModeStdin:
+		if hasPrompt {
+			return fmt.Errorf("llm.providers.%s.args must not reference {{prompt}} when prompt_mode is \"stdin\"", name)
+		}
+	default:
+		return fmt.Errorf("llm.providers.%s.prompt_mode must be arg, stdin or file, got %q", name, p.PromptMode)
+	}
+	switch p.Output {
+	case OutputClaude, OutputCodex, OutputText:
+		if len(p.Text) > 0 || len(p.Usage) > 0 {
+			return fmt.Errorf("llm.providers.%s: text/usage rules require output = \"jsonl\"", name)
+		}
+	case OutputJSONL:
+		if len(p.Text) == 0 {
+			return fmt.Errorf("llm.providers.%s: output = \"jsonl\" requires at least one [[llm.providers.%s.text]] rule", name, name)
+		}
+		for i, rule := range p.Text {
+			if strings.TrimSpace(rule.Path) == "" {
+				return fmt.Errorf("llm.providers.%s.text[%d].path is required", name, i)
+			}
+		}
+		for i, rule := range p.Usage {
+			if strings.TrimSpace(rule.InputTokens) == "" && strings.TrimSpace(rule.OutputTokens) == "" {
+				return fmt.Errorf("llm.providers.%s.usage[%d] needs input_tokens or output_tokens", name, i)
+			}
+		}
+	default:
+		return fmt.Errorf("llm.providers.%s.output must be claude, codex, jsonl or text, got %q", name, p.Output)
+	}
+	return nil
+}
+
+func validateCostConfig(cfg CostConfig) error {
+	for label, rates := range map[string]map[string]CostRate{"models": cfg.Models, "providers": cfg.Providers} {
+		for name, r := range rates {
+			if r.Input < 0 || r.Output < 0 || r.CacheRead < 0 || r.CacheWrite < 0 {
+				return fmt.Errorf("cost.%s.%s: rates must not be negative", label, name)
+			}
+		}
+	}
+	return nil
+}
+
+func validateBudgetConfig(cfg BudgetConfig) error {
+	if cfg.Job < 0 || cfg.ProjectDaily < 0 || cfg.Monthly < 0 {
+		return fmt.Errorf("budget: limits must not be negative")
+	}
+	if cfg.NotifyAt <= 0 || cfg.NotifyAt > 1 {
+		return fmt.Errorf("budget.notify_at must be greater than 0 and at most 1, got %v", cfg.NotifyAt)
+	}
+	return nil
+}
+
 func validateNotificationsConfig(cfg NotificationsConfig) ([]string, error) {
 	if cfg.WebhookURL != "" {
 		if err := validateWebhookURL(cfg.WebhookURL); err != nil {
@@ -509,7 +1272,7 @@ func normalizeTriggers(triggers []string) ([]string, error) {
 
 func isValidTrigger(trigger string) bool {
 	switch trigger {
-	case TriggerNeedsPR, TriggerFailed, TriggerPRCreated, TriggerPRMerged:
+	case TriggerNeedsPR, TriggerFailed, TriggerPRCreated, TriggerPRMerged, TriggerBudget, TriggerPlanApproval:
 		return true
 	default:
 		return false
@@ -544,8 +1307,30 @@ func resolvePaths(cfg *Config) {
 	if cfg.LogFile != "" {
 		cfg.LogFile = absPath(cfg.BaseDir, cfg.LogFile)
 	}
+	if cfg.LLM.RecordDir != "" {
+		cfg.LLM.RecordDir = absPath(cfg.BaseDir, cfg.LLM.RecordDir)
+	}
+	if cfg.LLM.ReplayDir != "" {
+		cfg.LLM.ReplayDir = absPath(cfg.BaseDir, cfg.LLM.ReplayDir)
+	}
 	for i := range cfg.Projects {
 		p := &cfg.Projects[i]
+		for name, value := range p.Env {
+			p.Env[name] = expandHome(value)
+		}
+		for _, c := range p.TestCmds {
+			for name, value := range c.Env {
+				c.Env[name] = expandHome(value)
+			}
+		}
+		if p.Sandbox != nil {
+			for j, path := range p.Sandbox.ReadOnly {
+				p.Sandbox.ReadOnly[j] = absPath(cfg.BaseDir, expandHome(path))
+			}
+			for j, path := range p.Sandbox.ReadWrite {
+				p.Sandbox.ReadWrite[j] = absPath(cfg.BaseDir, expandHome(path))
+			}
+		}
 		if p.Prompts != nil {
 			if p.Prompts.Plan != "" {
 				p.Prompts.Plan = absPath(cfg.BaseDir, p.Prompts.Plan)
@@ -563,9 +1348,26 @@ func resolvePaths(cfg *Config) {
 				p.Prompts.ConflictResolve = absPath(cfg.BaseDir, p.Prompts.ConflictResolve)
 			}
 		}
+		for j, reviewer := range p.CodeReviewers() {
+			if reviewer.Prompt != "" {
+				p.CodeReview.Reviewers[j].Prompt = absPath(cfg.BaseDir, reviewer.Prompt)
+			}
+		}
 	}
 }
 
+// expandHome replaces a leading "~/" with the user's home directory.
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
+
 func absPath(base, path string) string {
 	if filepath.IsAbs(path) {
 		return path
@@ -582,6 +1384,134 @@ func (cfg *Config) ProjectByName(name string) (*ProjectConfig, bool) {
 	return nil, false
 }
 
+// LLMRouteForStep resolves the provider and model for a pipeline step.
+// Project settings take precedence over global ones, and within each level a
+// [steps.<step>] entry takes precedence over the level default. Settings that
+// belong to a different provider than the resolved one are ignored.
+func (cfg *Config) LLMRouteForStep(p *ProjectConfig, step string) LLMRoute {
+	levels, owners := cfg.llmLevels(p, step)
+	return resolveLLMRoute(levels, owners, owners[0])
+}
+
+// CodeReviewerRoute resolves the provider and model for one code reviewer:
+// the code_review route, with the reviewer's provider and model taking
+// precedence. A reviewer provider picks up the model and endpoint settings
+// that belong to it at any level.
+func (cfg *Config) CodeReviewerRoute(p *ProjectConfig, reviewer CodeReviewer) LLMRoute {
+	levels, owners := cfg.llmLevels(p, StepCodeReview)
+	provider := owners[0]
+	if reviewer.Provider != "" {
+		provider = reviewer.Provider
+	}
+	route := resolveLLMRoute(levels, owners, provider)
+	if reviewer.Model != "" {
+		route.Model = reviewer.Model
+	}
+	return route
+}
+
+// LLMFallbackRoutes returns the routes to try, in order, when the primary
+// route for step fails transiently. Each fallback provider picks up the model
+// and endpoint settings that belong to it at any level.
+func (cfg *Config) LLMFallbackRoutes(p *ProjectConfig, step string) []LLMRoute {
+	chain := cfg.LLM.Fallback
+	if p != nil && p.LLM != nil && p.LLM.Fallback != nil {
+		chain = p.LLM.Fallback
+	}
+	levels, owners := cfg.llmLevels(p, step)
+	seen := map[string]bool{owners[0]: true}
+	var routes []LLMRoute
+	for _, provider := range chain {
+		if seen[provider] {
+			continue
+		}
+		seen[provider] = true
+		routes = append(routes, resolveLLMRoute(levels, owners, provider))
+	}
+	return routes
+}
+
+// llmLevels lists the routing levels for step from most to least specific,
+// along with the provider each level belongs to. A level without a provider
+// belongs to the provider of the level below it.
+func (cfg *Config) llmLevels(p *ProjectConfig, step string) ([]LLMRoute, []string) {
+	var levels []LLMRoute
+	if p != nil && p.LLM != nil {
+		levels = append(levels, p.LLM.Steps[step], LLMRoute{
+			Provider:  p.LLM.Provider,
+			Model:     p.LLM.Model,
+			BaseURL:   p.LLM.BaseURL,
+			APIKeyEnv: p.LLM.APIKeyEnv,
+			Timeout:   p.LLM.Timeout,
+			MaxTurns:  p.LLM.MaxTurns,
+		})
+	}
+	levels = append(levels, cfg.LLM.Steps[step], LLMRoute{
+		Provider:  cfg.LLM.Provider,
+		Model:     cfg.LLM.Model,
+		BaseURL:   cfg.LLM.BaseURL,
+		APIKeyEnv: cfg.LLM.APIKeyEnv,
+		Timeout:   cfg.LLM.Timeout,
+		MaxTurns:  cfg.LLM.MaxTurns,
+	})
+
+	owners := make([]string, len(levels))
+	owner := ""
+	for i := len(levels) - 1; i >= 0; i-- {
+		if levels[i].Provider != "" {
+			owner = levels[i].Provider
+		}
+		owners[i] = owner
+	}
+	return levels, owners
+}
+
+func resolveLLMRoute(levels []LLMRoute, owners []string, provider string) LLMRoute {
+	route := LLMRoute{Provider: provider}
+	for i, level := range levels {
+		// Limits apply to the step whatever provider runs it.
+		if route.Timeout == "" {
+			route.Timeout = level.Timeout
+		}
+		if route.MaxTurns == 0 {
+			route.MaxTurns = level.MaxTurns
+		}
+		if owners[i] != provider {
+			continue
+		}
+		if route.Model == "" {
+			route.Model = level.Model
+		}
+		if route.BaseURL == "" {
+			route.BaseURL = level.BaseURL
+		}
+		if route.APIKeyEnv == "" {
+			route.APIKeyEnv = level.APIKeyEnv
+		}
+	}
+	baseURL, keyEnv := defaultHTTPEndpoint(route.Provider)
+	if route.BaseURL == "" {
+		route.BaseURL = baseURL
+	}
+	if route.APIKeyEnv == "" {
+		route.APIKeyEnv = keyEnv
+	}
+	return route
+}
+
+// defaultHTTPEndpoint returns the default base URL and API key env var for an
+// HTTP provider, or empty strings for CLI providers.
+func defaultHTTPEndpoint(provider string) (string, string) {
+	switch provider {
+	case ProviderOpenAI:
+		return "https://api.openai.com/v1", "OPENAI_API_KEY"
+	case ProviderAnthropic:
+		return "https://api.anthropic.com", "ANTHROPIC_API_KEY"
+	default:
+		return "", ""
+	}
+}
+
 // GitTokenForProject returns the git token for a project source.
 func (cfg *Config) GitTokenForProject(p *ProjectConfig) string {
 	if p == nil {
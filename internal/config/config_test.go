@@ -6,6 +6,7 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoadParsesProjectsAndDefaults(t *testing.T) {
@@ -358,7 +359,7 @@ func TestLoadFailsForInvalidProvider(t *testing.T) {
 
 	content := `
 [llm]
-provider = "openai"
+provider = "gemini"
 
 [[projects]]
 name = "test"
@@ -382,6 +383,1011 @@ test_cmd = "make test"
 	}
 }
 
+func TestLoadOpenAICompatibleProvider(t *testing.T) {
+	t.Parallel()
+	tmp := t.TempDir()
+	cfgPath := filepath.Join(tmp, "autopr.toml")
+
+	content := `
+[llm]
+provider = "openai"
+base_url = "http://localhost:11434/v1"
+model = "qwen2.5-coder"
+allowed_commands = ["go"]
+
+[[projects]]
+name = "test"
+repo_url = "https://github.com/org/repo.git"
+test_cmd = "make test"
+
+  [projects.github]
+  owner = "org"
+  repo = "repo"
+`
+	if err := os.WriteFile(cfgPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.LLM.BaseURL != "http://localhost:11434/v1" || cfg.LLM.Model != "qwen2.5-coder" {
+		t.Fatalf("unexpected llm config: %+v", cfg.LLM)
+	}
+	if cfg.LLM.APIKeyEnv != "OPENAI_API_KEY" {
+		t.Fatalf("expected default api_key_env OPENAI_API_KEY, got %q", cfg.LLM.APIKeyEnv)
+	}
+}
+
+func TestLoadOpenAIProviderRequiresModel(t *testing.T) {
+	t.Parallel()
+	tmp := t.TempDir()
+	cfgPath := filepath.Join(tmp, "autopr.toml")
+
+	content := `
+[llm]
+provider = "openai"
+
+[[projects]]
+name = "test"
+repo_url = "https://github.com/org/repo.git"
+test_cmd = "make test"
+
+  [projects.github]
+  owner = "org"
+  repo = "repo"
+`
+	if err := os.WriteFile(cfgPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	_, err := Load(cfgPath)
+	if err == nil || !strings.Contains(err.Error(), "llm.model is required") {
+		t.Fatalf("expected missing model error, got %v", err)
+	}
+}
+
+func TestLoadCustomCLIProvider(t *testing.T) {
+	t.Parallel()
+	tmp := t.TempDir()
+	cfgPath := filepath.Join(tmp, "autopr.toml")
+
+	content := `
+[llm]
+provider = "gemini"
+
+  [llm.providers.gemini]
+  binary = "gemini"
+  args = ["--output-format", "stream-json", "--prompt", "{{prompt}}"]
+
+  [[llm.providers.gemini.text]]
+  type = "result"
+  path = "response"
+
+  [[llm.providers.gemini.usage]]
+  type = "result"
+  input_tokens = "stats.input_tokens"
+  output_tokens = "stats.output_tokens"
+
+  [llm.providers.aider]
+  binary = "aider"
+  args = ["--yes", "--message-file", "{{prompt}}"]
+  prompt_mode = "file"
+
+[[projects]]
+name = "test"
+repo_url = "https://github.com/org/repo.git"
+test_cmd = "make test"
+
+  [projects.github]
+  owner = "org"
+  repo = "repo"
+`
+	if err := os.WriteFile(cfgPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	gemini := cfg.LLM.Providers["gemini"]
+	if gemini.PromptMode != PromptModeArg || gemini.Output != OutputJSONL {
+		t.Fatalf("expected arg/jsonl defaults, got %q/%q", gemini.PromptMode, gemini.Output)
+	}
+	if len(gemini.Text) != 1 || gemini.Text[0].Path != "response" || gemini.Usage[0].InputTokens != "stats.input_tokens" {
+		t.Fatalf("unexpected gemini rules: %+v", gemini)
+	}
+	if aider := cfg.LLM.Providers["aider"]; aider.Output != OutputText || aider.PromptMode != PromptModeFile {
+		t.Fatalf("expected aider text output in file mode, got %+v", aider)
+	}
+}
+
+func TestLoadCustomCLIProviderValidation(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name     string
+		provider string
+		wantErr  string
+	}{
+		{"missing binary", `args = ["{{prompt}}"]`, "binary is required"},
+		{"arg mode without prompt", `binary = "x"
+  args = ["--go"]`, "must reference {{prompt}}"},
+		{"stdin mode with prompt", `binary = "x"
+  prompt_mode = "stdin"
+  args = ["{{prompt}}"]`, "must not reference {{prompt}}"},
+		{"bad prompt mode", `binary = "x"
+  prompt_mode = "pipe"`, "prompt_mode must be"},
+		{"jsonl without text rule", `binary = "x"
+  args = ["{{prompt}}"]
+  output = "jsonl"`, "requires at least one"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			cfgPath := filepath.Join(t.TempDir(), "autopr.toml")
+			content := `
+[llm]
+provider = "custom"
+
+  [llm.providers.custom]
+  ` + tc.provider + `
+
+[[projects]]
+name = "test"
+repo_url = "https://github.com/org/repo.git"
+test_cmd = "make test"
+
+  [projects.github]
+  owner = "org"
+  repo = "repo"
+`
+			if err := os.WriteFile(cfgPath, []byte(content), 0o644); err != nil {
+				t.Fatalf("write config: %v", err)
+			}
+			_, err := Load(cfgPath)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLoadReplayProvider(t *testing.T) {
+	t.Parallel()
+	write := func(t *testing.T, llm string) string {
+		t.Helper()
+		cfgPath := filepath.Join(t.TempDir(), "autopr.toml")
+		content := "[llm]\n" + llm + `
+
+[[projects]]
+name = "test"
+repo_url = "https://github.com/org/repo.git"
+test_cmd = "make test"
+
+  [projects.github]
+  owner = "org"
+  repo = "repo"
+`
+		if err := os.WriteFile(cfgPath, []byte(content), 0o644); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		return cfgPath
+	}
+
+	cfgPath := write(t, `provider = "replay"
+replay_dir = "fixtures/job-1"`)
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if want := filepath.Join(filepath.Dir(cfgPath), "fixtures", "job-1"); cfg.LLM.ReplayDir != want {
+		t.Fatalf("replay_dir = %q, want %q", cfg.LLM.ReplayDir, want)
+	}
+
+	if _, err := Load(write(t, `provider = "replay"`)); err == nil || !strings.Contains(err.Error(), "llm.replay_dir is required") {
+		t.Fatalf("expected missing replay_dir error, got %v", err)
+	}
+	if _, err := Load(write(t, `provider = "claude"
+record_dir = "rec"
+replay_dir = "rec"`)); err == nil || !strings.Contains(err.Error(), "must differ") {
+		t.Fatalf("expected record/replay dir conflict, got %v", err)
+	}
+}
+
+func TestLoadProjectSandboxPaths(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	cfgPath := filepath.Join(t.TempDir(), "autopr.toml")
+	content := `
+[[projects]]
+name = "test"
+repo_url = "https://github.com/org/repo.git"
+test_cmd = "make test"
+
+  [projects.github]
+  owner = "org"
+  repo = "repo"
+
+  [projects.sandbox]
+  enabled = true
+  deny_network = true
+  read_only = ["~/go/pkg/mod", "/opt/toolchain"]
+  read_write = ["cache"]
+`
+	if err := os.WriteFile(cfgPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	p := cfg.Projects[0]
+	if !p.SandboxEnabled() || !p.Sandbox.DenyNetwork {
+		t.Fatalf("expected sandbox with network denied, got %+v", p.Sandbox)
+	}
+	if want := []string{filepath.Join(home, "go", "pkg", "mod"), "/opt/toolchain"}; !reflect.DeepEqual(p.Sandbox.ReadOnly, want) {
+		t.Fatalf("read_only = %v, want %v", p.Sandbox.ReadOnly, want)
+	}
+	if want := filepath.Join(filepath.Dir(cfgPath), "cache"); p.Sandbox.ReadWrite[0] != want {
+		t.Fatalf("read_write = %v, want %s", p.Sandbox.ReadWrite, want)
+	}
+}
+
+func TestLoadProjectPlanReview(t *testing.T) {
+	cfgPath := filepath.Join(t.TempDir(), "autopr.toml")
+	content := `
+[[projects]]
+name = "reviewed"
+repo_url = "https://github.com/org/repo.git"
+test_cmd = "make test"
+plan_approval = true
+
+  [projects.github]
+  owner = "org"
+  repo = "repo"
+
+  [projects.plan_review]
+  enabled = true
+
+[[projects]]
+name = "unreviewed"
+repo_url = "https://github.com/org/other.git"
+test_cmd = "make test"
+
+  [projects.github]
+  owner = "org"
+  repo = "other"
+`
+	if err := os.WriteFile(cfgPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	reviewed := cfg.Projects[0]
+	if !reviewed.PlanReviewEnabled() || reviewed.PlanReview.MaxReplans != DefaultMaxReplans {
+		t.Fatalf("expected plan review with default max_replans, got %+v", reviewed.PlanReview)
+	}
+	if !reviewed.PlanApproval {
+		t.Fatalf("expected plan_approval to be parsed")
+	}
+	if cfg.Projects[1].PlanReviewEnabled() || cfg.Projects[1].PlanApproval {
+		t.Fatalf("expected plan review and plan approval off by default")
+	}
+}
+
+func TestLoadProjectClarify(t *testing.T) {
+	cfgPath := filepath.Join(t.TempDir(), "autopr.toml")
+	content := `
+[[projects]]
+name = "p"
+repo_url = "https://github.com/org/repo.git"
+test_cmd = "make test"
+
+  [projects.github]
+  owner = "org"
+  repo = "repo"
+
+  [projects.clarify]
+  enabled = true
+`
+	if err := os.WriteFile(cfgPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if !cfg.Projects[0].ClarifyEnabled() || cfg.Projects[0].Clarify.Timeout != DefaultClarifyTimeout {
+		t.Fatalf("expected clarify with the default timeout, got %+v", cfg.Projects[0].Clarify)
+	}
+}
+
+func TestLoadRejectsInvalidClarify(t *testing.T) {
+	cases := map[string]string{
+		"bad timeout": `
+  [projects.github]
+  owner = "org"
+  repo = "repo"
+
+  [projects.clarify]
+  enabled = true
+  timeout = "soon"`,
+		"sentry only": `
+  [projects.sentry]
+  org = "org"
+  project = "p"
+
+  [projects.clarify]
+  enabled = true`,
+	}
+	for name, source := range cases {
+		cfgPath := filepath.Join(t.TempDir(), "autopr.toml")
+		content := `
+[[projects]]
+name = "p"
+repo_url = "https://github.com/org/repo.git"
+test_cmd = "make test"
+` + source + `
+`
+		if err := os.WriteFile(cfgPath, []byte(content), 0o644); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		if _, err := Load(cfgPath); err == nil || !strings.Contains(err.Error(), "clarify") {
+			t.Errorf("%s: expected clarify error, got %v", name, err)
+		}
+	}
+}
+
+func TestLoadProjectReviewFeedback(t *testing.T) {
+	load := func(source string) (*Config, error) {
+		cfgPath := filepath.Join(t.TempDir(), "autopr.toml")
+		content := `
+[[projects]]
+name = "p"
+repo_url = "https://github.com/org/repo.git"
+test_cmd = "make test"
+` + source + `
+`
+		if err := os.WriteFile(cfgPath, []byte(content), 0o644); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		return Load(cfgPath)
+	}
+
+	cfg, err := load(`
+  [projects.gitlab]
+  base_url = "https://gitlab.example.com"
+  project_id = "1"
+
+  [projects.review_feedback]
+  enabled = true`)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if !cfg.Projects[0].ReviewFeedbackEnabled() || cfg.Projects[0].ReviewFeedback.MaxRounds != DefaultMaxReviewRounds {
+		t.Fatalf("expected review feedback with the default max_rounds, got %+v", cfg.Projects[0].ReviewFeedback)
+	}
+
+	for name, source := range map[string]string{
+		"negative max_rounds": `
+  [projects.github]
+  owner = "org"
+  repo = "repo"
+
+  [projects.review_feedback]
+  enabled = true
+  max_rounds = -1`,
+		"sentry only": `
+  [projects.sentry]
+  org = "org"
+  project = "p"
+
+  [projects.review_feedback]
+  enabled = true`,
+	} {
+		if _, err := load(source); err == nil || !strings.Contains(err.Error(), "review_feedback") {
+			t.Errorf("%s: expected review_feedback error, got %v", name, err)
+		} else if name == "negative max_rounds" && !strings.Contains(err.Error(), "must not be negative, got -1") {
+			t.Errorf("%s: unexpected error %v", name, err)
+		}
+	}
+}
+
+func TestLoadProjectPipeline(t *testing.T) {
+	cfgPath := filepath.Join(t.TempDir(), "autopr.toml")
+	content := `
+[[projects]]
+name = "docs"
+repo_url = "https://github.com/org/docs.git"
+
+  [projects.github]
+  owner = "org"
+  repo = "docs"
+
+  [[projects.pipeline.steps]]
+  name = "plan"
+
+  [[projects.pipeline.steps]]
+  name = "implement"
+
+  [[projects.pipeline.steps]]
+  name = "lint"
+  cmd = "make lint"
+
+  [[projects.pipeline.steps]]
+  name = "spellcheck"
+  cmd = "make spellcheck"
+  on_failure = "warn"
+  timeout = "2m"
+`
+	if err := os.WriteFile(cfgPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	p := cfg.Projects[0]
+	if p.HasPipelineStep(StepCodeReview) || p.HasPipelineStep(StepTests) || p.PlanReviewEnabled() {
+		t.Fatalf("expected skipped built-in steps to be absent, got %+v", p.PipelineSteps())
+	}
+	steps := p.PipelineSteps()
+	if len(steps) != 4 || steps[2].OnFailure != OnFailureLoop || steps[3].OnFailure != OnFailureWarn {
+		t.Fatalf("unexpected steps: %+v", steps)
+	}
+	if !steps[2].IsCommand() || steps[2].ArtifactKind() != "command:lint" {
+		t.Fatalf("expected lint command step, got %+v", steps[2])
+	}
+	if steps[2].TimeoutDuration() != 0 || steps[3].TimeoutDuration() != 2*time.Minute {
+		t.Fatalf("unexpected step timeouts: %+v", steps)
+	}
+}
+
+func TestDefaultPipelineSteps(t *testing.T) {
+	p := &ProjectConfig{PlanReview: &ProjectPlanReview{Enabled: true}}
+	var names []string
+	for _, s := range p.PipelineSteps() {
+		names = append(names, s.Name)
+	}
+	if got := strings.Join(names, ","); got != "plan,plan_review,implement,code_review,tests" {
+		t.Fatalf("unexpected default steps %q", got)
+	}
+}
+
+func TestLoadRejectsInvalidPipeline(t *testing.T) {
+	cases := map[string]string{
+		"out of order":             "[[projects.pipeline.steps]]\nname = \"implement\"\n[[projects.pipeline.steps]]\nname = \"plan\"",
+		"missing implement":        "[[projects.pipeline.steps]]\nname = \"plan\"",
+		"command before implement": "[[projects.pipeline.steps]]\nname = \"plan\"\n[[projects.pipeline.steps]]\nname = \"lint\"\ncmd = \"make lint\"\n[[projects.pipeline.steps]]\nname = \"implement\"",
+		"command without cmd":      "[[projects.pipeline.steps]]\nname = \"plan\"\n[[projects.pipeline.steps]]\nname = \"implement\"\n[[projects.pipeline.steps]]\nname = \"lint\"",
+		"bad on_failure":           "[[projects.pipeline.steps]]\nname = \"plan\"\n[[projects.pipeline.steps]]\nname = \"implement\"\n[[projects.pipeline.steps]]\nname = \"lint\"\ncmd = \"make lint\"\non_failure = \"retry\"",
+		"shell operator in cmd":    "[[projects.pipeline.steps]]\nname = \"plan\"\n[[projects.pipeline.steps]]\nname = \"implement\"\n[[projects.pipeline.steps]]\nname = \"lint\"\ncmd = \"make lint && make vet\"",
+		"shell cmd":                "[[projects.pipeline.steps]]\nname = \"plan\"\n[[projects.pipeline.steps]]\nname = \"implement\"\n[[projects.pipeline.steps]]\nname = \"lint\"\ncmd = \"bash lint.sh\"",
+		"bad timeout":              "[[projects.pipeline.steps]]\nname = \"plan\"\n[[projects.pipeline.steps]]\nname = \"implement\"\n[[projects.pipeline.steps]]\nname = \"lint\"\ncmd = \"make lint\"\ntimeout = \"soon\"",
+	}
+	for name, steps := range cases {
+		cfgPath := filepath.Join(t.TempDir(), "autopr.toml")
+		content := `
+[[projects]]
+name = "p"
+repo_url = "https://github.com/org/repo.git"
+test_cmd = "make test"
+
+  [projects.github]
+  owner = "org"
+  repo = "repo"
+
+` + steps + "\n"
+		if err := os.WriteFile(cfgPath, []byte(content), 0o644); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		if _, err := Load(cfgPath); err == nil || !strings.Contains(err.Error(), "pipeline") {
+			t.Errorf("%s: expected pipeline error, got %v", name, err)
+		}
+	}
+}
+
+func TestLoadProjectCodeReviewers(t *testing.T) {
+	cfgPath := filepath.Join(t.TempDir(), "autopr.toml")
+	content := `
+[llm]
+provider = "claude"
+model = "sonnet"
+
+[[projects]]
+name = "payments"
+repo_url = "https://github.com/org/payments.git"
+test_cmd = "make test"
+
+  [projects.github]
+  owner = "org"
+  repo = "payments"
+
+  [projects.code_review]
+
+  [[projects.code_review.reviewers]]
+  name = "security"
+  persona = "security"
+  prompt = "prompts/security.md"
+
+  [[projects.code_review.reviewers]]
+  name = "second-opinion"
+  provider = "codex"
+  model = "gpt-5"
+`
+	if err := os.WriteFile(cfgPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	p := &cfg.Projects[0]
+	if p.CodeReview.Policy != ReviewPolicyAll {
+		t.Fatalf("expected default policy %q, got %q", ReviewPolicyAll, p.CodeReview.Policy)
+	}
+	reviewers := p.CodeReviewers()
+	if len(reviewers) != 2 || reviewers[0].Prompt != filepath.Join(filepath.Dir(cfgPath), "prompts/security.md") {
+		t.Fatalf("unexpected reviewers: %+v", reviewers)
+	}
+	if got := cfg.CodeReviewerRoute(p, reviewers[0]); got.Provider != "claude" || got.Model != "sonnet" {
+		t.Fatalf("expected persona reviewer on the code_review route, got %+v", got)
+	}
+	if got := cfg.CodeReviewerRoute(p, reviewers[1]); got.Provider != "codex" || got.Model != "gpt-5" {
+		t.Fatalf("expected reviewer provider override, got %+v", got)
+	}
+}
+
+func TestLoadRejectsInvalidCodeReview(t *testing.T) {
+	cases := map[string]string{
+		"bad policy":         "policy = \"unanimous\"\n[[projects.code_review.reviewers]]\nname = \"a\"",
+		"unnamed reviewer":   "[[projects.code_review.reviewers]]\npersona = \"tests\"",
+		"duplicate reviewer": "[[projects.code_review.reviewers]]\nname = \"a\"\n[[projects.code_review.reviewers]]\nname = \"a\"",
+		"unknown persona":    "[[projects.code_review.reviewers]]\nname = \"a\"\npersona = \"style\"",
+		"unknown provider":   "[[projects.code_review.reviewers]]\nname = \"a\"\nprovider = \"nope\"",
+	}
+	for name, review := range cases {
+		cfgPath := filepath.Join(t.TempDir(), "autopr.toml")
+		content := `
+[[projects]]
+name = "p"
+repo_url = "https://github.com/org/repo.git"
+test_cmd = "make test"
+
+  [projects.github]
+  owner = "org"
+  repo = "repo"
+
+  [projects.code_review]
+` + review + "\n"
+		if err := os.WriteFile(cfgPath, []byte(content), 0o644); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		if _, err := Load(cfgPath); err == nil || !strings.Contains(err.Error(), "review") {
+			t.Errorf("%s: expected code review error, got %v", name, err)
+		}
+	}
+}
+
+func TestLoadProjectTestCommands(t *testing.T) {
+	cfgPath := filepath.Join(t.TempDir(), "autopr.toml")
+	content := `
+[[projects]]
+name = "api"
+repo_url = "https://github.com/org/api.git"
+test_cmds = [
+  ["go", "vet", "./..."],
+  { name = "integration", argv = ["go", "test", "./..."], dir = "svc/", timeout = "10m", env = { GOFLAGS = "-count=1" }, advisory = true },
+]
+
+  [projects.github]
+  owner = "org"
+  repo = "api"
+`
+	if err := os.WriteFile(cfgPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	cmds := cfg.Projects[0].TestCmds
+	if len(cmds) != 2 {
+		t.Fatalf("expected 2 test commands, got %+v", cmds)
+	}
+	if cmds[0].Name != "go vet ./..." || cmds[0].Advisory || cmds[0].TimeoutDuration() != 0 {
+		t.Fatalf("unexpected argv-only command: %+v", cmds[0])
+	}
+	want := TestCommand{Name: "integration", Argv: []string{"go", "test", "./..."}, Dir: "svc", Timeout: "10m", Env: map[string]string{"GOFLAGS": "-count=1"}, Advisory: true}
+	if !reflect.DeepEqual(cmds[1], want) {
+		t.Fatalf("unexpected command:\n got %+v\nwant %+v", cmds[1], want)
+	}
+	if got := cmds[1].String(); got != "svc$ go test ./..." {
+		t.Fatalf("String() = %q", got)
+	}
+}
+
+func TestLoadRejectsInvalidTestCommands(t *testing.T) {
+	cases := map[string]string{
+		"both forms":   `test_cmd = "make test"` + "\ntest_cmds = [[\"make\"]]",
+		"empty argv":   `test_cmds = [[]]`,
+		"duplicate":    `test_cmds = [["make"], ["make"]]`,
+		"bad timeout":  `test_cmds = [{ argv = ["make"], timeout = "soon" }]`,
+		"escaping dir": `test_cmds = [{ argv = ["make"], dir = "../other" }]`,
+		"unknown key":  `test_cmds = [{ argv = ["make"], shell = true }]`,
+		"non-string":   `test_cmds = [["make", 1]]`,
+	}
+	for name, cmds := range cases {
+		cfgPath := filepath.Join(t.TempDir(), "autopr.toml")
+		content := `
+[[projects]]
+name = "p"
+repo_url = "https://github.com/org/repo.git"
+` + cmds + `
+
+  [projects.github]
+  owner = "org"
+  repo = "repo"
+`
+		if err := os.WriteFile(cfgPath, []byte(content), 0o644); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		if _, err := Load(cfgPath); err == nil || !strings.Contains(err.Error(), "test_cmd") {
+			t.Errorf("%s: expected test_cmds error, got %v", name, err)
+		}
+	}
+}
+
+func TestLoadProjectDiffPolicy(t *testing.T) {
+	cfgPath := filepath.Join(t.TempDir(), "autopr.toml")
+	content := `
+[[projects]]
+name = "test"
+repo_url = "https://github.com/org/repo.git"
+test_cmd = "make test"
+
+  [projects.github]
+  owner = "org"
+  repo = "repo"
+
+  [projects.diff_policy]
+  max_files = 20
+  deny = [".github/workflows/", "*.lock"]
+  codeowners = ["@org/security"]
+`
+	if err := os.WriteFile(cfgPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	policy := cfg.Projects[0].DiffPolicy
+	if policy == nil || policy.MaxFiles != 20 || len(policy.Deny) != 2 || policy.CodeOwners[0] != "@org/security" {
+		t.Fatalf("unexpected diff policy: %+v", policy)
+	}
+	if policy.OnViolation != OnFailureLoop {
+		t.Fatalf("expected on_violation to default to loop, got %q", policy.OnViolation)
+	}
+}
+
+func TestLoadRejectsInvalidDiffPolicy(t *testing.T) {
+	cases := map[string]string{
+		"negative limit": `max_lines = -1`,
+		"bad pattern":    `deny = ["[a-"]`,
+		"empty pattern":  `allow = ["/"]`,
+		"empty owner":    `codeowners = [" "]`,
+		"bad action":     `on_violation = "ignore"`,
+	}
+	for name, policy := range cases {
+		cfgPath := filepath.Join(t.TempDir(), "autopr.toml")
+		content := `
+[[projects]]
+name = "p"
+repo_url = "https://github.com/org/repo.git"
+test_cmd = "make test"
+
+  [projects.github]
+  owner = "org"
+  repo = "repo"
+
+  [projects.diff_policy]
+  ` + policy + `
+`
+		if err := os.WriteFile(cfgPath, []byte(content), 0o644); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		if _, err := Load(cfgPath); err == nil || !strings.Contains(err.Error(), "diff_policy") {
+			t.Errorf("%s: expected diff_policy error, got %v", name, err)
+		}
+	}
+}
+
+func TestLoadProjectEnv(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	cfgPath := filepath.Join(t.TempDir(), "autopr.toml")
+	content := `
+[[projects]]
+name = "test"
+repo_url = "https://github.com/org/repo.git"
+test_cmd = "make test"
+
+  [projects.github]
+  owner = "org"
+  repo = "repo"
+
+  [projects.env]
+  GOMODCACHE = "~/go/pkg/mod"
+  GOFLAGS = "-mod=mod"
+`
+	if err := os.WriteFile(cfgPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	want := map[string]string{"GOMODCACHE": filepath.Join(home, "go", "pkg", "mod"), "GOFLAGS": "-mod=mod"}
+	if !reflect.DeepEqual(cfg.Projects[0].Env, want) {
+		t.Fatalf("env = %v, want %v", cfg.Projects[0].Env, want)
+	}
+
+	bad := strings.Replace(content, `GOFLAGS = "-mod=mod"`, `"BAD NAME" = "x"`, 1)
+	if err := os.WriteFile(cfgPath, []byte(bad), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if _, err := Load(cfgPath); err == nil || !strings.Contains(err.Error(), "invalid env variable name") {
+		t.Fatalf("expected invalid env name error, got %v", err)
+	}
+}
+
+func TestLoadCostConfig(t *testing.T) {
+	t.Parallel()
+	cfgPath := filepath.Join(t.TempDir(), "autopr.toml")
+	content := `
+[cost.models."claude-sonnet-4-5"]
+input = 3.0
+output = 15.0
+cache_read = 0.3
+cache_write = 3.75
+
+[cost.providers.ollama]
+input = 0.0
+output = 0.0
+
+[[projects]]
+name = "test"
+repo_url = "https://github.com/org/repo.git"
+test_cmd = "make test"
+
+  [projects.github]
+  owner = "org"
+  repo = "repo"
+`
+	if err := os.WriteFile(cfgPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	want := CostRate{Input: 3, Output: 15, CacheRead: 0.3, CacheWrite: 3.75}
+	if got := cfg.Cost.Models["claude-sonnet-4-5"]; got != want {
+		t.Fatalf("model rate = %+v, want %+v", got, want)
+	}
+	if _, ok := cfg.Cost.Providers["ollama"]; !ok {
+		t.Fatalf("expected ollama provider rate, got %+v", cfg.Cost.Providers)
+	}
+
+	bad := strings.Replace(content, "cache_read = 0.3", "cache_read = -1", 1)
+	if err := os.WriteFile(cfgPath, []byte(bad), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if _, err := Load(cfgPath); err == nil || !strings.Contains(err.Error(), "must not be negative") {
+		t.Fatalf("expected negative rate error, got %v", err)
+	}
+}
+
+func TestLoadBudgetConfig(t *testing.T) {
+	t.Parallel()
+	cfgPath := filepath.Join(t.TempDir(), "autopr.toml")
+	content := `
+[budget]
+job = 5.0
+project_daily = 20.0
+monthly = 300.0
+
+[[projects]]
+name = "test"
+repo_url = "https://github.com/org/repo.git"
+test_cmd = "make test"
+
+  [projects.github]
+  owner = "org"
+  repo = "repo"
+`
+	if err := os.WriteFile(cfgPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	want := BudgetConfig{Job: 5, ProjectDaily: 20, Monthly: 300, NotifyAt: 0.8}
+	if cfg.Budget != want {
+		t.Fatalf("budget = %+v, want %+v", cfg.Budget, want)
+	}
+
+	bad := strings.Replace(content, "monthly = 300.0", "monthly = 300.0\nnotify_at = 1.5", 1)
+	if err := os.WriteFile(cfgPath, []byte(bad), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if _, err := Load(cfgPath); err == nil || !strings.Contains(err.Error(), "budget.notify_at") {
+		t.Fatalf("expected notify_at error, got %v", err)
+	}
+}
+
+func TestLLMRouteForStep(t *testing.T) {
+	t.Parallel()
+	tmp := t.TempDir()
+	cfgPath := filepath.Join(tmp, "autopr.toml")
+
+	content := `
+[llm]
+provider = "claude"
+model = "sonnet"
+
+  [llm.steps.plan]
+  model = "haiku"
+
+  [llm.steps.code_review]
+  provider = "openai"
+  model = "gpt-5"
+
+[[projects]]
+name = "routed"
+repo_url = "https://github.com/org/repo.git"
+test_cmd = "make test"
+
+  [projects.github]
+  owner = "org"
+  repo = "repo"
+
+  [projects.llm]
+  provider = "codex"
+
+  [projects.llm.steps.implement]
+  model = "gpt-5-codex"
+  timeout = "2h"
+  max_turns = 120
+
+[[projects]]
+name = "plain"
+repo_url = "https://github.com/org/other.git"
+test_cmd = "make test"
+
+  [projects.github]
+  owner = "org"
+  repo = "other"
+`
+	if err := os.WriteFile(cfgPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	cfg, err := Load(cfgPath)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	plain, _ := cfg.ProjectByName("plain")
+	routed, _ := cfg.ProjectByName("routed")
+	cases := []struct {
+		project *ProjectConfig
+		step    string
+		want    LLMRoute
+	}{
+		{plain, "plan", LLMRoute{Provider: "claude", Model: "haiku"}},
+		{plain, "implement", LLMRoute{Provider: "claude", Model: "sonnet"}},
+		{plain, "code_review", LLMRoute{Provider: "openai", Model: "gpt-5", BaseURL: "https://api.openai.com/v1", APIKeyEnv: "OPENAI_API_KEY"}},
+		// Project provider wins; global claude model settings no longer apply.
+		{routed, "plan", LLMRoute{Provider: "codex"}},
+		{routed, "implement", LLMRoute{Provider: "codex", Model: "gpt-5-codex"}},
+		{routed, "code_review", LLMRoute{Provider: "codex"}},
+	}
+	for _, tc := range cases {
+		got := cfg.LLMRouteForStep(tc.project, tc.step)
+		got.Timeout, got.MaxTurns = "", 0
+		if got != tc.want {
+			t.Fatalf("%s/%s: expected %+v, got %+v", tc.project.Name, tc.step, tc.want, got)
+		}
+	}
+
+	if got := cfg.LLMRouteForStep(routed, "implement"); got.TimeoutDuration() != 2*time.Hour || got.MaxTurns != 120 {
+		t.Fatalf("expected project step limits, got %+v", got)
+	}
+	if got := cfg.LLMRouteForStep(plain, "implement"); got.Timeout != "60m" || got.MaxTurns != 50 {
+		t.Fatalf("expected default limits, got %+v", got)
+	}
+}
+
+func TestLLMFallbackRoutes(t *testing.T) {
+	t.Parallel()
+	cfg := &Config{LLM: LLMConfig{
+		Provider: "claude",
+		Model:    "sonnet",
+		Fallback: []string{"claude", "codex", "anthropic"},
+		Steps: map[string]LLMRoute{
+			"plan": {Provider: "anthropic", Model: "claude-haiku"},
+		},
+	}}
+	// plan is routed to anthropic, so anthropic is dropped from its chain
+	// while claude keeps the global model.
+	routes := cfg.LLMFallbackRoutes(nil, "plan")
+	want := []LLMRoute{{Provider: "claude", Model: "sonnet"}, {Provider: "codex"}}
+	if len(routes) != len(want) || routes[0] != want[0] || routes[1] != want[1] {
+		t.Fatalf("expected %+v, got %+v", want, routes)
+	}
+	implement := cfg.LLMFallbackRoutes(nil, "implement")
+	if len(implement) != 2 || implement[1].Provider != "anthropic" || implement[1].Model != "" {
+		t.Fatalf("expected codex and anthropic fallbacks for implement, got %+v", implement)
+	}
+
+	project := &ProjectConfig{Name: "p", LLM: &ProjectLLM{Fallback: []string{}}}
+	if got := cfg.LLMFallbackRoutes(project, "implement"); len(got) != 0 {
+		t.Fatalf("expected project to disable fallback, got %+v", got)
+	}
+}
+
+func TestLoadRejectsInvalidLLMStepRoutes(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name    string
+		llm     string
+		wantErr string
+	}{
+		{"unknown step", `
+  [llm.steps.deploy]
+  model = "x"`, "unknown step"},
+		{"http route without model", `
+  [llm.steps.plan]
+  provider = "anthropic"`, "model is required"},
+		{"unknown provider", `
+  [llm.steps.plan]
+  provider = "gemini"`, "unsupported provider"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			cfgPath := filepath.Join(t.TempDir(), "autopr.toml")
+			content := `
+[llm]
+provider = "claude"
+` + tc.llm + `
+
+[[projects]]
+name = "test"
+repo_url = "https://github.com/org/repo.git"
+test_cmd = "make test"
+
+  [projects.github]
+  owner = "org"
+  repo = "repo"
+`
+			if err := os.WriteFile(cfgPath, []byte(content), 0o644); err != nil {
+				t.Fatalf("write config: %v", err)
+			}
+			_, err := Load(cfgPath)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
 func TestLoadNormalizesGitHubIncludeLabels(t *testing.T) {
 	t.Parallel()
 	tmp := t.TempDir()
@@ -552,6 +1558,8 @@ test_cmd = "make test"
 		TriggerFailed,
 		TriggerPRCreated,
 		TriggerPRMerged,
+		TriggerBudget,
+		TriggerPlanApproval,
 	}
 	if !reflect.DeepEqual(cfg.Notifications.Triggers, want) {
 		t.Fatalf("expected default triggers %v, got %v", want, cfg.Notifications.Triggers)
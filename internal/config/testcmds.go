@@ -0,0 +1,330 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// TestCommand is one test_cmds entry: an argv run without a shell. A bare
+// array (["go", "test", "./..."]) is shorthand for {argv = [...]}.
+type TestCommand struct {
+	Name     string            `toml:"name"` // defaults to the argv joined by spaces
+	Argv     []string          `toml:"argv"`
+	Timeout  string            `toml:"timeout"`  // e.g. "10m"; unset means no limit
+	Dir      string            `toml:"dir"`      // working directory inside the worktree
+	Env      map[string]string `toml:"env"`      // added to the project env; "~/" expands
+	Advisory bool              `toml:"advisory"` // failures are recorded but do not fail the step
+}
+
+// UnmarshalTOML accepts either an argv array or a table.
+func (c *TestCommand) UnmarshalTOML(data any) error {
+	switch v := data.(type) {
+	case []any:
+		argv, err := tomlStrings(v)
+		if err != nil {
+			return fmt.Errorf("test_cmds: %w", err)
+		}
+		*c = TestCommand{Argv: argv}
+		return nil
+	case map[string]any:
+		*c = TestCommand{}
+		for key, value := range v {
+			var ok bool
+			switch key {
+			case "name":
+				c.Name, ok = value.(string)
+			case "timeout":
+				c.Timeout, ok = value.(string)
+			case "dir":
+				c.Dir, ok = value.(string)
+			case "advisory":
+				c.Advisory, ok = value.(bool)
+			case "argv":
+				var list []any
+				if list, ok = value.([]any); ok {
+					var err error
+					if c.Argv, err = tomlStrings(list); err != nil {
+						return fmt.Errorf("test_cmds argv: %w", err)
+					}
+				}
+			case "env":
+				var table map[string]any
+				if table, ok = value.(map[string]any); ok {
+					c.Env = make(map[string]string, len(table))
+					for name, val := range table {
+						s, isString := val.(string)
+						if !isString {
+							return fmt.Errorf("test_cmds env %q: expected a string", name)
+						}
+						c.Env[name] = s
+					}
+				}
+			default:
+				return fmt.Errorf("test_cmds: unknown key %q", key)
+			}
+			if !ok {
+				return fmt.Errorf("test_cmds %s: unexpected type %T", key, value)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("test_cmds: expected an argv array or a table, got %T", data)
+	}
+}
+
+func tomlStrings(values []any) ([]string, error) {
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected strings, got %T", v)
+		}
+		out = append(out, s)
+	}
+	return out, nil
+}
+
+// String renders the command for logs and artifacts, e.g.
+// "backend$ go test ./...".
+func (c TestCommand) String() string {
+	return c.Dir + "$ " + strings.Join(c.Argv, " ")
+}
+
+// TimeoutDuration returns the parsed timeout, or 0 when unset or invalid.
+func (c TestCommand) TimeoutDuration() time.Duration {
+	d, err := time.ParseDuration(c.Timeout)
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+// ArtifactKind returns the artifact kind the command's output is stored as.
+func (c TestCommand) ArtifactKind() string {
+	return "test_output:" + c.Name
+}
+
+func validateTestCommands(cmds []TestCommand) error {
+	seen := make(map[string]bool)
+	for i := range cmds {
+		c := &cmds[i]
+		if len(c.Argv) == 0 || strings.TrimSpace(c.Argv[0]) == "" {
+			return fmt.Errorf("test_cmds[%d]: argv is required", i)
+		}
+		c.Name = strings.TrimSpace(c.Name)
+		if c.Name == "" {
+			c.Name = strings.Join(c.Argv, " ")
+		}
+		if seen[c.Name] {
+			return fmt.Errorf("test_cmds: duplicate command %q; set a name", c.Name)
+		}
+		seen[c.Name] = true
+		if c.Timeout != "" {
+			if d, err := time.ParseDuration(c.Timeout); err != nil || d <= 0 {
+				return fmt.Errorf("test_cmds %q: invalid timeout %q: must be a positive duration", c.Name, c.Timeout)
+			}
+		}
+		if c.Dir != "" {
+			if !filepath.IsLocal(c.Dir) {
+				return fmt.Errorf("test_cmds %q: dir must be inside the repository, got %q", c.Name, c.Dir)
+			}
+			c.Dir = filepath.Clean(c.Dir)
+		}
+		for name := range c.Env {
+			if name == "" || strings.ContainsAny(name, "= \t\n") {
+				return fmt.Errorf("test_cmds %q: invalid env variable name %q", c.Name, name)
+			}
+		}
+	}
+	return nil
+}
+
+// ParseTestCommand splits a test_cmd or command step cmd into an argv without
+// a shell. Quoting and escapes work as in sh; shell operators outside quotes
+// are rejected.
+func ParseTestCommand(cmd string) ([]string, error) {
+	var args []string
+	var token strings.Builder
+	tokenStarted := false
+	inSingleQuote := false
+	inDoubleQuote := false
+	escaped := false
+
+	flush := func() {
+		if !tokenStarted {
+			return
+		}
+		args = append(args, token.String())
+		token.Reset()
+		tokenStarted = false
+	}
+
+	for i := 0; i < len(cmd); i++ {
+		ch := cmd[i]
+
+		if escaped {
+			token.WriteByte(ch)
+			tokenStarted = true
+			escaped = false
+			continue
+		}
+
+		if !inSingleQuote && !inDoubleQuote {
+			if reason, unsafe := unsafeTestCommandConstruct(cmd, i); unsafe {
+				return nil, fmt.Errorf("invalid test_cmd: %s", reason)
+			}
+		}
+
+		switch {
+		case inSingleQuote:
+			switch ch {
+			case '\'':
+				inSingleQuote = false
+			default:
+				token.WriteByte(ch)
+				tokenStarted = true
+			}
+		case inDoubleQuote:
+			switch ch {
+			case '"':
+				inDoubleQuote = false
+			case '\\':
+				tokenStarted = true
+				escaped = true
+			default:
+				token.WriteByte(ch)
+				tokenStarted = true
+			}
+		default:
+			switch ch {
+			case ' ', '\t', '\n', '\r', '\f', '\v':
+				flush()
+			case '\'':
+				tokenStarted = true
+				inSingleQuote = true
+			case '"':
+				tokenStarted = true
+				inDoubleQuote = true
+			case '\\':
+				tokenStarted = true
+				escaped = true
+			default:
+				token.WriteByte(ch)
+				tokenStarted = true
+			}
+		}
+	}
+
+	if escaped {
+		return nil, fmt.Errorf("invalid test_cmd: trailing escape")
+	}
+	if inSingleQuote || inDoubleQuote {
+		return nil, fmt.Errorf("invalid test_cmd: unterminated quote")
+	}
+
+	flush()
+	if len(args) == 0 {
+		return nil, fmt.Errorf("invalid test_cmd: empty command")
+	}
+	return args, nil
+}
+
+func unsafeTestCommandConstruct(cmd string, i int) (string, bool) {
+	ch := cmd[i]
+	switch ch {
+	case ';':
+		return "disallowed token ';'", true
+	case '|':
+		if i+1 < len(cmd) && cmd[i+1] == '|' {
+			return "disallowed token '||'", true
+		}
+		return "disallowed token '|'", true
+	case '&':
+		if i+1 < len(cmd) && cmd[i+1] == '&' {
+			return "disallowed token '&&'", true
+		}
+		return "disallowed token '&'", true
+	case '<':
+		return "disallowed token '<'", true
+	case '>':
+		return "disallowed token '>'", true
+	case '`':
+		return "disallowed token '`'", true
+	case '$':
+		if i+1 < len(cmd) && cmd[i+1] == '(' {
+			return "disallowed token '$('", true
+		}
+		return "disallowed token '$'", true
+	}
+	return "", false
+}
+
+var disallowedTestCommandExecutables = map[string]struct{}{
+	"sh":         {},
+	"bash":       {},
+	"zsh":        {},
+	"dash":       {},
+	"ksh":        {},
+	"csh":        {},
+	"tcsh":       {},
+	"fish":       {},
+	"cmd":        {},
+	"powershell": {},
+	"pwsh":       {},
+}
+
+// ValidateTestCommandArgs rejects argvs that would run a shell, directly or
+// through env or busybox.
+func ValidateTestCommandArgs(args []string) error {
+	base := normalizeExecutableName(args[0])
+	if _, disallowed := disallowedTestCommandExecutables[base]; disallowed {
+		return fmt.Errorf("invalid test_cmd: disallowed executable %q", base)
+	}
+	if base == "env" {
+		for i := firstEnvCommandIndex(args); i < len(args); i++ {
+			next := normalizeExecutableName(args[i])
+			if _, disallowed := disallowedTestCommandExecutables[next]; disallowed {
+				return fmt.Errorf("invalid test_cmd: disallowed executable %q", next)
+			}
+			break
+		}
+	}
+	if base == "busybox" && len(args) > 1 {
+		next := normalizeExecutableName(args[1])
+		if _, disallowed := disallowedTestCommandExecutables[next]; disallowed {
+			return fmt.Errorf("invalid test_cmd: disallowed executable %q", next)
+		}
+	}
+	return nil
+}
+
+func normalizeExecutableName(execName string) string {
+	base := strings.ToLower(filepath.Base(execName))
+	return strings.TrimSuffix(base, ".exe")
+}
+
+func firstEnvCommandIndex(args []string) int {
+	i := 1
+	for i < len(args) {
+		arg := args[i]
+		if arg == "--" {
+			i++
+			break
+		}
+		if strings.Contains(arg, "=") {
+			i++
+			continue
+		}
+		if !strings.HasPrefix(arg, "-") {
+			break
+		}
+		if arg == "-u" {
+			i += 2
+			continue
+		}
+		i++
+	}
+	return i
+}
@@ -0,0 +1,168 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTestCommandSimple(t *testing.T) {
+	t.Parallel()
+
+	got, err := ParseTestCommand("go test ./...")
+	if err != nil {
+		t.Fatalf("ParseTestCommand returned error: %v", err)
+	}
+	want := []string{"go", "test", "./..."}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected argv: got=%v want=%v", got, want)
+	}
+}
+
+func TestParseTestCommandQuotedArg(t *testing.T) {
+	t.Parallel()
+
+	got, err := ParseTestCommand(`go test -run "Test Foo"`)
+	if err != nil {
+		t.Fatalf("ParseTestCommand returned error: %v", err)
+	}
+	want := []string{"go", "test", "-run", "Test Foo"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected argv: got=%v want=%v", got, want)
+	}
+}
+
+func TestParseTestCommandSingleQuotedArg(t *testing.T) {
+	t.Parallel()
+
+	got, err := ParseTestCommand("go test -run 'Test Foo'")
+	if err != nil {
+		t.Fatalf("ParseTestCommand returned error: %v", err)
+	}
+	want := []string{"go", "test", "-run", "Test Foo"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected argv: got=%v want=%v", got, want)
+	}
+}
+
+func TestParseTestCommandEscapedWhitespace(t *testing.T) {
+	t.Parallel()
+
+	got, err := ParseTestCommand(`go test -run Test\ Foo`)
+	if err != nil {
+		t.Fatalf("ParseTestCommand returned error: %v", err)
+	}
+	want := []string{"go", "test", "-run", "Test Foo"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected argv: got=%v want=%v", got, want)
+	}
+}
+
+func TestParseTestCommandMixedQuoting(t *testing.T) {
+	t.Parallel()
+
+	got, err := ParseTestCommand(`go test -run "Test 'Foo'"`)
+	if err != nil {
+		t.Fatalf("ParseTestCommand returned error: %v", err)
+	}
+	want := []string{"go", "test", "-run", "Test 'Foo'"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected argv: got=%v want=%v", got, want)
+	}
+}
+
+func TestParseTestCommandEmptyQuotedArg(t *testing.T) {
+	t.Parallel()
+
+	got, err := ParseTestCommand(`go test -run ""`)
+	if err != nil {
+		t.Fatalf("ParseTestCommand returned error: %v", err)
+	}
+	want := []string{"go", "test", "-run", ""}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected argv: got=%v want=%v", got, want)
+	}
+}
+
+func TestParseTestCommandAllowsUnsafeTextInsideQuotes(t *testing.T) {
+	t.Parallel()
+
+	got, err := ParseTestCommand(`go test -run "A && B; C | D $(echo x)"`)
+	if err != nil {
+		t.Fatalf("ParseTestCommand returned error: %v", err)
+	}
+	want := []string{"go", "test", "-run", "A && B; C | D $(echo x)"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected argv: got=%v want=%v", got, want)
+	}
+}
+
+func TestParseTestCommandRejectsUnsafeConstructs(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		cmd        string
+		wantReason string
+	}{
+		{name: "and and", cmd: "go test && echo bad", wantReason: "'&&'"},
+		{name: "semicolon", cmd: "go test; echo bad", wantReason: "';'"},
+		{name: "backticks", cmd: "go test `echo bad`", wantReason: "'`'"},
+		{name: "subshell", cmd: "go test $(echo bad)", wantReason: "'$('"},
+		{name: "pipe", cmd: "go test | cat", wantReason: "'|'"},
+		{name: "or or", cmd: "go test || echo bad", wantReason: "'||'"},
+		{name: "ampersand", cmd: "go test & cat", wantReason: "'&'"},
+		{name: "input redirect", cmd: "go test < file", wantReason: "'<'"},
+		{name: "output redirect", cmd: "go test > file", wantReason: "'>'"},
+		{name: "bare dollar", cmd: "go test $TEST_DB", wantReason: "'$'"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := ParseTestCommand(tc.cmd)
+			if err == nil {
+				t.Fatalf("expected parse error for %q", tc.cmd)
+			}
+			if !strings.Contains(err.Error(), "invalid test_cmd") {
+				t.Fatalf("expected invalid test_cmd prefix, got: %v", err)
+			}
+			if !strings.Contains(err.Error(), tc.wantReason) {
+				t.Fatalf("expected error to include %s, got: %v", tc.wantReason, err)
+			}
+		})
+	}
+}
+
+func TestParseTestCommandRejectsMalformedSyntax(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		cmd        string
+		wantReason string
+	}{
+		{name: "unterminated single quote", cmd: "go test -run 'Test Foo", wantReason: "unterminated quote"},
+		{name: "unterminated double quote", cmd: `go test -run "Test Foo`, wantReason: "unterminated quote"},
+		{name: "trailing escape", cmd: `go test \`, wantReason: "trailing escape"},
+		{name: "whitespace only", cmd: "   ", wantReason: "empty command"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := ParseTestCommand(tc.cmd)
+			if err == nil {
+				t.Fatalf("expected parse error for %q", tc.cmd)
+			}
+			if !strings.Contains(err.Error(), "invalid test_cmd") {
+				t.Fatalf("expected invalid test_cmd prefix, got: %v", err)
+			}
+			if !strings.Contains(err.Error(), tc.wantReason) {
+				t.Fatalf("expected error to include %q, got: %v", tc.wantReason, err)
+			}
+		})
+	}
+}
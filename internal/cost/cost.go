@@ -1,36 +1,118 @@
 package cost
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+
+	"autopr/internal/config"
+)
 
 // Rate holds per-1M-token pricing in USD.
 type Rate struct {
-	Input  float64 // USD per 1M input tokens
-	Output float64 // USD per 1M output tokens
+	Input      float64 // USD per 1M uncached input tokens
+	Output     float64 // USD per 1M output tokens
+	CacheRead  float64 // USD per 1M input tokens read from the prompt cache
+	CacheWrite float64 // USD per 1M input tokens written to the prompt cache
+}
+
+// Usage holds the token counts of one or more sessions. Input excludes
+// cached tokens, which are counted in CacheRead and CacheWrite.
+type Usage struct {
+	Input      int
+	Output     int
+	CacheRead  int
+	CacheWrite int
 }
 
-// DefaultRates contains hardcoded per-provider pricing.
+// DefaultRates contains per-provider pricing used when a session's model has
+// no rate of its own.
 var DefaultRates = map[string]Rate{
-	"claude": {Input: 3.00, Output: 15.00},
-	"codex":  {Input: 3.00, Output: 12.00},
+	"claude": {Input: 3.00, Output: 15.00, CacheRead: 0.30, CacheWrite: 3.75},
+	"codex":  {Input: 3.00, Output: 12.00, CacheRead: 0.30},
+}
+
+// DefaultModelRates contains pricing for well-known models. Model names match
+// exactly or by prefix, so dated snapshots such as "claude-sonnet-4-5-20250929"
+// use the "claude-sonnet-4-5" rate.
+var DefaultModelRates = map[string]Rate{
+	"claude-opus-4":    {Input: 15.00, Output: 75.00, CacheRead: 1.50, CacheWrite: 18.75},
+	"claude-opus-4-5":  {Input: 5.00, Output: 25.00, CacheRead: 0.50, CacheWrite: 6.25},
+	"claude-sonnet-4":  {Input: 3.00, Output: 15.00, CacheRead: 0.30, CacheWrite: 3.75},
+	"claude-haiku-4-5": {Input: 1.00, Output: 5.00, CacheRead: 0.10, CacheWrite: 1.25},
+	"claude-3-5-haiku": {Input: 0.80, Output: 4.00, CacheRead: 0.08, CacheWrite: 1.00},
+	"gpt-5":            {Input: 1.25, Output: 10.00, CacheRead: 0.125},
+	"gpt-5-mini":       {Input: 0.25, Output: 2.00, CacheRead: 0.025},
+	"gpt-4.1":          {Input: 2.00, Output: 8.00, CacheRead: 0.50},
+}
+
+// Table resolves rates from the [cost] config on top of the defaults.
+type Table struct {
+	models    map[string]Rate
+	providers map[string]Rate
+}
+
+// NewTable returns the default rates overridden by cfg.
+func NewTable(cfg config.CostConfig) *Table {
+	t := &Table{models: make(map[string]Rate), providers: make(map[string]Rate)}
+	for name, rate := range DefaultModelRates {
+		t.models[name] = rate
+	}
+	for name, rate := range DefaultRates {
+		t.providers[name] = rate
+	}
+	for name, rate := range cfg.Models {
+		t.models[name] = rateFromConfig(rate)
+	}
+	for name, rate := range cfg.Providers {
+		t.providers[name] = rateFromConfig(rate)
+	}
+	return t
+}
+
+func rateFromConfig(r config.CostRate) Rate {
+	return Rate{Input: r.Input, Output: r.Output, CacheRead: r.CacheRead, CacheWrite: r.CacheWrite}
 }
 
-// Calculate returns the estimated cost in USD for the given token counts.
+// Lookup returns the rate for model, falling back to the provider's rate. The
+// longest model name that is a prefix of model wins.
+func (t *Table) Lookup(provider, model string) (Rate, bool) {
+	if rate, ok := t.models[model]; ok && model != "" {
+		return rate, true
+	}
+	best := ""
+	for name := range t.models {
+		if len(name) > len(best) && strings.HasPrefix(model, name) {
+			best = name
+		}
+	}
+	if best != "" {
+		return t.models[best], true
+	}
+	rate, ok := t.providers[provider]
+	return rate, ok
+}
+
+// defaultTable prices with the built-in rates only.
+var defaultTable = NewTable(config.CostConfig{})
+
+// Calculate returns the estimated cost in USD for the given token counts at
+// the provider's default rate.
 func Calculate(provider string, inputTokens, outputTokens int) float64 {
-	rate, ok := DefaultRates[provider]
+	return defaultTable.Cost(provider, "", Usage{Input: inputTokens, Output: outputTokens})
+}
+
+// Cost returns the estimated cost in USD of usage on provider/model. Unknown
+// models and providers cost 0.
+func (t *Table) Cost(provider, model string, u Usage) float64 {
+	rate, ok := t.Lookup(provider, model)
 	if !ok {
 		return 0
 	}
-	inCost := float64(inputTokens) / 1_000_000 * rate.Input
-	outCost := float64(outputTokens) / 1_000_000 * rate.Output
-	return inCost + outCost
+	return rate.cost(u)
 }
 
-// FormatUSD formats a cost as a dollar string (e.g. "$0.42" or "$1.23").
-func FormatUSD(cost float64) string {
-	return fmt.Sprintf("$%.2f", cost)
-}
-
-// FormatRate returns a display string for a provider's rate (e.g. "$3.00/$15.00 per 1M tokens").
+// FormatRate returns a display string for a provider's default input and
+// output rate (e.g. "$3.00/$15.00 per 1M tokens").
 func FormatRate(provider string) string {
 	rate, ok := DefaultRates[provider]
 	if !ok {
@@ -38,3 +120,34 @@ func FormatRate(provider string) string {
 	}
 	return fmt.Sprintf("$%.2f/$%.2f per 1M tokens", rate.Input, rate.Output)
 }
+
+// FormatRate returns a display string for the rate of provider/model.
+func (t *Table) FormatRate(provider, model string) string {
+	rate, ok := t.Lookup(provider, model)
+	if !ok {
+		return "unknown pricing"
+	}
+	return rate.String()
+}
+
+func (r Rate) cost(u Usage) float64 {
+	return (float64(u.Input)*r.Input +
+		float64(u.Output)*r.Output +
+		float64(u.CacheRead)*r.CacheRead +
+		float64(u.CacheWrite)*r.CacheWrite) / 1_000_000
+}
+
+// String formats the rate as "$3.00/$15.00 per 1M tokens", adding cache
+// rates when they are set.
+func (r Rate) String() string {
+	s := fmt.Sprintf("$%.2f/$%.2f per 1M tokens", r.Input, r.Output)
+	if r.CacheRead > 0 || r.CacheWrite > 0 {
+		s += fmt.Sprintf(" (cache $%.3g read/$%.3g write)", r.CacheRead, r.CacheWrite)
+	}
+	return s
+}
+
+// FormatUSD formats a cost as a dollar string (e.g. "$0.42" or "$1.23").
+func FormatUSD(cost float64) string {
+	return fmt.Sprintf("$%.2f", cost)
+}
@@ -1,7 +1,10 @@
 package cost
 
 import (
+	"math"
 	"testing"
+
+	"autopr/internal/config"
 )
 
 func TestCalculate(t *testing.T) {
@@ -57,6 +60,77 @@ func TestCalculate(t *testing.T) {
 	}
 }
 
+func TestTableCost(t *testing.T) {
+	t.Parallel()
+	table := NewTable(config.CostConfig{})
+
+	tests := []struct {
+		name            string
+		provider, model string
+		usage           Usage
+		want            float64
+	}{
+		{name: "claude zero tokens", provider: "claude", want: 0},
+		{
+			name: "claude provider default", provider: "claude",
+			usage: Usage{Input: 1_000_000, Output: 1_000_000},
+			want:  18.0, // $3 + $15
+		},
+		{
+			name: "codex provider default", provider: "codex",
+			usage: Usage{Input: 1_000_000, Output: 1_000_000},
+			want:  15.0, // $3 + $12
+		},
+		{
+			name: "cache tokens priced separately", provider: "claude", model: "claude-sonnet-4-5",
+			usage: Usage{Input: 1_000_000, Output: 1_000_000, CacheRead: 10_000_000, CacheWrite: 1_000_000},
+			want:  3 + 15 + 3 + 3.75,
+		},
+		{
+			name: "dated model snapshot matches by prefix", provider: "claude", model: "claude-haiku-4-5-20251001",
+			usage: Usage{Input: 1_000_000, Output: 1_000_000},
+			want:  6.0,
+		},
+		{
+			name: "longest prefix wins", provider: "codex", model: "gpt-5-mini-2025",
+			usage: Usage{Input: 1_000_000, Output: 1_000_000},
+			want:  2.25,
+		},
+		{
+			name: "unknown provider", provider: "gpt5",
+			usage: Usage{Input: 1_000_000, Output: 1_000_000},
+			want:  0,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := table.Cost(tc.provider, tc.model, tc.usage)
+			if math.Abs(got-tc.want) > 1e-9 {
+				t.Errorf("Cost(%q, %q, %+v) = %f, want %f", tc.provider, tc.model, tc.usage, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTableConfigOverrides(t *testing.T) {
+	t.Parallel()
+	table := NewTable(config.CostConfig{
+		Models:    map[string]config.CostRate{"claude-sonnet-4-5": {Input: 1, Output: 2, CacheRead: 0.5}},
+		Providers: map[string]config.CostRate{"ollama": {Input: 0.1, Output: 0.2}},
+	})
+
+	if got := table.Cost("claude", "claude-sonnet-4-5-20250929", Usage{Input: 1_000_000, CacheRead: 2_000_000}); got != 2 {
+		t.Fatalf("expected configured model rate, got %f", got)
+	}
+	if got := table.Cost("ollama", "llama3", Usage{Input: 1_000_000, Output: 1_000_000}); math.Abs(got-0.3) > 1e-9 {
+		t.Fatalf("expected configured provider rate, got %f", got)
+	}
+	if got := table.Cost("claude", "claude-opus-4-1", Usage{Output: 1_000_000}); got != 75 {
+		t.Fatalf("expected built-in rate for unconfigured model, got %f", got)
+	}
+}
+
 func TestFormatUSD(t *testing.T) {
 	t.Parallel()
 
@@ -78,6 +152,21 @@ func TestFormatUSD(t *testing.T) {
 	}
 }
 
+func TestTableFormatRate(t *testing.T) {
+	t.Parallel()
+	table := NewTable(config.CostConfig{})
+
+	if got := table.FormatRate("codex", ""); got != "$3.00/$12.00 per 1M tokens (cache $0.3 read/$0 write)" {
+		t.Errorf("FormatRate(codex) = %q", got)
+	}
+	if got := (Rate{Input: 3, Output: 15}).String(); got != "$3.00/$15.00 per 1M tokens" {
+		t.Errorf("Rate.String() = %q", got)
+	}
+	if got := table.FormatRate("unknown", ""); got != "unknown pricing" {
+		t.Errorf("FormatRate(unknown) = %q", got)
+	}
+}
+
 func TestFormatRate(t *testing.T) {
 	t.Parallel()
 
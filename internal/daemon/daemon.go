@@ -12,6 +12,7 @@ import (
 	"syscall"
 	"time"
 
+	"autopr/internal/budget"
 	"autopr/internal/config"
 	"autopr/internal/db"
 	"autopr/internal/issuesync"
@@ -65,7 +66,10 @@ func Run(cfg *config.Config, foreground bool) error {
 	defer stop()
 
 	// Create LLM provider.
-	provider := llm.NewCLIProvider(cfg.LLM.Provider)
+	provider, err := llm.NewFromConfig(cfg.LLM)
+	if err != nil {
+		return fmt.Errorf("create llm provider: %w", err)
+	}
 
 	// Create pipeline runner.
 	pipelineRunner := pipeline.New(store, provider, cfg)
@@ -86,7 +90,7 @@ func Run(cfg *config.Config, foreground bool) error {
 	}
 
 	// Start worker pool.
-	pool := worker.NewPool(cfg.Daemon.MaxWorkers, store, pipelineRunner, jobCh)
+	pool := worker.NewPool(cfg.Daemon.MaxWorkers, store, pipelineRunner, budget.New(store, cfg), jobCh)
 	pool.Start(ctx)
 
 	// Start webhook server.
@@ -0,0 +1,51 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// TestBaseline is a cached test run on a project's base branch. Content,
+// Status and Data have the same meaning as on a test_output artifact.
+type TestBaseline struct {
+	ProjectName string
+	BaseSHA     string
+	TestCmd     string
+	Content     string
+	Status      string
+	Data        string
+	CreatedAt   string
+}
+
+// ErrNoTestBaseline is returned when no baseline is cached for a commit.
+var ErrNoTestBaseline = errors.New("no test baseline")
+
+// GetTestBaseline returns the cached baseline of testCmd at baseSHA.
+func (s *Store) GetTestBaseline(ctx context.Context, project, baseSHA, testCmd string) (TestBaseline, error) {
+	b := TestBaseline{ProjectName: project, BaseSHA: baseSHA, TestCmd: testCmd}
+	err := s.Reader.QueryRowContext(ctx, `
+SELECT content, status, data, created_at FROM test_baselines
+WHERE project_name = ? AND base_sha = ? AND test_cmd = ?`, project, baseSHA, testCmd).
+		Scan(&b.Content, &b.Status, &b.Data, &b.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return TestBaseline{}, ErrNoTestBaseline
+	}
+	if err != nil {
+		return TestBaseline{}, fmt.Errorf("get test baseline %s@%s: %w", project, baseSHA, err)
+	}
+	return b, nil
+}
+
+// SaveTestBaseline caches a baseline, replacing any earlier run of the same
+// command at the same commit.
+func (s *Store) SaveTestBaseline(ctx context.Context, b TestBaseline) error {
+	_, err := s.Writer.ExecContext(ctx, `
+INSERT OR REPLACE INTO test_baselines(project_name, base_sha, test_cmd, content, status, data)
+VALUES(?, ?, ?, ?, ?, ?)`, b.ProjectName, b.BaseSHA, b.TestCmd, b.Content, b.Status, b.Data)
+	if err != nil {
+		return fmt.Errorf("save test baseline %s@%s: %w", b.ProjectName, b.BaseSHA, err)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// UsageSince returns token usage per provider/model of the sessions created
+// at or after since. An empty project covers every project. Cancelled
+// sessions count with the tokens they used before they were stopped.
+func (s *Store) UsageSince(ctx context.Context, project string, since time.Time) ([]ModelUsage, error) {
+	q := `
+SELECT s.llm_provider, COALESCE(s.model,''), COUNT(*),
+       COALESCE(SUM(s.input_tokens),0), COALESCE(SUM(s.output_tokens),0),
+       COALESCE(SUM(s.cache_read_tokens),0), COALESCE(SUM(s.cache_creation_tokens),0)
+FROM llm_sessions s
+JOIN jobs j ON j.id = s.job_id
+WHERE s.created_at >= ? AND s.status IN ('completed','failed','cancelled')`
+	args := []any{since.UTC().Format("2006-01-02T15:04:05Z")}
+	if project != "" {
+		q += ` AND j.project_name = ?`
+		args = append(args, project)
+	}
+	q += ` GROUP BY s.llm_provider, COALESCE(s.model,'')`
+
+	rows, err := s.Reader.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("usage since %s: %w", since.Format(time.RFC3339), err)
+	}
+	defer rows.Close()
+
+	var out []ModelUsage
+	for rows.Next() {
+		var mu ModelUsage
+		if err := rows.Scan(&mu.Provider, &mu.Model, &mu.Sessions,
+			&mu.InputTokens, &mu.OutputTokens, &mu.CacheReadTokens, &mu.CacheCreationTokens); err != nil {
+			return nil, fmt.Errorf("scan usage: %w", err)
+		}
+		out = append(out, mu)
+	}
+	return out, rows.Err()
+}
+
+// RecordBudgetAlert marks the threshold alert for scope in period as sent.
+// It reports false if the alert was already recorded. scope is "job:<id>",
+// "project:<name>" or "global"; period is the day or month the spend is
+// counted in, or empty for a job.
+func (s *Store) RecordBudgetAlert(ctx context.Context, scope, period string) (bool, error) {
+	res, err := s.Writer.ExecContext(ctx, `
+INSERT OR IGNORE INTO budget_alerts(scope, period) VALUES(?, ?)`, scope, period)
+	if err != nil {
+		return false, fmt.Errorf("record budget alert %s %s: %w", scope, period, err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("record budget alert %s %s: %w", scope, period, err)
+	}
+	return n > 0, nil
+}
@@ -3,6 +3,7 @@ package db
 import (
 	"context"
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -179,18 +180,25 @@ func TestValidTransitionsContract(t *testing.T) {
 	t.Parallel()
 	t.Run("edges", func(t *testing.T) {
 		expected := map[string][]string{
-			"queued":              {"planning", "cancelled"},
-			"planning":            {"implementing", "failed", "cancelled"},
-			"implementing":        {"reviewing", "failed", "cancelled"},
-			"reviewing":           {"implementing", "testing", "failed", "cancelled"},
-			"testing":             {"ready", "implementing", "rebasing", "failed", "cancelled"},
-			"rebasing":            {"resolving_conflicts", "ready", "failed", "cancelled"},
-			"resolving_conflicts": {"ready", "failed", "cancelled"},
-			"ready":               {"awaiting_checks", "approved", "rejected"},
-			"awaiting_checks":     {"approved", "rejected", "cancelled"},
-			"failed":              {"queued"},
-			"rejected":            {"queued"},
-			"cancelled":           {"queued"},
+			"queued":                 {"planning", "cancelled"},
+			"planning":               {"implementing", "reviewing_plan", "awaiting_plan_approval", "needs_info", "failed", "cancelled"},
+			"needs_info":             {"queued", "needs_human", "failed", "cancelled"},
+			"reviewing_plan":         {"implementing", "planning", "awaiting_plan_approval", "needs_human", "failed", "cancelled"},
+			"awaiting_plan_approval": {"queued", "implementing", "planning", "failed", "cancelled"},
+			"implementing":           {"reviewing", "running_command", "testing", "needs_human", "failed", "cancelled"},
+			"running_command":        {"reviewing", "testing", "implementing", "failed", "cancelled"},
+			"reviewing":              {"implementing", "running_command", "testing", "failed", "cancelled"},
+			"testing":                {"ready", "implementing", "rebasing", "failed", "cancelled"},
+			"rebasing":               {"resolving_conflicts", "ready", "failed", "cancelled"},
+			"resolving_conflicts":    {"ready", "failed", "cancelled"},
+			"ready":                  {"awaiting_checks", "approved", "rejected", "blocked", "needs_human"},
+			"blocked":                {"approved", "rejected"},
+			"awaiting_checks":        {"approved", "rejected", "cancelled"},
+			"approved":               {"queued"},
+			"failed":                 {"queued"},
+			"rejected":               {"queued"},
+			"cancelled":              {"queued"},
+			"needs_human":            {"queued"},
 		}
 
 		if got, want := len(ValidTransitions), len(expected); got != want {
@@ -794,6 +802,59 @@ func TestClaimJobSkipsIneligibleIssues(t *testing.T) {
 	}
 }
 
+func TestClaimJobSkipsProjects(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	store, err := Open(filepath.Join(t.TempDir(), "autopr.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer store.Close()
+
+	jobs := make(map[string]string)
+	for i, project := range []string{"spent", "fresh"} {
+		issueID, err := store.UpsertIssue(ctx, IssueUpsert{
+			ProjectName:   project,
+			Source:        "github",
+			SourceIssueID: fmt.Sprint(200 + i),
+			Title:         project + " issue",
+			URL:           fmt.Sprintf("https://github.com/org/repo/issues/%d", 200+i),
+			State:         "open",
+		})
+		if err != nil {
+			t.Fatalf("upsert issue: %v", err)
+		}
+		jobID, err := store.CreateJob(ctx, issueID, project, 3)
+		if err != nil {
+			t.Fatalf("create job: %v", err)
+		}
+		jobs[project] = jobID
+	}
+
+	claimedID, err := store.ClaimJob(ctx, "spent")
+	if err != nil {
+		t.Fatalf("claim job: %v", err)
+	}
+	if claimedID != jobs["fresh"] {
+		t.Fatalf("expected job of fresh project %q, got %q", jobs["fresh"], claimedID)
+	}
+	claimedID, err = store.ClaimJob(ctx, "spent")
+	if err != nil {
+		t.Fatalf("claim job: %v", err)
+	}
+	if claimedID != "" {
+		t.Fatalf("expected no claimable job, got %q", claimedID)
+	}
+	claimedID, err = store.ClaimJob(ctx)
+	if err != nil {
+		t.Fatalf("claim job: %v", err)
+	}
+	if claimedID != jobs["spent"] {
+		t.Fatalf("expected skipped project's job once unskipped, got %q", claimedID)
+	}
+}
+
 func TestResetJobForRetryBlockedWhenIssueIneligible(t *testing.T) {
 	t.Parallel()
 	ctx := context.Background()
@@ -2329,6 +2390,12 @@ func TestAggregateTokensByJob(t *testing.T) {
 	if err := store.CompleteSession(ctx, s2, "completed", "ok", "prompt", "", "/tmp/s2.jsonl", "", "", 200, 100, 2000); err != nil {
 		t.Fatalf("complete session 2: %v", err)
 	}
+	if err := store.SetSessionModel(ctx, s2, "claude-opus-4-1"); err != nil {
+		t.Fatalf("set model: %v", err)
+	}
+	if err := store.SetSessionCacheTokens(ctx, s2, 4000, 600); err != nil {
+		t.Fatalf("set cache tokens: %v", err)
+	}
 
 	ts, err = store.AggregateTokensByJob(ctx, jobID)
 	if err != nil {
@@ -2349,6 +2416,25 @@ func TestAggregateTokensByJob(t *testing.T) {
 	if ts.Provider != "claude" {
 		t.Fatalf("expected claude provider, got %q", ts.Provider)
 	}
+	if ts.TotalCacheReadTokens != 4000 || ts.TotalCacheCreationTokens != 600 {
+		t.Fatalf("expected cache tokens 4000/600, got %d/%d", ts.TotalCacheReadTokens, ts.TotalCacheCreationTokens)
+	}
+	if len(ts.Models) != 2 {
+		t.Fatalf("expected per-model breakdown for 2 models, got %+v", ts.Models)
+	}
+	for _, m := range ts.Models {
+		if m.Model == "claude-opus-4-1" && (m.InputTokens != 200 || m.CacheReadTokens != 4000 || m.Sessions != 1) {
+			t.Fatalf("unexpected opus usage: %+v", m)
+		}
+	}
+
+	sessions, err := store.ListSessionsByJob(ctx, jobID)
+	if err != nil {
+		t.Fatalf("list sessions: %v", err)
+	}
+	if sessions[1].CacheReadTokens != 4000 || sessions[1].CacheCreationTokens != 600 {
+		t.Fatalf("expected cache tokens on session, got %+v", sessions[1])
+	}
 }
 
 func TestAggregateTokensForJobs(t *testing.T) {
@@ -3385,3 +3471,575 @@ func TestRejectJobAwaitingChecksSetsCICompletedAt(t *testing.T) {
 		t.Fatalf("expected ci_completed_at to be set after reject from awaiting_checks")
 	}
 }
+
+func TestRecordPlanDecisionWritesNothingWhenNotAwaitingApproval(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	tmp := t.TempDir()
+
+	store, err := Open(filepath.Join(tmp, "autopr.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer store.Close()
+
+	jobID := createTestJobWithState(t, ctx, store, "plan-decision", "cancelled", "", "", "", "")
+	decision := Artifact{JobID: jobID, Kind: "plan_approval", Content: "keep the v1 API", Status: "revise"}
+	if err := store.RecordPlanDecision(ctx, decision, "keep the v1 API"); err == nil {
+		t.Fatalf("expected a decision on a cancelled job to fail")
+	}
+
+	job, err := store.GetJob(ctx, jobID)
+	if err != nil {
+		t.Fatalf("get job: %v", err)
+	}
+	if job.State != "cancelled" || job.HumanNotes != "" {
+		t.Fatalf("expected the job untouched, got state %q notes %q", job.State, job.HumanNotes)
+	}
+	if _, err := store.GetLatestArtifact(ctx, jobID, "plan_approval"); err == nil {
+		t.Fatalf("expected no plan_approval artifact")
+	}
+}
+
+func TestSessionsAcceptCustomProvidersAfterLegacyMigration(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	dbPath := filepath.Join(t.TempDir(), "autopr.db")
+
+	store, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	// Simulate a database that still restricts llm_provider.
+	if _, err := store.Writer.Exec(`DROP TABLE llm_sessions`); err != nil {
+		t.Fatalf("drop llm_sessions: %v", err)
+	}
+	if _, err := store.Writer.Exec(`
+CREATE TABLE llm_sessions (
+    id            INTEGER PRIMARY KEY AUTOINCREMENT,
+    job_id        TEXT NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    step          TEXT NOT NULL CHECK(step IN ('plan','plan_review','implement','code_review','tests','conflict_resolution')),
+    iteration     INTEGER NOT NULL DEFAULT 0,
+    llm_provider  TEXT NOT NULL CHECK(llm_provider IN ('codex', 'claude')),
+    prompt_hash   TEXT,
+    response_text TEXT,
+    prompt_text   TEXT,
+    input_tokens  INTEGER,
+    output_tokens INTEGER,
+    duration_ms   INTEGER,
+    jsonl_path    TEXT,
+    commit_sha    TEXT,
+    status        TEXT NOT NULL DEFAULT 'running' CHECK(status IN ('running','completed','failed','cancelled')),
+    error_message TEXT,
+    created_at    TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    completed_at  TEXT
+)`); err != nil {
+		t.Fatalf("create legacy llm_sessions: %v", err)
+	}
+	_ = store.Close()
+
+	store, err = Open(dbPath)
+	if err != nil {
+		t.Fatalf("reopen db: %v", err)
+	}
+	defer store.Close()
+
+	issueID, err := store.UpsertIssue(ctx, IssueUpsert{
+		ProjectName:   "myproject",
+		Source:        "github",
+		SourceIssueID: "http-1",
+		Title:         "http provider",
+		URL:           "https://github.com/org/repo/issues/http-1",
+		State:         "open",
+	})
+	if err != nil {
+		t.Fatalf("upsert issue: %v", err)
+	}
+	jobID, err := store.CreateJob(ctx, issueID, "myproject", 3)
+	if err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+	for _, provider := range []string{"openai", "anthropic", "aider"} {
+		if _, err := store.CreateSession(ctx, jobID, "plan", 0, provider, ""); err != nil {
+			t.Fatalf("create %s session: %v", provider, err)
+		}
+	}
+}
+
+func TestJobsAcceptPlanReviewStatesAfterLegacyMigration(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	dbPath := filepath.Join(t.TempDir(), "autopr.db")
+
+	store, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	// Simulate a database whose jobs table predates the plan review states.
+	if _, err := store.Writer.Exec(`DROP TABLE jobs`); err != nil {
+		t.Fatalf("drop jobs: %v", err)
+	}
+	if _, err := store.Writer.Exec(`
+CREATE TABLE jobs (
+    id              TEXT PRIMARY KEY,
+    autopr_issue_id TEXT NOT NULL REFERENCES issues(autopr_issue_id) ON DELETE RESTRICT,
+    project_name     TEXT NOT NULL,
+    state            TEXT NOT NULL DEFAULT 'queued'
+        CHECK(state IN ('queued','planning','implementing','reviewing','testing','ready','rebasing','resolving_conflicts','awaiting_checks','approved','rejected','failed','cancelled')),
+    iteration        INTEGER NOT NULL DEFAULT 0 CHECK(iteration >= 0),
+    max_iterations   INTEGER NOT NULL DEFAULT 3 CHECK(max_iterations > 0),
+    worktree_path    TEXT,
+    branch_name      TEXT,
+    commit_sha       TEXT,
+    human_notes      TEXT,
+    error_message    TEXT,
+    pr_url           TEXT,
+    pr_merged_at     TEXT,
+    pr_closed_at     TEXT,
+    reject_reason    TEXT,
+    created_at       TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    updated_at       TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    started_at       TEXT,
+    completed_at     TEXT,
+    ci_started_at    TEXT,
+    ci_completed_at  TEXT,
+    ci_status_summary TEXT
+)`); err != nil {
+		t.Fatalf("create legacy jobs: %v", err)
+	}
+	issueID, err := store.UpsertIssue(ctx, IssueUpsert{
+		ProjectName:   "myproject",
+		Source:        "github",
+		SourceIssueID: "plan-review-1",
+		Title:         "plan review",
+		URL:           "https://github.com/org/repo/issues/plan-review-1",
+		State:         "open",
+	})
+	if err != nil {
+		t.Fatalf("upsert issue: %v", err)
+	}
+	jobID, err := store.CreateJob(ctx, issueID, "myproject", 3)
+	if err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+	if _, err := store.Writer.Exec(`UPDATE jobs SET state = 'planning', ci_status_summary = 'checks passed' WHERE id = ?`, jobID); err != nil {
+		t.Fatalf("seed legacy job: %v", err)
+	}
+	_ = store.Close()
+
+	store, err = Open(dbPath)
+	if err != nil {
+		t.Fatalf("reopen db: %v", err)
+	}
+	defer store.Close()
+
+	for _, step := range [][2]string{{"planning", "reviewing_plan"}, {"reviewing_plan", "needs_human"}} {
+		if err := store.TransitionState(ctx, jobID, step[0], step[1]); err != nil {
+			t.Fatalf("transition %s->%s: %v", step[0], step[1], err)
+		}
+	}
+	job, err := store.GetJob(ctx, jobID)
+	if err != nil {
+		t.Fatalf("get job: %v", err)
+	}
+	if job.CIStatusSummary != "checks passed" || job.CompletedAt == "" {
+		t.Fatalf("expected migrated job to keep CI summary and complete in needs_human, got %+v", job)
+	}
+	// needs_human is terminal: a new job can be created for the issue.
+	if _, err := store.CreateJob(ctx, issueID, "myproject", 3); err != nil {
+		t.Fatalf("create job after needs_human: %v", err)
+	}
+}
+
+func TestJobsAcceptBlockedStateAfterLegacyMigration(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	dbPath := filepath.Join(t.TempDir(), "autopr.db")
+
+	store, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	// Simulate a database whose jobs table predates the blocked state.
+	if _, err := store.Writer.Exec(`DROP TABLE jobs`); err != nil {
+		t.Fatalf("drop jobs: %v", err)
+	}
+	if _, err := store.Writer.Exec(`
+CREATE TABLE jobs (
+    id              TEXT PRIMARY KEY,
+    autopr_issue_id TEXT NOT NULL REFERENCES issues(autopr_issue_id) ON DELETE RESTRICT,
+    project_name     TEXT NOT NULL,
+    state            TEXT NOT NULL DEFAULT 'queued'
+        CHECK(state IN ('queued','planning','reviewing_plan','implementing','running_command','reviewing','testing','ready','rebasing','resolving_conflicts','awaiting_checks','approved','rejected','failed','cancelled','needs_human')),
+    iteration        INTEGER NOT NULL DEFAULT 0 CHECK(iteration >= 0),
+    max_iterations   INTEGER NOT NULL DEFAULT 3 CHECK(max_iterations > 0),
+    worktree_path    TEXT,
+    branch_name      TEXT,
+    commit_sha       TEXT,
+    human_notes      TEXT,
+    error_message    TEXT,
+    pr_url           TEXT,
+    pr_merged_at     TEXT,
+    pr_closed_at     TEXT,
+    reject_reason    TEXT,
+    created_at       TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    updated_at       TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    started_at       TEXT,
+    completed_at     TEXT,
+    ci_started_at    TEXT,
+    ci_completed_at  TEXT,
+    ci_status_summary TEXT,
+    command_step     TEXT
+)`); err != nil {
+		t.Fatalf("create legacy jobs: %v", err)
+	}
+	jobID := createTestJobWithState(t, ctx, store, "blocked-1", "ready", "", "", "", "")
+	if _, err := store.Writer.Exec(`UPDATE jobs SET command_step = 'lint' WHERE id = ?`, jobID); err != nil {
+		t.Fatalf("seed legacy job: %v", err)
+	}
+	_ = store.Close()
+
+	store, err = Open(dbPath)
+	if err != nil {
+		t.Fatalf("reopen db: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.TransitionState(ctx, jobID, "ready", "blocked"); err != nil {
+		t.Fatalf("transition ready->blocked: %v", err)
+	}
+	job, err := store.GetJob(ctx, jobID)
+	if err != nil {
+		t.Fatalf("get job: %v", err)
+	}
+	if job.CommandStep != "lint" {
+		t.Fatalf("expected migrated job to keep its command step, got %+v", job)
+	}
+	if _, err := store.InsertArtifact(ctx, Artifact{JobID: jobID, AutoPRIssueID: job.AutoPRIssueID, Kind: "secret_scan", Content: "1 suspected secret", Status: "failed"}); err != nil {
+		t.Fatalf("create secret_scan artifact: %v", err)
+	}
+	// blocked is still active: the issue cannot get a second job.
+	if _, err := store.CreateJob(ctx, job.AutoPRIssueID, job.ProjectName, 3); err == nil {
+		t.Fatalf("expected a blocked job to count as active")
+	}
+	if err := store.TransitionState(ctx, jobID, "blocked", "approved"); err != nil {
+		t.Fatalf("transition blocked->approved: %v", err)
+	}
+}
+
+func TestPlanApprovalAfterLegacyMigration(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	dbPath := filepath.Join(t.TempDir(), "autopr.db")
+
+	store, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	// Simulate a database whose jobs and notification_events tables predate
+	// plan approval.
+	if _, err := store.Writer.Exec(`DROP TABLE notification_events`); err != nil {
+		t.Fatalf("drop notification_events: %v", err)
+	}
+	if _, err := store.Writer.Exec(`DROP TABLE jobs`); err != nil {
+		t.Fatalf("drop jobs: %v", err)
+	}
+	if _, err := store.Writer.Exec(`
+CREATE TABLE jobs (
+    id              TEXT PRIMARY KEY,
+    autopr_issue_id TEXT NOT NULL REFERENCES issues(autopr_issue_id) ON DELETE RESTRICT,
+    project_name     TEXT NOT NULL,
+    state            TEXT NOT NULL DEFAULT 'queued'
+        CHECK(state IN ('queued','planning','reviewing_plan','implementing','running_command','reviewing','testing','ready','blocked','rebasing','resolving_conflicts','awaiting_checks','approved','rejected','failed','cancelled','needs_human')),
+    iteration        INTEGER NOT NULL DEFAULT 0 CHECK(iteration >= 0),
+    max_iterations   INTEGER NOT NULL DEFAULT 3 CHECK(max_iterations > 0),
+    worktree_path    TEXT,
+    branch_name      TEXT,
+    commit_sha       TEXT,
+    human_notes      TEXT,
+    error_message    TEXT,
+    pr_url           TEXT,
+    pr_merged_at     TEXT,
+    pr_closed_at     TEXT,
+    reject_reason    TEXT,
+    created_at       TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    updated_at       TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    started_at       TEXT,
+    completed_at     TEXT,
+    ci_started_at    TEXT,
+    ci_completed_at  TEXT,
+    ci_status_summary TEXT,
+    command_step     TEXT
+)`); err != nil {
+		t.Fatalf("create legacy jobs: %v", err)
+	}
+	if _, err := store.Writer.Exec(`
+CREATE TABLE notification_events (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    job_id     TEXT NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    event_type TEXT NOT NULL CHECK(event_type IN ('needs_pr','failed','pr_created','pr_merged','budget_threshold')),
+    message    TEXT NOT NULL DEFAULT '',
+    status     TEXT NOT NULL DEFAULT 'pending' CHECK(status IN ('pending','processing','sent','failed','skipped')),
+    attempts   INTEGER NOT NULL DEFAULT 0 CHECK(attempts >= 0),
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    updated_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
+)`); err != nil {
+		t.Fatalf("create legacy notification_events: %v", err)
+	}
+	jobID := createTestJobWithState(t, ctx, store, "plan-approval-1", "planning", "", "", "", "")
+	if _, err := store.EnqueueNotificationEventMessage(ctx, jobID, NotificationEventBudget, "job budget at 80%"); err != nil {
+		t.Fatalf("seed legacy event: %v", err)
+	}
+	_ = store.Close()
+
+	store, err = Open(dbPath)
+	if err != nil {
+		t.Fatalf("reopen db: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.TransitionState(ctx, jobID, "planning", "awaiting_plan_approval"); err != nil {
+		t.Fatalf("transition planning->awaiting_plan_approval: %v", err)
+	}
+	if _, err := store.EnqueueNotificationEventMessage(ctx, jobID, NotificationEventPlanApproval, "Plan ready for approval"); err != nil {
+		t.Fatalf("enqueue plan_approval event: %v", err)
+	}
+	events, err := store.ListNotificationEvents(ctx, NotificationStatusPending, 10)
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	if len(events) != 2 || events[0].Message != "job budget at 80%" || events[1].EventType != NotificationEventPlanApproval {
+		t.Fatalf("unexpected events after migration: %+v", events)
+	}
+	job, err := store.GetJob(ctx, jobID)
+	if err != nil {
+		t.Fatalf("get job: %v", err)
+	}
+	if _, err := store.InsertArtifact(ctx, Artifact{JobID: jobID, AutoPRIssueID: job.AutoPRIssueID, Kind: "plan_approval", Status: "approved"}); err != nil {
+		t.Fatalf("create plan_approval artifact: %v", err)
+	}
+	// A job waiting for plan approval is still active and cancellable.
+	if _, err := store.CreateJob(ctx, job.AutoPRIssueID, job.ProjectName, 3); err == nil {
+		t.Fatalf("expected a job awaiting plan approval to count as active")
+	}
+	if err := store.CancelJob(ctx, jobID); err != nil {
+		t.Fatalf("cancel job awaiting plan approval: %v", err)
+	}
+}
+
+func TestNeedsInfoAfterLegacyMigration(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	dbPath := filepath.Join(t.TempDir(), "autopr.db")
+
+	store, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	// Simulate a database whose jobs and artifacts tables predate the
+	// clarifying-question loop.
+	if _, err := store.Writer.Exec(`DROP TABLE artifacts`); err != nil {
+		t.Fatalf("drop artifacts: %v", err)
+	}
+	if _, err := store.Writer.Exec(`DROP TABLE jobs`); err != nil {
+		t.Fatalf("drop jobs: %v", err)
+	}
+	if _, err := store.Writer.Exec(`
+CREATE TABLE jobs (
+    id              TEXT PRIMARY KEY,
+    autopr_issue_id TEXT NOT NULL REFERENCES issues(autopr_issue_id) ON DELETE RESTRICT,
+    project_name     TEXT NOT NULL,
+    state            TEXT NOT NULL DEFAULT 'queued'
+        CHECK(state IN ('queued','planning','reviewing_plan','awaiting_plan_approval','implementing','running_command','reviewing','testing','ready','blocked','rebasing','resolving_conflicts','awaiting_checks','approved','rejected','failed','cancelled','needs_human')),
+    iteration        INTEGER NOT NULL DEFAULT 0 CHECK(iteration >= 0),
+    max_iterations   INTEGER NOT NULL DEFAULT 3 CHECK(max_iterations > 0),
+    worktree_path    TEXT,
+    branch_name      TEXT,
+    commit_sha       TEXT,
+    human_notes      TEXT,
+    error_message    TEXT,
+    pr_url           TEXT,
+    pr_merged_at     TEXT,
+    pr_closed_at     TEXT,
+    reject_reason    TEXT,
+    created_at       TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    updated_at       TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    started_at       TEXT,
+    completed_at     TEXT,
+    ci_started_at    TEXT,
+    ci_completed_at  TEXT,
+    ci_status_summary TEXT,
+    command_step     TEXT
+)`); err != nil {
+		t.Fatalf("create legacy jobs: %v", err)
+	}
+	if _, err := store.Writer.Exec(`
+CREATE TABLE artifacts (
+    id               INTEGER PRIMARY KEY AUTOINCREMENT,
+    job_id           TEXT NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    autopr_issue_id  TEXT NOT NULL,
+    kind             TEXT NOT NULL CHECK(kind IN ('plan','plan_review','code_review','test_output','rebase_conflict','rebase_result','diff_policy','secret_scan','plan_approval') OR kind GLOB 'command:*' OR kind GLOB 'test_output:*'),
+    content          TEXT NOT NULL,
+    iteration        INTEGER NOT NULL DEFAULT 0,
+    commit_sha       TEXT,
+    status           TEXT NOT NULL DEFAULT '',
+    data             TEXT NOT NULL DEFAULT '',
+    created_at       TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
+)`); err != nil {
+		t.Fatalf("create legacy artifacts: %v", err)
+	}
+	jobID := createTestJobWithState(t, ctx, store, "needs-info-1", "planning", "", "", "", "")
+	_ = store.Close()
+
+	store, err = Open(dbPath)
+	if err != nil {
+		t.Fatalf("reopen db: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.TransitionState(ctx, jobID, "planning", "needs_info"); err != nil {
+		t.Fatalf("transition planning->needs_info: %v", err)
+	}
+	job, err := store.GetJob(ctx, jobID)
+	if err != nil {
+		t.Fatalf("get job: %v", err)
+	}
+	if _, err := store.InsertArtifact(ctx, Artifact{JobID: jobID, AutoPRIssueID: job.AutoPRIssueID, Kind: "clarification", Content: "Which format?", Status: "asked"}); err != nil {
+		t.Fatalf("create clarification artifact: %v", err)
+	}
+	// A job waiting for answers is still active and cancellable.
+	if _, err := store.CreateJob(ctx, job.AutoPRIssueID, job.ProjectName, 3); err == nil {
+		t.Fatalf("expected a job in needs_info to count as active")
+	}
+	if err := store.CancelJob(ctx, jobID); err != nil {
+		t.Fatalf("cancel job in needs_info: %v", err)
+	}
+}
+
+func TestCommandStepStateAndArtifactsAfterLegacyMigration(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	dbPath := filepath.Join(t.TempDir(), "autopr.db")
+
+	store, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	// Simulate a database whose artifacts table predates command step kinds.
+	if _, err := store.Writer.Exec(`DROP TABLE artifacts`); err != nil {
+		t.Fatalf("drop artifacts: %v", err)
+	}
+	if _, err := store.Writer.Exec(`
+CREATE TABLE artifacts (
+    id               INTEGER PRIMARY KEY AUTOINCREMENT,
+    job_id           TEXT NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    autopr_issue_id  TEXT NOT NULL,
+    kind             TEXT NOT NULL CHECK(kind IN ('plan','plan_review','code_review','test_output','rebase_conflict','rebase_result')),
+    content          TEXT NOT NULL,
+    iteration        INTEGER NOT NULL DEFAULT 0,
+    commit_sha       TEXT,
+    created_at       TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
+)`); err != nil {
+		t.Fatalf("create legacy artifacts: %v", err)
+	}
+	jobID := createTestJobWithState(t, ctx, store, "command-step-1", "implementing", "", "", "", "")
+	job, err := store.GetJob(ctx, jobID)
+	if err != nil {
+		t.Fatalf("get job: %v", err)
+	}
+	if _, err := store.CreateArtifact(ctx, jobID, job.AutoPRIssueID, "plan", "the plan", 0, ""); err != nil {
+		t.Fatalf("create legacy artifact: %v", err)
+	}
+	_ = store.Close()
+
+	store, err = Open(dbPath)
+	if err != nil {
+		t.Fatalf("reopen db: %v", err)
+	}
+	defer store.Close()
+
+	if err := store.TransitionState(ctx, jobID, "implementing", "running_command"); err != nil {
+		t.Fatalf("transition implementing->running_command: %v", err)
+	}
+	if err := store.UpdateJobField(ctx, jobID, "command_step", "lint"); err != nil {
+		t.Fatalf("set command_step: %v", err)
+	}
+	job, err = store.GetJob(ctx, jobID)
+	if err != nil {
+		t.Fatalf("get job: %v", err)
+	}
+	if got := DisplayJobState(job); got != "lint" {
+		t.Fatalf("expected command step name as display state, got %q", got)
+	}
+
+	if _, err := store.InsertArtifact(ctx, Artifact{JobID: jobID, AutoPRIssueID: job.AutoPRIssueID, Kind: "command:lint", Content: "lint failed", Status: "failed"}); err != nil {
+		t.Fatalf("create command artifact: %v", err)
+	}
+	art, err := store.GetLatestArtifact(ctx, jobID, "command:lint")
+	if err != nil || art.Status != "failed" || art.Content != "lint failed" {
+		t.Fatalf("expected failed command artifact, got %+v (err %v)", art, err)
+	}
+	if plan, err := store.GetLatestArtifact(ctx, jobID, "plan"); err != nil || plan.Content != "the plan" || plan.Status != "" {
+		t.Fatalf("expected legacy artifact to survive migration, got %+v (err %v)", plan, err)
+	}
+	if _, err := store.InsertArtifact(ctx, Artifact{JobID: jobID, AutoPRIssueID: job.AutoPRIssueID, Kind: "test_output:go vet ./...", Content: "ok", Status: "passed", Data: "{}"}); err != nil {
+		t.Fatalf("create test command artifact: %v", err)
+	}
+	if _, err := store.CreateArtifact(ctx, jobID, job.AutoPRIssueID, "lint", "x", 0, ""); err == nil {
+		t.Fatalf("expected unprefixed unknown artifact kind to be rejected")
+	}
+}
+
+func TestSessionEventsRoundTrip(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store, err := Open(filepath.Join(t.TempDir(), "autopr.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer store.Close()
+
+	issueID, err := store.UpsertIssue(ctx, IssueUpsert{
+		ProjectName:   "myproject",
+		Source:        "github",
+		SourceIssueID: "events-1",
+		Title:         "events",
+		URL:           "https://github.com/org/repo/issues/events-1",
+		State:         "open",
+	})
+	if err != nil {
+		t.Fatalf("upsert issue: %v", err)
+	}
+	jobID, err := store.CreateJob(ctx, issueID, "myproject", 3)
+	if err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+	sessionID, err := store.CreateSession(ctx, jobID, "implement", 0, "claude", "")
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+
+	exit := 2
+	if err := store.InsertSessionEvents(ctx, sessionID, jobID, []SessionEvent{
+		{EventType: "command", Tool: "Bash", Command: "go test ./...", ExitCode: &exit, CreatedAt: "2026-01-02T03:04:05Z"},
+		{EventType: "file_edit", Tool: "Edit", Path: "main.go", CreatedAt: "2026-01-02T03:04:06Z"},
+	}); err != nil {
+		t.Fatalf("insert events: %v", err)
+	}
+
+	events, err := store.ListSessionEventsByJob(ctx, jobID)
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %+v", events)
+	}
+	if events[0].Seq != 1 || events[0].SessionID != sessionID || events[0].Command != "go test ./..." ||
+		events[0].ExitCode == nil || *events[0].ExitCode != 2 {
+		t.Fatalf("unexpected first event: %+v", events[0])
+	}
+	if events[1].Seq != 2 || events[1].Path != "main.go" || events[1].ExitCode != nil {
+		t.Fatalf("unexpected second event: %+v", events[1])
+	}
+}
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// SessionEvent is one normalized agent action (command, file edit, tool call)
+// recorded for an LLM session.
+type SessionEvent struct {
+	ID        int64
+	SessionID int64
+	JobID     string
+	Seq       int
+	EventType string
+	Tool      string
+	Path      string
+	Command   string
+	ExitCode  *int // nil when the outcome is unknown
+	CreatedAt string
+}
+
+// InsertSessionEvents stores the events of a session in order. Seq and
+// SessionID/JobID are taken from the arguments, not from the events.
+func (s *Store) InsertSessionEvents(ctx context.Context, sessionID int64, jobID string, events []SessionEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	tx, err := s.Writer.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("insert session events: begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+INSERT INTO session_events(session_id, job_id, seq, event_type, tool, path, command, exit_code, created_at)
+VALUES(?,?,?,?,?,?,?,?,?)`)
+	if err != nil {
+		return fmt.Errorf("insert session events: %w", err)
+	}
+	defer stmt.Close()
+
+	for i, ev := range events {
+		var exitCode sql.NullInt64
+		if ev.ExitCode != nil {
+			exitCode = sql.NullInt64{Int64: int64(*ev.ExitCode), Valid: true}
+		}
+		if _, err := stmt.ExecContext(ctx, sessionID, jobID, i+1, ev.EventType, ev.Tool, ev.Path, ev.Command, exitCode, ev.CreatedAt); err != nil {
+			return fmt.Errorf("insert session event for session %d: %w", sessionID, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("insert session events: commit: %w", err)
+	}
+	return nil
+}
+
+// ListSessionEventsByJob returns all events of a job ordered by session and sequence.
+func (s *Store) ListSessionEventsByJob(ctx context.Context, jobID string) ([]SessionEvent, error) {
+	const q = `
+SELECT id, session_id, job_id, seq, event_type, tool, path, command, exit_code, created_at
+FROM session_events WHERE job_id = ? ORDER BY session_id ASC, seq ASC`
+	rows, err := s.Reader.QueryContext(ctx, q, jobID)
+	if err != nil {
+		return nil, fmt.Errorf("list session events: %w", err)
+	}
+	defer rows.Close()
+
+	var out []SessionEvent
+	for rows.Next() {
+		var ev SessionEvent
+		var exitCode sql.NullInt64
+		if err := rows.Scan(
+			&ev.ID, &ev.SessionID, &ev.JobID, &ev.Seq, &ev.EventType,
+			&ev.Tool, &ev.Path, &ev.Command, &exitCode, &ev.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("scan session event: %w", err)
+		}
+		if exitCode.Valid {
+			code := int(exitCode.Int64)
+			ev.ExitCode = &code
+		}
+		out = append(out, ev)
+	}
+	return out, rows.Err()
+}
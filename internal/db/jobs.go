@@ -28,16 +28,30 @@ var ValidTransitions = func() map[string][]string {
 	// planning phase
 	// queued: accepted by the system and waiting to be claimed; can enter planning or be cancelled.
 	registerTransition(transitions, "queued", "planning", "cancelled")
-	// planning: issue has an execution plan; can begin implementing, or terminally fail/cancel.
-	registerTransition(transitions, "planning", "implementing", "failed", "cancelled")
+	// planning: issue has an execution plan; can begin implementing (or plan review or plan approval), ask
+	// clarifying questions on the issue, or terminally fail/cancel.
+	registerTransition(transitions, "planning", "implementing", "reviewing_plan", "awaiting_plan_approval", "needs_info", "failed", "cancelled")
+	// needs_info: clarifying questions were posted on the issue; a reply requeues the job to plan again, no
+	// reply within the clarify timeout stops it for a human, or fail/cancel.
+	registerTransition(transitions, "needs_info", "queued", "needs_human", "failed", "cancelled")
+	// reviewing_plan: the plan is critiqued; can begin implementing, re-plan, wait for plan approval, stop for a human, or fail/cancel.
+	registerTransition(transitions, "reviewing_plan", "implementing", "planning", "awaiting_plan_approval", "needs_human", "failed", "cancelled")
+	// awaiting_plan_approval: the plan waits for a human; approve-plan and revise-plan requeue the job, which then
+	// begins implementing or re-plans with the notes, or fail/cancel.
+	registerTransition(transitions, "awaiting_plan_approval", "queued", "implementing", "planning", "failed", "cancelled")
 
 	// implementation phase
-	// implementing: code is being written; can be reviewed, or move to terminal failed/cancelled states.
-	registerTransition(transitions, "implementing", "reviewing", "failed", "cancelled")
+	// implementing: code is being written; can be reviewed, checked by a command step or tested (when the
+	// pipeline skips them), stop for a human when a review round runs out of iterations, or move to terminal
+	// failed/cancelled states.
+	registerTransition(transitions, "implementing", "reviewing", "running_command", "testing", "needs_human", "failed", "cancelled")
+	// running_command: a project command step (lint, build, ...) is running; can continue to review or testing,
+	// request implementing fixes, or fail/cancel.
+	registerTransition(transitions, "running_command", "reviewing", "testing", "implementing", "failed", "cancelled")
 
 	// review phase
-	// reviewing: code review is active; can request more implementation, pass to testing, or fail/cancel.
-	registerTransition(transitions, "reviewing", "implementing", "testing", "failed", "cancelled")
+	// reviewing: code review is active; can request more implementation, pass to a command step or testing, or fail/cancel.
+	registerTransition(transitions, "reviewing", "implementing", "running_command", "testing", "failed", "cancelled")
 
 	// testing phase
 	// testing: automated checks are running; can pass to rebasing (if rebase enabled), ready, request implementing fixes, or fail/cancel.
@@ -50,16 +64,25 @@ var ValidTransitions = func() map[string][]string {
 	registerTransition(transitions, "resolving_conflicts", "ready", "failed", "cancelled")
 
 	// completion phase
-	// ready: implementation appears complete and awaits approval decision.
-	registerTransition(transitions, "ready", "awaiting_checks", "approved", "rejected")
+	// ready: implementation appears complete and awaits approval decision; the push is blocked when the
+	// secret scan finds credentials in the diff, and a review round that ran out of iterations without
+	// passing review and tests stops for a human.
+	registerTransition(transitions, "ready", "awaiting_checks", "approved", "rejected", "blocked", "needs_human")
+	// blocked: the secret scan stopped the push; can be approved with an explicit override, or rejected.
+	registerTransition(transitions, "blocked", "approved", "rejected")
 	// awaiting_checks: PR created, waiting for CI check-runs to pass.
 	registerTransition(transitions, "awaiting_checks", "approved", "rejected", "cancelled")
+	// approved: the PR is open; new review comments on it requeue the job for another implement and test round.
+	registerTransition(transitions, "approved", "queued")
 	// failed: implementation failed and can be retried by returning to queue.
 	registerTransition(transitions, "failed", "queued")
 	// rejected: review outcome was not accepted; can be retried by returning to queue.
 	registerTransition(transitions, "rejected", "queued")
 	// cancelled: job execution was manually stopped; can be retried by returning to queue.
 	registerTransition(transitions, "cancelled", "queued")
+	// needs_human: plan review judged the issue infeasible, clarifying questions went unanswered or a review round
+	// did not pass; can be retried by returning to queue.
+	registerTransition(transitions, "needs_human", "queued")
 
 	return transitions
 }()
@@ -67,7 +90,7 @@ var ValidTransitions = func() map[string][]string {
 // IsCancellableState reports whether a job can be cancelled.
 func IsCancellableState(state string) bool {
 	switch state {
-	case "queued", "planning", "implementing", "reviewing", "testing", "rebasing", "resolving_conflicts", "awaiting_checks":
+	case "queued", "planning", "needs_info", "reviewing_plan", "awaiting_plan_approval", "implementing", "running_command", "reviewing", "testing", "rebasing", "resolving_conflicts", "awaiting_checks":
 		return true
 	default:
 		return false
@@ -79,6 +102,8 @@ func StepForState(state string) string {
 	switch state {
 	case "planning":
 		return "plan"
+	case "reviewing_plan":
+		return "plan_review"
 	case "implementing":
 		return "implement"
 	case "reviewing":
@@ -107,6 +132,16 @@ func DisplayState(state, prMergedAt, prClosedAt string) string {
 	switch state {
 	case "ready":
 		return "needs pr"
+	case "reviewing_plan":
+		return "reviewing plan"
+	case "needs_info":
+		return "needs info"
+	case "awaiting_plan_approval":
+		return "plan approval"
+	case "running_command":
+		return "running command"
+	case "needs_human":
+		return "needs human"
 	case "rebasing":
 		return "rebasing"
 	case "resolving_conflicts":
@@ -120,6 +155,15 @@ func DisplayState(state, prMergedAt, prClosedAt string) string {
 	}
 }
 
+// DisplayJobState is DisplayState for a job, naming the command step while
+// one is running.
+func DisplayJobState(j Job) string {
+	if j.State == "running_command" && j.CommandStep != "" {
+		return j.CommandStep
+	}
+	return DisplayState(j.State, j.PRMergedAt, j.PRClosedAt)
+}
+
 // DisplayStep returns a display-friendly name for an LLM session step,
 // aligned with the job state names for consistency across the UI.
 func DisplayStep(step string) string {
@@ -174,6 +218,7 @@ type Job struct {
 	CIStartedAt     string
 	CICompletedAt   string
 	CIStatusSummary string
+	CommandStep     string // pipeline command step running while in running_command
 
 	// Joined from issues table (populated by ListJobs).
 	IssueSource   string
@@ -195,22 +240,33 @@ func (s *Store) CreateJob(ctx context.Context, autoprIssueID, projectName string
 	return id, nil
 }
 
-// ClaimJob atomically claims the next queued job. Returns empty string if none available.
-func (s *Store) ClaimJob(ctx context.Context) (string, error) {
-	const q = `
+// ClaimJob atomically claims the next queued job, passing over jobs of the
+// skipped projects. Returns empty string if none available.
+func (s *Store) ClaimJob(ctx context.Context, skipProjects ...string) (string, error) {
+	where := `j.state = 'queued' AND i.eligible = 1`
+	args := make([]any, 0, len(skipProjects))
+	if len(skipProjects) > 0 {
+		placeholders := make([]string, len(skipProjects))
+		for i, name := range skipProjects {
+			placeholders[i] = "?"
+			args = append(args, name)
+		}
+		where += ` AND j.project_name NOT IN (` + strings.Join(placeholders, ",") + `)`
+	}
+	q := `
 UPDATE jobs SET state = 'planning', started_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now'),
                updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now')
 WHERE id = (
 	SELECT j.id
 	FROM jobs j
 	JOIN issues i ON i.autopr_issue_id = j.autopr_issue_id
-	WHERE j.state = 'queued' AND i.eligible = 1
+	WHERE ` + where + `
 	ORDER BY j.created_at ASC
 	LIMIT 1
 )
 RETURNING id`
 	var id string
-	err := s.Writer.QueryRowContext(ctx, q).Scan(&id)
+	err := s.Writer.QueryRowContext(ctx, q, args...).Scan(&id)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return "", nil
@@ -228,7 +284,7 @@ func (s *Store) TransitionState(ctx context.Context, jobID, from, to string) err
 		return fmt.Errorf("invalid transition: %s -> %s", from, to)
 	}
 	updates := make([]string, 0, 4)
-	if to == "approved" || to == "rejected" || to == "ready" || to == "failed" || to == "cancelled" {
+	if to == "approved" || to == "rejected" || to == "ready" || to == "failed" || to == "cancelled" || to == "needs_human" {
 		updates = append(updates, "completed_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now')")
 	}
 	if from == "ready" && to == "awaiting_checks" {
@@ -292,6 +348,16 @@ func (s *Store) TransitionState(ctx context.Context, jobID, from, to string) err
 			}
 		}
 	}
+	// A review round updates a PR that is already open: it neither needs nor creates one.
+	if eventType == NotificationEventNeedsPR || eventType == NotificationEventPRCreated {
+		inRound, err := reviewRoundInProgressTx(ctx, tx, jobID)
+		if err != nil {
+			return fmt.Errorf("transition job %s %s->%s: %w", jobID, from, to, err)
+		}
+		if inRound {
+			eventType = ""
+		}
+	}
 	if err := enqueueNotificationEventTx(ctx, tx, jobID, eventType); err != nil {
 		return fmt.Errorf("transition job %s %s->%s: %w", jobID, from, to, err)
 	}
@@ -302,6 +368,24 @@ func (s *Store) TransitionState(ctx context.Context, jobID, from, to string) err
 	return nil
 }
 
+// reviewRoundInProgressTx reports whether the job is addressing PR review
+// comments: its latest review_feedback artifact requested changes that have
+// not been addressed yet.
+func reviewRoundInProgressTx(ctx context.Context, tx *sql.Tx, jobID string) (bool, error) {
+	var status string
+	err := tx.QueryRowContext(ctx, `
+SELECT status FROM artifacts
+WHERE job_id = ? AND kind = 'review_feedback'
+ORDER BY id DESC LIMIT 1`, jobID).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("load review feedback: %w", err)
+	}
+	return status == "requested", nil
+}
+
 // RejectJob atomically sets reject_reason and transitions a job to rejected.
 func (s *Store) RejectJob(ctx context.Context, jobID, from, reason string) error {
 	allowed := ValidTransitions[from]
@@ -341,6 +425,38 @@ UPDATE jobs SET state = 'rejected', reject_reason = ?,
 	return nil
 }
 
+// RecordPlanDecision atomically stores a plan_approval artifact, sets the
+// job's human_notes when notes is non-empty and requeues a job awaiting plan
+// approval. Nothing is written unless the job is still awaiting approval.
+func (s *Store) RecordPlanDecision(ctx context.Context, a Artifact, notes string) error {
+	tx, err := s.Writer.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("record plan decision for job %s: %w", a.JobID, err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+UPDATE jobs SET state = 'queued',
+	            human_notes = CASE WHEN ? != '' THEN ? ELSE human_notes END,
+	            updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now')
+	WHERE id = ? AND state = 'awaiting_plan_approval'`, notes, notes, a.JobID)
+	if err != nil {
+		return fmt.Errorf("record plan decision for job %s: %w", a.JobID, err)
+	}
+	n, _ := res.RowsAffected()
+	if n == 0 {
+		return fmt.Errorf("job %s not in state awaiting_plan_approval (concurrent modification?)", a.JobID)
+	}
+	if _, err := tx.ExecContext(ctx, `INSERT INTO artifacts(job_id, autopr_issue_id, kind, content, iteration, commit_sha, status, data) VALUES(?,?,?,?,?,?,?,?)`,
+		a.JobID, a.AutoPRIssueID, a.Kind, a.Content, a.Iteration, a.CommitSHA, a.Status, a.Data); err != nil {
+		return fmt.Errorf("record plan decision for job %s: %w", a.JobID, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("record plan decision for job %s: %w", a.JobID, err)
+	}
+	return nil
+}
+
 // EnsureJobApproved transitions a ready job to approved. If the job is already
 // approved (or in another state due to concurrent updates), this is a no-op.
 func (s *Store) EnsureJobApproved(ctx context.Context, jobID string) error {
@@ -379,7 +495,7 @@ func (s *Store) GetJob(ctx context.Context, jobID string) (Job, error) {
 	       COALESCE(human_notes,''), COALESCE(error_message,''), COALESCE(pr_url,''),
 	       COALESCE(reject_reason,''), COALESCE(pr_merged_at,''), COALESCE(pr_closed_at,''),
 	       created_at, updated_at, COALESCE(started_at,''), COALESCE(completed_at,''),
-	       COALESCE(ci_started_at,''), COALESCE(ci_completed_at,''), COALESCE(ci_status_summary,'')
+	       COALESCE(ci_started_at,''), COALESCE(ci_completed_at,''), COALESCE(ci_status_summary,''), COALESCE(command_step,'')
 	FROM jobs WHERE id = ?`
 	var j Job
 	err := s.Reader.QueryRowContext(ctx, q, jobID).Scan(
@@ -388,7 +504,7 @@ func (s *Store) GetJob(ctx context.Context, jobID string) (Job, error) {
 		&j.HumanNotes, &j.ErrorMessage, &j.PRURL,
 		&j.RejectReason, &j.PRMergedAt, &j.PRClosedAt,
 		&j.CreatedAt, &j.UpdatedAt, &j.StartedAt, &j.CompletedAt,
-		&j.CIStartedAt, &j.CICompletedAt, &j.CIStatusSummary,
+		&j.CIStartedAt, &j.CICompletedAt, &j.CIStatusSummary, &j.CommandStep,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -400,7 +516,7 @@ func (s *Store) GetJob(ctx context.Context, jobID string) (Job, error) {
 }
 
 func buildJobsFilterClause(project, state string) (string, []any) {
-	activeStates := []string{"planning", "implementing", "reviewing", "testing", "rebasing", "resolving_conflicts", "awaiting_checks"}
+	activeStates := []string{"planning", "needs_info", "reviewing_plan", "awaiting_plan_approval", "implementing", "running_command", "reviewing", "testing", "rebasing", "resolving_conflicts", "awaiting_checks"}
 	clause := []string{"1=1"}
 	args := make([]any, 0, 3)
 
@@ -438,7 +554,7 @@ func (s *Store) ListJobs(ctx context.Context, project, state, orderBy string, as
 	       COALESCE(j.human_notes,''), COALESCE(j.error_message,''), COALESCE(j.pr_url,''),
 	       COALESCE(j.reject_reason,''), COALESCE(j.pr_merged_at,''), COALESCE(j.pr_closed_at,''),
 	       j.created_at, j.updated_at, COALESCE(j.started_at,''), COALESCE(j.completed_at,''),
-	       COALESCE(j.ci_started_at,''), COALESCE(j.ci_completed_at,''), COALESCE(j.ci_status_summary,''),
+	       COALESCE(j.ci_started_at,''), COALESCE(j.ci_completed_at,''), COALESCE(j.ci_status_summary,''), COALESCE(j.command_step,''),
 	       COALESCE(i.source,''), COALESCE(i.source_issue_id,''), COALESCE(i.title,''), COALESCE(i.url,'')
 FROM jobs j
 LEFT JOIN issues i ON j.autopr_issue_id = i.autopr_issue_id ` + whereClause
@@ -464,7 +580,7 @@ LEFT JOIN issues i ON j.autopr_issue_id = i.autopr_issue_id ` + whereClause
 			&j.HumanNotes, &j.ErrorMessage, &j.PRURL,
 			&j.RejectReason, &j.PRMergedAt, &j.PRClosedAt,
 			&j.CreatedAt, &j.UpdatedAt, &j.StartedAt, &j.CompletedAt,
-			&j.CIStartedAt, &j.CICompletedAt, &j.CIStatusSummary,
+			&j.CIStartedAt, &j.CICompletedAt, &j.CIStatusSummary, &j.CommandStep,
 			&j.IssueSource, &j.SourceIssueID, &j.IssueTitle, &j.IssueURL,
 		); err != nil {
 			return nil, fmt.Errorf("scan job: %w", err)
@@ -501,7 +617,7 @@ func (s *Store) ListJobsPage(ctx context.Context, project, state, orderBy string
 	       COALESCE(j.human_notes,''), COALESCE(j.error_message,''), COALESCE(j.pr_url,''),
 	       COALESCE(j.reject_reason,''), COALESCE(j.pr_merged_at,''), COALESCE(j.pr_closed_at,''),
 	       j.created_at, j.updated_at, COALESCE(j.started_at,''), COALESCE(j.completed_at,''),
-	       COALESCE(j.ci_started_at,''), COALESCE(j.ci_completed_at,''), COALESCE(j.ci_status_summary,''),
+	       COALESCE(j.ci_started_at,''), COALESCE(j.ci_completed_at,''), COALESCE(j.ci_status_summary,''), COALESCE(j.command_step,''),
 	       COALESCE(i.source,''), COALESCE(i.source_issue_id,''), COALESCE(i.title,''), COALESCE(i.url,'')
 FROM jobs j
 LEFT JOIN issues i ON j.autopr_issue_id = i.autopr_issue_id ` + whereClause + " ORDER BY " + orderExpr + " " + direction + ", j.id LIMIT ? OFFSET ?"
@@ -522,7 +638,7 @@ LEFT JOIN issues i ON j.autopr_issue_id = i.autopr_issue_id ` + whereClause + "
 			&j.HumanNotes, &j.ErrorMessage, &j.PRURL,
 			&j.RejectReason, &j.PRMergedAt, &j.PRClosedAt,
 			&j.CreatedAt, &j.UpdatedAt, &j.StartedAt, &j.CompletedAt,
-			&j.CIStartedAt, &j.CICompletedAt, &j.CIStatusSummary,
+			&j.CIStartedAt, &j.CICompletedAt, &j.CIStatusSummary, &j.CommandStep,
 			&j.IssueSource, &j.SourceIssueID, &j.IssueTitle, &j.IssueURL,
 		); err != nil {
 			return nil, 0, fmt.Errorf("scan job: %w", err)
@@ -541,19 +657,25 @@ func resolveJobOrderExpression(orderBy string) string {
 CASE
     WHEN j.state = 'queued' THEN 1
     WHEN j.state = 'planning' THEN 2
-    WHEN j.state = 'implementing' THEN 3
-    WHEN j.state = 'reviewing' THEN 4
-    WHEN j.state = 'testing' THEN 5
-    WHEN j.state = 'rebasing' THEN 6
-    WHEN j.state = 'resolving_conflicts' THEN 7
-    WHEN j.state = 'ready' THEN 8
-    WHEN j.state = 'awaiting_checks' THEN 9
-    WHEN j.state = 'approved' AND COALESCE(j.pr_merged_at, '') = '' THEN 10
-    WHEN j.state = 'merged' OR COALESCE(j.pr_merged_at, '') <> '' THEN 11
-    WHEN j.state = 'rejected' THEN 12
-    WHEN j.state = 'failed' THEN 13
-    WHEN j.state = 'cancelled' THEN 14
-    ELSE 15
+    WHEN j.state = 'needs_info' THEN 3
+    WHEN j.state = 'reviewing_plan' THEN 4
+    WHEN j.state = 'awaiting_plan_approval' THEN 5
+    WHEN j.state = 'implementing' THEN 6
+    WHEN j.state = 'running_command' THEN 7
+    WHEN j.state = 'reviewing' THEN 8
+    WHEN j.state = 'testing' THEN 9
+    WHEN j.state = 'rebasing' THEN 10
+    WHEN j.state = 'resolving_conflicts' THEN 11
+    WHEN j.state = 'ready' THEN 12
+    WHEN j.state = 'blocked' THEN 13
+    WHEN j.state = 'awaiting_checks' THEN 14
+    WHEN j.state = 'approved' AND COALESCE(j.pr_merged_at, '') = '' THEN 15
+    WHEN j.state = 'merged' OR COALESCE(j.pr_merged_at, '') <> '' THEN 16
+    WHEN j.state = 'rejected' THEN 17
+    WHEN j.state = 'needs_human' THEN 18
+    WHEN j.state = 'failed' THEN 19
+    WHEN j.state = 'cancelled' THEN 20
+    ELSE 21
 END`
 	case "created_at":
 		return "j.created_at"
@@ -570,7 +692,7 @@ func (s *Store) UpdateJobField(ctx context.Context, jobID, field, value string)
 		"worktree_path": true, "branch_name": true, "commit_sha": true,
 		"human_notes": true, "error_message": true, "pr_url": true,
 		"reject_reason": true, "pr_merged_at": true, "pr_closed_at": true,
-		"ci_status_summary": true,
+		"ci_status_summary": true, "command_step": true,
 	}
 	if !allowed[field] {
 		return fmt.Errorf("cannot update field %q", field)
@@ -602,15 +724,29 @@ func (s *Store) IncrementIteration(ctx context.Context, jobID string) error {
 	return nil
 }
 
-// ResetJobForRetry resets a failed/rejected/cancelled job to queued with fresh state.
+// StartReviewRound bumps the iteration of a job addressing PR review comments
+// and allows the round iterations more implement loops from there, whatever
+// the earlier rounds used.
+func (s *Store) StartReviewRound(ctx context.Context, jobID string, iterations int) error {
+	_, err := s.Writer.ExecContext(ctx, `
+UPDATE jobs SET iteration = iteration + 1, max_iterations = iteration + 1 + ?,
+               updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now')
+WHERE id = ?`, iterations, jobID)
+	if err != nil {
+		return fmt.Errorf("start review round %s: %w", jobID, err)
+	}
+	return nil
+}
+
+// ResetJobForRetry resets a failed/rejected/cancelled/needs_human job to queued with fresh state.
 func (s *Store) ResetJobForRetry(ctx context.Context, jobID, notes string) error {
 	res, err := s.Writer.ExecContext(ctx, `
 	UPDATE jobs SET state = 'queued', iteration = iteration + 1, worktree_path = NULL, branch_name = NULL,
 	               commit_sha = NULL, error_message = NULL, human_notes = ?,
 	               started_at = NULL, completed_at = NULL,
-	               ci_started_at = NULL, ci_completed_at = NULL, ci_status_summary = '',
+	               ci_started_at = NULL, ci_completed_at = NULL, ci_status_summary = '', command_step = NULL,
 	               updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now')
-WHERE id = ? AND state IN ('failed', 'rejected', 'cancelled')
+WHERE id = ? AND state IN ('failed', 'rejected', 'cancelled', 'needs_human')
   AND EXISTS (
     SELECT 1 FROM issues i
     WHERE i.autopr_issue_id = jobs.autopr_issue_id AND i.eligible = 1
@@ -620,7 +756,7 @@ WHERE id = ? AND state IN ('failed', 'rejected', 'cancelled')
     WHERE sibling.autopr_issue_id = jobs.autopr_issue_id
       AND sibling.id != jobs.id
       AND (
-        sibling.state NOT IN ('approved', 'rejected', 'failed', 'cancelled')
+        sibling.state NOT IN ('approved', 'rejected', 'failed', 'cancelled', 'needs_human')
         OR (sibling.state = 'approved' AND sibling.pr_url != ''
             AND (sibling.pr_merged_at IS NULL OR sibling.pr_merged_at = '')
             AND (sibling.pr_closed_at IS NULL OR sibling.pr_closed_at = ''))
@@ -641,7 +777,7 @@ SELECT j.state, COALESCE(i.eligible, 1), COALESCE(i.skip_reason, ''),
          SELECT s.id FROM jobs s
          WHERE s.autopr_issue_id = j.autopr_issue_id AND s.id != j.id
            AND (
-             s.state NOT IN ('approved', 'rejected', 'failed', 'cancelled')
+             s.state NOT IN ('approved', 'rejected', 'failed', 'cancelled', 'needs_human')
              OR (s.state = 'approved' AND s.pr_url != ''
                  AND (s.pr_merged_at IS NULL OR s.pr_merged_at = '')
                  AND (s.pr_closed_at IS NULL OR s.pr_closed_at = ''))
@@ -665,6 +801,16 @@ WHERE j.id = ?`, jobID).Scan(&state, &eligible, &skipReason, &siblingID)
 	return nil
 }
 
+// CountCompletedSessionsForStep returns the number of completed LLM sessions for a given job, iteration, and step.
+func (s *Store) CountCompletedSessionsForStep(ctx context.Context, jobID string, iteration int, step string) (int, error) {
+	const q = `SELECT COUNT(*) FROM llm_sessions WHERE job_id = ? AND iteration = ? AND step = ? AND status = 'completed'`
+	var count int
+	if err := s.Reader.QueryRowContext(ctx, q, jobID, iteration, step).Scan(&count); err != nil {
+		return 0, fmt.Errorf("count completed sessions for job %s step %s iteration %d: %w", jobID, step, iteration, err)
+	}
+	return count, nil
+}
+
 // HasCompletedSessionForStep reports whether a completed LLM session exists for a given job, iteration, and step.
 func (s *Store) HasCompletedSessionForStep(ctx context.Context, jobID string, iteration int, step string) (bool, error) {
 	const q = `SELECT COUNT(*) FROM llm_sessions WHERE job_id = ? AND iteration = ? AND step = ? AND status = 'completed'`
@@ -692,7 +838,7 @@ WHERE id = ? AND state IN ('failed', 'cancelled')
     WHERE sibling.autopr_issue_id = jobs.autopr_issue_id
       AND sibling.id != jobs.id
       AND (
-        sibling.state NOT IN ('approved', 'rejected', 'failed', 'cancelled')
+        sibling.state NOT IN ('approved', 'rejected', 'failed', 'cancelled', 'needs_human')
         OR (sibling.state = 'approved' AND sibling.pr_url != ''
             AND (sibling.pr_merged_at IS NULL OR sibling.pr_merged_at = '')
             AND (sibling.pr_closed_at IS NULL OR sibling.pr_closed_at = '')
@@ -714,7 +860,7 @@ SELECT j.state, COALESCE(i.eligible, 1), COALESCE(i.skip_reason, ''),
          SELECT s.id FROM jobs s
          WHERE s.autopr_issue_id = j.autopr_issue_id AND s.id != j.id
            AND (
-             s.state NOT IN ('approved', 'rejected', 'failed', 'cancelled')
+             s.state NOT IN ('approved', 'rejected', 'failed', 'cancelled', 'needs_human')
              OR (s.state = 'approved' AND s.pr_url != ''
                  AND (s.pr_merged_at IS NULL OR s.pr_merged_at = '')
                  AND (s.pr_closed_at IS NULL OR s.pr_closed_at = '')
@@ -753,7 +899,7 @@ func (s *Store) CancelJob(ctx context.Context, jobID string) error {
 	    END,
 	    completed_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now'),
 	    updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now')
-WHERE id = ? AND state IN ('queued', 'planning', 'implementing', 'reviewing', 'testing', 'rebasing', 'resolving_conflicts', 'awaiting_checks')`, jobID)
+WHERE id = ? AND state IN ('queued', 'planning', 'needs_info', 'reviewing_plan', 'awaiting_plan_approval', 'implementing', 'running_command', 'reviewing', 'testing', 'rebasing', 'resolving_conflicts', 'awaiting_checks')`, jobID)
 	if err != nil {
 		return fmt.Errorf("cancel job %s: %w", jobID, err)
 	}
@@ -784,7 +930,7 @@ func (s *Store) CancelAllCancellableJobs(ctx context.Context) ([]string, error)
 	    END,
 	    completed_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now'),
 	    updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now')
-WHERE state IN ('queued', 'planning', 'implementing', 'reviewing', 'testing', 'rebasing', 'resolving_conflicts', 'awaiting_checks')
+WHERE state IN ('queued', 'planning', 'needs_info', 'reviewing_plan', 'awaiting_plan_approval', 'implementing', 'running_command', 'reviewing', 'testing', 'rebasing', 'resolving_conflicts', 'awaiting_checks')
 RETURNING id`)
 	if err != nil {
 		return nil, fmt.Errorf("cancel all jobs: %w", err)
@@ -818,7 +964,7 @@ func (s *Store) CancelCancellableJobsForIssue(ctx context.Context, autoprIssueID
 	    completed_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now'),
 	    updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now')
 WHERE autopr_issue_id = ?
-  AND state IN ('queued', 'planning', 'implementing', 'reviewing', 'testing', 'rebasing', 'resolving_conflicts', 'awaiting_checks')
+  AND state IN ('queued', 'planning', 'needs_info', 'reviewing_plan', 'awaiting_plan_approval', 'implementing', 'running_command', 'reviewing', 'testing', 'rebasing', 'resolving_conflicts', 'awaiting_checks')
 RETURNING id`, reason, autoprIssueID)
 	if err != nil {
 		return nil, fmt.Errorf("cancel jobs for issue %s: %w", autoprIssueID, err)
@@ -900,7 +1046,7 @@ func (s *Store) ListApprovedJobsWithPR(ctx context.Context) ([]Job, error) {
 	       COALESCE(j.human_notes,''), COALESCE(j.error_message,''), COALESCE(j.pr_url,''),
 	       COALESCE(j.reject_reason,''), COALESCE(j.pr_merged_at,''), COALESCE(j.pr_closed_at,''),
 	       j.created_at, j.updated_at, COALESCE(j.started_at,''), COALESCE(j.completed_at,''),
-	       COALESCE(j.ci_started_at,''), COALESCE(j.ci_completed_at,''), COALESCE(j.ci_status_summary,''),
+	       COALESCE(j.ci_started_at,''), COALESCE(j.ci_completed_at,''), COALESCE(j.ci_status_summary,''), COALESCE(j.command_step,''),
 	       COALESCE(i.source,''), COALESCE(i.source_issue_id,''), COALESCE(i.title,''), COALESCE(i.url,'')
 FROM jobs j
 LEFT JOIN issues i ON j.autopr_issue_id = i.autopr_issue_id
@@ -923,7 +1069,7 @@ ORDER BY j.updated_at DESC`
 			&j.HumanNotes, &j.ErrorMessage, &j.PRURL,
 			&j.RejectReason, &j.PRMergedAt, &j.PRClosedAt,
 			&j.CreatedAt, &j.UpdatedAt, &j.StartedAt, &j.CompletedAt,
-			&j.CIStartedAt, &j.CICompletedAt, &j.CIStatusSummary,
+			&j.CIStartedAt, &j.CICompletedAt, &j.CIStatusSummary, &j.CommandStep,
 			&j.IssueSource, &j.SourceIssueID, &j.IssueTitle, &j.IssueURL,
 		); err != nil {
 			return nil, fmt.Errorf("scan approved job: %w", err)
@@ -941,7 +1087,7 @@ func (s *Store) ListAwaitingChecksJobs(ctx context.Context) ([]Job, error) {
 	       COALESCE(j.human_notes,''), COALESCE(j.error_message,''), COALESCE(j.pr_url,''),
 	       COALESCE(j.reject_reason,''), COALESCE(j.pr_merged_at,''), COALESCE(j.pr_closed_at,''),
 	       j.created_at, j.updated_at, COALESCE(j.started_at,''), COALESCE(j.completed_at,''),
-	       COALESCE(j.ci_started_at,''), COALESCE(j.ci_completed_at,''), COALESCE(j.ci_status_summary,''),
+	       COALESCE(j.ci_started_at,''), COALESCE(j.ci_completed_at,''), COALESCE(j.ci_status_summary,''), COALESCE(j.command_step,''),
 	       COALESCE(i.source,''), COALESCE(i.source_issue_id,''), COALESCE(i.title,''), COALESCE(i.url,'')
 FROM jobs j
 LEFT JOIN issues i ON j.autopr_issue_id = i.autopr_issue_id
@@ -962,7 +1108,7 @@ ORDER BY j.updated_at DESC`
 			&j.HumanNotes, &j.ErrorMessage, &j.PRURL,
 			&j.RejectReason, &j.PRMergedAt, &j.PRClosedAt,
 			&j.CreatedAt, &j.UpdatedAt, &j.StartedAt, &j.CompletedAt,
-			&j.CIStartedAt, &j.CICompletedAt, &j.CIStatusSummary,
+			&j.CIStartedAt, &j.CICompletedAt, &j.CIStatusSummary, &j.CommandStep,
 			&j.IssueSource, &j.SourceIssueID, &j.IssueTitle, &j.IssueURL,
 		); err != nil {
 			return nil, fmt.Errorf("scan awaiting_checks job: %w", err)
@@ -981,7 +1127,7 @@ func (s *Store) ListReadyOrApprovedJobsWithBranchNoPR(ctx context.Context) ([]Jo
 	       COALESCE(j.human_notes,''), COALESCE(j.error_message,''), COALESCE(j.pr_url,''),
 	       COALESCE(j.reject_reason,''), COALESCE(j.pr_merged_at,''), COALESCE(j.pr_closed_at,''),
 	       j.created_at, j.updated_at, COALESCE(j.started_at,''), COALESCE(j.completed_at,''),
-	       COALESCE(j.ci_started_at,''), COALESCE(j.ci_completed_at,''), COALESCE(j.ci_status_summary,''),
+	       COALESCE(j.ci_started_at,''), COALESCE(j.ci_completed_at,''), COALESCE(j.ci_status_summary,''), COALESCE(j.command_step,''),
 	       COALESCE(i.source,''), COALESCE(i.source_issue_id,''), COALESCE(i.title,''), COALESCE(i.url,'')
 FROM jobs j
 LEFT JOIN issues i ON j.autopr_issue_id = i.autopr_issue_id
@@ -1006,7 +1152,7 @@ ORDER BY j.updated_at DESC`
 			&j.HumanNotes, &j.ErrorMessage, &j.PRURL,
 			&j.RejectReason, &j.PRMergedAt, &j.PRClosedAt,
 			&j.CreatedAt, &j.UpdatedAt, &j.StartedAt, &j.CompletedAt,
-			&j.CIStartedAt, &j.CICompletedAt, &j.CIStatusSummary,
+			&j.CIStartedAt, &j.CICompletedAt, &j.CIStatusSummary, &j.CommandStep,
 			&j.IssueSource, &j.SourceIssueID, &j.IssueTitle, &j.IssueURL,
 		); err != nil {
 			return nil, fmt.Errorf("scan ready/approved branch job: %w", err)
@@ -1017,7 +1163,7 @@ ORDER BY j.updated_at DESC`
 }
 
 // ListCleanableJobs returns jobs whose worktrees can be safely removed:
-// rejected/failed/cancelled jobs, and approved jobs where the PR has been merged or closed.
+// rejected/failed/cancelled/needs_human jobs, and approved jobs where the PR has been merged or closed.
 func (s *Store) ListCleanableJobs(ctx context.Context) ([]Job, error) {
 	const q = `
 	SELECT id, autopr_issue_id, project_name, state, iteration, max_iterations,
@@ -1025,11 +1171,11 @@ func (s *Store) ListCleanableJobs(ctx context.Context) ([]Job, error) {
 	       COALESCE(human_notes,''), COALESCE(error_message,''), COALESCE(pr_url,''),
 	       COALESCE(reject_reason,''), COALESCE(pr_merged_at,''), COALESCE(pr_closed_at,''),
 	       created_at, updated_at, COALESCE(started_at,''), COALESCE(completed_at,''),
-	       COALESCE(ci_started_at,''), COALESCE(ci_completed_at,''), COALESCE(ci_status_summary,'')
+	       COALESCE(ci_started_at,''), COALESCE(ci_completed_at,''), COALESCE(ci_status_summary,''), COALESCE(command_step,'')
 FROM jobs
 WHERE worktree_path IS NOT NULL AND worktree_path != ''
   AND (
-    state IN ('rejected', 'failed', 'cancelled')
+    state IN ('rejected', 'failed', 'cancelled', 'needs_human')
     OR (state = 'approved' AND pr_merged_at IS NOT NULL AND pr_merged_at != '')
     OR (state = 'approved' AND pr_closed_at IS NOT NULL AND pr_closed_at != '')
   )
@@ -1049,7 +1195,7 @@ ORDER BY updated_at DESC`
 			&j.HumanNotes, &j.ErrorMessage, &j.PRURL,
 			&j.RejectReason, &j.PRMergedAt, &j.PRClosedAt,
 			&j.CreatedAt, &j.UpdatedAt, &j.StartedAt, &j.CompletedAt,
-			&j.CIStartedAt, &j.CICompletedAt, &j.CIStatusSummary,
+			&j.CIStartedAt, &j.CICompletedAt, &j.CIStatusSummary, &j.CommandStep,
 		); err != nil {
 			return nil, fmt.Errorf("scan cleanable job: %w", err)
 		}
@@ -1087,7 +1233,7 @@ func (s *Store) HasAnyNonMergedJobForIssue(ctx context.Context, autoprIssueID st
 // Returns true if there's a job in progress OR an approved job whose PR hasn't been merged/closed.
 func (s *Store) HasActiveJobForIssue(ctx context.Context, autoprIssueID string) (bool, error) {
 	const q = `SELECT COUNT(*) FROM jobs WHERE autopr_issue_id = ? AND (
-		state NOT IN ('approved', 'rejected', 'failed', 'cancelled')
+		state NOT IN ('approved', 'rejected', 'failed', 'cancelled', 'needs_human')
 		OR (state = 'approved' AND pr_url != '' AND (pr_merged_at IS NULL OR pr_merged_at = '') AND (pr_closed_at IS NULL OR pr_closed_at = ''))
 	)`
 	var count int
@@ -1101,7 +1247,7 @@ func (s *Store) HasActiveJobForIssue(ctx context.Context, autoprIssueID string)
 // GetActiveJobForIssue returns the ID of an active job for the given issue, or empty string if none.
 func (s *Store) GetActiveJobForIssue(ctx context.Context, autoprIssueID string) (string, error) {
 	const q = `SELECT id FROM jobs WHERE autopr_issue_id = ? AND (
-		state NOT IN ('approved', 'rejected', 'failed', 'cancelled')
+		state NOT IN ('approved', 'rejected', 'failed', 'cancelled', 'needs_human')
 		OR (state = 'approved' AND pr_url != '' AND (pr_merged_at IS NULL OR pr_merged_at = '') AND (pr_closed_at IS NULL OR pr_closed_at = ''))
 	) LIMIT 1`
 	var id string
@@ -1123,10 +1269,11 @@ type LLMSession struct {
 	Step         string
 	Iteration    int
 	LLMProvider  string
+	Model        string
 	PromptHash   string
 	ResponseText string
 	PromptText   string
-	InputTokens  int
+	InputTokens  int // excludes prompt-cache tokens
 	OutputTokens int
 	DurationMS   int
 	JSONLPath    string
@@ -1135,6 +1282,9 @@ type LLMSession struct {
 	ErrorMessage string
 	CreatedAt    string
 	CompletedAt  string
+
+	CacheReadTokens     int
+	CacheCreationTokens int
 }
 
 const recoveredSessionErrorMessage = "session recovered on daemon startup: previous run interrupted"
@@ -1163,6 +1313,23 @@ WHERE id = ? AND status = 'running'`,
 	return nil
 }
 
+// SetSessionModel records the model that ran a session.
+func (s *Store) SetSessionModel(ctx context.Context, sessionID int64, model string) error {
+	if _, err := s.Writer.ExecContext(ctx, `UPDATE llm_sessions SET model = ? WHERE id = ?`, model, sessionID); err != nil {
+		return fmt.Errorf("set session %d model: %w", sessionID, err)
+	}
+	return nil
+}
+
+// SetSessionCacheTokens records the prompt-cache token counts of a session.
+func (s *Store) SetSessionCacheTokens(ctx context.Context, sessionID int64, cacheRead, cacheCreation int) error {
+	if _, err := s.Writer.ExecContext(ctx, `UPDATE llm_sessions SET cache_read_tokens = ?, cache_creation_tokens = ? WHERE id = ?`,
+		cacheRead, cacheCreation, sessionID); err != nil {
+		return fmt.Errorf("set session %d cache tokens: %w", sessionID, err)
+	}
+	return nil
+}
+
 // RecoverRunningSessions marks any stale running LLM sessions as failed.
 // Called on daemon startup after a crash/interruption.
 func (s *Store) RecoverRunningSessions(ctx context.Context) (int64, error) {
@@ -1185,9 +1352,10 @@ WHERE status = 'running'`,
 
 func (s *Store) ListSessionsByJob(ctx context.Context, jobID string) ([]LLMSession, error) {
 	const q = `
-SELECT id, job_id, step, iteration, llm_provider,
+SELECT id, job_id, step, iteration, llm_provider, COALESCE(model,''),
        COALESCE(prompt_hash,''), COALESCE(response_text,''),
        COALESCE(input_tokens,0), COALESCE(output_tokens,0), COALESCE(duration_ms,0),
+       COALESCE(cache_read_tokens,0), COALESCE(cache_creation_tokens,0),
        COALESCE(jsonl_path,''), COALESCE(commit_sha,''), status,
        COALESCE(error_message,''), created_at, COALESCE(completed_at,'')
 FROM llm_sessions WHERE job_id = ? ORDER BY id ASC`
@@ -1201,9 +1369,10 @@ FROM llm_sessions WHERE job_id = ? ORDER BY id ASC`
 	for rows.Next() {
 		var sess LLMSession
 		if err := rows.Scan(
-			&sess.ID, &sess.JobID, &sess.Step, &sess.Iteration, &sess.LLMProvider,
+			&sess.ID, &sess.JobID, &sess.Step, &sess.Iteration, &sess.LLMProvider, &sess.Model,
 			&sess.PromptHash, &sess.ResponseText,
 			&sess.InputTokens, &sess.OutputTokens, &sess.DurationMS,
+			&sess.CacheReadTokens, &sess.CacheCreationTokens,
 			&sess.JSONLPath, &sess.CommitSHA, &sess.Status,
 			&sess.ErrorMessage, &sess.CreatedAt, &sess.CompletedAt,
 		); err != nil {
@@ -1221,6 +1390,7 @@ type LLMSessionSummary struct {
 	Step         string
 	Iteration    int
 	LLMProvider  string
+	Model        string
 	InputTokens  int
 	OutputTokens int
 	DurationMS   int
@@ -1228,12 +1398,16 @@ type LLMSessionSummary struct {
 	ErrorMessage string
 	CreatedAt    string
 	CompletedAt  string
+
+	CacheReadTokens     int
+	CacheCreationTokens int
 }
 
 func (s *Store) ListSessionSummariesByJob(ctx context.Context, jobID string) ([]LLMSessionSummary, error) {
 	const q = `
-SELECT id, job_id, step, iteration, llm_provider,
+SELECT id, job_id, step, iteration, llm_provider, COALESCE(model,''),
        COALESCE(input_tokens,0), COALESCE(output_tokens,0), COALESCE(duration_ms,0),
+       COALESCE(cache_read_tokens,0), COALESCE(cache_creation_tokens,0),
        status, COALESCE(error_message,''), created_at, COALESCE(completed_at,'')
 FROM llm_sessions WHERE job_id = ? ORDER BY id ASC`
 	rows, err := s.Reader.QueryContext(ctx, q, jobID)
@@ -1246,8 +1420,9 @@ FROM llm_sessions WHERE job_id = ? ORDER BY id ASC`
 	for rows.Next() {
 		var sess LLMSessionSummary
 		if err := rows.Scan(
-			&sess.ID, &sess.JobID, &sess.Step, &sess.Iteration, &sess.LLMProvider,
+			&sess.ID, &sess.JobID, &sess.Step, &sess.Iteration, &sess.LLMProvider, &sess.Model,
 			&sess.InputTokens, &sess.OutputTokens, &sess.DurationMS,
+			&sess.CacheReadTokens, &sess.CacheCreationTokens,
 			&sess.Status, &sess.ErrorMessage, &sess.CreatedAt, &sess.CompletedAt,
 		); err != nil {
 			return nil, fmt.Errorf("scan session summary: %w", err)
@@ -1259,17 +1434,19 @@ FROM llm_sessions WHERE job_id = ? ORDER BY id ASC`
 
 func (s *Store) GetFullSession(ctx context.Context, sessionID int) (LLMSession, error) {
 	const q = `
-SELECT id, job_id, step, iteration, llm_provider,
+SELECT id, job_id, step, iteration, llm_provider, COALESCE(model,''),
        COALESCE(prompt_hash,''), COALESCE(response_text,''), COALESCE(prompt_text,''),
        COALESCE(input_tokens,0), COALESCE(output_tokens,0), COALESCE(duration_ms,0),
+       COALESCE(cache_read_tokens,0), COALESCE(cache_creation_tokens,0),
        COALESCE(jsonl_path,''), COALESCE(commit_sha,''), status,
        COALESCE(error_message,''), created_at, COALESCE(completed_at,'')
 FROM llm_sessions WHERE id = ?`
 	var sess LLMSession
 	err := s.Reader.QueryRowContext(ctx, q, sessionID).Scan(
-		&sess.ID, &sess.JobID, &sess.Step, &sess.Iteration, &sess.LLMProvider,
+		&sess.ID, &sess.JobID, &sess.Step, &sess.Iteration, &sess.LLMProvider, &sess.Model,
 		&sess.PromptHash, &sess.ResponseText, &sess.PromptText,
 		&sess.InputTokens, &sess.OutputTokens, &sess.DurationMS,
+		&sess.CacheReadTokens, &sess.CacheCreationTokens,
 		&sess.JSONLPath, &sess.CommitSHA, &sess.Status,
 		&sess.ErrorMessage, &sess.CreatedAt, &sess.CompletedAt,
 	)
@@ -1292,6 +1469,8 @@ type Artifact struct {
 	Content       string
 	Iteration     int
 	CommitSHA     string
+	Status        string // passed, failed or warned for command step output
+	Data          string // structured JSON payload, e.g. code review findings
 	CreatedAt     string
 }
 
@@ -1304,13 +1483,24 @@ func (s *Store) CreateArtifact(ctx context.Context, jobID, autoprIssueID, kind,
 	return res.LastInsertId()
 }
 
+// InsertArtifact stores an artifact including its status and structured data.
+// ID and CreatedAt are ignored.
+func (s *Store) InsertArtifact(ctx context.Context, a Artifact) (int64, error) {
+	const q = `INSERT INTO artifacts(job_id, autopr_issue_id, kind, content, iteration, commit_sha, status, data) VALUES(?,?,?,?,?,?,?,?)`
+	res, err := s.Writer.ExecContext(ctx, q, a.JobID, a.AutoPRIssueID, a.Kind, a.Content, a.Iteration, a.CommitSHA, a.Status, a.Data)
+	if err != nil {
+		return 0, fmt.Errorf("create artifact: %w", err)
+	}
+	return res.LastInsertId()
+}
+
 func (s *Store) GetLatestArtifact(ctx context.Context, jobID, kind string) (Artifact, error) {
 	const q = `
-SELECT id, job_id, autopr_issue_id, kind, content, iteration, COALESCE(commit_sha,''), created_at
+SELECT id, job_id, autopr_issue_id, kind, content, iteration, COALESCE(commit_sha,''), status, data, created_at
 FROM artifacts WHERE job_id = ? AND kind = ? ORDER BY id DESC LIMIT 1`
 	var a Artifact
 	err := s.Reader.QueryRowContext(ctx, q, jobID, kind).Scan(
-		&a.ID, &a.JobID, &a.AutoPRIssueID, &a.Kind, &a.Content, &a.Iteration, &a.CommitSHA, &a.CreatedAt,
+		&a.ID, &a.JobID, &a.AutoPRIssueID, &a.Kind, &a.Content, &a.Iteration, &a.CommitSHA, &a.Status, &a.Data, &a.CreatedAt,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -1323,7 +1513,7 @@ FROM artifacts WHERE job_id = ? AND kind = ? ORDER BY id DESC LIMIT 1`
 
 func (s *Store) ListArtifactsByJob(ctx context.Context, jobID string) ([]Artifact, error) {
 	const q = `
-SELECT id, job_id, autopr_issue_id, kind, content, iteration, COALESCE(commit_sha,''), created_at
+SELECT id, job_id, autopr_issue_id, kind, content, iteration, COALESCE(commit_sha,''), status, data, created_at
 FROM artifacts WHERE job_id = ? ORDER BY id ASC`
 	rows, err := s.Reader.QueryContext(ctx, q, jobID)
 	if err != nil {
@@ -1334,7 +1524,7 @@ FROM artifacts WHERE job_id = ? ORDER BY id ASC`
 	var out []Artifact
 	for rows.Next() {
 		var a Artifact
-		if err := rows.Scan(&a.ID, &a.JobID, &a.AutoPRIssueID, &a.Kind, &a.Content, &a.Iteration, &a.CommitSHA, &a.CreatedAt); err != nil {
+		if err := rows.Scan(&a.ID, &a.JobID, &a.AutoPRIssueID, &a.Kind, &a.Content, &a.Iteration, &a.CommitSHA, &a.Status, &a.Data, &a.CreatedAt); err != nil {
 			return nil, fmt.Errorf("scan artifact: %w", err)
 		}
 		out = append(out, a)
@@ -1402,30 +1592,34 @@ func ShortID(id string) string {
 
 // TokenSummary holds aggregated token/cost data for a job's sessions.
 type TokenSummary struct {
-	TotalInputTokens  int
-	TotalOutputTokens int
-	TotalDurationMS   int
-	SessionCount      int
-	Provider          string // Most-used provider (for cost calculation).
+	TotalInputTokens         int
+	TotalOutputTokens        int
+	TotalCacheReadTokens     int
+	TotalCacheCreationTokens int
+	TotalDurationMS          int
+	SessionCount             int
+	Provider                 string       // Most-used provider.
+	Models                   []ModelUsage // Per provider/model token counts, for pricing.
+}
+
+// ModelUsage holds the token counts of a job's sessions on one provider/model.
+type ModelUsage struct {
+	Provider            string
+	Model               string
+	Sessions            int
+	InputTokens         int
+	OutputTokens        int
+	CacheReadTokens     int
+	CacheCreationTokens int
 }
 
 // AggregateTokensByJob returns aggregated token counts for a single job.
 func (s *Store) AggregateTokensByJob(ctx context.Context, jobID string) (TokenSummary, error) {
-	const q = `
-SELECT COALESCE(SUM(input_tokens),0), COALESCE(SUM(output_tokens),0),
-       COALESCE(SUM(duration_ms),0), COUNT(*),
-       COALESCE((SELECT llm_provider FROM llm_sessions WHERE job_id = ? AND status IN ('completed','failed')
-                 GROUP BY llm_provider ORDER BY COUNT(*) DESC LIMIT 1), '')
-FROM llm_sessions WHERE job_id = ? AND status IN ('completed','failed')`
-	var ts TokenSummary
-	err := s.Reader.QueryRowContext(ctx, q, jobID, jobID).Scan(
-		&ts.TotalInputTokens, &ts.TotalOutputTokens,
-		&ts.TotalDurationMS, &ts.SessionCount, &ts.Provider,
-	)
+	summaries, err := s.AggregateTokensForJobs(ctx, []string{jobID})
 	if err != nil {
 		return TokenSummary{}, fmt.Errorf("aggregate tokens for job %s: %w", jobID, err)
 	}
-	return ts, nil
+	return summaries[jobID], nil
 }
 
 // AggregateTokensForJobs returns aggregated token counts for multiple jobs.
@@ -1443,14 +1637,14 @@ func (s *Store) AggregateTokensForJobs(ctx context.Context, jobIDs []string) (ma
 	ph := strings.Join(placeholders, ",")
 
 	q := fmt.Sprintf(`
-SELECT job_id, COALESCE(SUM(input_tokens),0), COALESCE(SUM(output_tokens),0),
-       COALESCE(SUM(duration_ms),0), COUNT(*),
-       COALESCE((SELECT s2.llm_provider FROM llm_sessions s2
-                 WHERE s2.job_id = llm_sessions.job_id AND s2.status IN ('completed','failed')
-                 GROUP BY s2.llm_provider ORDER BY COUNT(*) DESC LIMIT 1), '')
+SELECT job_id, llm_provider, COALESCE(model,''), COUNT(*),
+       COALESCE(SUM(input_tokens),0), COALESCE(SUM(output_tokens),0),
+       COALESCE(SUM(cache_read_tokens),0), COALESCE(SUM(cache_creation_tokens),0),
+       COALESCE(SUM(duration_ms),0)
 FROM llm_sessions
 WHERE job_id IN (%s) AND status IN ('completed','failed')
-GROUP BY job_id`, ph)
+GROUP BY job_id, llm_provider, COALESCE(model,'')
+ORDER BY job_id, COUNT(*) DESC, llm_provider, COALESCE(model,'')`, ph)
 
 	rows, err := s.Reader.QueryContext(ctx, q, args...)
 	if err != nil {
@@ -1459,13 +1653,32 @@ GROUP BY job_id`, ph)
 	defer rows.Close()
 
 	out := make(map[string]TokenSummary, len(jobIDs))
+	providerSessions := make(map[string]map[string]int)
 	for rows.Next() {
-		var ts TokenSummary
 		var jobID string
-		if err := rows.Scan(&jobID, &ts.TotalInputTokens, &ts.TotalOutputTokens,
-			&ts.TotalDurationMS, &ts.SessionCount, &ts.Provider); err != nil {
+		var mu ModelUsage
+		var durationMS int
+		if err := rows.Scan(&jobID, &mu.Provider, &mu.Model, &mu.Sessions,
+			&mu.InputTokens, &mu.OutputTokens, &mu.CacheReadTokens, &mu.CacheCreationTokens,
+			&durationMS); err != nil {
 			return nil, fmt.Errorf("scan token summary: %w", err)
 		}
+		ts := out[jobID]
+		ts.TotalInputTokens += mu.InputTokens
+		ts.TotalOutputTokens += mu.OutputTokens
+		ts.TotalCacheReadTokens += mu.CacheReadTokens
+		ts.TotalCacheCreationTokens += mu.CacheCreationTokens
+		ts.TotalDurationMS += durationMS
+		ts.SessionCount += mu.Sessions
+		ts.Models = append(ts.Models, mu)
+
+		if providerSessions[jobID] == nil {
+			providerSessions[jobID] = make(map[string]int)
+		}
+		providerSessions[jobID][mu.Provider] += mu.Sessions
+		if n := providerSessions[jobID][mu.Provider]; ts.Provider == "" || n > providerSessions[jobID][ts.Provider] {
+			ts.Provider = mu.Provider
+		}
 		out[jobID] = ts
 	}
 	return out, rows.Err()
@@ -1474,17 +1687,19 @@ GROUP BY job_id`, ph)
 // GetRunningSessionForJob returns the most recent running session for a job, or nil if none.
 func (s *Store) GetRunningSessionForJob(ctx context.Context, jobID string) (*LLMSession, error) {
 	const q = `
-SELECT id, job_id, step, iteration, llm_provider,
+SELECT id, job_id, step, iteration, llm_provider, COALESCE(model,''),
        COALESCE(prompt_hash,''), COALESCE(response_text,''),
        COALESCE(input_tokens,0), COALESCE(output_tokens,0), COALESCE(duration_ms,0),
+       COALESCE(cache_read_tokens,0), COALESCE(cache_creation_tokens,0),
        COALESCE(jsonl_path,''), COALESCE(commit_sha,''), status,
        COALESCE(error_message,''), created_at, COALESCE(completed_at,'')
 FROM llm_sessions WHERE job_id = ? AND status = 'running' ORDER BY id DESC LIMIT 1`
 	var sess LLMSession
 	err := s.Reader.QueryRowContext(ctx, q, jobID).Scan(
-		&sess.ID, &sess.JobID, &sess.Step, &sess.Iteration, &sess.LLMProvider,
+		&sess.ID, &sess.JobID, &sess.Step, &sess.Iteration, &sess.LLMProvider, &sess.Model,
 		&sess.PromptHash, &sess.ResponseText,
 		&sess.InputTokens, &sess.OutputTokens, &sess.DurationMS,
+		&sess.CacheReadTokens, &sess.CacheCreationTokens,
 		&sess.JSONLPath, &sess.CommitSHA, &sess.Status,
 		&sess.ErrorMessage, &sess.CreatedAt, &sess.CompletedAt,
 	)
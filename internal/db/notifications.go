@@ -9,10 +9,12 @@ import (
 )
 
 const (
-	NotificationEventNeedsPR  = "needs_pr"
-	NotificationEventFailed   = "failed"
-	NotificationEventPRCreated = "pr_created"
-	NotificationEventPRMerged  = "pr_merged"
+	NotificationEventNeedsPR      = "needs_pr"
+	NotificationEventFailed       = "failed"
+	NotificationEventPRCreated    = "pr_created"
+	NotificationEventPRMerged     = "pr_merged"
+	NotificationEventBudget       = "budget_threshold"
+	NotificationEventPlanApproval = "plan_approval"
 )
 
 const (
@@ -29,6 +31,7 @@ type NotificationEvent struct {
 	ID        int64
 	JobID     string
 	EventType string
+	Message   string
 	Status    string
 	Attempts  int
 	LastError string
@@ -37,12 +40,18 @@ type NotificationEvent struct {
 }
 
 func (s *Store) EnqueueNotificationEvent(ctx context.Context, jobID, eventType string) (int64, error) {
+	return s.EnqueueNotificationEventMessage(ctx, jobID, eventType, "")
+}
+
+// EnqueueNotificationEventMessage enqueues an event carrying a message for
+// the notification body, such as which budget crossed its threshold.
+func (s *Store) EnqueueNotificationEventMessage(ctx context.Context, jobID, eventType, message string) (int64, error) {
 	if err := validateNotificationEventType(eventType); err != nil {
 		return 0, err
 	}
 	res, err := s.Writer.ExecContext(ctx, `
-INSERT INTO notification_events(job_id, event_type, status)
-VALUES(?, ?, 'pending')`, jobID, eventType)
+INSERT INTO notification_events(job_id, event_type, message, status)
+VALUES(?, ?, ?, 'pending')`, jobID, eventType, message)
 	if err != nil {
 		return 0, fmt.Errorf("enqueue notification event for job %s: %w", jobID, err)
 	}
@@ -70,7 +79,7 @@ VALUES(?, ?, 'pending')`, jobID, eventType); err != nil {
 
 func (s *Store) ListNotificationEvents(ctx context.Context, status string, limit int) ([]NotificationEvent, error) {
 	q := `
-SELECT id, job_id, event_type, status, attempts, COALESCE(last_error, ''), created_at, updated_at
+SELECT id, job_id, event_type, message, status, attempts, COALESCE(last_error, ''), created_at, updated_at
 FROM notification_events`
 	args := make([]any, 0, 2)
 	if status != "" {
@@ -96,6 +105,7 @@ FROM notification_events`
 			&event.ID,
 			&event.JobID,
 			&event.EventType,
+			&event.Message,
 			&event.Status,
 			&event.Attempts,
 			&event.LastError,
@@ -139,13 +149,14 @@ WHERE id = (
 	ORDER BY created_at ASC
 	LIMIT 1
 )
-RETURNING id, job_id, event_type, status, attempts, COALESCE(last_error, ''), created_at, updated_at`
+RETURNING id, job_id, event_type, message, status, attempts, COALESCE(last_error, ''), created_at, updated_at`
 
 	var event NotificationEvent
 	err := s.Writer.QueryRowContext(ctx, q, maxAttempts).Scan(
 		&event.ID,
 		&event.JobID,
 		&event.EventType,
+		&event.Message,
 		&event.Status,
 		&event.Attempts,
 		&event.LastError,
@@ -254,7 +265,7 @@ WHERE status IN ('sent', 'skipped')
 
 func validateNotificationEventType(eventType string) error {
 	switch eventType {
-	case NotificationEventNeedsPR, NotificationEventFailed, NotificationEventPRCreated, NotificationEventPRMerged:
+	case NotificationEventNeedsPR, NotificationEventFailed, NotificationEventPRCreated, NotificationEventPRMerged, NotificationEventBudget, NotificationEventPlanApproval:
 		return nil
 	default:
 		return fmt.Errorf("unsupported notification event type %q", eventType)
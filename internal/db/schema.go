@@ -41,7 +41,7 @@ CREATE TABLE IF NOT EXISTS jobs (
     autopr_issue_id TEXT NOT NULL REFERENCES issues(autopr_issue_id) ON DELETE RESTRICT,
     project_name     TEXT NOT NULL,
     state            TEXT NOT NULL DEFAULT 'queued'
-        CHECK(state IN ('queued','planning','implementing','reviewing','testing','ready','rebasing','resolving_conflicts','awaiting_checks','approved','rejected','failed','cancelled')),
+        CHECK(state IN ('queued','planning','needs_info','reviewing_plan','awaiting_plan_approval','implementing','running_command','reviewing','testing','ready','blocked','rebasing','resolving_conflicts','awaiting_checks','approved','rejected','failed','cancelled','needs_human')),
     iteration        INTEGER NOT NULL DEFAULT 0 CHECK(iteration >= 0),
     max_iterations   INTEGER NOT NULL DEFAULT 3 CHECK(max_iterations > 0),
     worktree_path    TEXT,
@@ -59,7 +59,8 @@ CREATE TABLE IF NOT EXISTS jobs (
     completed_at     TEXT,
     ci_started_at    TEXT,
     ci_completed_at  TEXT,
-    ci_status_summary TEXT
+    ci_status_summary TEXT,
+    command_step     TEXT
 );
 
 CREATE INDEX IF NOT EXISTS idx_jobs_state ON jobs(state);
@@ -67,19 +68,22 @@ CREATE INDEX IF NOT EXISTS idx_jobs_issue ON jobs(autopr_issue_id);
 CREATE INDEX IF NOT EXISTS idx_jobs_state_project ON jobs(state, project_name);
 CREATE UNIQUE INDEX IF NOT EXISTS idx_jobs_one_active_per_issue
     ON jobs(autopr_issue_id)
-    WHERE state NOT IN ('approved', 'rejected', 'failed', 'cancelled');
+    WHERE state NOT IN ('approved', 'rejected', 'failed', 'cancelled', 'needs_human');
 
 CREATE TABLE IF NOT EXISTS llm_sessions (
     id            INTEGER PRIMARY KEY AUTOINCREMENT,
     job_id        TEXT NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
     step          TEXT NOT NULL CHECK(step IN ('plan','plan_review','implement','code_review','tests','conflict_resolution')),
     iteration     INTEGER NOT NULL DEFAULT 0,
-    llm_provider  TEXT NOT NULL CHECK(llm_provider IN ('codex', 'claude')),
+    llm_provider  TEXT NOT NULL,
+    model         TEXT,
     prompt_hash   TEXT,
     response_text TEXT,
     prompt_text   TEXT,
     input_tokens  INTEGER,
     output_tokens INTEGER,
+    cache_read_tokens     INTEGER,
+    cache_creation_tokens INTEGER,
     duration_ms   INTEGER,
     jsonl_path    TEXT,
     commit_sha    TEXT,
@@ -92,20 +96,38 @@ CREATE TABLE IF NOT EXISTS llm_sessions (
 CREATE INDEX IF NOT EXISTS idx_sessions_job ON llm_sessions(job_id);
 CREATE INDEX IF NOT EXISTS idx_sessions_job_iteration_step_status
     ON llm_sessions(job_id, iteration, step, status);
+CREATE INDEX IF NOT EXISTS idx_sessions_created ON llm_sessions(created_at);
 
 CREATE TABLE IF NOT EXISTS artifacts (
     id               INTEGER PRIMARY KEY AUTOINCREMENT,
     job_id           TEXT NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
     autopr_issue_id  TEXT NOT NULL,
-    kind             TEXT NOT NULL CHECK(kind IN ('plan','plan_review','code_review','test_output','rebase_conflict','rebase_result')),
+    kind             TEXT NOT NULL CHECK(kind IN ('plan','plan_review','code_review','test_output','rebase_conflict','rebase_result','diff_policy','secret_scan','plan_approval','clarification','review_feedback') OR kind GLOB 'command:*' OR kind GLOB 'test_output:*'),
     content          TEXT NOT NULL,
     iteration        INTEGER NOT NULL DEFAULT 0,
     commit_sha       TEXT,
+    status           TEXT NOT NULL DEFAULT '',
+    data             TEXT NOT NULL DEFAULT '',
     created_at       TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
 );
 
 CREATE INDEX IF NOT EXISTS idx_artifacts_job ON artifacts(job_id);
 
+CREATE TABLE IF NOT EXISTS session_events (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    session_id  INTEGER NOT NULL REFERENCES llm_sessions(id) ON DELETE CASCADE,
+    job_id      TEXT NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    seq         INTEGER NOT NULL,
+    event_type  TEXT NOT NULL,
+    tool        TEXT NOT NULL DEFAULT '',
+    path        TEXT NOT NULL DEFAULT '',
+    command     TEXT NOT NULL DEFAULT '',
+    exit_code   INTEGER,
+    created_at  TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_session_events_job ON session_events(job_id, session_id, seq);
+
 CREATE TABLE IF NOT EXISTS sync_cursors (
     project_name   TEXT NOT NULL,
     source         TEXT NOT NULL CHECK(source IN ('gitlab', 'github', 'sentry')),
@@ -117,7 +139,8 @@ CREATE TABLE IF NOT EXISTS sync_cursors (
 CREATE TABLE IF NOT EXISTS notification_events (
     id         INTEGER PRIMARY KEY AUTOINCREMENT,
     job_id     TEXT NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
-    event_type TEXT NOT NULL CHECK(event_type IN ('needs_pr','failed','pr_created','pr_merged')),
+    event_type TEXT NOT NULL CHECK(event_type IN ('needs_pr','failed','pr_created','pr_merged','budget_threshold','plan_approval')),
+    message    TEXT NOT NULL DEFAULT '',
     status     TEXT NOT NULL DEFAULT 'pending' CHECK(status IN ('pending','processing','sent','failed','skipped')),
     attempts   INTEGER NOT NULL DEFAULT 0 CHECK(attempts >= 0),
     last_error TEXT NOT NULL DEFAULT '',
@@ -129,6 +152,24 @@ CREATE INDEX IF NOT EXISTS idx_notification_events_status_created
     ON notification_events(status, created_at);
 CREATE INDEX IF NOT EXISTS idx_notification_events_job
     ON notification_events(job_id);
+
+CREATE TABLE IF NOT EXISTS budget_alerts (
+    scope      TEXT NOT NULL,
+    period     TEXT NOT NULL,
+    created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    PRIMARY KEY(scope, period)
+);
+
+CREATE TABLE IF NOT EXISTS test_baselines (
+    project_name TEXT NOT NULL,
+    base_sha     TEXT NOT NULL,
+    test_cmd     TEXT NOT NULL,
+    content      TEXT NOT NULL DEFAULT '',
+    status       TEXT NOT NULL DEFAULT '',
+    data         TEXT NOT NULL DEFAULT '',
+    created_at   TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    PRIMARY KEY(project_name, base_sha, test_cmd)
+);
 `
 
 func (s *Store) createSchema() error {
@@ -184,18 +225,53 @@ func (s *Store) createSchema() error {
 	}
 	if _, err := s.Writer.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_jobs_one_active_per_issue
 		ON jobs(autopr_issue_id)
-		WHERE state NOT IN ('approved', 'rejected', 'failed', 'cancelled')`); err != nil {
+		WHERE state NOT IN ('approved', 'rejected', 'failed', 'cancelled', 'needs_human')`); err != nil {
 		return fmt.Errorf("create active-job index: %w", err)
 	}
 
 	if err := s.migrateNotificationEventsNeedsPR(); err != nil {
 		return err
 	}
+	if err := s.migrateNotificationEventsForBudgetThreshold(); err != nil {
+		return err
+	}
+	if err := s.migrateNotificationEventTypes(); err != nil {
+		return err
+	}
 
 	// Ensure CI metadata columns exist even if an older migration recreated jobs.
 	_, _ = s.Writer.Exec("ALTER TABLE jobs ADD COLUMN ci_started_at TEXT")
 	_, _ = s.Writer.Exec("ALTER TABLE jobs ADD COLUMN ci_completed_at TEXT")
 	_, _ = s.Writer.Exec("ALTER TABLE jobs ADD COLUMN ci_status_summary TEXT")
+	if err := s.migrateJobsForPlanReviewStates(); err != nil {
+		return err
+	}
+	_, _ = s.Writer.Exec("ALTER TABLE jobs ADD COLUMN command_step TEXT")
+	if err := s.migrateJobsForCommandSteps(); err != nil {
+		return err
+	}
+	if err := s.migrateJobStates(); err != nil {
+		return err
+	}
+	if err := s.migrateArtifactsForCommandKinds(); err != nil {
+		return err
+	}
+	_, _ = s.Writer.Exec("ALTER TABLE artifacts ADD COLUMN data TEXT NOT NULL DEFAULT ''")
+	if err := s.migrateArtifactKinds(); err != nil {
+		return err
+	}
+
+	if err := s.migrateSessionsForCustomProviders(); err != nil {
+		return err
+	}
+	_, _ = s.Writer.Exec("ALTER TABLE llm_sessions ADD COLUMN model TEXT")
+	_, _ = s.Writer.Exec("ALTER TABLE llm_sessions ADD COLUMN cache_read_tokens INTEGER")
+	_, _ = s.Writer.Exec("ALTER TABLE llm_sessions ADD COLUMN cache_creation_tokens INTEGER")
+	// Budget checks sum recent sessions on every worker poll. Created after
+	// the migrations above, which may rebuild llm_sessions.
+	if _, err := s.Writer.Exec(`CREATE INDEX IF NOT EXISTS idx_sessions_created ON llm_sessions(created_at)`); err != nil {
+		return fmt.Errorf("create idx_sessions_created: %w", err)
+	}
 
 	return nil
 }
@@ -512,6 +588,288 @@ FROM jobs`); err != nil {
 	})
 }
 
+// migrateJobsForPlanReviewStates recreates jobs to allow the reviewing_plan
+// and needs_human states. It runs after the CI columns are re-added, so they
+// are carried over.
+func (s *Store) migrateJobsForPlanReviewStates() error {
+	sqlText, err := s.tableSQL("jobs")
+	if err != nil {
+		return err
+	}
+	if strings.Contains(sqlText, "'needs_human'") {
+		return nil
+	}
+
+	return s.withForeignKeysOff(func() error {
+		tx, err := s.Writer.Begin()
+		if err != nil {
+			return fmt.Errorf("begin jobs plan review migration: %w", err)
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.Exec(`
+CREATE TABLE jobs_new (
+    id              TEXT PRIMARY KEY,
+    autopr_issue_id TEXT NOT NULL REFERENCES issues(autopr_issue_id) ON DELETE RESTRICT,
+    project_name     TEXT NOT NULL,
+    state            TEXT NOT NULL DEFAULT 'queued'
+        CHECK(state IN ('queued','planning','reviewing_plan','implementing','reviewing','testing','ready','rebasing','resolving_conflicts','awaiting_checks','approved','rejected','failed','cancelled','needs_human')),
+    iteration        INTEGER NOT NULL DEFAULT 0 CHECK(iteration >= 0),
+    max_iterations   INTEGER NOT NULL DEFAULT 3 CHECK(max_iterations > 0),
+    worktree_path    TEXT,
+    branch_name      TEXT,
+    commit_sha       TEXT,
+    human_notes      TEXT,
+    error_message    TEXT,
+    pr_url           TEXT,
+    pr_merged_at     TEXT,
+    pr_closed_at     TEXT,
+    reject_reason    TEXT,
+    created_at       TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    updated_at       TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    started_at       TEXT,
+    completed_at     TEXT,
+    ci_started_at    TEXT,
+    ci_completed_at  TEXT,
+    ci_status_summary TEXT
+)`); err != nil {
+			return fmt.Errorf("create jobs_new for plan review migration: %w", err)
+		}
+
+		if _, err := tx.Exec(`
+INSERT INTO jobs_new (
+    id, autopr_issue_id, project_name, state, iteration, max_iterations,
+    worktree_path, branch_name, commit_sha, human_notes, error_message,
+    pr_url, pr_merged_at, pr_closed_at, reject_reason, created_at, updated_at,
+    started_at, completed_at, ci_started_at, ci_completed_at, ci_status_summary
+)
+SELECT
+    id, autopr_issue_id, project_name, state, iteration, max_iterations,
+    worktree_path, branch_name, commit_sha, human_notes, error_message,
+    pr_url, pr_merged_at, pr_closed_at, reject_reason, created_at, updated_at,
+    started_at, completed_at, ci_started_at, ci_completed_at, ci_status_summary
+FROM jobs`); err != nil {
+			return fmt.Errorf("copy jobs rows for plan review migration: %w", err)
+		}
+
+		if _, err := tx.Exec(`DROP TABLE jobs`); err != nil {
+			return fmt.Errorf("drop jobs for plan review migration: %w", err)
+		}
+		if _, err := tx.Exec(`ALTER TABLE jobs_new RENAME TO jobs`); err != nil {
+			return fmt.Errorf("rename jobs_new for plan review migration: %w", err)
+		}
+		if _, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_jobs_state ON jobs(state)`); err != nil {
+			return fmt.Errorf("create idx_jobs_state for plan review migration: %w", err)
+		}
+		if _, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_jobs_issue ON jobs(autopr_issue_id)`); err != nil {
+			return fmt.Errorf("create idx_jobs_issue for plan review migration: %w", err)
+		}
+		if _, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_jobs_state_project ON jobs(state, project_name)`); err != nil {
+			return fmt.Errorf("create idx_jobs_state_project for plan review migration: %w", err)
+		}
+		if _, err := tx.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_jobs_one_active_per_issue
+    ON jobs(autopr_issue_id)
+    WHERE state NOT IN ('approved', 'rejected', 'failed', 'cancelled', 'needs_human')`); err != nil {
+			return fmt.Errorf("create active-job index for plan review migration: %w", err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("commit jobs plan review migration: %w", err)
+		}
+		return nil
+	})
+}
+
+// migrateJobsForCommandSteps recreates jobs to allow the running_command
+// state. It runs after the command_step column is added, so it is carried
+// over.
+func (s *Store) migrateJobsForCommandSteps() error {
+	sqlText, err := s.tableSQL("jobs")
+	if err != nil {
+		return err
+	}
+	if strings.Contains(sqlText, "'running_command'") {
+		return nil
+	}
+
+	return s.withForeignKeysOff(func() error {
+		tx, err := s.Writer.Begin()
+		if err != nil {
+			return fmt.Errorf("begin jobs command step migration: %w", err)
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.Exec(`
+CREATE TABLE jobs_new (
+    id              TEXT PRIMARY KEY,
+    autopr_issue_id TEXT NOT NULL REFERENCES issues(autopr_issue_id) ON DELETE RESTRICT,
+    project_name     TEXT NOT NULL,
+    state            TEXT NOT NULL DEFAULT 'queued'
+        CHECK(state IN ('queued','planning','reviewing_plan','implementing','running_command','reviewing','testing','ready','rebasing','resolving_conflicts','awaiting_checks','approved','rejected','failed','cancelled','needs_human')),
+    iteration        INTEGER NOT NULL DEFAULT 0 CHECK(iteration >= 0),
+    max_iterations   INTEGER NOT NULL DEFAULT 3 CHECK(max_iterations > 0),
+    worktree_path    TEXT,
+    branch_name      TEXT,
+    commit_sha       TEXT,
+    human_notes      TEXT,
+    error_message    TEXT,
+    pr_url           TEXT,
+    pr_merged_at     TEXT,
+    pr_closed_at     TEXT,
+    reject_reason    TEXT,
+    created_at       TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    updated_at       TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    started_at       TEXT,
+    completed_at     TEXT,
+    ci_started_at    TEXT,
+    ci_completed_at  TEXT,
+    ci_status_summary TEXT,
+    command_step     TEXT
+)`); err != nil {
+			return fmt.Errorf("create jobs_new for command step migration: %w", err)
+		}
+
+		if _, err := tx.Exec(`
+INSERT INTO jobs_new (
+    id, autopr_issue_id, project_name, state, iteration, max_iterations,
+    worktree_path, branch_name, commit_sha, human_notes, error_message,
+    pr_url, pr_merged_at, pr_closed_at, reject_reason, created_at, updated_at,
+    started_at, completed_at, ci_started_at, ci_completed_at, ci_status_summary,
+    command_step
+)
+SELECT
+    id, autopr_issue_id, project_name, state, iteration, max_iterations,
+    worktree_path, branch_name, commit_sha, human_notes, error_message,
+    pr_url, pr_merged_at, pr_closed_at, reject_reason, created_at, updated_at,
+    started_at, completed_at, ci_started_at, ci_completed_at, ci_status_summary,
+    command_step
+FROM jobs`); err != nil {
+			return fmt.Errorf("copy jobs rows for command step migration: %w", err)
+		}
+
+		if _, err := tx.Exec(`DROP TABLE jobs`); err != nil {
+			return fmt.Errorf("drop jobs for command step migration: %w", err)
+		}
+		if _, err := tx.Exec(`ALTER TABLE jobs_new RENAME TO jobs`); err != nil {
+			return fmt.Errorf("rename jobs_new for command step migration: %w", err)
+		}
+		if _, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_jobs_state ON jobs(state)`); err != nil {
+			return fmt.Errorf("create idx_jobs_state for command step migration: %w", err)
+		}
+		if _, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_jobs_issue ON jobs(autopr_issue_id)`); err != nil {
+			return fmt.Errorf("create idx_jobs_issue for command step migration: %w", err)
+		}
+		if _, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_jobs_state_project ON jobs(state, project_name)`); err != nil {
+			return fmt.Errorf("create idx_jobs_state_project for command step migration: %w", err)
+		}
+		if _, err := tx.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_jobs_one_active_per_issue
+    ON jobs(autopr_issue_id)
+    WHERE state NOT IN ('approved', 'rejected', 'failed', 'cancelled', 'needs_human')`); err != nil {
+			return fmt.Errorf("create active-job index for command step migration: %w", err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("commit jobs command step migration: %w", err)
+		}
+		return nil
+	})
+}
+
+// migrateJobStates recreates jobs with the current state constraint, which
+// adds the blocked, awaiting_plan_approval and needs_info states. The check
+// looks for the newest state.
+func (s *Store) migrateJobStates() error {
+	sqlText, err := s.tableSQL("jobs")
+	if err != nil {
+		return err
+	}
+	if strings.Contains(sqlText, "'needs_info'") {
+		return nil
+	}
+
+	return s.withForeignKeysOff(func() error {
+		tx, err := s.Writer.Begin()
+		if err != nil {
+			return fmt.Errorf("begin job state migration: %w", err)
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.Exec(`
+CREATE TABLE jobs_new (
+    id              TEXT PRIMARY KEY,
+    autopr_issue_id TEXT NOT NULL REFERENCES issues(autopr_issue_id) ON DELETE RESTRICT,
+    project_name     TEXT NOT NULL,
+    state            TEXT NOT NULL DEFAULT 'queued'
+        CHECK(state IN ('queued','planning','needs_info','reviewing_plan','awaiting_plan_approval','implementing','running_command','reviewing','testing','ready','blocked','rebasing','resolving_conflicts','awaiting_checks','approved','rejected','failed','cancelled','needs_human')),
+    iteration        INTEGER NOT NULL DEFAULT 0 CHECK(iteration >= 0),
+    max_iterations   INTEGER NOT NULL DEFAULT 3 CHECK(max_iterations > 0),
+    worktree_path    TEXT,
+    branch_name      TEXT,
+    commit_sha       TEXT,
+    human_notes      TEXT,
+    error_message    TEXT,
+    pr_url           TEXT,
+    pr_merged_at     TEXT,
+    pr_closed_at     TEXT,
+    reject_reason    TEXT,
+    created_at       TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    updated_at       TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    started_at       TEXT,
+    completed_at     TEXT,
+    ci_started_at    TEXT,
+    ci_completed_at  TEXT,
+    ci_status_summary TEXT,
+    command_step     TEXT
+)`); err != nil {
+			return fmt.Errorf("create jobs_new for job state migration: %w", err)
+		}
+
+		if _, err := tx.Exec(`
+INSERT INTO jobs_new (
+    id, autopr_issue_id, project_name, state, iteration, max_iterations,
+    worktree_path, branch_name, commit_sha, human_notes, error_message,
+    pr_url, pr_merged_at, pr_closed_at, reject_reason, created_at, updated_at,
+    started_at, completed_at, ci_started_at, ci_completed_at, ci_status_summary,
+    command_step
+)
+SELECT
+    id, autopr_issue_id, project_name, state, iteration, max_iterations,
+    worktree_path, branch_name, commit_sha, human_notes, error_message,
+    pr_url, pr_merged_at, pr_closed_at, reject_reason, created_at, updated_at,
+    started_at, completed_at, ci_started_at, ci_completed_at, ci_status_summary,
+    command_step
+FROM jobs`); err != nil {
+			return fmt.Errorf("copy jobs rows for job state migration: %w", err)
+		}
+
+		if _, err := tx.Exec(`DROP TABLE jobs`); err != nil {
+			return fmt.Errorf("drop jobs for job state migration: %w", err)
+		}
+		if _, err := tx.Exec(`ALTER TABLE jobs_new RENAME TO jobs`); err != nil {
+			return fmt.Errorf("rename jobs_new for job state migration: %w", err)
+		}
+		if _, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_jobs_state ON jobs(state)`); err != nil {
+			return fmt.Errorf("create idx_jobs_state for job state migration: %w", err)
+		}
+		if _, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_jobs_issue ON jobs(autopr_issue_id)`); err != nil {
+			return fmt.Errorf("create idx_jobs_issue for job state migration: %w", err)
+		}
+		if _, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_jobs_state_project ON jobs(state, project_name)`); err != nil {
+			return fmt.Errorf("create idx_jobs_state_project for job state migration: %w", err)
+		}
+		if _, err := tx.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_jobs_one_active_per_issue
+    ON jobs(autopr_issue_id)
+    WHERE state NOT IN ('approved', 'rejected', 'failed', 'cancelled', 'needs_human')`); err != nil {
+			return fmt.Errorf("create active-job index for job state migration: %w", err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("commit job state migration: %w", err)
+		}
+		return nil
+	})
+}
+
 func (s *Store) migrateSessionsForCancelledStatus() error {
 	sqlText, err := s.tableSQL("llm_sessions")
 	if err != nil {
@@ -653,6 +1011,82 @@ FROM llm_sessions`); err != nil {
 	})
 }
 
+func (s *Store) migrateSessionsForCustomProviders() error {
+	sqlText, err := s.tableSQL("llm_sessions")
+	if err != nil {
+		return err
+	}
+	// Custom providers from [llm.providers.<name>] can use any name, so the
+	// legacy CHECK on llm_provider is dropped.
+	if !strings.Contains(sqlText, "check(llm_provider") {
+		return nil
+	}
+
+	return s.withForeignKeysOff(func() error {
+		tx, err := s.Writer.Begin()
+		if err != nil {
+			return fmt.Errorf("begin llm_sessions custom provider migration: %w", err)
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.Exec(`
+CREATE TABLE llm_sessions_new (
+    id            INTEGER PRIMARY KEY AUTOINCREMENT,
+    job_id        TEXT NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    step          TEXT NOT NULL CHECK(step IN ('plan','plan_review','implement','code_review','tests','conflict_resolution')),
+    iteration     INTEGER NOT NULL DEFAULT 0,
+    llm_provider  TEXT NOT NULL,
+    prompt_hash   TEXT,
+    response_text TEXT,
+    prompt_text   TEXT,
+    input_tokens  INTEGER,
+    output_tokens INTEGER,
+    duration_ms   INTEGER,
+    jsonl_path    TEXT,
+    commit_sha    TEXT,
+    status        TEXT NOT NULL DEFAULT 'running' CHECK(status IN ('running','completed','failed','cancelled')),
+    error_message TEXT,
+    created_at    TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    completed_at  TEXT
+)`); err != nil {
+			return fmt.Errorf("create llm_sessions_new for custom provider migration: %w", err)
+		}
+
+		if _, err := tx.Exec(`
+INSERT INTO llm_sessions_new (
+    id, job_id, step, iteration, llm_provider, prompt_hash, response_text, prompt_text,
+    input_tokens, output_tokens, duration_ms, jsonl_path, commit_sha, status,
+    error_message, created_at, completed_at
+)
+SELECT
+    id, job_id, step, iteration, llm_provider, prompt_hash, response_text, prompt_text,
+    input_tokens, output_tokens, duration_ms, jsonl_path, commit_sha, status,
+    error_message, created_at, completed_at
+FROM llm_sessions`); err != nil {
+			return fmt.Errorf("copy llm_sessions rows for custom provider migration: %w", err)
+		}
+
+		if _, err := tx.Exec(`DROP TABLE llm_sessions`); err != nil {
+			return fmt.Errorf("drop llm_sessions for custom provider migration: %w", err)
+		}
+		if _, err := tx.Exec(`ALTER TABLE llm_sessions_new RENAME TO llm_sessions`); err != nil {
+			return fmt.Errorf("rename llm_sessions_new for custom provider migration: %w", err)
+		}
+		if _, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_sessions_job ON llm_sessions(job_id)`); err != nil {
+			return fmt.Errorf("create idx_sessions_job for custom provider migration: %w", err)
+		}
+		if _, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_sessions_job_iteration_step_status
+    ON llm_sessions(job_id, iteration, step, status)`); err != nil {
+			return fmt.Errorf("create idx_sessions_job_iteration_step_status for custom provider migration: %w", err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("commit llm_sessions custom provider migration: %w", err)
+		}
+		return nil
+	})
+}
+
 func (s *Store) migrateArtifactsForRebaseKind() error {
 	sqlText, err := s.tableSQL("artifacts")
 	if err != nil {
@@ -767,6 +1201,129 @@ FROM artifacts`); err != nil {
 	})
 }
 
+// migrateArtifactsForCommandKinds recreates artifacts to allow the
+// "command:<step>" kinds of pipeline command steps and adds the status column.
+func (s *Store) migrateArtifactsForCommandKinds() error {
+	sqlText, err := s.tableSQL("artifacts")
+	if err != nil {
+		return err
+	}
+	if strings.Contains(sqlText, "'command:*'") {
+		return nil
+	}
+
+	return s.withForeignKeysOff(func() error {
+		tx, err := s.Writer.Begin()
+		if err != nil {
+			return fmt.Errorf("begin artifacts command kind migration: %w", err)
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.Exec(`
+CREATE TABLE artifacts_new (
+    id               INTEGER PRIMARY KEY AUTOINCREMENT,
+    job_id           TEXT NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    autopr_issue_id  TEXT NOT NULL,
+    kind             TEXT NOT NULL CHECK(kind IN ('plan','plan_review','code_review','test_output','rebase_conflict','rebase_result') OR kind GLOB 'command:*'),
+    content          TEXT NOT NULL,
+    iteration        INTEGER NOT NULL DEFAULT 0,
+    commit_sha       TEXT,
+    status           TEXT NOT NULL DEFAULT '',
+    created_at       TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
+)`); err != nil {
+			return fmt.Errorf("create artifacts_new for command kind migration: %w", err)
+		}
+
+		if _, err := tx.Exec(`
+INSERT INTO artifacts_new (
+    id, job_id, autopr_issue_id, kind, content, iteration, commit_sha, created_at
+)
+SELECT
+    id, job_id, autopr_issue_id, kind, content, iteration, commit_sha, created_at
+FROM artifacts`); err != nil {
+			return fmt.Errorf("copy artifacts rows for command kind migration: %w", err)
+		}
+
+		if _, err := tx.Exec(`DROP TABLE artifacts`); err != nil {
+			return fmt.Errorf("drop artifacts for command kind migration: %w", err)
+		}
+		if _, err := tx.Exec(`ALTER TABLE artifacts_new RENAME TO artifacts`); err != nil {
+			return fmt.Errorf("rename artifacts_new for command kind migration: %w", err)
+		}
+		if _, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_artifacts_job ON artifacts(job_id)`); err != nil {
+			return fmt.Errorf("create idx_artifacts_job for command kind migration: %w", err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("commit artifacts command kind migration: %w", err)
+		}
+		return nil
+	})
+}
+
+// migrateArtifactKinds recreates artifacts with the current kind constraint,
+// which adds the "test_output:<name>" kinds of individual test commands and
+// the diff_policy, secret_scan, plan_approval, clarification and
+// review_feedback kinds. The check looks for the newest kind.
+func (s *Store) migrateArtifactKinds() error {
+	sqlText, err := s.tableSQL("artifacts")
+	if err != nil {
+		return err
+	}
+	if strings.Contains(sqlText, "'review_feedback'") {
+		return nil
+	}
+
+	return s.withForeignKeysOff(func() error {
+		tx, err := s.Writer.Begin()
+		if err != nil {
+			return fmt.Errorf("begin artifact kind migration: %w", err)
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.Exec(`
+CREATE TABLE artifacts_new (
+    id               INTEGER PRIMARY KEY AUTOINCREMENT,
+    job_id           TEXT NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    autopr_issue_id  TEXT NOT NULL,
+    kind             TEXT NOT NULL CHECK(kind IN ('plan','plan_review','code_review','test_output','rebase_conflict','rebase_result','diff_policy','secret_scan','plan_approval','clarification','review_feedback') OR kind GLOB 'command:*' OR kind GLOB 'test_output:*'),
+    content          TEXT NOT NULL,
+    iteration        INTEGER NOT NULL DEFAULT 0,
+    commit_sha       TEXT,
+    status           TEXT NOT NULL DEFAULT '',
+    data             TEXT NOT NULL DEFAULT '',
+    created_at       TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
+)`); err != nil {
+			return fmt.Errorf("create artifacts_new for artifact kind migration: %w", err)
+		}
+
+		if _, err := tx.Exec(`
+INSERT INTO artifacts_new (
+    id, job_id, autopr_issue_id, kind, content, iteration, commit_sha, status, data, created_at
+)
+SELECT
+    id, job_id, autopr_issue_id, kind, content, iteration, commit_sha, status, data, created_at
+FROM artifacts`); err != nil {
+			return fmt.Errorf("copy artifacts rows for artifact kind migration: %w", err)
+		}
+
+		if _, err := tx.Exec(`DROP TABLE artifacts`); err != nil {
+			return fmt.Errorf("drop artifacts for artifact kind migration: %w", err)
+		}
+		if _, err := tx.Exec(`ALTER TABLE artifacts_new RENAME TO artifacts`); err != nil {
+			return fmt.Errorf("rename artifacts_new for artifact kind migration: %w", err)
+		}
+		if _, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_artifacts_job ON artifacts(job_id)`); err != nil {
+			return fmt.Errorf("create idx_artifacts_job for artifact kind migration: %w", err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("commit artifact kind migration: %w", err)
+		}
+		return nil
+	})
+}
+
 // migrateNotificationEventsNeedsPR renames event_type 'awaiting_approval' → 'needs_pr'
 // and recreates the table with an updated CHECK constraint.
 func (s *Store) migrateNotificationEventsNeedsPR() error {
@@ -828,6 +1385,127 @@ FROM notification_events`); err != nil {
 	})
 }
 
+// migrateNotificationEventsForBudgetThreshold recreates notification_events
+// with 'budget_threshold' in the event_type CHECK and a message column.
+func (s *Store) migrateNotificationEventsForBudgetThreshold() error {
+	sqlText, err := s.tableSQL("notification_events")
+	if err != nil {
+		return err
+	}
+	if strings.Contains(sqlText, "'budget_threshold'") {
+		return nil
+	}
+
+	return s.withForeignKeysOff(func() error {
+		tx, err := s.Writer.Begin()
+		if err != nil {
+			return fmt.Errorf("begin notification_events budget migration: %w", err)
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.Exec(`
+CREATE TABLE notification_events_new (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    job_id     TEXT NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    event_type TEXT NOT NULL CHECK(event_type IN ('needs_pr','failed','pr_created','pr_merged','budget_threshold')),
+    message    TEXT NOT NULL DEFAULT '',
+    status     TEXT NOT NULL DEFAULT 'pending' CHECK(status IN ('pending','processing','sent','failed','skipped')),
+    attempts   INTEGER NOT NULL DEFAULT 0 CHECK(attempts >= 0),
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    updated_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
+)`); err != nil {
+			return fmt.Errorf("create notification_events_new: %w", err)
+		}
+
+		if _, err := tx.Exec(`
+INSERT INTO notification_events_new (id, job_id, event_type, status, attempts, last_error, created_at, updated_at)
+SELECT id, job_id, event_type, status, attempts, last_error, created_at, updated_at
+FROM notification_events`); err != nil {
+			return fmt.Errorf("copy notification_events rows: %w", err)
+		}
+
+		if _, err := tx.Exec(`DROP TABLE notification_events`); err != nil {
+			return fmt.Errorf("drop notification_events: %w", err)
+		}
+		if _, err := tx.Exec(`ALTER TABLE notification_events_new RENAME TO notification_events`); err != nil {
+			return fmt.Errorf("rename notification_events_new: %w", err)
+		}
+		if _, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_notification_events_status_created ON notification_events(status, created_at)`); err != nil {
+			return fmt.Errorf("create idx_notification_events_status_created: %w", err)
+		}
+		if _, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_notification_events_job ON notification_events(job_id)`); err != nil {
+			return fmt.Errorf("create idx_notification_events_job: %w", err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("commit notification_events budget migration: %w", err)
+		}
+		return nil
+	})
+}
+
+// migrateNotificationEventTypes recreates notification_events with the
+// current event_type constraint, which adds plan_approval. The check looks
+// for the newest event type.
+func (s *Store) migrateNotificationEventTypes() error {
+	sqlText, err := s.tableSQL("notification_events")
+	if err != nil {
+		return err
+	}
+	if strings.Contains(sqlText, "'plan_approval'") {
+		return nil
+	}
+
+	return s.withForeignKeysOff(func() error {
+		tx, err := s.Writer.Begin()
+		if err != nil {
+			return fmt.Errorf("begin notification_events event type migration: %w", err)
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.Exec(`
+CREATE TABLE notification_events_new (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    job_id     TEXT NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    event_type TEXT NOT NULL CHECK(event_type IN ('needs_pr','failed','pr_created','pr_merged','budget_threshold','plan_approval')),
+    message    TEXT NOT NULL DEFAULT '',
+    status     TEXT NOT NULL DEFAULT 'pending' CHECK(status IN ('pending','processing','sent','failed','skipped')),
+    attempts   INTEGER NOT NULL DEFAULT 0 CHECK(attempts >= 0),
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+    updated_at TEXT NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%SZ', 'now'))
+)`); err != nil {
+			return fmt.Errorf("create notification_events_new for event type migration: %w", err)
+		}
+
+		if _, err := tx.Exec(`
+INSERT INTO notification_events_new (id, job_id, event_type, message, status, attempts, last_error, created_at, updated_at)
+SELECT id, job_id, event_type, message, status, attempts, last_error, created_at, updated_at
+FROM notification_events`); err != nil {
+			return fmt.Errorf("copy notification_events rows for event type migration: %w", err)
+		}
+
+		if _, err := tx.Exec(`DROP TABLE notification_events`); err != nil {
+			return fmt.Errorf("drop notification_events for event type migration: %w", err)
+		}
+		if _, err := tx.Exec(`ALTER TABLE notification_events_new RENAME TO notification_events`); err != nil {
+			return fmt.Errorf("rename notification_events_new for event type migration: %w", err)
+		}
+		if _, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_notification_events_status_created ON notification_events(status, created_at)`); err != nil {
+			return fmt.Errorf("create idx_notification_events_status_created for event type migration: %w", err)
+		}
+		if _, err := tx.Exec(`CREATE INDEX IF NOT EXISTS idx_notification_events_job ON notification_events(job_id)`); err != nil {
+			return fmt.Errorf("create idx_notification_events_job for event type migration: %w", err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("commit notification_events event type migration: %w", err)
+		}
+		return nil
+	})
+}
+
 // RecoverInFlightJobs resets any jobs stuck in active states back to queued,
 // except rebasing/resolving_conflicts which return to ready to continue readiness checks.
 // Called on daemon startup after a crash.
@@ -838,7 +1516,7 @@ func (s *Store) RecoverInFlightJobs(ctx context.Context, reposRoot string) (int6
 	inFlightQuery := `
 SELECT id, state, COALESCE(worktree_path, '')
 FROM jobs
-WHERE state IN ('planning', 'implementing', 'reviewing', 'testing', 'rebasing', 'resolving_conflicts')`
+WHERE state IN ('planning', 'reviewing_plan', 'implementing', 'running_command', 'reviewing', 'testing', 'rebasing', 'resolving_conflicts')`
 	rows, err := s.Reader.QueryContext(ctx, inFlightQuery)
 	if err != nil {
 		return 0, fmt.Errorf("recover in-flight jobs: query in-flight jobs: %w", err)
@@ -877,7 +1555,7 @@ WHERE state IN ('planning', 'implementing', 'reviewing', 'testing', 'rebasing',
 		ELSE 'queued'
 	END,
 	updated_at = strftime('%Y-%m-%dT%H:%M:%SZ', 'now')
-	WHERE state IN ('planning', 'implementing', 'reviewing', 'testing', 'rebasing', 'resolving_conflicts')`)
+	WHERE state IN ('planning', 'reviewing_plan', 'implementing', 'running_command', 'reviewing', 'testing', 'rebasing', 'resolving_conflicts')`)
 	if err != nil {
 		return 0, fmt.Errorf("recover in-flight jobs: %w", err)
 	}
@@ -0,0 +1,78 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// UsageFilter selects the sessions of a usage report. Zero values are
+// unbounded.
+type UsageFilter struct {
+	Project string
+	Since   time.Time // sessions created at or after
+	Until   time.Time // sessions created before
+}
+
+// SessionUsage is the token usage of one job's sessions for one step, day and
+// provider/model, together with the job's outcome fields.
+type SessionUsage struct {
+	JobID      string
+	Project    string
+	Step       string
+	Day        string // YYYY-MM-DD (UTC) the sessions were created
+	JobState   string
+	PRMergedAt string
+	PRClosedAt string
+	ModelUsage
+}
+
+// ListSessionUsage returns the usage of finished sessions matching f, one row
+// per job, step, day and provider/model. Cancelled sessions are included with
+// the tokens they used before they were stopped.
+func (s *Store) ListSessionUsage(ctx context.Context, f UsageFilter) ([]SessionUsage, error) {
+	q := `
+SELECT s.job_id, j.project_name, s.step, substr(s.created_at, 1, 10),
+       j.state, COALESCE(j.pr_merged_at,''), COALESCE(j.pr_closed_at,''),
+       s.llm_provider, COALESCE(s.model,''), COUNT(*),
+       COALESCE(SUM(s.input_tokens),0), COALESCE(SUM(s.output_tokens),0),
+       COALESCE(SUM(s.cache_read_tokens),0), COALESCE(SUM(s.cache_creation_tokens),0)
+FROM llm_sessions s
+JOIN jobs j ON j.id = s.job_id
+WHERE s.status IN ('completed','failed','cancelled')`
+	var args []any
+	if f.Project != "" {
+		q += ` AND j.project_name = ?`
+		args = append(args, f.Project)
+	}
+	if !f.Since.IsZero() {
+		q += ` AND s.created_at >= ?`
+		args = append(args, f.Since.UTC().Format("2006-01-02T15:04:05Z"))
+	}
+	if !f.Until.IsZero() {
+		q += ` AND s.created_at < ?`
+		args = append(args, f.Until.UTC().Format("2006-01-02T15:04:05Z"))
+	}
+	q += `
+GROUP BY s.job_id, s.step, substr(s.created_at, 1, 10), s.llm_provider, COALESCE(s.model,'')
+ORDER BY substr(s.created_at, 1, 10), s.job_id, s.step`
+
+	rows, err := s.Reader.QueryContext(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list session usage: %w", err)
+	}
+	defer rows.Close()
+
+	var out []SessionUsage
+	for rows.Next() {
+		var u SessionUsage
+		if err := rows.Scan(&u.JobID, &u.Project, &u.Step, &u.Day,
+			&u.JobState, &u.PRMergedAt, &u.PRClosedAt,
+			&u.Provider, &u.Model, &u.Sessions,
+			&u.InputTokens, &u.OutputTokens, &u.CacheReadTokens, &u.CacheCreationTokens); err != nil {
+			return nil, fmt.Errorf("scan session usage: %w", err)
+		}
+		out = append(out, u)
+	}
+	return out, rows.Err()
+}
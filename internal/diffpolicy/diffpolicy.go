@@ -0,0 +1,203 @@
+// Package diffpolicy checks a job's changes against the project's diff
+// policy: size limits, denied and allowed paths, and paths whose CODEOWNERS
+// entries need a human to approve them.
+package diffpolicy
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"autopr/internal/config"
+)
+
+// Diff summarizes a job's changes against the base branch.
+type Diff struct {
+	Files []string `json:"files"`
+	Lines int      `json:"lines"` // insertions plus deletions
+}
+
+// Violation is one broken rule of the policy.
+type Violation struct {
+	Rule    string   `json:"rule"` // max_files, max_lines, deny, allow or codeowners
+	Message string   `json:"message"`
+	Files   []string `json:"files,omitempty"`
+}
+
+// maxListedFiles caps the paths named in a violation message.
+const maxListedFiles = 10
+
+// Check returns the rules of policy that diff breaks. owners are the base
+// branch's CODEOWNERS rules, only consulted when the policy names owners.
+func Check(policy config.ProjectDiffPolicy, diff Diff, owners []OwnerRule) []Violation {
+	var violations []Violation
+	if policy.MaxFiles > 0 && len(diff.Files) > policy.MaxFiles {
+		violations = append(violations, Violation{
+			Rule:    "max_files",
+			Message: fmt.Sprintf("%d files changed, at most %d allowed", len(diff.Files), policy.MaxFiles),
+		})
+	}
+	if policy.MaxLines > 0 && diff.Lines > policy.MaxLines {
+		violations = append(violations, Violation{
+			Rule:    "max_lines",
+			Message: fmt.Sprintf("%d lines changed, at most %d allowed", diff.Lines, policy.MaxLines),
+		})
+	}
+
+	var denied, notAllowed []string
+	protected := make(map[string][]string) // policy owner -> files
+	for _, file := range diff.Files {
+		if slices.ContainsFunc(policy.Deny, func(p string) bool { return Match(p, file) }) {
+			denied = append(denied, file)
+		}
+		if len(policy.Allow) > 0 && !slices.ContainsFunc(policy.Allow, func(p string) bool { return Match(p, file) }) {
+			notAllowed = append(notAllowed, file)
+		}
+		fileOwners := Owners(owners, file)
+		for _, owner := range policy.CodeOwners {
+			if slices.ContainsFunc(fileOwners, func(o string) bool { return strings.EqualFold(o, owner) }) {
+				protected[owner] = append(protected[owner], file)
+			}
+		}
+	}
+	if len(denied) > 0 {
+		violations = append(violations, Violation{
+			Rule:    "deny",
+			Message: "changes denied paths: " + listFiles(denied),
+			Files:   denied,
+		})
+	}
+	if len(notAllowed) > 0 {
+		violations = append(violations, Violation{
+			Rule:    "allow",
+			Message: "changes paths outside the allowed ones: " + listFiles(notAllowed),
+			Files:   notAllowed,
+		})
+	}
+	for _, owner := range policy.CodeOwners {
+		if files := protected[owner]; len(files) > 0 {
+			violations = append(violations, Violation{
+				Rule:    "codeowners",
+				Message: fmt.Sprintf("changes paths owned by %s, which need human approval: %s", owner, listFiles(files)),
+				Files:   files,
+			})
+		}
+	}
+	return violations
+}
+
+// Format renders violations as one line each.
+func Format(violations []Violation) string {
+	lines := make([]string, 0, len(violations))
+	for _, v := range violations {
+		lines = append(lines, "- "+v.Message)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func listFiles(files []string) string {
+	if len(files) > maxListedFiles {
+		return strings.Join(files[:maxListedFiles], ", ") + fmt.Sprintf(" and %d more", len(files)-maxListedFiles)
+	}
+	return strings.Join(files, ", ")
+}
+
+// Match reports whether the slash-separated path name matches a
+// CODEOWNERS-style pattern. A pattern without a slash (other than a trailing
+// one) matches a file name in any directory; otherwise, including with a
+// leading slash, it is anchored at the repository root. "**" matches any
+// number of directories, and a pattern that names a directory matches
+// everything inside it.
+func Match(pattern, name string) bool {
+	dirOnly := strings.HasSuffix(pattern, "/")
+	anchored := strings.HasPrefix(pattern, "/")
+	pattern = strings.Trim(pattern, "/")
+	if pattern == "" {
+		return false
+	}
+	segs := strings.Split(pattern, "/")
+	if len(segs) == 1 && !anchored {
+		segs = append([]string{"**"}, segs...)
+	}
+	names := strings.Split(name, "/")
+	if !dirOnly && matchSegments(segs, names) {
+		return true
+	}
+	// Anything beneath a matching directory.
+	return matchSegments(slices.Concat(segs, []string{"*", "**"}), names)
+}
+
+func matchSegments(segs, names []string) bool {
+	for len(segs) > 0 {
+		if segs[0] == "**" {
+			for i := 0; i <= len(names); i++ {
+				if matchSegments(segs[1:], names[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(names) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(segs[0], names[0]); !ok {
+			return false
+		}
+		segs, names = segs[1:], names[1:]
+	}
+	return len(names) == 0
+}
+
+// OwnerRule is one CODEOWNERS line.
+type OwnerRule struct {
+	Pattern string
+	Owners  []string
+}
+
+// CodeOwnersPaths are where GitHub and GitLab look for CODEOWNERS, in order.
+var CodeOwnersPaths = []string{".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS", ".gitlab/CODEOWNERS"}
+
+// ParseCodeOwners parses a CODEOWNERS file. GitLab section headers are
+// skipped; their rules apply like any other.
+func ParseCodeOwners(text string) []OwnerRule {
+	var rules []OwnerRule
+	for _, line := range strings.Split(text, "\n") {
+		if i := strings.Index(line, " #"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], "[") || strings.HasPrefix(fields[0], "^[") {
+			continue
+		}
+		rules = append(rules, OwnerRule{Pattern: fields[0], Owners: fields[1:]})
+	}
+	return rules
+}
+
+// Owners returns the owners of name: those of the last matching rule.
+func Owners(rules []OwnerRule, name string) []string {
+	for i := len(rules) - 1; i >= 0; i-- {
+		if Match(rules[i].Pattern, name) {
+			return rules[i].Owners
+		}
+	}
+	return nil
+}
+
+// statSummaryRe matches the last line of `git diff --stat`:
+// " 3 files changed, 10 insertions(+), 2 deletions(-)".
+var statSummaryRe = regexp.MustCompile(`(\d+) (insertion|deletion)s?\([+-]\)`)
+
+// ParseStatLines returns the insertions plus deletions of a `git diff --stat`
+// summary.
+func ParseStatLines(stat string) int {
+	lines := 0
+	for _, m := range statSummaryRe.FindAllStringSubmatch(stat, -1) {
+		n, _ := strconv.Atoi(m[1])
+		lines += n
+	}
+	return lines
+}
@@ -0,0 +1,99 @@
+package diffpolicy
+
+import (
+	"strings"
+	"testing"
+
+	"autopr/internal/config"
+)
+
+func TestMatch(t *testing.T) {
+	cases := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"package-lock.json", "package-lock.json", true},
+		{"package-lock.json", "web/package-lock.json", true},
+		{"*.lock", "vendor/Cargo.lock", true},
+		{".github/workflows/", ".github/workflows/ci.yml", true},
+		{".github/workflows", ".github/workflows/ci.yml", true},
+		{"/db/migrations/**", "db/migrations/001_init.sql", true},
+		{"db/migrations/**", "app/db/migrations/001_init.sql", false},
+		{"docs/**/*.md", "docs/guide/intro.md", true},
+		{"docs/**/*.md", "docs/intro.md", true},
+		{"docs/*.md", "docs/guide/intro.md", false},
+		{"src/", "src", false},
+		{"/Makefile", "Makefile", true},
+		{"/Makefile", "sub/Makefile", false},
+		{"/docs/", "docs/x.md", true},
+		{"/docs/", "a/docs/x.md", false},
+		{"docs/", "a/docs/x.md", true},
+		{"*", "anything/at/all.go", true},
+	}
+	for _, c := range cases {
+		if got := Match(c.pattern, c.name); got != c.want {
+			t.Errorf("Match(%q, %q) = %v, want %v", c.pattern, c.name, got, c.want)
+		}
+	}
+}
+
+func TestOwnersLastMatchWins(t *testing.T) {
+	rules := ParseCodeOwners(`# Default owners
+*                 @org/devs
+/internal/auth/   @org/security @alice  # auth needs security review
+
+[Docs]
+docs/             @org/writers
+internal/auth/README.md @org/devs
+`)
+	if len(rules) != 4 {
+		t.Fatalf("expected 4 rules, got %+v", rules)
+	}
+	for name, want := range map[string]string{
+		"main.go":                  "@org/devs",
+		"internal/auth/token.go":   "@org/security @alice",
+		"internal/auth/README.md":  "@org/devs",
+		"docs/guide/setup.md":      "@org/writers",
+		"internal/authz/policy.go": "@org/devs",
+	} {
+		if got := strings.Join(Owners(rules, name), " "); got != want {
+			t.Errorf("Owners(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	policy := config.ProjectDiffPolicy{
+		MaxFiles:   3,
+		MaxLines:   100,
+		Deny:       []string{".github/workflows/", "*.lock"},
+		Allow:      []string{"src/", ".github/", "*.lock"},
+		CodeOwners: []string{"@org/security"},
+	}
+	owners := ParseCodeOwners("src/auth/ @org/security\n")
+	diff := Diff{
+		Files: []string{".github/workflows/ci.yml", "Cargo.lock", "src/auth/login.rs", "README.md"},
+		Lines: ParseStatLines(" 4 files changed, 120 insertions(+), 3 deletions(-)\n"),
+	}
+
+	violations := Check(policy, diff, owners)
+	var rules []string
+	for _, v := range violations {
+		rules = append(rules, v.Rule)
+	}
+	if got := strings.Join(rules, ","); got != "max_files,max_lines,deny,allow,codeowners" {
+		t.Fatalf("unexpected violations: %s\n%s", got, Format(violations))
+	}
+	want := `- 4 files changed, at most 3 allowed
+- 123 lines changed, at most 100 allowed
+- changes denied paths: .github/workflows/ci.yml, Cargo.lock
+- changes paths outside the allowed ones: README.md
+- changes paths owned by @org/security, which need human approval: src/auth/login.rs`
+	if got := Format(violations); got != want {
+		t.Fatalf("unexpected message:\n%s\nwant:\n%s", got, want)
+	}
+
+	if v := Check(config.ProjectDiffPolicy{MaxFiles: 10}, diff, nil); len(v) != 0 {
+		t.Fatalf("expected no violations, got %+v", v)
+	}
+}
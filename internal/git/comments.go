@@ -0,0 +1,275 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+
+	"autopr/internal/httputil"
+)
+
+// IssueComment is a comment on a GitHub issue or a note on a GitLab issue.
+type IssueComment struct {
+	ID        int64
+	Author    string
+	Body      string
+	CreatedAt time.Time
+	// Maintainer reports whether the author has write access to the
+	// repository (GitHub owner, member or collaborator; GitLab maintainer).
+	Maintainer bool
+	// Bot reports whether the author is a bot account.
+	Bot bool
+}
+
+// gitlabMaintainerAccess is GitLab's access level of the Maintainer role.
+const gitlabMaintainerAccess = 40
+
+// PostGitHubIssueComment adds a comment to a GitHub issue or pull request.
+func PostGitHubIssueComment(ctx context.Context, token, owner, repo, number, body string) (IssueComment, error) {
+	apiURL := fmt.Sprintf("%s/repos/%s/%s/issues/%s/comments", githubAPIBase, owner, repo, number)
+	var comment githubComment
+	if err := githubJSON(ctx, token, "POST", apiURL, map[string]any{"body": body}, http.StatusCreated, &comment); err != nil {
+		return IssueComment{}, fmt.Errorf("github post comment: %w", err)
+	}
+	return comment.issueComment(), nil
+}
+
+// ListGitHubIssueComments returns the comments on a GitHub issue created
+// after since, oldest first.
+func ListGitHubIssueComments(ctx context.Context, token, owner, repo, number string, since time.Time) ([]IssueComment, error) {
+	apiURL := fmt.Sprintf("%s/repos/%s/%s/issues/%s/comments?since=%s&per_page=100",
+		githubAPIBase, owner, repo, number, url.QueryEscape(since.UTC().Format(time.RFC3339)))
+	var comments []githubComment
+	if err := githubJSON(ctx, token, "GET", apiURL, nil, http.StatusOK, &comments); err != nil {
+		return nil, fmt.Errorf("github list comments: %w", err)
+	}
+	var out []IssueComment
+	for _, c := range comments {
+		// since filters on the update time; edits of older comments are not replies.
+		if comment := c.issueComment(); comment.CreatedAt.After(since) {
+			out = append(out, comment)
+		}
+	}
+	return out, nil
+}
+
+// GetGitHubIssueAuthor returns the login of the user who opened a GitHub issue.
+func GetGitHubIssueAuthor(ctx context.Context, token, owner, repo, number string) (string, error) {
+	apiURL := fmt.Sprintf("%s/repos/%s/%s/issues/%s", githubAPIBase, owner, repo, number)
+	var issue struct {
+		User struct {
+			Login string `json:"login"`
+		} `json:"user"`
+	}
+	if err := githubJSON(ctx, token, "GET", apiURL, nil, http.StatusOK, &issue); err != nil {
+		return "", fmt.Errorf("github get issue: %w", err)
+	}
+	return issue.User.Login, nil
+}
+
+// GetGitHubUser returns the login of the user the token authenticates as.
+func GetGitHubUser(ctx context.Context, token string) (string, error) {
+	var user struct {
+		Login string `json:"login"`
+	}
+	if err := githubJSON(ctx, token, "GET", githubAPIBase+"/user", nil, http.StatusOK, &user); err != nil {
+		return "", fmt.Errorf("github get user: %w", err)
+	}
+	return user.Login, nil
+}
+
+type githubComment struct {
+	ID   int64 `json:"id"`
+	User struct {
+		Login string `json:"login"`
+		Type  string `json:"type"`
+	} `json:"user"`
+	Body              string    `json:"body"`
+	CreatedAt         time.Time `json:"created_at"`
+	AuthorAssociation string    `json:"author_association"`
+}
+
+func (c githubComment) issueComment() IssueComment {
+	return IssueComment{
+		ID:         c.ID,
+		Author:     c.User.Login,
+		Body:       c.Body,
+		CreatedAt:  c.CreatedAt,
+		Maintainer: slices.Contains([]string{"OWNER", "MEMBER", "COLLABORATOR"}, c.AuthorAssociation),
+		Bot:        c.User.Type == "Bot",
+	}
+}
+
+// PostGitLabIssueNote adds a note to a GitLab issue.
+func PostGitLabIssueNote(ctx context.Context, token, baseURL, projectID, iid, body string) (IssueComment, error) {
+	baseURL = NormalizeGitLabBaseURL(baseURL)
+	apiURL := fmt.Sprintf("%s/api/v4/projects/%s/issues/%s/notes", baseURL, projectID, iid)
+	var note gitlabNote
+	if err := gitlabJSON(ctx, token, "POST", apiURL, map[string]any{"body": body}, http.StatusCreated, &note); err != nil {
+		return IssueComment{}, fmt.Errorf("gitlab post note: %w", err)
+	}
+	return note.issueComment(), nil
+}
+
+// ListGitLabIssueNotes returns the user notes on a GitLab issue created after
+// since, oldest first. System notes (label changes, mentions) are skipped and
+// the maintainer flag is looked up once per author.
+func ListGitLabIssueNotes(ctx context.Context, token, baseURL, projectID, iid string, since time.Time) ([]IssueComment, error) {
+	baseURL = NormalizeGitLabBaseURL(baseURL)
+	apiURL := fmt.Sprintf("%s/api/v4/projects/%s/issues/%s/notes?order_by=created_at&sort=desc&per_page=100", baseURL, projectID, iid)
+	var notes []gitlabNote
+	if err := gitlabJSON(ctx, token, "GET", apiURL, nil, http.StatusOK, &notes); err != nil {
+		return nil, fmt.Errorf("gitlab list notes: %w", err)
+	}
+
+	maintainers := map[int64]bool{}
+	var out []IssueComment
+	for _, n := range slices.Backward(notes) {
+		if n.System || !n.CreatedAt.After(since) {
+			continue
+		}
+		maintainer, ok := maintainers[n.Author.ID]
+		if !ok {
+			var err error
+			if maintainer, err = gitlabIsMaintainer(ctx, token, baseURL, projectID, n.Author.ID); err != nil {
+				return nil, err
+			}
+			maintainers[n.Author.ID] = maintainer
+		}
+		comment := n.issueComment()
+		comment.Maintainer = maintainer
+		out = append(out, comment)
+	}
+	return out, nil
+}
+
+// GetGitLabIssueAuthor returns the username of the user who opened a GitLab issue.
+func GetGitLabIssueAuthor(ctx context.Context, token, baseURL, projectID, iid string) (string, error) {
+	baseURL = NormalizeGitLabBaseURL(baseURL)
+	apiURL := fmt.Sprintf("%s/api/v4/projects/%s/issues/%s", baseURL, projectID, iid)
+	var issue struct {
+		Author struct {
+			Username string `json:"username"`
+		} `json:"author"`
+	}
+	if err := gitlabJSON(ctx, token, "GET", apiURL, nil, http.StatusOK, &issue); err != nil {
+		return "", fmt.Errorf("gitlab get issue: %w", err)
+	}
+	return issue.Author.Username, nil
+}
+
+// GetGitLabUser returns the username of the user the token authenticates as.
+func GetGitLabUser(ctx context.Context, token, baseURL string) (string, error) {
+	apiURL := NormalizeGitLabBaseURL(baseURL) + "/api/v4/user"
+	var user struct {
+		Username string `json:"username"`
+	}
+	if err := gitlabJSON(ctx, token, "GET", apiURL, nil, http.StatusOK, &user); err != nil {
+		return "", fmt.Errorf("gitlab get user: %w", err)
+	}
+	return user.Username, nil
+}
+
+// gitlabIsMaintainer reports whether a user has at least the Maintainer role
+// in the project, including inherited group membership.
+func gitlabIsMaintainer(ctx context.Context, token, baseURL, projectID string, userID int64) (bool, error) {
+	apiURL := fmt.Sprintf("%s/api/v4/projects/%s/members/all/%d", baseURL, projectID, userID)
+	var member struct {
+		AccessLevel int `json:"access_level"`
+	}
+	err := gitlabJSON(ctx, token, "GET", apiURL, nil, http.StatusOK, &member)
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) && statusErr.status == http.StatusNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("gitlab get member: %w", err)
+	}
+	return member.AccessLevel >= gitlabMaintainerAccess, nil
+}
+
+type gitlabNote struct {
+	ID     int64  `json:"id"`
+	Body   string `json:"body"`
+	System bool   `json:"system"`
+	Author struct {
+		ID       int64  `json:"id"`
+		Username string `json:"username"`
+		Bot      bool   `json:"bot"`
+	} `json:"author"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (n gitlabNote) issueComment() IssueComment {
+	return IssueComment{ID: n.ID, Author: n.Author.Username, Body: n.Body, CreatedAt: n.CreatedAt, Bot: n.Author.Bot}
+}
+
+// httpStatusError is returned by githubJSON and gitlabJSON for unexpected
+// response codes.
+type httpStatusError struct {
+	status int
+	body   string
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("HTTP %d: %s", e.status, e.body)
+}
+
+func githubJSON(ctx context.Context, token, method, apiURL string, payload any, wantStatus int, out any) error {
+	return doJSON(ctx, method, apiURL, payload, wantStatus, out, func(req *http.Request) {
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Accept", "application/vnd.github+json")
+	})
+}
+
+func gitlabJSON(ctx context.Context, token, method, apiURL string, payload any, wantStatus int, out any) error {
+	return doJSON(ctx, method, apiURL, payload, wantStatus, out, func(req *http.Request) {
+		req.Header.Set("PRIVATE-TOKEN", token)
+	})
+}
+
+func doJSON(ctx context.Context, method, apiURL string, payload any, wantStatus int, out any, auth func(*http.Request)) error {
+	var buf []byte
+	if payload != nil {
+		var err error
+		if buf, err = json.Marshal(payload); err != nil {
+			return fmt.Errorf("marshal payload: %w", err)
+		}
+	}
+
+	resp, err := httputil.Do(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, method, apiURL, bytes.NewReader(buf))
+		if err != nil {
+			return nil, err
+		}
+		auth(req)
+		if payload != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		return req, nil
+	}, httputil.DefaultRetryConfig())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != wantStatus {
+		msg := string(respBody)
+		if len(msg) > 4096 {
+			msg = msg[:4096]
+		}
+		return &httpStatusError{status: resp.StatusCode, body: msg}
+	}
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestListGitHubIssueComments_SkipsOlderComments(t *testing.T) {
+	since := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/acme/repo/issues/12/comments" || r.URL.Query().Get("since") != "2026-03-01T12:00:00Z" {
+			t.Errorf("unexpected request: %s", r.URL)
+		}
+		fmt.Fprint(w, `[
+			{"id": 1, "user": {"login": "bob"}, "body": "edited", "created_at": "2026-02-28T09:00:00Z", "author_association": "NONE"},
+			{"id": 2, "user": {"login": "carol"}, "body": "CSV", "created_at": "2026-03-01T13:00:00Z", "author_association": "MEMBER"}
+		]`)
+	}))
+	defer srv.Close()
+
+	withGitHubAPIBase(t, srv.URL, func() {
+		comments, err := ListGitHubIssueComments(context.Background(), "tok", "acme", "repo", "12", since)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(comments) != 1 || comments[0].ID != 2 || comments[0].Author != "carol" || !comments[0].Maintainer {
+			t.Fatalf("expected only carol's new comment from a maintainer, got %+v", comments)
+		}
+	})
+}
+
+func TestListGitLabIssueNotes_SkipsSystemNotesAndChecksMembers(t *testing.T) {
+	t.Parallel()
+	since := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	memberLookups := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v4/projects/123/issues/7/notes":
+			// Newest first, as requested with sort=desc.
+			fmt.Fprint(w, `[
+				{"id": 4, "body": "thanks", "system": false, "author": {"id": 20, "username": "dave"}, "created_at": "2026-03-01T15:00:00.000Z"},
+				{"id": 3, "body": "added label", "system": true, "author": {"id": 10, "username": "erin"}, "created_at": "2026-03-01T14:00:00.000Z"},
+				{"id": 2, "body": "use CSV", "system": false, "author": {"id": 10, "username": "erin"}, "created_at": "2026-03-01T13:00:00.000Z"},
+				{"id": 1, "body": "old", "system": false, "author": {"id": 20, "username": "dave"}, "created_at": "2026-02-01T13:00:00.000Z"}
+			]`)
+		case "/api/v4/projects/123/members/all/10":
+			memberLookups++
+			fmt.Fprint(w, `{"access_level": 40}`)
+		case "/api/v4/projects/123/members/all/20":
+			memberLookups++
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message":"404 Not found"}`)
+		default:
+			t.Errorf("unexpected request: %s", r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	notes, err := ListGitLabIssueNotes(context.Background(), "tok", srv.URL, "123", "7", since)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(notes) != 2 || notes[0].ID != 2 || !notes[0].Maintainer || notes[1].ID != 4 || notes[1].Maintainer {
+		t.Fatalf("expected erin's maintainer note then dave's, got %+v", notes)
+	}
+	if memberLookups != 2 {
+		t.Fatalf("expected one member lookup per author, got %d", memberLookups)
+	}
+}
+
+func TestGetGitHubUserAndBotComments(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/user":
+			fmt.Fprint(w, `{"login": "autopr-bot"}`)
+		case "/repos/acme/repo/issues/12/comments":
+			fmt.Fprint(w, `[{"id": 3, "user": {"login": "labeler[bot]", "type": "Bot"}, "body": "labelled", "created_at": "2026-03-01T13:00:00Z", "author_association": "NONE"}]`)
+		default:
+			t.Errorf("unexpected request: %s", r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	withGitHubAPIBase(t, srv.URL, func() {
+		login, err := GetGitHubUser(context.Background(), "tok")
+		if err != nil || login != "autopr-bot" {
+			t.Fatalf("expected autopr-bot, got %q (err %v)", login, err)
+		}
+		comments, err := ListGitHubIssueComments(context.Background(), "tok", "acme", "repo", "12", time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+		if err != nil || len(comments) != 1 || !comments[0].Bot {
+			t.Fatalf("expected a bot comment, got %+v (err %v)", comments, err)
+		}
+	})
+}
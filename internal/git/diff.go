@@ -51,3 +51,15 @@ func DiffStatAgainstBase(ctx context.Context, worktreePath, baseBranch string) (
 	}
 	return out, nil
 }
+
+// CommitDiffsAgainstBase returns the patches of every commit on HEAD that is
+// not on origin/<baseBranch>, oldest first. Unlike DiffAgainstBase it covers
+// what a push sends, including changes a later commit reverted, and ignores
+// uncommitted changes.
+func CommitDiffsAgainstBase(ctx context.Context, worktreePath, baseBranch string) (string, error) {
+	out, err := runGitOutput(ctx, worktreePath, "log", "-p", "--reverse", "--no-color", "--no-ext-diff", "--format=", fmt.Sprintf("origin/%s..HEAD", baseBranch))
+	if err != nil {
+		return "", fmt.Errorf("commit diffs against origin/%s: %w", baseBranch, err)
+	}
+	return out, nil
+}
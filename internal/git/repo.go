@@ -111,6 +111,36 @@ func LatestCommit(ctx context.Context, dir string) (string, error) {
 	return strings.TrimSpace(out), nil
 }
 
+// MergeBase returns the best common ancestor of two commits.
+func MergeBase(ctx context.Context, dir, a, b string) (string, error) {
+	out, err := runGitOutput(ctx, dir, "merge-base", a, b)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// CommitSubjects returns "<short sha> <subject>" for each commit in from..to,
+// oldest first. Merge commits are skipped.
+func CommitSubjects(ctx context.Context, dir, from, to string) ([]string, error) {
+	out, err := runGitOutput(ctx, dir, "log", "--reverse", "--no-merges", "--format=%h %s", from+".."+to)
+	if err != nil {
+		return nil, err
+	}
+	var subjects []string
+	for line := range strings.Lines(out) {
+		if line = strings.TrimSpace(line); line != "" {
+			subjects = append(subjects, line)
+		}
+	}
+	return subjects, nil
+}
+
+// ShowFile returns the contents of path at ref.
+func ShowFile(ctx context.Context, dir, ref, path string) (string, error) {
+	return runGitOutput(ctx, dir, "show", ref+":"+path)
+}
+
 // CommitAll stages all changes (including new files) and commits with the given message.
 func CommitAll(ctx context.Context, dir, message string) (string, error) {
 	// Stage everything — LLM tools create new files that need to be included.
@@ -0,0 +1,181 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ReviewComment is a human review comment on a PR/MR: an inline comment on
+// the diff, a GitHub review that requested changes, or a GitLab MR note.
+type ReviewComment struct {
+	ID     int64
+	Author string
+	Body   string
+	// Path and Line locate inline comments; both are empty for comments on
+	// the PR as a whole.
+	Path      string
+	Line      int
+	CreatedAt time.Time
+}
+
+// ListGitHubPRReviewComments returns the inline review comments and the
+// change-request reviews on a GitHub PR created after since, oldest first.
+// Comments by bots are skipped.
+func ListGitHubPRReviewComments(ctx context.Context, token, prURL string, since time.Time) ([]ReviewComment, error) {
+	owner, repo, number, err := parseGitHubPRURL(prURL)
+	if err != nil {
+		return nil, err
+	}
+
+	apiURL := fmt.Sprintf("%s/repos/%s/%s/pulls/%s/comments?since=%s&per_page=100",
+		githubAPIBase, owner, repo, number, url.QueryEscape(since.UTC().Format(time.RFC3339)))
+	var comments []struct {
+		ID        int64      `json:"id"`
+		User      githubUser `json:"user"`
+		Body      string     `json:"body"`
+		Path      string     `json:"path"`
+		Line      *int       `json:"line"`
+		CreatedAt time.Time  `json:"created_at"`
+	}
+	if err := githubJSON(ctx, token, "GET", apiURL, nil, http.StatusOK, &comments); err != nil {
+		return nil, fmt.Errorf("github list review comments: %w", err)
+	}
+
+	apiURL = fmt.Sprintf("%s/repos/%s/%s/pulls/%s/reviews?per_page=100", githubAPIBase, owner, repo, number)
+	var reviews []struct {
+		ID          int64      `json:"id"`
+		User        githubUser `json:"user"`
+		Body        string     `json:"body"`
+		State       string     `json:"state"`
+		SubmittedAt time.Time  `json:"submitted_at"`
+	}
+	if err := githubJSON(ctx, token, "GET", apiURL, nil, http.StatusOK, &reviews); err != nil {
+		return nil, fmt.Errorf("github list reviews: %w", err)
+	}
+
+	var out []ReviewComment
+	for _, c := range comments {
+		// since filters on the update time; edits of older comments are not new feedback.
+		if c.User.Type == "Bot" || !c.CreatedAt.After(since) {
+			continue
+		}
+		comment := ReviewComment{ID: c.ID, Author: c.User.Login, Body: c.Body, Path: c.Path, CreatedAt: c.CreatedAt}
+		if c.Line != nil {
+			comment.Line = *c.Line
+		}
+		out = append(out, comment)
+	}
+	for _, r := range reviews {
+		// The inline comments of a change request without a summary are listed above.
+		if r.State != "CHANGES_REQUESTED" || r.User.Type == "Bot" || strings.TrimSpace(r.Body) == "" || !r.SubmittedAt.After(since) {
+			continue
+		}
+		out = append(out, ReviewComment{ID: r.ID, Author: r.User.Login, Body: r.Body, CreatedAt: r.SubmittedAt})
+	}
+	slices.SortStableFunc(out, func(a, b ReviewComment) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return out, nil
+}
+
+// PostGitHubPRComment adds a comment to the conversation of a GitHub PR.
+func PostGitHubPRComment(ctx context.Context, token, prURL, body string) (IssueComment, error) {
+	owner, repo, number, err := parseGitHubPRURL(prURL)
+	if err != nil {
+		return IssueComment{}, err
+	}
+	return PostGitHubIssueComment(ctx, token, owner, repo, number, body)
+}
+
+// ListGitLabMRReviewNotes returns the user notes on a GitLab MR, inline diff
+// notes included, created after since, oldest first. System notes and notes
+// by bot users are skipped.
+func ListGitLabMRReviewNotes(ctx context.Context, token, baseURL, mrURL string, since time.Time) ([]ReviewComment, error) {
+	baseURL = NormalizeGitLabBaseURL(baseURL)
+	projectPath, iid, err := parseGitLabMRURL(baseURL, mrURL)
+	if err != nil {
+		return nil, err
+	}
+
+	apiURL := fmt.Sprintf("%s/api/v4/projects/%s/merge_requests/%s/notes?order_by=created_at&sort=desc&per_page=100", baseURL, projectPath, iid)
+	var notes []struct {
+		ID     int64  `json:"id"`
+		Body   string `json:"body"`
+		System bool   `json:"system"`
+		Author struct {
+			Username string `json:"username"`
+			Bot      bool   `json:"bot"`
+		} `json:"author"`
+		Position *struct {
+			NewPath string `json:"new_path"`
+			NewLine int    `json:"new_line"`
+		} `json:"position"`
+		CreatedAt time.Time `json:"created_at"`
+	}
+	if err := gitlabJSON(ctx, token, "GET", apiURL, nil, http.StatusOK, &notes); err != nil {
+		return nil, fmt.Errorf("gitlab list MR notes: %w", err)
+	}
+
+	var out []ReviewComment
+	for _, n := range slices.Backward(notes) {
+		if n.System || n.Author.Bot || !n.CreatedAt.After(since) {
+			continue
+		}
+		comment := ReviewComment{ID: n.ID, Author: n.Author.Username, Body: n.Body, CreatedAt: n.CreatedAt}
+		if n.Position != nil {
+			comment.Path, comment.Line = n.Position.NewPath, n.Position.NewLine
+		}
+		out = append(out, comment)
+	}
+	return out, nil
+}
+
+// PostGitLabMRNote adds a note to a GitLab MR.
+func PostGitLabMRNote(ctx context.Context, token, baseURL, mrURL, body string) (IssueComment, error) {
+	baseURL = NormalizeGitLabBaseURL(baseURL)
+	projectPath, iid, err := parseGitLabMRURL(baseURL, mrURL)
+	if err != nil {
+		return IssueComment{}, err
+	}
+	apiURL := fmt.Sprintf("%s/api/v4/projects/%s/merge_requests/%s/notes", baseURL, projectPath, iid)
+	var note gitlabNote
+	if err := gitlabJSON(ctx, token, "POST", apiURL, map[string]any{"body": body}, http.StatusCreated, &note); err != nil {
+		return IssueComment{}, fmt.Errorf("gitlab post MR note: %w", err)
+	}
+	return note.issueComment(), nil
+}
+
+type githubUser struct {
+	Login string `json:"login"`
+	Type  string `json:"type"`
+}
+
+// parseGitHubPRURL splits https://github.com/{owner}/{repo}/pull/{number}.
+func parseGitHubPRURL(prURL string) (owner, repo, number string, err error) {
+	matches := githubPRNumberRe.FindStringSubmatch(prURL)
+	if len(matches) < 2 {
+		return "", "", "", fmt.Errorf("cannot parse PR number from URL: %s", prURL)
+	}
+	parts := strings.Split(strings.TrimPrefix(prURL, "https://github.com/"), "/")
+	if len(parts) < 3 {
+		return "", "", "", fmt.Errorf("cannot parse owner/repo from URL: %s", prURL)
+	}
+	return parts[0], parts[1], matches[1], nil
+}
+
+// parseGitLabMRURL splits {baseURL}/{group}/{project}/-/merge_requests/{iid}
+// into the URL-encoded project path and the MR iid.
+func parseGitLabMRURL(baseURL, mrURL string) (projectPath, iid string, err error) {
+	matches := gitlabMRNumberRe.FindStringSubmatch(mrURL)
+	if len(matches) < 2 {
+		return "", "", fmt.Errorf("cannot parse MR number from URL: %s", mrURL)
+	}
+	before, _, ok := strings.Cut(strings.TrimPrefix(mrURL, baseURL+"/"), "/-/merge_requests/")
+	if !ok {
+		return "", "", fmt.Errorf("cannot parse project path from URL: %s", mrURL)
+	}
+	return strings.ReplaceAll(before, "/", "%2F"), matches[1], nil
+}
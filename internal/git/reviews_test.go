@@ -0,0 +1,82 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestListGitHubPRReviewComments_MergesInlineCommentsAndChangeRequests(t *testing.T) {
+	since := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/acme/repo/pulls/5/comments":
+			if r.URL.Query().Get("since") != "2026-03-01T12:00:00Z" {
+				t.Errorf("unexpected since: %s", r.URL)
+			}
+			fmt.Fprint(w, `[
+				{"id": 1, "user": {"login": "bob", "type": "User"}, "body": "edited", "path": "a.go", "line": 3, "created_at": "2026-02-28T09:00:00Z"},
+				{"id": 2, "user": {"login": "carol", "type": "User"}, "body": "rename this", "path": "a.go", "line": 7, "created_at": "2026-03-01T14:00:00Z"},
+				{"id": 3, "user": {"login": "lint[bot]", "type": "Bot"}, "body": "style", "path": "a.go", "line": 8, "created_at": "2026-03-01T14:00:00Z"}
+			]`)
+		case "/repos/acme/repo/pulls/5/reviews":
+			fmt.Fprint(w, `[
+				{"id": 10, "user": {"login": "carol", "type": "User"}, "body": "Needs tests.", "state": "CHANGES_REQUESTED", "submitted_at": "2026-03-01T13:00:00Z"},
+				{"id": 11, "user": {"login": "dave", "type": "User"}, "body": "LGTM", "state": "APPROVED", "submitted_at": "2026-03-01T15:00:00Z"},
+				{"id": 12, "user": {"login": "erin", "type": "User"}, "body": "", "state": "CHANGES_REQUESTED", "submitted_at": "2026-03-01T15:00:00Z"}
+			]`)
+		default:
+			t.Errorf("unexpected request: %s", r.URL)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	withGitHubAPIBase(t, srv.URL, func() {
+		comments, err := ListGitHubPRReviewComments(context.Background(), "tok", "https://github.com/acme/repo/pull/5", since)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(comments) != 2 || comments[0].ID != 10 || comments[1].ID != 2 {
+			t.Fatalf("expected the change request then the inline comment, got %+v", comments)
+		}
+		if comments[1].Path != "a.go" || comments[1].Line != 7 || comments[1].Author != "carol" {
+			t.Fatalf("expected the inline comment's location, got %+v", comments[1])
+		}
+	})
+}
+
+func TestListGitLabMRReviewNotes_SkipsSystemAndBotNotes(t *testing.T) {
+	t.Parallel()
+	since := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/api/v4/projects/acme%2Frepo/merge_requests/9/notes" {
+			t.Errorf("unexpected request: %s", r.URL)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		// Newest first, as requested with sort=desc.
+		fmt.Fprint(w, `[
+			{"id": 5, "body": "general remark", "system": false, "author": {"username": "dave"}, "created_at": "2026-03-01T16:00:00.000Z"},
+			{"id": 4, "body": "pipeline passed", "system": false, "author": {"username": "ci-bot", "bot": true}, "created_at": "2026-03-01T15:00:00.000Z"},
+			{"id": 3, "body": "added 1 commit", "system": true, "author": {"username": "erin"}, "created_at": "2026-03-01T14:00:00.000Z"},
+			{"id": 2, "body": "off by one", "system": false, "author": {"username": "erin"}, "position": {"new_path": "b.go", "new_line": 12}, "created_at": "2026-03-01T13:00:00.000Z"},
+			{"id": 1, "body": "old", "system": false, "author": {"username": "dave"}, "created_at": "2026-02-01T13:00:00.000Z"}
+		]`)
+	}))
+	defer srv.Close()
+
+	notes, err := ListGitLabMRReviewNotes(context.Background(), "tok", srv.URL, srv.URL+"/acme/repo/-/merge_requests/9", since)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(notes) != 2 || notes[0].ID != 2 || notes[1].ID != 5 {
+		t.Fatalf("expected erin's diff note then dave's note, got %+v", notes)
+	}
+	if notes[0].Path != "b.go" || notes[0].Line != 12 || notes[1].Path != "" {
+		t.Fatalf("expected only the diff note to carry a location, got %+v", notes)
+	}
+}
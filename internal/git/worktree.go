@@ -85,3 +85,23 @@ func isFilesystemRoot(path string) bool {
 func RemoveJobDir(worktreePath string) {
 	_ = os.RemoveAll(worktreePath)
 }
+
+// AddDetachedWorktree checks out ref at path as a linked worktree of the
+// repository in dir, detached from any branch. An existing worktree at path
+// is replaced.
+func AddDetachedWorktree(ctx context.Context, dir, path, ref string) error {
+	_ = RemoveWorktree(ctx, dir, path)
+	if err := runGit(ctx, dir, "worktree", "add", "--detach", path, ref); err != nil {
+		return fmt.Errorf("add worktree at %s: %w", ref, err)
+	}
+	return nil
+}
+
+// RemoveWorktree removes a linked worktree added by AddDetachedWorktree,
+// along with its administrative files in dir.
+func RemoveWorktree(ctx context.Context, dir, path string) error {
+	err := runGit(ctx, dir, "worktree", "remove", "--force", path)
+	_ = os.RemoveAll(path)
+	_ = runGit(ctx, dir, "worktree", "prune")
+	return err
+}
@@ -91,6 +91,52 @@ func TestCloneForJob_RejectsUnsafeDestination(t *testing.T) {
 	}
 }
 
+func TestAddDetachedWorktreeChecksOutMergeBase(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	tmp := t.TempDir()
+	remote := createRemoteWithMainBranch(t, tmp)
+	clone := filepath.Join(tmp, "clone")
+	if err := CloneForJob(ctx, remote, "", clone, "autopr/job-789", "main"); err != nil {
+		t.Fatalf("clone for job: %v", err)
+	}
+	runGitCmd(t, clone, "config", "user.email", "test@example.com")
+	runGitCmd(t, clone, "config", "user.name", "Test User")
+	if err := os.WriteFile(filepath.Join(clone, "change.txt"), []byte("job change\n"), 0o644); err != nil {
+		t.Fatalf("write change: %v", err)
+	}
+	if _, err := CommitAll(ctx, clone, "job change"); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+
+	base, err := MergeBase(ctx, clone, "HEAD", "origin/main")
+	if err != nil {
+		t.Fatalf("merge-base: %v", err)
+	}
+	baseline := filepath.Join(tmp, "baseline")
+	if err := AddDetachedWorktree(ctx, clone, baseline, base); err != nil {
+		t.Fatalf("add worktree: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(baseline, "README.md")); err != nil {
+		t.Fatalf("expected base checkout: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(baseline, "change.txt")); !os.IsNotExist(err) {
+		t.Fatalf("expected job change to be absent from the base checkout, stat err=%v", err)
+	}
+
+	if err := RemoveWorktree(ctx, clone, baseline); err != nil {
+		t.Fatalf("remove worktree: %v", err)
+	}
+	if _, err := os.Stat(baseline); !os.IsNotExist(err) {
+		t.Fatalf("expected worktree to be removed, stat err=%v", err)
+	}
+	worktrees, err := runGitOutput(ctx, clone, "worktree", "list")
+	if err != nil || strings.Count(strings.TrimSpace(worktrees), "\n") != 0 {
+		t.Fatalf("expected only the main worktree, got %q (err %v)", worktrees, err)
+	}
+}
+
 func createRemoteWithMainBranch(t *testing.T, tmp string) string {
 	t.Helper()
 
@@ -0,0 +1,150 @@
+package issuesync
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"autopr/internal/config"
+	"autopr/internal/db"
+	"autopr/internal/git"
+	"autopr/internal/pipeline"
+)
+
+// checkNeedsInfo requeues needs_info jobs once the issue author or a
+// maintainer replies to the clarifying questions, with the reply recorded for
+// the next plan. Jobs without a reply within the project's clarify timeout
+// stop in needs_human.
+func (s *Syncer) checkNeedsInfo(ctx context.Context) {
+	jobs, err := s.store.ListJobs(ctx, "", "needs_info", "updated_at", true)
+	if err != nil {
+		slog.Error("check needs_info: list jobs", "err", err)
+		return
+	}
+
+	for _, job := range jobs {
+		proj, ok := s.cfg.ProjectByName(job.ProjectName)
+		if !ok {
+			continue
+		}
+		asked, err := pipeline.PendingClarification(ctx, s.store, job.ID)
+		if err != nil {
+			slog.Warn("check needs_info: pending questions", "job", job.ID, "err", err)
+			continue
+		}
+
+		reply, err := s.findClarificationReply(ctx, proj, job, asked)
+		if err != nil {
+			slog.Warn("check needs_info: fetch replies", "job", job.ID, "err", err)
+		}
+		if reply != nil {
+			if err := pipeline.AnswerClarification(ctx, s.store, job.ID, *reply); err != nil {
+				slog.Error("check needs_info: resume job", "job", job.ID, "err", err)
+				continue
+			}
+			slog.Info("clarifying questions answered, resuming", "job", db.ShortID(job.ID), "author", reply.Author)
+			select {
+			case s.jobCh <- job.ID:
+			default:
+				slog.Warn("sync: job channel full", "job_id", job.ID)
+			}
+			continue
+		}
+
+		timeout := clarifyTimeout(proj)
+		if time.Since(asked.PostedAt) <= timeout {
+			continue
+		}
+		reason := fmt.Sprintf("no reply to the clarifying questions within %s", timeout)
+		if err := s.store.TransitionState(ctx, job.ID, "needs_info", "needs_human"); err != nil {
+			slog.Error("check needs_info: stop timed-out job", "job", job.ID, "err", err)
+			continue
+		}
+		if err := s.store.UpdateJobField(ctx, job.ID, "error_message", reason); err != nil {
+			slog.Warn("check needs_info: persist reason", "job", job.ID, "err", err)
+		}
+		slog.Info("clarifying questions timed out", "job", db.ShortID(job.ID))
+	}
+}
+
+// findClarificationReply returns the first reply to the questions, or nil when
+// nobody has replied yet. Comments by bots and by the user AutoPR posts as are
+// never replies. The issue author's comments are; a maintainer's comment only
+// when it is addressed to the questions (see answersQuestions).
+func (s *Syncer) findClarificationReply(ctx context.Context, proj *config.ProjectConfig, job db.Job, asked pipeline.ClarificationData) (*git.IssueComment, error) {
+	var comments []git.IssueComment
+	var author, self string
+	var err error
+	switch {
+	case job.IssueSource == "github" && proj.GitHub != nil && s.cfg.Tokens.GitHub != "":
+		token := s.cfg.Tokens.GitHub
+		comments, err = s.listGitHubIssueComments(ctx, token, proj.GitHub.Owner, proj.GitHub.Repo, job.SourceIssueID, asked.PostedAt)
+		if err != nil || len(comments) == 0 {
+			return nil, err
+		}
+		author, err = s.getGitHubIssueAuthor(ctx, token, proj.GitHub.Owner, proj.GitHub.Repo, job.SourceIssueID)
+		if err == nil {
+			self, err = s.getGitHubUser(ctx, token)
+		}
+
+	case job.IssueSource == "gitlab" && proj.GitLab != nil && s.cfg.Tokens.GitLab != "":
+		token := s.cfg.Tokens.GitLab
+		comments, err = s.listGitLabIssueNotes(ctx, token, proj.GitLab.BaseURL, proj.GitLab.ProjectID, job.SourceIssueID, asked.PostedAt)
+		if err != nil || len(comments) == 0 {
+			return nil, err
+		}
+		author, err = s.getGitLabIssueAuthor(ctx, token, proj.GitLab.BaseURL, proj.GitLab.ProjectID, job.SourceIssueID)
+		if err == nil {
+			self, err = s.getGitLabUser(ctx, token, proj.GitLab.BaseURL)
+		}
+
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, c := range comments {
+		if c.ID == asked.CommentID || c.Bot {
+			continue
+		}
+		// Someone who files issues with AutoPR's own token still answers
+		// their own issue.
+		if c.Author == self && c.Author != author {
+			continue
+		}
+		if c.Author == author || (c.Maintainer && answersQuestions(c.Body, self)) {
+			return &c, nil
+		}
+	}
+	return nil, nil
+}
+
+// answersQuestions reports whether a maintainer's comment is addressed to the
+// clarifying questions: it mentions the AutoPR user or quotes the questions.
+// Other maintainer comments, such as triage notes or "looking into it", are
+// not taken as answers.
+func answersQuestions(body, self string) bool {
+	if self != "" && strings.Contains(strings.ToLower(body), "@"+strings.ToLower(self)) {
+		return true
+	}
+	for _, line := range strings.Split(body, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), ">") {
+			return true
+		}
+	}
+	return false
+}
+
+func clarifyTimeout(proj *config.ProjectConfig) time.Duration {
+	if proj.Clarify != nil {
+		if d, err := time.ParseDuration(proj.Clarify.Timeout); err == nil && d > 0 {
+			return d
+		}
+	}
+	d, _ := time.ParseDuration(config.DefaultClarifyTimeout)
+	return d
+}
@@ -0,0 +1,175 @@
+package issuesync
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"autopr/internal/config"
+	"autopr/internal/db"
+	"autopr/internal/git"
+	"autopr/internal/pipeline"
+)
+
+func clarifyTestConfig() *config.Config {
+	return &config.Config{
+		Tokens: config.TokensConfig{GitHub: "token"},
+		Projects: []config.ProjectConfig{{
+			Name:    "project-gh",
+			GitHub:  &config.ProjectGitHub{Owner: "acme", Repo: "repo"},
+			Clarify: &config.ProjectClarify{Enabled: true, Timeout: "24h"},
+		}},
+	}
+}
+
+// createNeedsInfoJob creates a job waiting on questions posted as comment 7.
+func createNeedsInfoJob(t *testing.T, ctx context.Context, store *db.Store, postedAt time.Time) string {
+	t.Helper()
+	jobID := createSyncTestJob(t, ctx, store, "project-gh", "12", "needs_info", "", "")
+	job, err := store.GetJob(ctx, jobID)
+	if err != nil {
+		t.Fatalf("get job: %v", err)
+	}
+	data, _ := json.Marshal(pipeline.ClarificationData{CommentID: 7, PostedAt: postedAt})
+	if _, err := store.InsertArtifact(ctx, db.Artifact{
+		JobID:         jobID,
+		AutoPRIssueID: job.AutoPRIssueID,
+		Kind:          "clarification",
+		Content:       "Which format?",
+		Status:        "asked",
+		Data:          string(data),
+	}); err != nil {
+		t.Fatalf("insert questions: %v", err)
+	}
+	return jobID
+}
+
+func TestCheckNeedsInfo_ResumesOnAuthorReply(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := openTestStore(t)
+	defer store.Close()
+
+	postedAt := time.Now().Add(-time.Hour).UTC()
+	jobID := createNeedsInfoJob(t, ctx, store, postedAt)
+
+	jobCh := make(chan string, 1)
+	s := NewSyncer(clarifyTestConfig(), store, jobCh)
+	s.listGitHubIssueComments = func(ctx context.Context, token, owner, repo, number string, since time.Time) ([]git.IssueComment, error) {
+		if number != "12" || !since.Equal(postedAt) {
+			t.Fatalf("unexpected comment query: issue %q since %v", number, since)
+		}
+		return []git.IssueComment{
+			{ID: 7, Author: "autopr-bot", Body: "Which format?", Maintainer: true},
+			{ID: 8, Author: "bystander", Body: "+1"},
+			{ID: 9, Author: "alice", Body: "CSV please."},
+		}, nil
+	}
+	s.getGitHubIssueAuthor = func(ctx context.Context, token, owner, repo, number string) (string, error) {
+		return "alice", nil
+	}
+	s.getGitHubUser = func(ctx context.Context, token string) (string, error) {
+		return "autopr-bot", nil
+	}
+
+	s.checkNeedsInfo(ctx)
+
+	job, err := store.GetJob(ctx, jobID)
+	if err != nil {
+		t.Fatalf("get job: %v", err)
+	}
+	if job.State != "queued" {
+		t.Fatalf("expected the answered job to be requeued, got %q", job.State)
+	}
+	answer, err := store.GetLatestArtifact(ctx, jobID, "clarification")
+	if err != nil || answer.Status != "answered" || answer.Content != "CSV please." {
+		t.Fatalf("expected alice's reply to be recorded, got %+v (err %v)", answer, err)
+	}
+	select {
+	case got := <-jobCh:
+		if got != jobID {
+			t.Fatalf("expected job %q on the channel, got %q", jobID, got)
+		}
+	default:
+		t.Fatalf("expected the resumed job to be sent to the workers")
+	}
+}
+
+func TestCheckNeedsInfo_TimesOutWithoutReply(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := openTestStore(t)
+	defer store.Close()
+
+	jobID := createNeedsInfoJob(t, ctx, store, time.Now().Add(-25*time.Hour).UTC())
+
+	s := NewSyncer(clarifyTestConfig(), store, make(chan string, 1))
+	s.listGitHubIssueComments = func(ctx context.Context, token, owner, repo, number string, since time.Time) ([]git.IssueComment, error) {
+		return []git.IssueComment{{ID: 8, Author: "bystander", Body: "+1"}}, nil
+	}
+	s.getGitHubIssueAuthor = func(ctx context.Context, token, owner, repo, number string) (string, error) {
+		return "alice", nil
+	}
+	s.getGitHubUser = func(ctx context.Context, token string) (string, error) {
+		return "autopr-bot", nil
+	}
+
+	s.checkNeedsInfo(ctx)
+
+	job, err := store.GetJob(ctx, jobID)
+	if err != nil {
+		t.Fatalf("get job: %v", err)
+	}
+	if job.State != "needs_human" || job.ErrorMessage != "no reply to the clarifying questions within 24h0m0s" {
+		t.Fatalf("expected the job to time out into needs_human, got %q (%q)", job.State, job.ErrorMessage)
+	}
+}
+
+func TestCheckNeedsInfo_IgnoresCommentsThatAreNotAnswers(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := openTestStore(t)
+	defer store.Close()
+
+	jobID := createNeedsInfoJob(t, ctx, store, time.Now().Add(-time.Hour).UTC())
+
+	comments := []git.IssueComment{
+		{ID: 8, Author: "autopr-bot", Body: "Still waiting on the questions above.", Maintainer: true},
+		{ID: 9, Author: "ci-helper[bot]", Body: "Labelled needs-info.", Maintainer: true, Bot: true},
+		{ID: 10, Author: "carol", Body: "Looking into this.", Maintainer: true},
+	}
+	s := NewSyncer(clarifyTestConfig(), store, make(chan string, 1))
+	s.listGitHubIssueComments = func(ctx context.Context, token, owner, repo, number string, since time.Time) ([]git.IssueComment, error) {
+		return comments, nil
+	}
+	s.getGitHubIssueAuthor = func(ctx context.Context, token, owner, repo, number string) (string, error) {
+		return "alice", nil
+	}
+	s.getGitHubUser = func(ctx context.Context, token string) (string, error) {
+		return "autopr-bot", nil
+	}
+
+	s.checkNeedsInfo(ctx)
+	job, err := store.GetJob(ctx, jobID)
+	if err != nil {
+		t.Fatalf("get job: %v", err)
+	}
+	if job.State != "needs_info" {
+		t.Fatalf("expected comments that are not answers to leave the job waiting, got %q", job.State)
+	}
+
+	comments = append(comments, git.IssueComment{ID: 11, Author: "carol", Body: "> Which format?\n\nCSV.", Maintainer: true})
+	s.checkNeedsInfo(ctx)
+	job, err = store.GetJob(ctx, jobID)
+	if err != nil {
+		t.Fatalf("get job: %v", err)
+	}
+	if job.State != "queued" {
+		t.Fatalf("expected a maintainer reply quoting the questions to requeue the job, got %q", job.State)
+	}
+	answer, err := store.GetLatestArtifact(ctx, jobID, "clarification")
+	if err != nil || answer.Content != "> Which format?\n\nCSV." {
+		t.Fatalf("expected carol's reply to be recorded, got %+v (err %v)", answer, err)
+	}
+}
@@ -0,0 +1,106 @@
+package issuesync
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"autopr/internal/config"
+	"autopr/internal/db"
+	"autopr/internal/git"
+	"autopr/internal/pipeline"
+)
+
+// checkPRReviews requeues approved jobs whose open PR/MR got new review
+// comments or change requests from humans, for projects with review_feedback
+// enabled. The worker addresses them in another implement and test round.
+func (s *Syncer) checkPRReviews(ctx context.Context) {
+	jobs, err := s.store.ListApprovedJobsWithPR(ctx)
+	if err != nil {
+		slog.Error("check PR reviews: list approved jobs", "err", err)
+		return
+	}
+
+	for _, job := range jobs {
+		proj, ok := s.cfg.ProjectByName(job.ProjectName)
+		if !ok || !proj.ReviewFeedbackEnabled() {
+			continue
+		}
+		// The round commits on top of the job's worktree.
+		if job.WorktreePath == "" {
+			continue
+		}
+		if _, err := os.Stat(job.WorktreePath); err != nil {
+			slog.Debug("check PR reviews: worktree missing", "job", job.ID, "path", job.WorktreePath)
+			continue
+		}
+
+		rounds, since, err := pipeline.ReviewRounds(ctx, s.store, job.ID)
+		if err != nil {
+			slog.Warn("check PR reviews: review rounds", "job", job.ID, "err", err)
+			continue
+		}
+		if rounds >= maxReviewRounds(proj) {
+			continue
+		}
+		if since.IsZero() {
+			// The PR cannot have comments from before the job existed.
+			since, _ = parseTimestamp(job.CreatedAt)
+		}
+
+		comments, err := s.fetchReviewComments(ctx, proj, job, since)
+		if err != nil {
+			slog.Warn("check PR reviews: fetch comments", "job", job.ID, "err", err)
+			continue
+		}
+		if len(comments) == 0 {
+			continue
+		}
+		if err := pipeline.RequestReviewRound(ctx, s.store, job.ID, comments); err != nil {
+			slog.Error("check PR reviews: requeue job", "job", job.ID, "err", err)
+			continue
+		}
+		slog.Info("new PR review comments, requeueing job", "job", db.ShortID(job.ID), "comments", len(comments), "round", rounds+1)
+		select {
+		case s.jobCh <- job.ID:
+		default:
+			slog.Warn("sync: job channel full", "job_id", job.ID)
+		}
+	}
+}
+
+// fetchReviewComments returns the human review comments on the job's PR/MR
+// after since, without AutoPR's own replies.
+func (s *Syncer) fetchReviewComments(ctx context.Context, proj *config.ProjectConfig, job db.Job, since time.Time) ([]git.ReviewComment, error) {
+	var comments []git.ReviewComment
+	var err error
+	switch {
+	case proj.GitHub != nil && s.cfg.Tokens.GitHub != "":
+		comments, err = s.listGitHubPRReviewComments(ctx, s.cfg.Tokens.GitHub, job.PRURL, since)
+	case proj.GitLab != nil && s.cfg.Tokens.GitLab != "":
+		comments, err = s.listGitLabMRReviewNotes(ctx, s.cfg.Tokens.GitLab, proj.GitLab.BaseURL, job.PRURL, since)
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var out []git.ReviewComment
+	for _, c := range comments {
+		if strings.Contains(c.Body, pipeline.ReviewReplyMarker) || strings.TrimSpace(c.Body) == "" {
+			continue
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+func maxReviewRounds(proj *config.ProjectConfig) int {
+	if proj.ReviewFeedback != nil && proj.ReviewFeedback.MaxRounds > 0 {
+		return proj.ReviewFeedback.MaxRounds
+	}
+	return config.DefaultMaxReviewRounds
+}
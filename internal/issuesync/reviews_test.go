@@ -0,0 +1,121 @@
+package issuesync
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"autopr/internal/config"
+	"autopr/internal/git"
+	"autopr/internal/pipeline"
+)
+
+func TestCheckPRReviews_RequeuesJobWithNewReviewComments(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := openTestStore(t)
+	defer store.Close()
+
+	prURL := "https://github.com/acme/repo/pull/5"
+	jobID := createSyncTestJob(t, ctx, store, "project-gh", "12", "approved", "autopr/12", prURL)
+	if err := store.UpdateJobField(ctx, jobID, "worktree_path", t.TempDir()); err != nil {
+		t.Fatalf("set worktree: %v", err)
+	}
+	cfg := &config.Config{
+		Tokens: config.TokensConfig{GitHub: "token"},
+		Projects: []config.ProjectConfig{{
+			Name:           "project-gh",
+			GitHub:         &config.ProjectGitHub{Owner: "acme", Repo: "repo"},
+			ReviewFeedback: &config.ProjectReviewFeedback{Enabled: true, MaxRounds: 1},
+		}},
+	}
+
+	jobCh := make(chan string, 1)
+	s := NewSyncer(cfg, store, jobCh)
+	queries := 0
+	s.listGitHubPRReviewComments = func(ctx context.Context, token, url string, since time.Time) ([]git.ReviewComment, error) {
+		queries++
+		if url != prURL || since.IsZero() {
+			t.Fatalf("unexpected review query: %s since %v", url, since)
+		}
+		return []git.ReviewComment{
+			{ID: 1, Author: "autopr-bot", Body: "Addressed.\n" + pipeline.ReviewReplyMarker, CreatedAt: time.Now().UTC()},
+			{ID: 2, Author: "carol", Body: "Please add a test.", Path: "a.go", Line: 3, CreatedAt: time.Now().UTC()},
+		}, nil
+	}
+
+	s.checkPRReviews(ctx)
+
+	job, err := store.GetJob(ctx, jobID)
+	if err != nil {
+		t.Fatalf("get job: %v", err)
+	}
+	if job.State != "queued" {
+		t.Fatalf("expected the job to be requeued for the review, got %q", job.State)
+	}
+	select {
+	case got := <-jobCh:
+		if got != jobID {
+			t.Fatalf("expected job %s to be enqueued, got %s", jobID, got)
+		}
+	default:
+		t.Fatalf("expected the job to be enqueued")
+	}
+	feedback, err := store.GetLatestArtifact(ctx, jobID, "review_feedback")
+	if err != nil {
+		t.Fatalf("expected a review_feedback artifact: %v", err)
+	}
+	if !strings.Contains(feedback.Content, "@carol on a.go:3:\nPlease add a test.") || strings.Contains(feedback.Content, "Addressed.") {
+		t.Fatalf("expected only carol's comment to be recorded, got:\n%s", feedback.Content)
+	}
+
+	// With max_rounds reached, later comments are left to humans.
+	if _, err := store.Writer.ExecContext(ctx, `UPDATE jobs SET state = 'approved' WHERE id = ?`, jobID); err != nil {
+		t.Fatalf("reset job: %v", err)
+	}
+	s.checkPRReviews(ctx)
+	if queries != 1 {
+		t.Fatalf("expected no review query after max_rounds, got %d queries", queries)
+	}
+	if job, _ := store.GetJob(ctx, jobID); job.State != "approved" {
+		t.Fatalf("expected the job to stay approved, got %q", job.State)
+	}
+}
+
+func TestCheckPRReviews_SkipsProjectsWithoutReviewFeedback(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	store := openTestStore(t)
+	defer store.Close()
+
+	jobID := createSyncTestJob(t, ctx, store, "project-gh", "13", "approved", "autopr/13", "https://github.com/acme/repo/pull/6")
+	if err := store.UpdateJobField(ctx, jobID, "worktree_path", t.TempDir()); err != nil {
+		t.Fatalf("set worktree: %v", err)
+	}
+	cfg := &config.Config{
+		Tokens: config.TokensConfig{GitHub: "token"},
+		Projects: []config.ProjectConfig{{
+			Name:   "project-gh",
+			GitHub: &config.ProjectGitHub{Owner: "acme", Repo: "repo"},
+		}},
+	}
+	s := NewSyncer(cfg, store, make(chan string, 1))
+	s.listGitHubPRReviewComments = func(ctx context.Context, token, url string, since time.Time) ([]git.ReviewComment, error) {
+		t.Fatalf("expected no review query for a project without review_feedback")
+		return nil, nil
+	}
+
+	s.checkPRReviews(ctx)
+
+	job, err := store.GetJob(ctx, jobID)
+	if err != nil {
+		t.Fatalf("get job: %v", err)
+	}
+	if job.State != "approved" {
+		t.Fatalf("expected the job to stay approved, got %q", job.State)
+	}
+	if _, err := store.GetLatestArtifact(ctx, jobID, "review_feedback"); err == nil {
+		t.Fatalf("expected no review_feedback artifact")
+	}
+}
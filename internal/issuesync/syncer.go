@@ -25,6 +25,15 @@ type Syncer struct {
 	checkGitLabMRStatus     func(ctx context.Context, token, baseURL, mrURL string) (git.PRMergeStatus, error)
 	deleteRemoteBranch      func(ctx context.Context, dir, branchName, token string) error
 	getGitHubCheckRunStatus func(ctx context.Context, token, owner, repo, ref string) (git.CheckRunStatus, error)
+	listGitHubIssueComments func(ctx context.Context, token, owner, repo, number string, since time.Time) ([]git.IssueComment, error)
+	listGitLabIssueNotes    func(ctx context.Context, token, baseURL, projectID, iid string, since time.Time) ([]git.IssueComment, error)
+	getGitHubIssueAuthor    func(ctx context.Context, token, owner, repo, number string) (string, error)
+	getGitLabIssueAuthor    func(ctx context.Context, token, baseURL, projectID, iid string) (string, error)
+	getGitHubUser           func(ctx context.Context, token string) (string, error)
+	getGitLabUser           func(ctx context.Context, token, baseURL string) (string, error)
+
+	listGitHubPRReviewComments func(ctx context.Context, token, prURL string, since time.Time) ([]git.ReviewComment, error)
+	listGitLabMRReviewNotes    func(ctx context.Context, token, baseURL, mrURL string, since time.Time) ([]git.ReviewComment, error)
 }
 
 func NewSyncer(cfg *config.Config, store *db.Store, jobCh chan<- string) *Syncer {
@@ -38,6 +47,15 @@ func NewSyncer(cfg *config.Config, store *db.Store, jobCh chan<- string) *Syncer
 		checkGitLabMRStatus:     git.CheckGitLabMRStatus,
 		deleteRemoteBranch:      git.DeleteRemoteBranchWithToken,
 		getGitHubCheckRunStatus: git.GetGitHubCheckRunStatus,
+		listGitHubIssueComments: git.ListGitHubIssueComments,
+		listGitLabIssueNotes:    git.ListGitLabIssueNotes,
+		getGitHubIssueAuthor:    git.GetGitHubIssueAuthor,
+		getGitLabIssueAuthor:    git.GetGitLabIssueAuthor,
+		getGitHubUser:           git.GetGitHubUser,
+		getGitLabUser:           git.GetGitLabUser,
+
+		listGitHubPRReviewComments: git.ListGitHubPRReviewComments,
+		listGitLabMRReviewNotes:    git.ListGitLabMRReviewNotes,
 	}
 }
 
@@ -72,6 +90,12 @@ func (s *Syncer) syncAll(ctx context.Context) {
 
 	// Check if any job PRs have been merged or closed.
 	s.checkPRStatus(ctx)
+
+	// Resume jobs whose clarifying questions were answered.
+	s.checkNeedsInfo(ctx)
+
+	// Requeue jobs with new review comments on their open PRs.
+	s.checkPRReviews(ctx)
 }
 
 func (s *Syncer) syncProject(ctx context.Context, p *config.ProjectConfig) error {
@@ -9,21 +9,104 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
+
+	"autopr/internal/config"
+	"autopr/internal/sandbox"
 )
 
-// CLIProvider invokes an LLM via its CLI tool (claude or codex).
+// CLIProvider invokes an LLM via its CLI tool. The built-in claude and codex
+// tools and custom [llm.providers.<name>] entries share the same CLISpec model.
 type CLIProvider struct {
-	name string // "claude" or "codex"
+	name string
+	opts CLIOptions
+	spec CLISpec
+	iso  *Isolation
+}
+
+// CLIOptions are per-route settings substituted into the argument template.
+// Zero values drop the corresponding flag so the tool's default applies.
+type CLIOptions struct {
+	Model    string // {{model}}
+	MaxTurns int    // {{max_turns}}
+}
+
+// CLISpec describes how to invoke a CLI tool and parse its output.
+type CLISpec struct {
+	Binary     string
+	Args       []string // may reference {{prompt}}, {{jsonl}}, {{model}} and {{max_turns}}
+	PromptMode string   // config.PromptModeArg, PromptModeStdin or PromptModeFile
+	Output     string   // config.OutputClaude, OutputCodex, OutputJSONL or OutputText
+	Text       []config.JSONLTextRule
+	Usage      []config.JSONLUsageRule
+}
+
+var builtinCLISpecs = map[string]CLISpec{
+	config.ProviderClaude: {
+		Binary: "claude",
+		Args: []string{
+			"--print",
+			"--output-format", "stream-json",
+			"--max-turns", "{{max_turns}}",
+			"--dangerously-skip-permissions",
+			"--model", "{{model}}",
+			"--prompt", "{{prompt}}",
+		},
+		PromptMode: config.PromptModeArg,
+		Output:     config.OutputClaude,
+	},
+	config.ProviderCodex: {
+		Binary:     "codex",
+		Args:       []string{"exec", "--model", "{{model}}", "--full-auto", "--json", "{{prompt}}"},
+		PromptMode: config.PromptModeArg,
+		Output:     config.OutputCodex,
+	},
 }
 
-func NewCLIProvider(name string) *CLIProvider {
-	return &CLIProvider{name: name}
+// NewCLIProvider returns a provider for a built-in CLI tool. Unknown names are
+// invoked as `<name> <prompt>` and parsed as Claude/Codex JSONL.
+func NewCLIProvider(name string, opts CLIOptions) *CLIProvider {
+	spec, ok := builtinCLISpecs[name]
+	if !ok {
+		spec = CLISpec{Binary: name, Args: []string{"{{prompt}}"}, PromptMode: config.PromptModeArg, Output: config.OutputClaude}
+	}
+	return &CLIProvider{name: name, opts: opts, spec: spec}
+}
+
+// NewCustomCLIProvider returns a provider declared in [llm.providers.<name>].
+func NewCustomCLIProvider(name string, cfg config.CLIProviderConfig, opts CLIOptions) *CLIProvider {
+	return &CLIProvider{name: name, opts: opts, spec: CLISpec{
+		Binary:     cfg.Binary,
+		Args:       cfg.Args,
+		PromptMode: cfg.PromptMode,
+		Output:     cfg.Output,
+		Text:       cfg.Text,
+		Usage:      cfg.Usage,
+	}}
 }
 
 func (p *CLIProvider) Name() string { return p.name }
 
+// WithIsolation returns a copy of p that runs the tool with a scrubbed
+// environment and, if configured, inside a sandbox. The tool also inherits its
+// own API settings (AgentVars) and login state (AgentHomeLinks), and the
+// network stays available because it has to reach its API.
+func (p *CLIProvider) WithIsolation(iso Isolation) Provider {
+	isolated := *p
+	iso.Env.Pass = append(slices.Clip(iso.Env.Pass), sandbox.AgentVars...)
+	iso.Env.Links = append(slices.Clip(iso.Env.Links), sandbox.AgentHomeLinks...)
+	if iso.Sandbox != nil {
+		opts := *iso.Sandbox
+		opts.DenyNetwork = false
+		iso.Sandbox = &opts
+	}
+	isolated.iso = &iso
+	return &isolated
+}
+
 func (p *CLIProvider) Run(ctx context.Context, workDir, prompt, jsonlPath string) (Response, error) {
 	start := time.Now()
 
@@ -39,22 +122,63 @@ func (p *CLIProvider) Run(ctx context.Context, workDir, prompt, jsonlPath string
 		_ = os.MkdirAll(filepath.Dir(jsonlFile), 0o755)
 	}
 
-	args := p.buildArgs(prompt, jsonlFile)
+	promptArg := prompt
+	if p.spec.PromptMode == config.PromptModeFile {
+		promptFile := strings.TrimSuffix(jsonlFile, ".jsonl") + ".prompt.md"
+		if err := os.WriteFile(promptFile, []byte(prompt), 0o600); err != nil {
+			return Response{}, fmt.Errorf("write prompt file: %w", err)
+		}
+		defer os.Remove(promptFile)
+		promptArg = promptFile
+	}
+	args := p.buildArgs(promptArg, jsonlFile)
+	// A tool that writes its own transcript to {{jsonl}} owns that file; stdout
+	// is then only used as a plain-text fallback.
+	toolWritesJSONL := p.referencesJSONL()
 
-	slog.Debug("llm exec", "provider", p.name, "workdir", workDir, "args_count", len(args))
+	slog.Debug("llm exec", "provider", p.name, "binary", p.spec.Binary, "workdir", workDir, "args_count", len(args))
 
-	cmd := exec.CommandContext(ctx, p.name, args...)
+	cmd := exec.CommandContext(ctx, p.spec.Binary, args...)
 	cmd.Dir = workDir
+	if p.spec.PromptMode == config.PromptModeStdin {
+		cmd.Stdin = strings.NewReader(prompt)
+	}
+	if p.iso != nil {
+		env, err := p.iso.Env.Environ()
+		if err != nil {
+			return Response{}, err
+		}
+		cmd.Env = env
+	}
+	if p.iso != nil && p.iso.Sandbox != nil {
+		opts := *p.iso.Sandbox
+		if p.spec.PromptMode == config.PromptModeFile {
+			opts.ReadOnly = append(slices.Clip(opts.ReadOnly), promptArg)
+		}
+		if toolWritesJSONL {
+			// The file must exist to be bound into the sandbox.
+			if f, err := os.OpenFile(jsonlFile, os.O_CREATE|os.O_WRONLY, 0o644); err == nil {
+				f.Close()
+			}
+			opts.ReadWrite = append(slices.Clip(opts.ReadWrite), jsonlFile)
+		}
+		if err := sandbox.Wrap(cmd, opts); err != nil {
+			return Response{}, err
+		}
+	}
+	KillProcessGroupOnCancel(cmd)
 
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		return Response{}, fmt.Errorf("stdout pipe: %w", err)
 	}
-	// Discard stderr — LLM tools emit noisy internal warnings (e.g. codex rollout state errors).
-	cmd.Stderr = nil
+	// Keep only a bounded stderr tail — LLM tools emit noisy internal warnings
+	// (e.g. codex rollout state errors), so it is only reported on failure.
+	stderr := &tailBuffer{limit: maxStderrTail}
+	cmd.Stderr = stderr
 
 	if err := cmd.Start(); err != nil {
-		return Response{}, fmt.Errorf("start %s: %w", p.name, err)
+		return Response{}, fmt.Errorf("start %s: %w", p.spec.Binary, err)
 	}
 
 	// Read streaming JSONL output and capture the final text.
@@ -62,9 +186,13 @@ func (p *CLIProvider) Run(ctx context.Context, workDir, prompt, jsonlPath string
 	resp.JSONLPath = jsonlFile
 
 	// Open JSONL file once for the entire session.
-	jsonlF, jsonlErr := os.OpenFile(jsonlFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
-	if jsonlErr != nil {
-		slog.Warn("failed to open jsonl file", "path", jsonlFile, "err", jsonlErr)
+	var jsonlF *os.File
+	if !toolWritesJSONL {
+		var jsonlErr error
+		jsonlF, jsonlErr = os.OpenFile(jsonlFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+		if jsonlErr != nil {
+			slog.Warn("failed to open jsonl file", "path", jsonlFile, "err", jsonlErr)
+		}
 	}
 	defer func() {
 		if jsonlF != nil {
@@ -74,8 +202,8 @@ func (p *CLIProvider) Run(ctx context.Context, workDir, prompt, jsonlPath string
 
 	scanner := bufio.NewScanner(stdout)
 	scanner.Buffer(make([]byte, 0, 256*1024), 1024*1024) // 1MB line buffer
-	var lastText string
-	var totalIn, totalOut int
+	out := outputParser{model: p.opts.Model}
+	var stdoutText strings.Builder
 
 	for scanner.Scan() {
 		line := scanner.Text()
@@ -90,50 +218,65 @@ func (p *CLIProvider) Run(ctx context.Context, workDir, prompt, jsonlPath string
 			}
 		}
 
-		var msg jsonlMessage
-		if err := json.Unmarshal([]byte(line), &msg); err != nil {
+		if toolWritesJSONL || p.spec.Output == config.OutputText {
+			stdoutText.WriteString(line + "\n")
 			continue
 		}
+		out.parseLine(p.spec, line)
+	}
 
-		switch {
-		// Claude format: assistant messages with content blocks.
-		case msg.Type == "assistant" && msg.Message.Content != nil:
-			for _, block := range msg.Message.Content {
-				if block.Type == "text" && block.Text != "" {
-					lastText = block.Text
-				}
-			}
-			if msg.Message.Usage.InputTokens > 0 {
-				totalIn += msg.Message.Usage.InputTokens
-			}
-			if msg.Message.Usage.OutputTokens > 0 {
-				totalOut += msg.Message.Usage.OutputTokens
-			}
-		case msg.Type == "result":
-			if msg.Result != "" {
-				lastText = msg.Result
-			}
+	waitErr := cmd.Wait()
+	if toolWritesJSONL && p.spec.Output != config.OutputText {
+		if err := out.parseFile(p.spec, jsonlFile); err != nil {
+			slog.Warn("failed to read provider jsonl", "path", jsonlFile, "err", err)
+		}
+	}
 
-		// Codex format: item.completed with nested item object.
-		case msg.Type == "item.completed" && msg.Item != nil:
-			if msg.Item.Type == "agent_message" && msg.Item.Text != "" {
-				lastText = msg.Item.Text
+	if err := waitErr; err != nil {
+		err = fmt.Errorf("%s exited with error: %w", p.name, err)
+		errText := out.errorText
+		if errText == "" && p.spec.Output == config.OutputText {
+			errText = lastLines(stdoutText.String(), 5)
+		}
+		if ctx.Err() == nil && errText != "" {
+			err = fmt.Errorf("%w: %s", err, errText)
+		}
+		if tail := stderr.String(); tail != "" {
+			err = fmt.Errorf("%w\nstderr (tail):\n%s", err, tail)
+		}
+		// Keep what the agent did and spent before failing so it can still be
+		// audited and counted against the budget.
+		partial := Response{
+			JSONLPath:           jsonlFile,
+			InputTokens:         out.totalIn,
+			OutputTokens:        out.totalOut,
+			CacheReadTokens:     out.totalCacheRead,
+			CacheCreationTokens: out.totalCacheWrite,
+			Model:               out.model,
+			Events:              out.events.events,
+			DurationMS:          int(time.Since(start).Milliseconds()),
+		}
+		// Text output is model output, not an error message: classify only
+		// the stream's error and stderr.
+		if ctx.Err() == nil {
+			if reason := classifyTransient(out.errorText + "\n" + stderr.String()); reason != "" {
+				return partial, &TransientError{Reason: reason, Err: err}
 			}
-
-		// Codex format: turn.completed with usage stats.
-		case msg.Type == "turn.completed" && msg.Usage != nil:
-			totalIn += msg.Usage.InputTokens
-			totalOut += msg.Usage.OutputTokens
 		}
+		return partial, err
 	}
 
-	if err := cmd.Wait(); err != nil {
-		return Response{}, fmt.Errorf("%s exited with error: %w", p.name, err)
+	if out.lastText == "" {
+		out.lastText = strings.TrimSpace(stdoutText.String())
 	}
 
-	resp.Text = lastText
-	resp.InputTokens = totalIn
-	resp.OutputTokens = totalOut
+	resp.Text = out.lastText
+	resp.InputTokens = out.totalIn
+	resp.OutputTokens = out.totalOut
+	resp.CacheReadTokens = out.totalCacheRead
+	resp.CacheCreationTokens = out.totalCacheWrite
+	resp.Model = out.model
+	resp.Events = out.events.events
 	resp.DurationMS = int(time.Since(start).Milliseconds())
 
 	// Try to detect commit SHA from git.
@@ -142,26 +285,205 @@ func (p *CLIProvider) Run(ctx context.Context, workDir, prompt, jsonlPath string
 	return resp, nil
 }
 
+// buildArgs expands the argument template. A bare "{{model}}" or
+// "{{max_turns}}" argument without a value is dropped along with the flag
+// right before it.
 func (p *CLIProvider) buildArgs(prompt, jsonlFile string) []string {
-	switch p.name {
-	case "claude":
-		return []string{
-			"--print",
-			"--output-format", "stream-json",
-			"--max-turns", "50",
-			"--dangerously-skip-permissions",
-			"--prompt", prompt,
+	maxTurns := ""
+	if p.opts.MaxTurns > 0 {
+		maxTurns = strconv.Itoa(p.opts.MaxTurns)
+	}
+	optional := map[string]string{"{{model}}": p.opts.Model, "{{max_turns}}": maxTurns}
+
+	args := make([]string, 0, len(p.spec.Args))
+	for _, arg := range p.spec.Args {
+		if value, ok := optional[arg]; ok && value == "" {
+			if n := len(args); n > 0 && strings.HasPrefix(args[n-1], "-") {
+				args = args[:n-1]
+			}
+			continue
 		}
-	case "codex":
-		return []string{
-			"exec",
-			"--full-auto",
-			"--json",
-			prompt,
+		arg = strings.ReplaceAll(arg, "{{model}}", p.opts.Model)
+		arg = strings.ReplaceAll(arg, "{{max_turns}}", maxTurns)
+		arg = strings.ReplaceAll(arg, "{{prompt}}", prompt)
+		args = append(args, strings.ReplaceAll(arg, "{{jsonl}}", jsonlFile))
+	}
+	return args
+}
+
+func (p *CLIProvider) referencesJSONL() bool {
+	for _, arg := range p.spec.Args {
+		if strings.Contains(arg, "{{jsonl}}") {
+			return true
 		}
-	default:
-		return []string{prompt}
 	}
+	return false
+}
+
+// outputParser accumulates final text and token usage across JSONL lines.
+type outputParser struct {
+	model                           string
+	lastText                        string
+	totalIn, totalOut               int
+	totalCacheRead, totalCacheWrite int
+	errorText                       string // last error reported in the stream
+	events                          eventParser
+}
+
+func (o *outputParser) parseFile(spec CLISpec, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 256*1024), 1024*1024)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			o.parseLine(spec, line)
+		}
+	}
+	return scanner.Err()
+}
+
+func (o *outputParser) parseLine(spec CLISpec, line string) {
+	if msg := streamErrorText(line); msg != "" {
+		o.errorText = msg
+	}
+	o.events.parseLine(line)
+	if spec.Output == config.OutputJSONL {
+		o.parseRules(spec, line)
+		return
+	}
+
+	var msg jsonlMessage
+	if err := json.Unmarshal([]byte(line), &msg); err != nil {
+		return
+	}
+
+	if msg.Type == "system" && msg.Model != "" {
+		o.model = msg.Model
+	}
+
+	switch {
+	// Claude format: assistant messages with content blocks.
+	case msg.Type == "assistant" && msg.Message.Content != nil:
+		for _, block := range msg.Message.Content {
+			if block.Type == "text" && block.Text != "" {
+				o.lastText = block.Text
+			}
+		}
+		usage := msg.Message.Usage
+		o.totalIn += max(usage.InputTokens, 0)
+		o.totalOut += max(usage.OutputTokens, 0)
+		o.totalCacheRead += max(usage.CacheReadInputTokens, 0)
+		o.totalCacheWrite += max(usage.CacheCreationInputTokens, 0)
+	case msg.Type == "result":
+		if msg.Result != "" {
+			o.lastText = msg.Result
+		}
+
+	// Codex format: item.completed with nested item object.
+	case msg.Type == "item.completed" && msg.Item != nil:
+		if msg.Item.Type == "agent_message" && msg.Item.Text != "" {
+			o.lastText = msg.Item.Text
+		}
+
+	// Codex format: turn.completed with usage stats.
+	// input_tokens includes cached_input_tokens, which are billed separately.
+	case msg.Type == "turn.completed" && msg.Usage != nil:
+		o.totalIn += msg.Usage.InputTokens - msg.Usage.CachedInputTokens
+		o.totalOut += msg.Usage.OutputTokens
+		o.totalCacheRead += msg.Usage.CachedInputTokens
+	}
+}
+
+// parseRules applies the declarative text and usage rules of a custom provider.
+func (o *outputParser) parseRules(spec CLISpec, line string) {
+	var msg map[string]any
+	if err := json.Unmarshal([]byte(line), &msg); err != nil {
+		return
+	}
+	msgType, _ := msg["type"].(string)
+
+	for _, rule := range spec.Text {
+		if rule.Type != "" && rule.Type != msgType {
+			continue
+		}
+		if text, ok := lookupPath(msg, rule.Path).(string); ok && text != "" {
+			o.lastText = text
+		}
+	}
+	for _, rule := range spec.Usage {
+		if rule.Type != "" && rule.Type != msgType {
+			continue
+		}
+		o.totalIn += lookupInt(msg, rule.InputTokens)
+		o.totalOut += lookupInt(msg, rule.OutputTokens)
+		o.totalCacheRead += lookupInt(msg, rule.CacheReadTokens)
+		o.totalCacheWrite += lookupInt(msg, rule.CacheCreationTokens)
+	}
+}
+
+// streamErrorText extracts an error message from a Claude or Codex JSONL
+// line: Claude results with is_error, Codex "error" and "turn.failed" events,
+// and any line carrying a top-level error object.
+func streamErrorText(line string) string {
+	if !strings.Contains(line, "error") {
+		return ""
+	}
+	var msg map[string]any
+	if err := json.Unmarshal([]byte(line), &msg); err != nil {
+		return ""
+	}
+	msgType, _ := msg["type"].(string)
+	if isErr, _ := msg["is_error"].(bool); isErr {
+		if text, ok := msg["result"].(string); ok {
+			return text
+		}
+	}
+	if msgType == "error" {
+		if text, ok := msg["message"].(string); ok {
+			return text
+		}
+	}
+	if text, ok := lookupPath(msg, "error.message").(string); ok {
+		return text
+	}
+	if text, ok := msg["error"].(string); ok && msgType != "" {
+		return text
+	}
+	return ""
+}
+
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// lookupPath resolves a dotted path such as "item.text" in a decoded JSON object.
+func lookupPath(v any, path string) any {
+	if path == "" {
+		return nil
+	}
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = obj[key]
+	}
+	return v
+}
+
+func lookupInt(v any, path string) int {
+	if n, ok := lookupPath(v, path).(float64); ok {
+		return int(n)
+	}
+	return 0
 }
 
 func detectLatestCommit(ctx context.Context, dir string) string {
@@ -180,6 +502,7 @@ type jsonlMessage struct {
 	Type string `json:"type"`
 
 	// Claude format fields.
+	Model   string      `json:"model,omitempty"` // set on the system init line
 	Message jsonlAssist `json:"message"`
 	Result  string      `json:"result,omitempty"`
 
@@ -206,4 +529,10 @@ type jsonlItem struct {
 type jsonlUsage struct {
 	InputTokens  int `json:"input_tokens"`
 	OutputTokens int `json:"output_tokens"`
+
+	// Claude reports prompt-cache tokens separately from input_tokens.
+	CacheReadInputTokens     int `json:"cache_read_input_tokens,omitempty"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens,omitempty"`
+	// Codex reports cached tokens as a subset of input_tokens.
+	CachedInputTokens int `json:"cached_input_tokens,omitempty"`
 }
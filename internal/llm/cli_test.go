@@ -0,0 +1,295 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"autopr/internal/config"
+	"autopr/internal/sandbox"
+)
+
+func TestCustomCLIProviderJSONLRules(t *testing.T) {
+	t.Parallel()
+	p := NewCustomCLIProvider("echoer", config.CLIProviderConfig{
+		Binary: "sh",
+		Args: []string{"-c", `printf '{"type":"progress","n":1}\n{"type":"done","out":{"text":"%s"},"stats":{"in":7,"out":3}}\n' "$1"`,
+			"sh", "{{prompt}}"},
+		PromptMode: config.PromptModeArg,
+		Output:     config.OutputJSONL,
+		Text:       []config.JSONLTextRule{{Type: "done", Path: "out.text"}},
+		Usage:      []config.JSONLUsageRule{{Type: "done", InputTokens: "stats.in", OutputTokens: "stats.out"}},
+	}, CLIOptions{})
+	jsonlPath := filepath.Join(t.TempDir(), "session.jsonl")
+
+	resp, err := p.Run(context.Background(), t.TempDir(), "hello", jsonlPath)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if resp.Text != "hello" || resp.InputTokens != 7 || resp.OutputTokens != 3 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	data, err := os.ReadFile(jsonlPath)
+	if err != nil || strings.Count(string(data), "\n") != 2 {
+		t.Fatalf("expected stdout copied to jsonl, got %q err=%v", data, err)
+	}
+}
+
+func TestCustomCLIProviderPromptModes(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name string
+		cfg  config.CLIProviderConfig
+	}{
+		{"stdin", config.CLIProviderConfig{Binary: "cat", PromptMode: config.PromptModeStdin}},
+		{"file", config.CLIProviderConfig{Binary: "cat", Args: []string{"{{prompt}}"}, PromptMode: config.PromptModeFile}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			tc.cfg.Output = config.OutputText
+			p := NewCustomCLIProvider("cat", tc.cfg, CLIOptions{})
+			jsonlPath := filepath.Join(t.TempDir(), "session.jsonl")
+			resp, err := p.Run(context.Background(), t.TempDir(), "line one\nline two", jsonlPath)
+			if err != nil {
+				t.Fatalf("run: %v", err)
+			}
+			if resp.Text != "line one\nline two" {
+				t.Fatalf("expected prompt echoed back, got %q", resp.Text)
+			}
+			if _, err := os.Stat(strings.TrimSuffix(jsonlPath, ".jsonl") + ".prompt.md"); !os.IsNotExist(err) {
+				t.Fatalf("expected prompt file to be removed, stat err=%v", err)
+			}
+		})
+	}
+}
+
+func TestCustomCLIProviderReadsToolWrittenJSONL(t *testing.T) {
+	t.Parallel()
+	p := NewCustomCLIProvider("writer", config.CLIProviderConfig{
+		Binary:     "sh",
+		Args:       []string{"-c", `echo '{"type":"result","result":"from file"}' > "$1"; echo noise`, "sh", "{{jsonl}}"},
+		PromptMode: config.PromptModeStdin,
+		Output:     config.OutputClaude,
+	}, CLIOptions{})
+	jsonlPath := filepath.Join(t.TempDir(), "session.jsonl")
+
+	resp, err := p.Run(context.Background(), t.TempDir(), "ignored", jsonlPath)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if resp.Text != "from file" {
+		t.Fatalf("expected text parsed from tool-written jsonl, got %q", resp.Text)
+	}
+}
+
+func TestBuiltinCLIProviderArgs(t *testing.T) {
+	t.Parallel()
+	args := NewCLIProvider("codex", CLIOptions{}).buildArgs("fix it", "/tmp/s.jsonl")
+	if strings.Join(args, " ") != "exec --full-auto --json fix it" {
+		t.Fatalf("unexpected codex args: %v", args)
+	}
+	args = NewCLIProvider("codex", CLIOptions{Model: "gpt-5-codex"}).buildArgs("fix it", "/tmp/s.jsonl")
+	if strings.Join(args, " ") != "exec --model gpt-5-codex --full-auto --json fix it" {
+		t.Fatalf("unexpected codex args with model: %v", args)
+	}
+	args = NewCLIProvider("claude", CLIOptions{}).buildArgs("fix it", "/tmp/s.jsonl")
+	if args[len(args)-1] != "fix it" || args[len(args)-2] != "--prompt" || strings.Contains(strings.Join(args, " "), "--max-turns") {
+		t.Fatalf("unexpected claude args: %v", args)
+	}
+	args = NewCLIProvider("claude", CLIOptions{MaxTurns: 30}).buildArgs("fix it", "/tmp/s.jsonl")
+	if !strings.Contains(strings.Join(args, " "), "--max-turns 30") {
+		t.Fatalf("expected --max-turns 30 in claude args: %v", args)
+	}
+}
+
+func TestCLIProviderReportsModelFromInitLine(t *testing.T) {
+	t.Parallel()
+	p := NewCustomCLIProvider("fake-claude", config.CLIProviderConfig{
+		Binary:     "sh",
+		Args:       []string{"-c", `echo '{"type":"system","subtype":"init","model":"claude-opus-x"}'; echo '{"type":"result","result":"ok"}'`, "sh", "{{prompt}}"},
+		PromptMode: config.PromptModeArg,
+		Output:     config.OutputClaude,
+	}, CLIOptions{Model: "opus"})
+
+	resp, err := p.Run(context.Background(), t.TempDir(), "go", filepath.Join(t.TempDir(), "s.jsonl"))
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if resp.Model != "claude-opus-x" || resp.Text != "ok" {
+		t.Fatalf("expected model from init line, got %+v", resp)
+	}
+}
+
+func TestCLIProviderKeepsUsageOfFailedSession(t *testing.T) {
+	t.Parallel()
+	script := `echo '{"type":"system","subtype":"init","model":"claude-opus-x"}'
+echo '{"type":"assistant","message":{"content":[{"type":"text","text":"a"}],"usage":{"input_tokens":10,"output_tokens":5,"cache_read_input_tokens":1000,"cache_creation_input_tokens":200}}}'
+echo '{"type":"result","is_error":true,"result":"max turns reached"}'
+exit 1`
+	p := NewCustomCLIProvider("fake-claude", config.CLIProviderConfig{
+		Binary:     "sh",
+		Args:       []string{"-c", script, "sh", "{{prompt}}"},
+		PromptMode: config.PromptModeArg,
+		Output:     config.OutputClaude,
+	}, CLIOptions{Model: "opus"})
+
+	resp, err := p.Run(context.Background(), t.TempDir(), "go", filepath.Join(t.TempDir(), "s.jsonl"))
+	if err == nil {
+		t.Fatalf("expected error")
+	}
+	if resp.InputTokens != 10 || resp.OutputTokens != 5 || resp.CacheReadTokens != 1000 || resp.CacheCreationTokens != 200 || resp.Model != "claude-opus-x" {
+		t.Fatalf("expected the usage spent before failing, got %+v", resp)
+	}
+}
+
+func TestOutputParserCountsCacheTokens(t *testing.T) {
+	t.Parallel()
+
+	claude := outputParser{}
+	for _, line := range []string{
+		`{"type":"assistant","message":{"content":[{"type":"text","text":"a"}],"usage":{"input_tokens":10,"output_tokens":5,"cache_read_input_tokens":1000,"cache_creation_input_tokens":200}}}`,
+		`{"type":"assistant","message":{"content":[{"type":"text","text":"b"}],"usage":{"input_tokens":3,"output_tokens":2,"cache_read_input_tokens":1200}}}`,
+	} {
+		claude.parseLine(CLISpec{Output: config.OutputClaude}, line)
+	}
+	if claude.totalIn != 13 || claude.totalOut != 7 || claude.totalCacheRead != 2200 || claude.totalCacheWrite != 200 {
+		t.Fatalf("unexpected claude totals: %+v", claude)
+	}
+
+	// Codex counts cached tokens inside input_tokens.
+	codex := outputParser{}
+	codex.parseLine(CLISpec{Output: config.OutputCodex},
+		`{"type":"turn.completed","usage":{"input_tokens":5000,"cached_input_tokens":4000,"output_tokens":300}}`)
+	if codex.totalIn != 1000 || codex.totalOut != 300 || codex.totalCacheRead != 4000 || codex.totalCacheWrite != 0 {
+		t.Fatalf("unexpected codex totals: %+v", codex)
+	}
+}
+
+func TestCLIProviderClassifiesTransientStreamErrors(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name   string
+		stream string
+		reason string
+	}{
+		{"claude rate limit", `{"type":"result","is_error":true,"result":"API Error: 429 rate_limit_error"}`, TransientRateLimit},
+		{"claude expired auth", `{"type":"result","is_error":true,"result":"OAuth token has expired. Please run /login"}`, TransientAuthExpired},
+		{"codex server error", `{"type":"turn.failed","error":{"message":"stream error: last status: 503 Service Unavailable"}}`, TransientServerError},
+		{"permanent", `{"type":"result","is_error":true,"result":"invalid flag --foo"}`, ""},
+		{"bare status code", `{"type":"result","is_error":true,"result":"fixed the 401 handler, then hit max turns"}`, ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			p := NewCustomCLIProvider("failing", config.CLIProviderConfig{
+				Binary:     "sh",
+				Args:       []string{"-c", `echo "$1"; exit 1`, "sh", "{{prompt}}"},
+				PromptMode: config.PromptModeArg,
+				Output:     config.OutputClaude,
+			}, CLIOptions{})
+			_, err := p.Run(context.Background(), t.TempDir(), tc.stream, filepath.Join(t.TempDir(), "s.jsonl"))
+			if err == nil {
+				t.Fatalf("expected error")
+			}
+			reason, ok := IsTransient(err)
+			if reason != tc.reason || ok != (tc.reason != "") {
+				t.Fatalf("expected transient reason %q, got %q (ok=%v): %v", tc.reason, reason, ok, err)
+			}
+		})
+	}
+}
+
+func TestCLIProviderClassifiesStderrButNotTextOutput(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name   string
+		script string
+		reason string
+	}{
+		{"model output", `echo "fixed the 401 handler"; echo "raised the quota to 529 rows"; exit 1`, ""},
+		{"numbers on stderr", `echo "retried 429 files" >&2; exit 1`, ""},
+		{"status on stderr", `echo "request failed: HTTP 429" >&2; exit 1`, TransientRateLimit},
+		{"json status on stderr", `echo '{"error":{"status":401}}' >&2; exit 1`, TransientAuthExpired},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			p := NewCustomCLIProvider("failing", config.CLIProviderConfig{
+				Binary:     "sh",
+				Args:       []string{"-c", tc.script, "sh", "{{prompt}}"},
+				PromptMode: config.PromptModeArg,
+				Output:     config.OutputText,
+			}, CLIOptions{})
+			_, err := p.Run(context.Background(), t.TempDir(), "x", filepath.Join(t.TempDir(), "s.jsonl"))
+			if err == nil {
+				t.Fatalf("expected error")
+			}
+			reason, ok := IsTransient(err)
+			if reason != tc.reason || ok != (tc.reason != "") {
+				t.Fatalf("expected transient reason %q, got %q (ok=%v): %v", tc.reason, reason, ok, err)
+			}
+		})
+	}
+}
+
+func TestCLIProviderKillsProcessGroupAndKeepsStderrTail(t *testing.T) {
+	t.Parallel()
+	p := NewCustomCLIProvider("hang", config.CLIProviderConfig{
+		Binary: "sh",
+		// The background sleep inherits stdout; only a group kill lets Run return promptly.
+		Args:       []string{"-c", `echo "warming up" >&2; sleep 30 & sleep 30`, "sh", "{{prompt}}"},
+		PromptMode: config.PromptModeArg,
+		Output:     config.OutputText,
+	}, CLIOptions{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := p.Run(ctx, t.TempDir(), "x", filepath.Join(t.TempDir(), "s.jsonl"))
+	if err == nil {
+		t.Fatalf("expected error after timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Fatalf("expected process group to be killed promptly, took %s", elapsed)
+	}
+	if !strings.Contains(err.Error(), "warming up") {
+		t.Fatalf("expected stderr tail in error, got %v", err)
+	}
+	if _, ok := IsTransient(err); ok {
+		t.Fatalf("timeouts must not be treated as transient: %v", err)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected process error, not bare context error: %v", err)
+	}
+}
+
+func TestTailBufferKeepsLastBytes(t *testing.T) {
+	t.Parallel()
+	tb := &tailBuffer{limit: 8}
+	_, _ = tb.Write([]byte("0123456789"))
+	_, _ = tb.Write([]byte("ab"))
+	if got := tb.String(); got != "...456789ab" {
+		t.Fatalf("unexpected tail %q", got)
+	}
+}
+
+func TestCLIProviderSandboxFailsClosedWithoutBwrap(t *testing.T) {
+	t.Setenv("PATH", "/usr/bin:/bin")
+	if _, err := exec.LookPath("bwrap"); err == nil {
+		t.Skip("bwrap installed")
+	}
+	p := NewCustomCLIProvider("echoer", config.CLIProviderConfig{
+		Binary: "echo", Args: []string{"{{prompt}}"}, PromptMode: config.PromptModeArg, Output: config.OutputText,
+	}, CLIOptions{}).WithIsolation(Isolation{Sandbox: &sandbox.Options{}})
+
+	_, err := p.Run(context.Background(), t.TempDir(), "hi", filepath.Join(t.TempDir(), "s.jsonl"))
+	if !errors.Is(err, sandbox.ErrUnavailable) {
+		t.Fatalf("expected sandbox to fail closed, got %v", err)
+	}
+}
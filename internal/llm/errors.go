@@ -0,0 +1,103 @@
+package llm
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Transient failure reasons.
+const (
+	TransientRateLimit   = "rate_limit"
+	TransientServerError = "server_error"
+	TransientAuthExpired = "auth_expired"
+	TransientUnavailable = "unavailable"
+)
+
+// TransientError marks a provider failure that another provider may not hit,
+// such as a rate limit or an outage. The pipeline retries the step on the next
+// provider in the fallback chain.
+type TransientError struct {
+	Reason string
+	Err    error
+}
+
+func (e *TransientError) Error() string {
+	return fmt.Sprintf("%v (transient: %s)", e.Err, e.Reason)
+}
+
+func (e *TransientError) Unwrap() error { return e.Err }
+
+// IsTransient reports whether err is a transient provider failure and why.
+func IsTransient(err error) (string, bool) {
+	var te *TransientError
+	if errors.As(err, &te) {
+		return te.Reason, true
+	}
+	return "", false
+}
+
+// transientForStatus classifies an HTTP status code.
+func transientForStatus(code int) string {
+	switch {
+	case code == 429:
+		return TransientRateLimit
+	case code == 401:
+		return TransientAuthExpired
+	case code >= 500:
+		return TransientServerError
+	default:
+		return ""
+	}
+}
+
+// transientMarkers maps substrings of provider error messages (lowercased)
+// to transient reasons. Only error messages are matched, never model output.
+var transientMarkers = []struct {
+	marker string
+	reason string
+}{
+	{"rate limit", TransientRateLimit},
+	{"rate_limit", TransientRateLimit},
+	{"too many requests", TransientRateLimit},
+	{"usage limit", TransientRateLimit},
+	{"quota exceeded", TransientRateLimit},
+	{"exceeded your current quota", TransientRateLimit},
+	{"insufficient_quota", TransientRateLimit},
+	{"overloaded", TransientServerError},
+	{"internal server error", TransientServerError},
+	{"bad gateway", TransientServerError},
+	{"service unavailable", TransientServerError},
+	{"gateway timeout", TransientServerError},
+	{"token has expired", TransientAuthExpired},
+	{"token expired", TransientAuthExpired},
+	{"oauth token", TransientAuthExpired},
+	{"authentication_error", TransientAuthExpired},
+	{"please run /login", TransientAuthExpired},
+}
+
+// statusCodePattern matches an HTTP status code in a lowercased error
+// message. Bare numbers are not enough: the code must follow a status
+// context such as `status 429`, `http 401`, `api error: 529` or
+// `"status":429`.
+var statusCodePattern = regexp.MustCompile(`(?:status(?: code)?|http(?:/[\d.]+)?|api error|"status"|"code")\s*[:=]?\s*(\d{3})\b`)
+
+// classifyTransient returns the transient reason for a provider error
+// message, or "" if the failure looks permanent.
+func classifyTransient(msg string) string {
+	lower := strings.ToLower(msg)
+	for _, m := range transientMarkers {
+		if strings.Contains(lower, m.marker) {
+			return m.reason
+		}
+	}
+	for _, match := range statusCodePattern.FindAllStringSubmatch(lower, -1) {
+		code, _ := strconv.Atoi(match[1])
+		if reason := transientForStatus(code); reason != "" {
+			return reason
+		}
+	}
+	return ""
+}
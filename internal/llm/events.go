@@ -0,0 +1,214 @@
+package llm
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event types recorded for agent actions.
+const (
+	EventCommand  = "command"   // shell or allow-listed command execution
+	EventFileEdit = "file_edit" // file created, modified or deleted
+	EventFileRead = "file_read" // file or directory read
+	EventTool     = "tool"      // any other tool call (search, MCP, web)
+)
+
+// Event is a normalized record of one action the agent took during a session.
+// ExitCode is the command's exit status for commands; other tools report 0 on
+// success and 1 when the tool returned an error. It is nil while unknown.
+type Event struct {
+	Type      string
+	Tool      string
+	Path      string
+	Command   string
+	ExitCode  *int
+	Timestamp time.Time
+}
+
+// eventParser extracts events from Claude stream-json (including the shape
+// written by HTTP providers) and Codex exec --json lines.
+type eventParser struct {
+	events  []Event
+	pending map[string]int // Claude tool_use id -> index in events
+	now     func() time.Time
+}
+
+func (e *eventParser) parseLine(line string) {
+	if !strings.Contains(line, "tool_use") && !strings.Contains(line, "tool_result") && !strings.Contains(line, "item.completed") {
+		return
+	}
+	var msg eventMessage
+	if err := json.Unmarshal([]byte(line), &msg); err != nil {
+		return
+	}
+	switch {
+	case msg.Type == "assistant":
+		for _, block := range msg.Message.Content {
+			if block.Type == "tool_use" {
+				e.toolUse(block)
+			}
+		}
+	case msg.Type == "user":
+		for _, block := range msg.Message.Content {
+			if block.Type == "tool_result" {
+				e.toolResult(block)
+			}
+		}
+	case msg.Type == "item.completed" && msg.Item != nil:
+		e.codexItem(*msg.Item)
+	}
+}
+
+func (e *eventParser) add(ev Event) int {
+	if e.now == nil {
+		e.now = time.Now
+	}
+	ev.Timestamp = e.now().UTC()
+	e.events = append(e.events, ev)
+	return len(e.events) - 1
+}
+
+func (e *eventParser) toolUse(block eventBlock) {
+	var input struct {
+		Command      string   `json:"command"`
+		Argv         []string `json:"argv"`
+		FilePath     string   `json:"file_path"`
+		NotebookPath string   `json:"notebook_path"`
+		Path         string   `json:"path"`
+	}
+	_ = json.Unmarshal(block.Input, &input)
+
+	ev := Event{Tool: block.Name, Type: EventTool, Path: input.Path}
+	switch block.Name {
+	case "Bash":
+		ev.Type, ev.Command = EventCommand, input.Command
+	case "run_command":
+		ev.Type, ev.Command = EventCommand, strings.Join(input.Argv, " ")
+	case "Edit", "MultiEdit", "Write", "NotebookEdit", "write_file":
+		ev.Type = EventFileEdit
+	case "Read", "NotebookRead", "read_file", "list_dir", "LS":
+		ev.Type = EventFileRead
+	}
+	if input.FilePath != "" {
+		ev.Path = input.FilePath
+	} else if input.NotebookPath != "" {
+		ev.Path = input.NotebookPath
+	}
+
+	idx := e.add(ev)
+	if block.ID != "" {
+		if e.pending == nil {
+			e.pending = make(map[string]int)
+		}
+		e.pending[block.ID] = idx
+	}
+}
+
+// exitCodePattern matches the exit status reported in a failed tool result:
+// Claude's Bash tool ("Exit code 2") and Go's exec errors ("exit status 2").
+var exitCodePattern = regexp.MustCompile(`(?i)exit (?:code|status) (\d+)`)
+
+func (e *eventParser) toolResult(block eventBlock) {
+	idx, ok := e.pending[block.ToolUseID]
+	if !ok {
+		return
+	}
+	delete(e.pending, block.ToolUseID)
+
+	code := 0
+	if block.IsError {
+		code = 1
+		firstLine, _, _ := strings.Cut(resultText(block.Content), "\n")
+		if m := exitCodePattern.FindStringSubmatch(firstLine); m != nil {
+			if n, err := strconv.Atoi(m[1]); err == nil {
+				code = n
+			}
+		}
+	}
+	e.events[idx].ExitCode = &code
+}
+
+func (e *eventParser) codexItem(item eventItem) {
+	status := func() *int {
+		code := 0
+		if item.Status == "failed" || item.Status == "declined" {
+			code = 1
+		}
+		return &code
+	}
+
+	switch item.Type {
+	case "command_execution":
+		ev := Event{Type: EventCommand, Tool: item.Type, Command: item.Command}
+		if item.ExitCode != nil {
+			code := *item.ExitCode
+			ev.ExitCode = &code
+		} else if item.Status != "" && item.Status != "in_progress" {
+			ev.ExitCode = status()
+		}
+		e.add(ev)
+	case "file_change":
+		for _, change := range item.Changes {
+			e.add(Event{Type: EventFileEdit, Tool: item.Type, Path: change.Path, ExitCode: status()})
+		}
+	case "mcp_tool_call":
+		e.add(Event{Type: EventTool, Tool: item.Server + "." + item.Tool, ExitCode: status()})
+	case "web_search":
+		e.add(Event{Type: EventTool, Tool: item.Type, Command: item.Query})
+	}
+}
+
+// resultText flattens a tool_result content field, which is either a string
+// or a list of text blocks.
+func resultText(raw json.RawMessage) string {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	var blocks []struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(raw, &blocks); err != nil {
+		return ""
+	}
+	parts := make([]string, 0, len(blocks))
+	for _, b := range blocks {
+		parts = append(parts, b.Text)
+	}
+	return strings.Join(parts, "\n")
+}
+
+type eventMessage struct {
+	Type    string `json:"type"`
+	Message struct {
+		Content []eventBlock `json:"content"`
+	} `json:"message"`
+	Item *eventItem `json:"item,omitempty"`
+}
+
+type eventBlock struct {
+	Type      string          `json:"type"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   json.RawMessage `json:"content,omitempty"`
+	IsError   bool            `json:"is_error,omitempty"`
+}
+
+type eventItem struct {
+	Type     string `json:"type"`
+	Command  string `json:"command,omitempty"`
+	ExitCode *int   `json:"exit_code,omitempty"`
+	Status   string `json:"status,omitempty"`
+	Changes  []struct {
+		Path string `json:"path"`
+		Kind string `json:"kind"`
+	} `json:"changes,omitempty"`
+	Server string `json:"server,omitempty"`
+	Tool   string `json:"tool,omitempty"`
+	Query  string `json:"query,omitempty"`
+}
@@ -0,0 +1,93 @@
+package llm
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"autopr/internal/config"
+)
+
+func TestEventParserClaudeToolCalls(t *testing.T) {
+	t.Parallel()
+	ts := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	p := eventParser{now: func() time.Time { return ts }}
+	lines := []string{
+		`{"type":"system","subtype":"init","model":"claude-sonnet-4-5"}`,
+		`{"type":"assistant","message":{"content":[{"type":"text","text":"looking"},{"type":"tool_use","id":"t1","name":"Bash","input":{"command":"go test ./..."}},{"type":"tool_use","id":"t2","name":"Edit","input":{"file_path":"/repo/main.go","old_string":"a","new_string":"b"}}]}}`,
+		`{"type":"user","message":{"content":[{"type":"tool_result","tool_use_id":"t1","is_error":true,"content":"Exit code 2\nFAIL"},{"type":"tool_result","tool_use_id":"t2","content":[{"type":"text","text":"ok"}]}]}}`,
+		`{"type":"assistant","message":{"content":[{"type":"tool_use","id":"t3","name":"Grep","input":{"pattern":"TODO","path":"internal"}}]}}`,
+	}
+	for _, line := range lines {
+		p.parseLine(line)
+	}
+
+	if len(p.events) != 3 {
+		t.Fatalf("expected 3 events, got %+v", p.events)
+	}
+	cmd, edit, grep := p.events[0], p.events[1], p.events[2]
+	if cmd.Type != EventCommand || cmd.Command != "go test ./..." || cmd.ExitCode == nil || *cmd.ExitCode != 2 {
+		t.Fatalf("unexpected command event: %+v", cmd)
+	}
+	if !cmd.Timestamp.Equal(ts) {
+		t.Fatalf("timestamp = %v, want %v", cmd.Timestamp, ts)
+	}
+	if edit.Type != EventFileEdit || edit.Path != "/repo/main.go" || edit.ExitCode == nil || *edit.ExitCode != 0 {
+		t.Fatalf("unexpected edit event: %+v", edit)
+	}
+	if grep.Type != EventTool || grep.Tool != "Grep" || grep.Path != "internal" || grep.ExitCode != nil {
+		t.Fatalf("unexpected tool event: %+v", grep)
+	}
+}
+
+func TestEventParserCodexItems(t *testing.T) {
+	t.Parallel()
+	var p eventParser
+	lines := []string{
+		`{"type":"item.started","item":{"id":"i0","type":"command_execution","command":"bash -lc ls","status":"in_progress"}}`,
+		`{"type":"item.completed","item":{"id":"i0","type":"command_execution","command":"bash -lc ls","aggregated_output":"a\n","exit_code":0,"status":"completed"}}`,
+		`{"type":"item.completed","item":{"id":"i1","type":"file_change","changes":[{"path":"a.go","kind":"update"},{"path":"b.go","kind":"add"}],"status":"completed"}}`,
+		`{"type":"item.completed","item":{"id":"i2","type":"command_execution","command":"make test","exit_code":1,"status":"failed"}}`,
+		`{"type":"item.completed","item":{"id":"i3","type":"agent_message","text":"done"}}`,
+	}
+	for _, line := range lines {
+		p.parseLine(line)
+	}
+
+	want := []struct {
+		typ, path, command string
+		exit               int
+	}{
+		{EventCommand, "", "bash -lc ls", 0},
+		{EventFileEdit, "a.go", "", 0},
+		{EventFileEdit, "b.go", "", 0},
+		{EventCommand, "", "make test", 1},
+	}
+	if len(p.events) != len(want) {
+		t.Fatalf("expected %d events, got %+v", len(want), p.events)
+	}
+	for i, w := range want {
+		ev := p.events[i]
+		if ev.Type != w.typ || ev.Path != w.path || ev.Command != w.command || ev.ExitCode == nil || *ev.ExitCode != w.exit {
+			t.Fatalf("event %d = %+v, want %+v", i, ev, w)
+		}
+	}
+}
+
+func TestCLIProviderReturnsEventsOnFailure(t *testing.T) {
+	t.Parallel()
+	p := &CLIProvider{name: "claude", spec: CLISpec{
+		Binary: "sh",
+		Args: []string{"-c", `printf '%s\n' '{"type":"assistant","message":{"content":[{"type":"tool_use","id":"t1","name":"Write","input":{"file_path":"x.go"}}]}}'; exit 3`,
+			"sh", "{{prompt}}"},
+		Output: config.OutputClaude,
+	}}
+	resp, err := p.Run(context.Background(), t.TempDir(), "go", filepath.Join(t.TempDir(), "s.jsonl"))
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if len(resp.Events) != 1 || resp.Events[0].Type != EventFileEdit || resp.Events[0].Path != "x.go" {
+		t.Fatalf("expected partial events on failure, got %+v", resp.Events)
+	}
+}
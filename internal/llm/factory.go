@@ -0,0 +1,85 @@
+package llm
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"autopr/internal/config"
+)
+
+// NewFromConfig builds the default provider selected by the [llm] config section.
+func NewFromConfig(cfg config.LLMConfig) (Provider, error) {
+	return NewForRoute(cfg, config.LLMRoute{
+		Provider:  cfg.Provider,
+		Model:     cfg.Model,
+		BaseURL:   cfg.BaseURL,
+		APIKeyEnv: cfg.APIKeyEnv,
+		Timeout:   cfg.Timeout,
+		MaxTurns:  cfg.MaxTurns,
+	})
+}
+
+// NewForRoute builds the provider for a resolved step route. When
+// llm.record_dir is set, the provider's sessions are recorded for replay.
+func NewForRoute(cfg config.LLMConfig, route config.LLMRoute) (Provider, error) {
+	p, err := newProvider(cfg, route)
+	if err != nil || cfg.RecordDir == "" || route.Provider == config.ProviderReplay {
+		return p, err
+	}
+	return NewRecordingProvider(p, cfg.RecordDir), nil
+}
+
+func newProvider(cfg config.LLMConfig, route config.LLMRoute) (Provider, error) {
+	switch route.Provider {
+	case config.ProviderReplay:
+		return NewReplayProvider(cfg.ReplayDir), nil
+	case config.ProviderOpenAI, config.ProviderAnthropic:
+		apiKey := ""
+		if route.APIKeyEnv != "" {
+			apiKey = os.Getenv(route.APIKeyEnv)
+		}
+		return NewHTTPProvider(HTTPConfig{
+			Name:            route.Provider,
+			API:             route.Provider,
+			BaseURL:         route.BaseURL,
+			APIKey:          apiKey,
+			Model:           route.Model,
+			MaxTurns:        route.MaxTurns,
+			AllowedCommands: cfg.AllowedCommands,
+		})
+	default:
+		opts := CLIOptions{Model: route.Model, MaxTurns: route.MaxTurns}
+		if custom, ok := cfg.Providers[route.Provider]; ok {
+			return NewCustomCLIProvider(route.Provider, custom, opts), nil
+		}
+		return NewCLIProvider(route.Provider, opts), nil
+	}
+}
+
+// Router builds and caches one provider per distinct route.
+type Router struct {
+	cfg config.LLMConfig
+
+	mu        sync.Mutex
+	providers map[config.LLMRoute]Provider
+}
+
+func NewRouter(cfg config.LLMConfig) *Router {
+	return &Router{cfg: cfg, providers: make(map[config.LLMRoute]Provider)}
+}
+
+// Provider returns the provider for route, creating it on first use.
+func (r *Router) Provider(route config.LLMRoute) (Provider, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if p, ok := r.providers[route]; ok {
+		return p, nil
+	}
+	p, err := NewForRoute(r.cfg, route)
+	if err != nil {
+		return nil, fmt.Errorf("create %s provider: %w", route.Provider, err)
+	}
+	r.providers[route] = p
+	return p, nil
+}
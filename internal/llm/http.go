@@ -0,0 +1,556 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Supported HTTP API dialects.
+const (
+	APIAnthropic = "anthropic" // Anthropic Messages API (/v1/messages)
+	APIOpenAI    = "openai"    // OpenAI chat completions API (/v1/chat/completions)
+)
+
+const (
+	defaultHTTPMaxTurns  = 50
+	defaultHTTPMaxTokens = 8192
+	anthropicAPIVersion  = "2023-06-01"
+	maxHTTPErrorBody     = 2048
+)
+
+const httpSystemPrompt = `You are an autonomous software engineering agent working inside a git repository.
+The repository root is your working directory; all paths are relative to it.
+Use the provided tools to inspect and modify files and to run allowed commands.
+When you are finished, reply with a final message summarizing what you did and do not call any more tools.`
+
+// HTTPConfig configures an HTTPProvider.
+type HTTPConfig struct {
+	Name            string   // Provider name recorded on sessions (defaults to API).
+	API             string   // APIAnthropic or APIOpenAI.
+	BaseURL         string   // API root, e.g. "https://api.anthropic.com" or "http://localhost:11434/v1".
+	APIKey          string   // Optional for local OpenAI-compatible servers.
+	Model           string   // Model name sent with every request.
+	MaxTurns        int      // Max request/tool round-trips per Run (default 50).
+	MaxTokens       int      // Max output tokens per request (default 8192).
+	AllowedCommands []string // Executables the run_command tool may invoke.
+}
+
+// HTTPProvider talks to an LLM HTTP API directly and runs its own tool loop
+// confined to the job worktree. It does not need a vendor CLI installed.
+type HTTPProvider struct {
+	cfg    HTTPConfig
+	client *http.Client
+	iso    *Isolation // applied to run_command tool calls
+}
+
+func NewHTTPProvider(cfg HTTPConfig) (*HTTPProvider, error) {
+	switch cfg.API {
+	case APIAnthropic, APIOpenAI:
+	default:
+		return nil, fmt.Errorf("unsupported http api %q (must be %s or %s)", cfg.API, APIAnthropic, APIOpenAI)
+	}
+	if strings.TrimSpace(cfg.BaseURL) == "" {
+		return nil, fmt.Errorf("http provider base_url is required")
+	}
+	if strings.TrimSpace(cfg.Model) == "" {
+		return nil, fmt.Errorf("http provider model is required")
+	}
+	if cfg.Name == "" {
+		cfg.Name = cfg.API
+	}
+	if cfg.MaxTurns <= 0 {
+		cfg.MaxTurns = defaultHTTPMaxTurns
+	}
+	if cfg.MaxTokens <= 0 {
+		cfg.MaxTokens = defaultHTTPMaxTokens
+	}
+	return &HTTPProvider{cfg: cfg, client: &http.Client{}}, nil
+}
+
+func (p *HTTPProvider) Name() string { return p.cfg.Name }
+
+// WithIsolation returns a copy of p whose run_command tool runs isolated. API
+// calls are made by the daemon itself and are not affected.
+func (p *HTTPProvider) WithIsolation(iso Isolation) Provider {
+	isolated := *p
+	isolated.iso = &iso
+	return &isolated
+}
+
+func (p *HTTPProvider) Run(ctx context.Context, workDir, prompt, jsonlPath string) (Response, error) {
+	start := time.Now()
+
+	jsonlFile := jsonlPath
+	if jsonlFile == "" {
+		jsonlDir := filepath.Join(filepath.Dir(workDir), "sessions")
+		_ = os.MkdirAll(jsonlDir, 0o755)
+		jsonlFile = filepath.Join(jsonlDir, fmt.Sprintf("session-%d.jsonl", time.Now().UnixNano()))
+	} else {
+		_ = os.MkdirAll(filepath.Dir(jsonlFile), 0o755)
+	}
+
+	resp := Response{Model: p.cfg.Model}
+	resp.JSONLPath = jsonlFile
+
+	rec := newJSONLRecorder(jsonlFile)
+	defer rec.Close()
+
+	tools := &toolbox{root: workDir, allowedCommands: p.cfg.AllowedCommands, iso: p.iso}
+	history := []chatMessage{{Role: "user", Text: prompt}}
+
+	slog.Debug("llm http exec", "provider", p.cfg.Name, "api", p.cfg.API, "model", p.cfg.Model, "workdir", workDir)
+
+	for turn := 0; turn < p.cfg.MaxTurns; turn++ {
+		reply, err := p.send(ctx, history)
+		if err != nil {
+			resp.DurationMS = int(time.Since(start).Milliseconds())
+			resp.Events = rec.events.events
+			return resp, err
+		}
+		resp.InputTokens += reply.InputTokens
+		resp.OutputTokens += reply.OutputTokens
+		resp.CacheReadTokens += reply.CacheReadTokens
+		resp.CacheCreationTokens += reply.CacheCreationTokens
+		if reply.Model != "" {
+			resp.Model = reply.Model
+		}
+		rec.assistant(reply)
+		if reply.Text != "" {
+			resp.Text = reply.Text
+		}
+
+		history = append(history, chatMessage{Role: "assistant", Text: reply.Text, ToolCalls: reply.ToolCalls})
+		if len(reply.ToolCalls) == 0 {
+			rec.result(resp.Text)
+			resp.Events = rec.events.events
+			resp.DurationMS = int(time.Since(start).Milliseconds())
+			resp.CommitSHA = detectLatestCommit(ctx, workDir)
+			return resp, nil
+		}
+
+		results := make([]toolResult, 0, len(reply.ToolCalls))
+		for _, call := range reply.ToolCalls {
+			out, err := tools.call(ctx, call.Name, call.Input)
+			res := toolResult{CallID: call.ID, Content: out}
+			if err != nil {
+				res.Content = err.Error()
+				res.IsError = true
+			}
+			results = append(results, res)
+		}
+		rec.toolResults(results)
+		history = append(history, chatMessage{Role: "tool", ToolResults: results})
+	}
+
+	resp.DurationMS = int(time.Since(start).Milliseconds())
+	resp.Events = rec.events.events
+	return resp, fmt.Errorf("%s exceeded max turns (%d)", p.cfg.Name, p.cfg.MaxTurns)
+}
+
+// Provider-neutral conversation types.
+
+type chatMessage struct {
+	Role        string // "user", "assistant", or "tool"
+	Text        string
+	ToolCalls   []toolCall
+	ToolResults []toolResult
+}
+
+type toolCall struct {
+	ID    string
+	Name  string
+	Input json.RawMessage
+}
+
+type toolResult struct {
+	CallID  string
+	Content string
+	IsError bool
+}
+
+type chatReply struct {
+	Model     string
+	Text      string
+	ToolCalls []toolCall
+	// Token counts as in Response: InputTokens excludes cached tokens.
+	InputTokens         int
+	OutputTokens        int
+	CacheReadTokens     int
+	CacheCreationTokens int
+}
+
+func (p *HTTPProvider) send(ctx context.Context, history []chatMessage) (chatReply, error) {
+	switch p.cfg.API {
+	case APIAnthropic:
+		return p.sendAnthropic(ctx, history)
+	default:
+		return p.sendOpenAI(ctx, history)
+	}
+}
+
+// apiEndpoint joins the base URL and an API path, tolerating base URLs that
+// already include the /v1 prefix (common for OpenAI-compatible servers).
+func apiEndpoint(baseURL, path string) string {
+	base := strings.TrimRight(strings.TrimSpace(baseURL), "/")
+	if strings.HasSuffix(base, "/v1") {
+		return base + path
+	}
+	return base + "/v1" + path
+}
+
+func (p *HTTPProvider) postJSON(ctx context.Context, url string, headers map[string]string, body, out any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("encode %s request: %w", p.cfg.Name, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("build %s request: %w", p.cfg.Name, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	httpResp, err := p.client.Do(req)
+	if err != nil {
+		err = fmt.Errorf("%s request: %w", p.cfg.Name, err)
+		if ctx.Err() == nil {
+			// Connection failures are treated as an outage.
+			return &TransientError{Reason: TransientUnavailable, Err: err}
+		}
+		return err
+	}
+	defer httpResp.Body.Close()
+
+	data, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return fmt.Errorf("read %s response: %w", p.cfg.Name, err)
+	}
+	if httpResp.StatusCode >= 300 {
+		snippet := strings.TrimSpace(string(data))
+		if len(snippet) > maxHTTPErrorBody {
+			snippet = snippet[:maxHTTPErrorBody] + "..."
+		}
+		err := fmt.Errorf("%s returned HTTP %d: %s", p.cfg.Name, httpResp.StatusCode, snippet)
+		if reason := transientForStatus(httpResp.StatusCode); reason != "" {
+			return &TransientError{Reason: reason, Err: err}
+		}
+		return err
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("decode %s response: %w", p.cfg.Name, err)
+	}
+	return nil
+}
+
+// Anthropic Messages API.
+
+type anthropicRequest struct {
+	Model     string             `json:"model"`
+	MaxTokens int                `json:"max_tokens"`
+	System    string             `json:"system,omitempty"`
+	Messages  []anthropicMessage `json:"messages"`
+	Tools     []anthropicTool    `json:"tools,omitempty"`
+}
+
+type anthropicMessage struct {
+	Role    string           `json:"role"`
+	Content []anthropicBlock `json:"content"`
+}
+
+type anthropicBlock struct {
+	Type      string          `json:"type"`
+	Text      string          `json:"text,omitempty"`
+	ID        string          `json:"id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Input     json.RawMessage `json:"input,omitempty"`
+	ToolUseID string          `json:"tool_use_id,omitempty"`
+	Content   string          `json:"content,omitempty"`
+	IsError   bool            `json:"is_error,omitempty"`
+}
+
+type anthropicTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+type anthropicResponse struct {
+	Model   string           `json:"model"`
+	Content []anthropicBlock `json:"content"`
+	Usage   jsonlUsage       `json:"usage"`
+}
+
+func (p *HTTPProvider) sendAnthropic(ctx context.Context, history []chatMessage) (chatReply, error) {
+	req := anthropicRequest{
+		Model:     p.cfg.Model,
+		MaxTokens: p.cfg.MaxTokens,
+		System:    httpSystemPrompt,
+	}
+	for _, def := range toolDefinitions {
+		req.Tools = append(req.Tools, anthropicTool{Name: def.Name, Description: def.Description, InputSchema: def.Schema})
+	}
+	for _, m := range history {
+		switch m.Role {
+		case "user":
+			req.Messages = append(req.Messages, anthropicMessage{Role: "user", Content: []anthropicBlock{{Type: "text", Text: m.Text}}})
+		case "assistant":
+			var blocks []anthropicBlock
+			if m.Text != "" {
+				blocks = append(blocks, anthropicBlock{Type: "text", Text: m.Text})
+			}
+			for _, c := range m.ToolCalls {
+				blocks = append(blocks, anthropicBlock{Type: "tool_use", ID: c.ID, Name: c.Name, Input: c.Input})
+			}
+			req.Messages = append(req.Messages, anthropicMessage{Role: "assistant", Content: blocks})
+		case "tool":
+			var blocks []anthropicBlock
+			for _, r := range m.ToolResults {
+				blocks = append(blocks, anthropicBlock{Type: "tool_result", ToolUseID: r.CallID, Content: r.Content, IsError: r.IsError})
+			}
+			req.Messages = append(req.Messages, anthropicMessage{Role: "user", Content: blocks})
+		}
+	}
+
+	headers := map[string]string{"anthropic-version": anthropicAPIVersion}
+	if p.cfg.APIKey != "" {
+		headers["x-api-key"] = p.cfg.APIKey
+	}
+	var out anthropicResponse
+	if err := p.postJSON(ctx, apiEndpoint(p.cfg.BaseURL, "/messages"), headers, req, &out); err != nil {
+		return chatReply{}, err
+	}
+
+	reply := chatReply{
+		Model:               out.Model,
+		InputTokens:         out.Usage.InputTokens,
+		OutputTokens:        out.Usage.OutputTokens,
+		CacheReadTokens:     out.Usage.CacheReadInputTokens,
+		CacheCreationTokens: out.Usage.CacheCreationInputTokens,
+	}
+	var text []string
+	for _, block := range out.Content {
+		switch block.Type {
+		case "text":
+			if block.Text != "" {
+				text = append(text, block.Text)
+			}
+		case "tool_use":
+			input := block.Input
+			if len(input) == 0 {
+				input = json.RawMessage("{}")
+			}
+			reply.ToolCalls = append(reply.ToolCalls, toolCall{ID: block.ID, Name: block.Name, Input: input})
+		}
+	}
+	reply.Text = strings.Join(text, "\n")
+	return reply, nil
+}
+
+// OpenAI chat completions API.
+
+type openAIRequest struct {
+	Model     string          `json:"model"`
+	MaxTokens int             `json:"max_tokens,omitempty"`
+	Messages  []openAIMessage `json:"messages"`
+	Tools     []openAITool    `json:"tools,omitempty"`
+}
+
+type openAIMessage struct {
+	Role       string           `json:"role"`
+	Content    *string          `json:"content"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+type openAIToolCall struct {
+	ID       string             `json:"id"`
+	Type     string             `json:"type"`
+	Function openAIFunctionCall `json:"function"`
+}
+
+type openAIFunctionCall struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+type openAITool struct {
+	Type     string            `json:"type"`
+	Function openAIFunctionDef `json:"function"`
+}
+
+type openAIFunctionDef struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	Parameters  json.RawMessage `json:"parameters"`
+}
+
+type openAIResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message openAIMessage `json:"message"`
+	} `json:"choices"`
+	Usage struct {
+		PromptTokens        int `json:"prompt_tokens"`
+		CompletionTokens    int `json:"completion_tokens"`
+		PromptTokensDetails struct {
+			CachedTokens int `json:"cached_tokens"`
+		} `json:"prompt_tokens_details"`
+	} `json:"usage"`
+}
+
+func (p *HTTPProvider) sendOpenAI(ctx context.Context, history []chatMessage) (chatReply, error) {
+	req := openAIRequest{Model: p.cfg.Model, MaxTokens: p.cfg.MaxTokens}
+	for _, def := range toolDefinitions {
+		req.Tools = append(req.Tools, openAITool{
+			Type:     "function",
+			Function: openAIFunctionDef{Name: def.Name, Description: def.Description, Parameters: def.Schema},
+		})
+	}
+	req.Messages = append(req.Messages, openAIMessage{Role: "system", Content: stringPtr(httpSystemPrompt)})
+	for _, m := range history {
+		switch m.Role {
+		case "user":
+			req.Messages = append(req.Messages, openAIMessage{Role: "user", Content: stringPtr(m.Text)})
+		case "assistant":
+			msg := openAIMessage{Role: "assistant"}
+			if m.Text != "" {
+				msg.Content = stringPtr(m.Text)
+			}
+			for _, c := range m.ToolCalls {
+				msg.ToolCalls = append(msg.ToolCalls, openAIToolCall{
+					ID:       c.ID,
+					Type:     "function",
+					Function: openAIFunctionCall{Name: c.Name, Arguments: string(c.Input)},
+				})
+			}
+			req.Messages = append(req.Messages, msg)
+		case "tool":
+			for _, r := range m.ToolResults {
+				req.Messages = append(req.Messages, openAIMessage{Role: "tool", Content: stringPtr(r.Content), ToolCallID: r.CallID})
+			}
+		}
+	}
+
+	headers := map[string]string{}
+	if p.cfg.APIKey != "" {
+		headers["Authorization"] = "Bearer " + p.cfg.APIKey
+	}
+	var out openAIResponse
+	if err := p.postJSON(ctx, apiEndpoint(p.cfg.BaseURL, "/chat/completions"), headers, req, &out); err != nil {
+		return chatReply{}, err
+	}
+	if len(out.Choices) == 0 {
+		return chatReply{}, fmt.Errorf("%s returned no choices", p.cfg.Name)
+	}
+
+	msg := out.Choices[0].Message
+	// OpenAI counts cached tokens as part of prompt_tokens.
+	cached := out.Usage.PromptTokensDetails.CachedTokens
+	reply := chatReply{
+		Model:           out.Model,
+		InputTokens:     out.Usage.PromptTokens - cached,
+		OutputTokens:    out.Usage.CompletionTokens,
+		CacheReadTokens: cached,
+	}
+	if msg.Content != nil {
+		reply.Text = *msg.Content
+	}
+	for i, c := range msg.ToolCalls {
+		id := c.ID
+		if id == "" {
+			id = fmt.Sprintf("call_%d", i)
+		}
+		args := strings.TrimSpace(c.Function.Arguments)
+		if args == "" {
+			args = "{}"
+		}
+		reply.ToolCalls = append(reply.ToolCalls, toolCall{ID: id, Name: c.Function.Name, Input: json.RawMessage(args)})
+	}
+	return reply, nil
+}
+
+func stringPtr(s string) *string { return &s }
+
+// jsonlRecorder writes the conversation in Claude stream-json shape so that
+// `ap logs --follow` and other JSONL readers work unchanged.
+type jsonlRecorder struct {
+	f      *os.File
+	events eventParser
+}
+
+func newJSONLRecorder(path string) *jsonlRecorder {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		slog.Warn("failed to open jsonl file", "path", path, "err", err)
+		return &jsonlRecorder{}
+	}
+	return &jsonlRecorder{f: f}
+}
+
+func (r *jsonlRecorder) Close() {
+	if r.f != nil {
+		r.f.Close()
+	}
+}
+
+func (r *jsonlRecorder) write(v any) {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	r.events.parseLine(string(line))
+	if r.f == nil {
+		return
+	}
+	if _, err := r.f.Write(append(line, '\n')); err != nil {
+		slog.Warn("failed to write jsonl line", "err", err)
+	}
+}
+
+func (r *jsonlRecorder) assistant(reply chatReply) {
+	var blocks []anthropicBlock
+	if reply.Text != "" {
+		blocks = append(blocks, anthropicBlock{Type: "text", Text: reply.Text})
+	}
+	for _, c := range reply.ToolCalls {
+		blocks = append(blocks, anthropicBlock{Type: "tool_use", ID: c.ID, Name: c.Name, Input: c.Input})
+	}
+	r.write(map[string]any{
+		"type": "assistant",
+		"message": map[string]any{
+			"content": blocks,
+			"usage": jsonlUsage{
+				InputTokens:              reply.InputTokens,
+				OutputTokens:             reply.OutputTokens,
+				CacheReadInputTokens:     reply.CacheReadTokens,
+				CacheCreationInputTokens: reply.CacheCreationTokens,
+			},
+		},
+	})
+}
+
+func (r *jsonlRecorder) toolResults(results []toolResult) {
+	blocks := make([]anthropicBlock, 0, len(results))
+	for _, res := range results {
+		blocks = append(blocks, anthropicBlock{Type: "tool_result", ToolUseID: res.CallID, Content: res.Content, IsError: res.IsError})
+	}
+	r.write(map[string]any{
+		"type":    "user",
+		"message": map[string]any{"content": blocks},
+	})
+}
+
+func (r *jsonlRecorder) result(text string) {
+	r.write(map[string]any{"type": "result", "result": text})
+}
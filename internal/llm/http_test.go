@@ -0,0 +1,186 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// scriptedServer replies to successive requests with the given JSON bodies
+// and records every decoded request body.
+func scriptedServer(t *testing.T, path string, replies []string) (*httptest.Server, func() []map[string]any) {
+	t.Helper()
+	var mu sync.Mutex
+	var requests []map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path {
+			t.Errorf("unexpected path %q, want %q", r.URL.Path, path)
+			http.NotFound(w, r)
+			return
+		}
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		requests = append(requests, body)
+		idx := len(requests) - 1
+		mu.Unlock()
+		if idx >= len(replies) {
+			http.Error(w, "no more scripted replies", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(replies[idx]))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []map[string]any {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]map[string]any(nil), requests...)
+	}
+}
+
+func TestHTTPProviderAnthropicToolLoop(t *testing.T) {
+	t.Parallel()
+	srv, requests := scriptedServer(t, "/v1/messages", []string{
+		`{"content":[{"type":"text","text":"writing"},{"type":"tool_use","id":"tu_1","name":"write_file","input":{"path":"pkg/hello.txt","content":"hi"}}],"usage":{"input_tokens":10,"output_tokens":5}}`,
+		`{"content":[{"type":"text","text":"done"}],"usage":{"input_tokens":20,"output_tokens":3}}`,
+	})
+
+	p, err := NewHTTPProvider(HTTPConfig{API: APIAnthropic, BaseURL: srv.URL, APIKey: "k", Model: "m"})
+	if err != nil {
+		t.Fatalf("new provider: %v", err)
+	}
+	workDir := t.TempDir()
+	jsonlPath := filepath.Join(t.TempDir(), "session.jsonl")
+
+	resp, err := p.Run(context.Background(), workDir, "do it", jsonlPath)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if resp.Text != "done" {
+		t.Fatalf("expected final text 'done', got %q", resp.Text)
+	}
+	if resp.InputTokens != 30 || resp.OutputTokens != 8 {
+		t.Fatalf("unexpected tokens: %d in / %d out", resp.InputTokens, resp.OutputTokens)
+	}
+	if resp.JSONLPath != jsonlPath {
+		t.Fatalf("expected jsonl path %q, got %q", jsonlPath, resp.JSONLPath)
+	}
+	data, err := os.ReadFile(filepath.Join(workDir, "pkg", "hello.txt"))
+	if err != nil || string(data) != "hi" {
+		t.Fatalf("expected tool to write file, got %q err=%v", data, err)
+	}
+	if len(resp.Events) != 1 || resp.Events[0].Type != EventFileEdit || resp.Events[0].Path != "pkg/hello.txt" ||
+		resp.Events[0].ExitCode == nil || *resp.Events[0].ExitCode != 0 {
+		t.Fatalf("expected write_file event, got %+v", resp.Events)
+	}
+
+	reqs := requests()
+	if len(reqs) != 2 {
+		t.Fatalf("expected 2 requests, got %d", len(reqs))
+	}
+	msgs := reqs[1]["messages"].([]any)
+	last := msgs[len(msgs)-1].(map[string]any)
+	block := last["content"].([]any)[0].(map[string]any)
+	if block["type"] != "tool_result" || block["tool_use_id"] != "tu_1" {
+		t.Fatalf("expected tool_result for tu_1, got %v", block)
+	}
+
+	jsonl, err := os.ReadFile(jsonlPath)
+	if err != nil {
+		t.Fatalf("read jsonl: %v", err)
+	}
+	if !strings.Contains(string(jsonl), `"type":"result"`) || !strings.Contains(string(jsonl), `"tool_use"`) {
+		t.Fatalf("unexpected jsonl contents: %s", jsonl)
+	}
+}
+
+func TestHTTPProviderOpenAIToolLoop(t *testing.T) {
+	t.Parallel()
+	srv, requests := scriptedServer(t, "/v1/chat/completions", []string{
+		`{"choices":[{"message":{"role":"assistant","content":null,"tool_calls":[{"id":"c1","type":"function","function":{"name":"read_file","arguments":"{\"path\":\"README.md\"}"}}]}}],"usage":{"prompt_tokens":7,"completion_tokens":2}}`,
+		`{"choices":[{"message":{"role":"assistant","content":"summary"}}],"usage":{"prompt_tokens":9,"completion_tokens":4}}`,
+	})
+
+	p, err := NewHTTPProvider(HTTPConfig{API: APIOpenAI, BaseURL: srv.URL + "/v1", Model: "local"})
+	if err != nil {
+		t.Fatalf("new provider: %v", err)
+	}
+	workDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(workDir, "README.md"), []byte("readme body"), 0o644); err != nil {
+		t.Fatalf("write readme: %v", err)
+	}
+
+	resp, err := p.Run(context.Background(), workDir, "summarize", filepath.Join(t.TempDir(), "s.jsonl"))
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if resp.Text != "summary" || resp.InputTokens != 16 || resp.OutputTokens != 6 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+
+	msgs := requests()[1]["messages"].([]any)
+	last := msgs[len(msgs)-1].(map[string]any)
+	if last["role"] != "tool" || last["tool_call_id"] != "c1" || last["content"] != "readme body" {
+		t.Fatalf("expected tool message with file content, got %v", last)
+	}
+}
+
+func TestHTTPProviderReturnsHTTPErrors(t *testing.T) {
+	t.Parallel()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"error":"rate limited"}`, http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	p, err := NewHTTPProvider(HTTPConfig{API: APIOpenAI, BaseURL: srv.URL, Model: "m"})
+	if err != nil {
+		t.Fatalf("new provider: %v", err)
+	}
+	_, err = p.Run(context.Background(), t.TempDir(), "x", filepath.Join(t.TempDir(), "s.jsonl"))
+	if err == nil || !strings.Contains(err.Error(), "HTTP 429") {
+		t.Fatalf("expected HTTP 429 error, got %v", err)
+	}
+	if reason, ok := IsTransient(err); !ok || reason != TransientRateLimit {
+		t.Fatalf("expected 429 to be a transient rate limit, got %q %v", reason, ok)
+	}
+}
+
+func TestToolboxConfinesPathsAndCommands(t *testing.T) {
+	t.Parallel()
+	root := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+	tb := &toolbox{root: root, allowedCommands: []string{"echo"}}
+	ctx := context.Background()
+
+	for _, input := range []string{
+		`{"path":"../x","content":"a"}`,
+		`{"path":"/etc/passwd","content":"a"}`,
+		`{"path":".git/config","content":"a"}`,
+		`{"path":"escape/x","content":"a"}`,
+	} {
+		if _, err := tb.call(ctx, "write_file", json.RawMessage(input)); err == nil {
+			t.Fatalf("expected write_file %s to be rejected", input)
+		}
+	}
+	if entries, _ := os.ReadDir(outside); len(entries) != 0 {
+		t.Fatalf("expected nothing written outside the worktree")
+	}
+
+	if _, err := tb.call(ctx, "run_command", json.RawMessage(`{"argv":["rm","-rf","."]}`)); err == nil {
+		t.Fatalf("expected disallowed command to be rejected")
+	}
+	out, err := tb.call(ctx, "run_command", json.RawMessage(`{"argv":["echo","ok"]}`))
+	if err != nil || strings.TrimSpace(out) != "ok" {
+		t.Fatalf("expected allowed command output, got %q err=%v", out, err)
+	}
+}
@@ -0,0 +1,57 @@
+package llm
+
+import (
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	maxStderrTail = 4 * 1024
+	// processWaitDelay bounds how long Wait blocks on pipes held open by
+	// orphaned grandchildren after the process group is killed.
+	processWaitDelay = 5 * time.Second
+)
+
+// KillProcessGroupOnCancel starts cmd in its own process group and kills the
+// whole group when cmd's context is done, so subprocesses it spawns (test
+// runners, language servers) do not outlive a timeout. cmd must have been
+// created with exec.CommandContext.
+func KillProcessGroupOnCancel(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = processWaitDelay
+}
+
+// tailBuffer is an io.Writer that keeps only the last limit bytes written.
+type tailBuffer struct {
+	mu        sync.Mutex
+	limit     int
+	buf       []byte
+	truncated bool
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = append(t.buf, p...)
+	if over := len(t.buf) - t.limit; over > 0 {
+		t.buf = append(t.buf[:0], t.buf[over:]...)
+		t.truncated = true
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s := strings.TrimSpace(strings.ToValidUTF8(string(t.buf), ""))
+	if t.truncated && s != "" {
+		s = "..." + s
+	}
+	return s
+}
@@ -1,24 +1,46 @@
 package llm
 
-import "context"
+import (
+	"context"
+
+	"autopr/internal/sandbox"
+)
 
 // Provider is the interface for LLM backends.
 type Provider interface {
 	// Name returns the provider name (e.g. "claude", "codex").
 	Name() string
 
-	// Run invokes the LLM CLI tool in the given workDir with the given prompt.
+	// Run invokes the LLM (CLI tool or HTTP API) in the given workDir with the given prompt.
 	// jsonlPath is the pre-determined path for the JSONL session file.
 	// Returns the response with token counts and timing.
 	Run(ctx context.Context, workDir, prompt, jsonlPath string) (Response, error)
 }
 
+// Isolation confines the local processes a provider starts for one job.
+type Isolation struct {
+	Env     sandbox.Env      // allow-listed environment and per-job HOME
+	Sandbox *sandbox.Options // nil runs processes outside a sandbox
+}
+
+// Isolator is implemented by providers that start local processes and can
+// confine them (see [projects.sandbox] and [projects.env]).
+type Isolator interface {
+	WithIsolation(iso Isolation) Provider
+}
+
 // Response captures the output of an LLM invocation.
 type Response struct {
-	Text         string
-	InputTokens  int
-	OutputTokens int
-	DurationMS   int
-	JSONLPath    string
-	CommitSHA    string // Set if the LLM tool committed changes.
+	Text string
+	// InputTokens excludes prompt-cache tokens, which are counted separately
+	// because they are billed at different rates.
+	InputTokens         int
+	OutputTokens        int
+	CacheReadTokens     int
+	CacheCreationTokens int
+	DurationMS          int
+	JSONLPath           string
+	CommitSHA           string  // Set if the LLM tool committed changes.
+	Model               string  // Model that actually ran, when known.
+	Events              []Event // Tool calls, commands and file changes, in order.
 }
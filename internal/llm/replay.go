@@ -0,0 +1,222 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"autopr/internal/config"
+)
+
+// Recordings are stored one directory per job worktree:
+//
+//	<record_dir>/<job-id>/001.jsonl   session transcript (Claude or Codex JSONL)
+//	<record_dir>/<job-id>/001.patch   worktree changes made during the session
+//
+// Sessions are numbered in the order the pipeline ran them. A replay reads
+// one job directory and plays its sessions back in the same order.
+
+// ReplayProvider plays back a recorded job: the Nth session run in a worktree
+// replays the Nth transcript in dir and applies its patch, if any.
+type ReplayProvider struct {
+	dir string
+
+	mu    sync.Mutex
+	calls map[string]int // worktree -> sessions replayed so far
+}
+
+func NewReplayProvider(dir string) *ReplayProvider {
+	return &ReplayProvider{dir: dir, calls: make(map[string]int)}
+}
+
+func (p *ReplayProvider) Name() string { return config.ProviderReplay }
+
+func (p *ReplayProvider) Run(ctx context.Context, workDir, prompt, jsonlPath string) (Response, error) {
+	start := time.Now()
+
+	recordings, err := listRecordings(p.dir)
+	if err != nil {
+		return Response{}, fmt.Errorf("replay: %w", err)
+	}
+	p.mu.Lock()
+	n := p.calls[workDir]
+	p.calls[workDir] = n + 1
+	p.mu.Unlock()
+	if n >= len(recordings) {
+		return Response{}, fmt.Errorf("replay: no recorded session %d in %s (%d recorded)", n+1, p.dir, len(recordings))
+	}
+	src := recordings[n]
+
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return Response{}, fmt.Errorf("replay: %w", err)
+	}
+	if jsonlPath != "" {
+		_ = os.MkdirAll(filepath.Dir(jsonlPath), 0o755)
+		if err := os.WriteFile(jsonlPath, data, 0o644); err != nil {
+			slog.Warn("failed to write replayed jsonl", "path", jsonlPath, "err", err)
+		}
+	}
+
+	out := outputParser{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 256*1024), 1024*1024)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			out.parseLine(CLISpec{Output: config.OutputClaude}, line)
+		}
+	}
+
+	resp := Response{
+		Text:                out.lastText,
+		InputTokens:         out.totalIn,
+		OutputTokens:        out.totalOut,
+		CacheReadTokens:     out.totalCacheRead,
+		CacheCreationTokens: out.totalCacheWrite,
+		JSONLPath:           jsonlPath,
+		Model:               out.model,
+		Events:              out.events.events,
+	}
+
+	patch := strings.TrimSuffix(src, ".jsonl") + ".patch"
+	if info, err := os.Stat(patch); err == nil && info.Size() > 0 {
+		if _, err := runGit(ctx, workDir, nil, "apply", "--binary", "--whitespace=nowarn", patch); err != nil {
+			return resp, fmt.Errorf("replay: apply %s: %w", filepath.Base(patch), err)
+		}
+	}
+	resp.DurationMS = int(time.Since(start).Milliseconds())
+
+	if out.errorText != "" {
+		return resp, fmt.Errorf("replay: recorded session failed: %s", out.errorText)
+	}
+	resp.CommitSHA = detectLatestCommit(ctx, workDir)
+	return resp, nil
+}
+
+// listRecordings returns the session transcripts in dir in replay order.
+func listRecordings(dir string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// RecordingProvider wraps a provider and saves every session's transcript and
+// worktree changes in the layout ReplayProvider reads.
+type RecordingProvider struct {
+	Provider
+	dir string
+}
+
+func NewRecordingProvider(p Provider, dir string) *RecordingProvider {
+	return &RecordingProvider{Provider: p, dir: dir}
+}
+
+// WithIsolation isolates the wrapped provider, if it supports isolation.
+func (p *RecordingProvider) WithIsolation(iso Isolation) Provider {
+	if i, ok := p.Provider.(Isolator); ok {
+		return &RecordingProvider{Provider: i.WithIsolation(iso), dir: p.dir}
+	}
+	return p
+}
+
+func (p *RecordingProvider) Run(ctx context.Context, workDir, prompt, jsonlPath string) (Response, error) {
+	before, snapErr := snapshotTree(ctx, workDir)
+	resp, err := p.Provider.Run(ctx, workDir, prompt, jsonlPath)
+	if snapErr != nil {
+		slog.Warn("record session: snapshot worktree", "workdir", workDir, "err", snapErr)
+		return resp, err
+	}
+	transcript := resp.JSONLPath
+	if transcript == "" {
+		transcript = jsonlPath
+	}
+	if recErr := p.record(ctx, workDir, transcript, before); recErr != nil {
+		slog.Warn("record session", "workdir", workDir, "err", recErr)
+	}
+	return resp, err
+}
+
+func (p *RecordingProvider) record(ctx context.Context, workDir, jsonlPath, before string) error {
+	ctx = context.WithoutCancel(ctx)
+	dir := filepath.Join(p.dir, filepath.Base(workDir))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	existing, err := listRecordings(dir)
+	if err != nil {
+		return err
+	}
+	base := filepath.Join(dir, fmt.Sprintf("%03d", len(existing)+1))
+
+	transcript, err := os.ReadFile(jsonlPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.WriteFile(base+".jsonl", transcript, 0o644); err != nil {
+		return err
+	}
+
+	after, err := snapshotTree(ctx, workDir)
+	if err != nil {
+		return err
+	}
+	if after == before {
+		return nil
+	}
+	patch, err := runGit(ctx, workDir, nil, "diff", "--binary", before, after)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(base+".patch", []byte(patch), 0o644)
+}
+
+// snapshotTree writes the current worktree contents (tracked, modified and
+// untracked files, honouring .gitignore) as a git tree object and returns its
+// hash. A throwaway index is used so the real index is left untouched.
+func snapshotTree(ctx context.Context, workDir string) (string, error) {
+	index, err := os.CreateTemp("", "autopr-record-index-*")
+	if err != nil {
+		return "", err
+	}
+	index.Close()
+	os.Remove(index.Name()) // git creates it; an empty file is not a valid index
+	defer os.Remove(index.Name())
+
+	env := []string{"GIT_INDEX_FILE=" + index.Name()}
+	if _, err := runGit(ctx, workDir, env, "add", "-A"); err != nil {
+		return "", err
+	}
+	tree, err := runGit(ctx, workDir, env, "write-tree")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(tree), nil
+}
+
+// runGit runs git in dir with extra environment variables and returns stdout.
+func runGit(ctx context.Context, dir string, env []string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
@@ -0,0 +1,112 @@
+package llm
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type scriptedProvider struct {
+	run func(workDir, jsonlPath string) (Response, error)
+}
+
+func (p scriptedProvider) Name() string { return "scripted" }
+
+func (p scriptedProvider) Run(ctx context.Context, workDir, prompt, jsonlPath string) (Response, error) {
+	return p.run(workDir, jsonlPath)
+}
+
+func TestRecordThenReplaySession(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	recordDir := t.TempDir()
+	transcript := `{"type":"assistant","message":{"content":[{"type":"tool_use","id":"t1","name":"Write","input":{"file_path":"notes.txt"}}],"usage":{"input_tokens":12,"output_tokens":4}}}` + "\n" +
+		`{"type":"result","result":"wrote notes"}` + "\n"
+
+	recorded := initReplayRepo(t, filepath.Join(t.TempDir(), "job-1"))
+	rec := NewRecordingProvider(scriptedProvider{run: func(workDir, jsonlPath string) (Response, error) {
+		if err := os.WriteFile(filepath.Join(workDir, "notes.txt"), []byte("hi\n"), 0o644); err != nil {
+			return Response{}, err
+		}
+		if err := os.WriteFile(filepath.Join(workDir, "README.md"), []byte("changed\n"), 0o644); err != nil {
+			return Response{}, err
+		}
+		return Response{Text: "wrote notes", JSONLPath: jsonlPath}, os.WriteFile(jsonlPath, []byte(transcript), 0o644)
+	}}, recordDir)
+	if _, err := rec.Run(ctx, recorded, "prompt", filepath.Join(t.TempDir(), "s1.jsonl")); err != nil {
+		t.Fatalf("record run: %v", err)
+	}
+	if rec.Name() != "scripted" {
+		t.Fatalf("recording provider should keep the wrapped name, got %q", rec.Name())
+	}
+	jobDir := filepath.Join(recordDir, "job-1")
+	for _, name := range []string{"001.jsonl", "001.patch"} {
+		if _, err := os.Stat(filepath.Join(jobDir, name)); err != nil {
+			t.Fatalf("expected %s to be recorded: %v", name, err)
+		}
+	}
+
+	replayed := initReplayRepo(t, filepath.Join(t.TempDir(), "job-2"))
+	replay := NewReplayProvider(jobDir)
+	jsonlPath := filepath.Join(t.TempDir(), "replayed.jsonl")
+	resp, err := replay.Run(ctx, replayed, "prompt", jsonlPath)
+	if err != nil {
+		t.Fatalf("replay run: %v", err)
+	}
+	if resp.Text != "wrote notes" || resp.InputTokens != 12 || resp.OutputTokens != 4 {
+		t.Fatalf("unexpected replayed response: %+v", resp)
+	}
+	if len(resp.Events) != 1 || resp.Events[0].Path != "notes.txt" {
+		t.Fatalf("expected replayed events, got %+v", resp.Events)
+	}
+	for name, want := range map[string]string{"notes.txt": "hi\n", "README.md": "changed\n"} {
+		data, err := os.ReadFile(filepath.Join(replayed, name))
+		if err != nil || string(data) != want {
+			t.Fatalf("%s = %q (err=%v), want %q", name, data, err, want)
+		}
+	}
+	if data, err := os.ReadFile(jsonlPath); err != nil || string(data) != transcript {
+		t.Fatalf("expected transcript copied to session jsonl, got %q err=%v", data, err)
+	}
+
+	if _, err := replay.Run(ctx, replayed, "prompt", ""); err == nil || !strings.Contains(err.Error(), "no recorded session 2") {
+		t.Fatalf("expected exhausted recording error, got %v", err)
+	}
+}
+
+func TestReplayReportsRecordedFailure(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "001.jsonl"), []byte(`{"type":"result","is_error":true,"result":"rate limited"}`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	_, err := NewReplayProvider(dir).Run(context.Background(), t.TempDir(), "prompt", "")
+	if err == nil || !strings.Contains(err.Error(), "rate limited") {
+		t.Fatalf("expected recorded failure, got %v", err)
+	}
+}
+
+func initReplayRepo(t *testing.T, dir string) string {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("hello\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"-c", "user.email=test@example.com", "-c", "user.name=Test", "add", "README.md"},
+		{"-c", "user.email=test@example.com", "-c", "user.name=Test", "commit", "-q", "-m", "init"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	return dir
+}
@@ -0,0 +1,237 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"autopr/internal/safepath"
+	"autopr/internal/sandbox"
+)
+
+const (
+	maxToolReadBytes   = 256 * 1024
+	maxToolOutputBytes = 64 * 1024
+	toolCommandTimeout = 10 * time.Minute
+)
+
+type toolDefinition struct {
+	Name        string
+	Description string
+	Schema      json.RawMessage
+}
+
+// toolDefinitions are the tools exposed to HTTP providers. Every tool is
+// confined to the job worktree.
+var toolDefinitions = []toolDefinition{
+	{
+		Name:        "read_file",
+		Description: "Read a UTF-8 text file relative to the repository root.",
+		Schema:      json.RawMessage(`{"type":"object","properties":{"path":{"type":"string"}},"required":["path"]}`),
+	},
+	{
+		Name:        "write_file",
+		Description: "Create or overwrite a file relative to the repository root with the given content.",
+		Schema:      json.RawMessage(`{"type":"object","properties":{"path":{"type":"string"},"content":{"type":"string"}},"required":["path","content"]}`),
+	},
+	{
+		Name:        "list_dir",
+		Description: "List entries of a directory relative to the repository root. Directories end with '/'.",
+		Schema:      json.RawMessage(`{"type":"object","properties":{"path":{"type":"string"}},"required":["path"]}`),
+	},
+	{
+		Name:        "run_command",
+		Description: "Run an allow-listed command (no shell) in the repository root and return its combined output.",
+		Schema:      json.RawMessage(`{"type":"object","properties":{"argv":{"type":"array","items":{"type":"string"}}},"required":["argv"]}`),
+	},
+}
+
+// toolbox executes tool calls against a single worktree.
+type toolbox struct {
+	root            string
+	allowedCommands []string
+	iso             *Isolation
+}
+
+func (t *toolbox) call(ctx context.Context, name string, input json.RawMessage) (string, error) {
+	var args struct {
+		Path    string   `json:"path"`
+		Content string   `json:"content"`
+		Argv    []string `json:"argv"`
+	}
+	if len(input) > 0 {
+		if err := json.Unmarshal(input, &args); err != nil {
+			return "", fmt.Errorf("invalid %s arguments: %w", name, err)
+		}
+	}
+
+	switch name {
+	case "read_file":
+		return t.readFile(args.Path)
+	case "write_file":
+		return t.writeFile(args.Path, args.Content)
+	case "list_dir":
+		return t.listDir(args.Path)
+	case "run_command":
+		return t.runCommand(ctx, args.Argv)
+	default:
+		return "", fmt.Errorf("unknown tool %q", name)
+	}
+}
+
+// cleanRel validates a worktree-relative path lexically, rejecting absolute
+// paths, traversal and the .git directory.
+func cleanRel(rel string) (string, error) {
+	rel = strings.TrimSpace(rel)
+	if filepath.IsAbs(rel) {
+		return "", fmt.Errorf("path must be relative to the repository root: %s", rel)
+	}
+	cleaned := filepath.Clean(rel)
+	if cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path is outside the repository: %s", rel)
+	}
+	if strings.Split(cleaned, string(filepath.Separator))[0] == ".git" {
+		return "", fmt.Errorf("access to .git is not allowed: %s", rel)
+	}
+	return cleaned, nil
+}
+
+// resolve maps a worktree-relative path to an absolute path that is
+// guaranteed to stay inside the worktree without following symlinks.
+func (t *toolbox) resolve(rel string) (string, error) {
+	cleaned, err := cleanRel(rel)
+	if err != nil {
+		return "", err
+	}
+	if cleaned == "." {
+		return filepath.Clean(t.root), nil
+	}
+	return safepath.ResolveNoSymlinkPath(t.root, filepath.Join(t.root, cleaned))
+}
+
+func (t *toolbox) readFile(rel string) (string, error) {
+	path, err := t.resolve(rel)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("not a regular file: %s", rel)
+	}
+	if info.Size() > maxToolReadBytes {
+		return "", fmt.Errorf("file too large (%d bytes, limit %d): %s", info.Size(), maxToolReadBytes, rel)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func (t *toolbox) writeFile(rel, content string) (string, error) {
+	cleaned, err := cleanRel(rel)
+	if err != nil {
+		return "", err
+	}
+	if cleaned == "." {
+		return "", fmt.Errorf("path is required")
+	}
+	// Refuse symlinked ancestors before MkdirAll can follow them out of the worktree.
+	dir := t.root
+	for _, part := range strings.Split(filepath.Dir(cleaned), string(filepath.Separator)) {
+		if part == "." {
+			break
+		}
+		dir = filepath.Join(dir, part)
+		info, err := os.Lstat(dir)
+		if os.IsNotExist(err) {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return "", fmt.Errorf("path contains symlink component: %s", rel)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(t.root, filepath.Dir(cleaned)), 0o755); err != nil {
+		return "", err
+	}
+	path, err := t.resolve(cleaned)
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("wrote %d bytes to %s", len(content), cleaned), nil
+}
+
+func (t *toolbox) listDir(rel string) (string, error) {
+	path, err := t.resolve(rel)
+	if err != nil {
+		return "", err
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return "", err
+	}
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if e.Name() == ".git" {
+			continue
+		}
+		name := e.Name()
+		if e.IsDir() {
+			name += "/"
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, "\n"), nil
+}
+
+func (t *toolbox) runCommand(ctx context.Context, argv []string) (string, error) {
+	if len(argv) == 0 || strings.TrimSpace(argv[0]) == "" {
+		return "", fmt.Errorf("argv is required")
+	}
+	if !slices.Contains(t.allowedCommands, argv[0]) {
+		return "", fmt.Errorf("command %q is not allowed (allowed: %s)", argv[0], strings.Join(t.allowedCommands, ", "))
+	}
+
+	cmdCtx, cancel := context.WithTimeout(ctx, toolCommandTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(cmdCtx, argv[0], argv[1:]...)
+	cmd.Dir = t.root
+	if t.iso != nil {
+		env, err := t.iso.Env.Environ()
+		if err != nil {
+			return "", err
+		}
+		cmd.Env = env
+		if t.iso.Sandbox != nil {
+			if err := sandbox.Wrap(cmd, *t.iso.Sandbox); err != nil {
+				return "", err
+			}
+		}
+	}
+	out, err := cmd.CombinedOutput()
+	output := string(out)
+	if len(output) > maxToolOutputBytes {
+		output = output[:maxToolOutputBytes] + "\n... (truncated)"
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %w\n%s", strings.Join(argv, " "), err, output)
+	}
+	return output, nil
+}
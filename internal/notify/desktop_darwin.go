@@ -25,6 +25,9 @@ func (s *desktopSender) Send(ctx context.Context, payload Payload) error {
 	if payload.PRURL != "" {
 		message = escapeAppleScriptString(fmt.Sprintf("%s - %s (%s)", payload.Project, payload.IssueTitle, payload.PRURL))
 	}
+	if payload.Message != "" {
+		message = escapeAppleScriptString(fmt.Sprintf("%s - %s", payload.Project, payload.Message))
+	}
 	script := fmt.Sprintf(`display notification "%s" with title "%s"`, message, title)
 	if err := exec.CommandContext(ctx, "osascript", "-e", script).Run(); err != nil {
 		return fmt.Errorf("desktop notification failed: %w", err)
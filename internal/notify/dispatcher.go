@@ -161,6 +161,7 @@ func (d *Dispatcher) buildPayload(ctx context.Context, event db.NotificationEven
 		State:      EventState(event.EventType),
 		IssueTitle: issueTitle,
 		PRURL:      strings.TrimSpace(job.PRURL),
+		Message:    event.Message,
 		Project:    job.ProjectName,
 		Timestamp:  time.Now().UTC().Format(time.RFC3339),
 	}, nil
@@ -8,10 +8,12 @@ import (
 )
 
 const (
-	TriggerNeedsPR  = "needs_pr"
-	TriggerFailed   = "failed"
-	TriggerPRCreated = "pr_created"
-	TriggerPRMerged  = "pr_merged"
+	TriggerNeedsPR      = "needs_pr"
+	TriggerFailed       = "failed"
+	TriggerPRCreated    = "pr_created"
+	TriggerPRMerged     = "pr_merged"
+	TriggerBudget       = "budget_threshold"
+	TriggerPlanApproval = "plan_approval"
 )
 
 var AllTriggers = []string{
@@ -19,6 +21,8 @@ var AllTriggers = []string{
 	TriggerFailed,
 	TriggerPRCreated,
 	TriggerPRMerged,
+	TriggerBudget,
+	TriggerPlanApproval,
 }
 
 type Payload struct {
@@ -27,6 +31,7 @@ type Payload struct {
 	State      string `json:"state"`
 	IssueTitle string `json:"issue_title"`
 	PRURL      string `json:"pr_url,omitempty"`
+	Message    string `json:"message,omitempty"`
 	Project    string `json:"project"`
 	Timestamp  string `json:"timestamp"`
 }
@@ -44,7 +49,7 @@ type ChannelResult struct {
 
 func IsValidTrigger(trigger string) bool {
 	switch trigger {
-	case TriggerNeedsPR, TriggerFailed, TriggerPRCreated, TriggerPRMerged:
+	case TriggerNeedsPR, TriggerFailed, TriggerPRCreated, TriggerPRMerged, TriggerBudget, TriggerPlanApproval:
 		return true
 	default:
 		return false
@@ -79,6 +84,10 @@ func EventState(event string) string {
 		return "pr created"
 	case TriggerPRMerged:
 		return "pr merged"
+	case TriggerBudget:
+		return "budget threshold"
+	case TriggerPlanApproval:
+		return "awaiting plan approval"
 	default:
 		return "failed"
 	}
@@ -92,6 +101,10 @@ func EventLabel(event string) string {
 		return "PR Created"
 	case TriggerPRMerged:
 		return "PR Merged"
+	case TriggerBudget:
+		return "Budget Threshold"
+	case TriggerPlanApproval:
+		return "Plan Approval"
 	default:
 		return "Job Failed"
 	}
@@ -115,5 +128,8 @@ func SlackText(payload Payload) string {
 	if payload.PRURL != "" {
 		text += "\nPR: " + payload.PRURL
 	}
+	if payload.Message != "" {
+		text += "\n" + payload.Message
+	}
 	return text
 }
@@ -0,0 +1,213 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+
+	"autopr/internal/config"
+	"autopr/internal/db"
+	"autopr/internal/git"
+)
+
+// clarificationArtifactKind artifacts record the questions the plan step
+// asked on the issue (status asked) and the reply the syncer picked up
+// (status answered).
+const clarificationArtifactKind = "clarification"
+
+// Clarification statuses, stored as the status of a clarification artifact.
+const (
+	clarificationAsked    = "asked"
+	clarificationAnswered = "answered"
+)
+
+// errNeedsInfo signals that the plan step asked clarifying questions and the
+// job is parked in needs_info until someone replies on the issue.
+var errNeedsInfo = errors.New("waiting for answers to clarifying questions")
+
+// needsInfoRe matches the line the planner puts above its questions.
+var needsInfoRe = regexp.MustCompile(`(?m)^[ \t*#>]*NEEDS_INFO[ \t*:]*$`)
+
+const clarifyInstructions = `If the issue is too ambiguous to plan without guessing, do not write a plan. Instead respond with a line containing only NEEDS_INFO, followed by the questions for the issue author. Ask only what you cannot find out from the issue or the repository.`
+
+// ClarificationData is the JSON payload of clarification artifacts. For asked
+// questions it holds the posted comment, for answers the reply.
+type ClarificationData struct {
+	CommentID int64     `json:"comment_id"`
+	Author    string    `json:"author,omitempty"`
+	PostedAt  time.Time `json:"posted_at"`
+}
+
+// canClarify reports whether the plan step may ask questions on the issue:
+// the project opted in and the issue comes from a tracker AutoPR can comment on.
+func canClarify(issue db.Issue, projectCfg *config.ProjectConfig) bool {
+	if !projectCfg.ClarifyEnabled() {
+		return false
+	}
+	switch issue.Source {
+	case "github":
+		return projectCfg.GitHub != nil
+	case "gitlab":
+		return projectCfg.GitLab != nil
+	}
+	return false
+}
+
+// parseClarifyingQuestions returns the questions of a plan response that
+// starts or ends with a NEEDS_INFO line, or "" for a regular plan.
+func parseClarifyingQuestions(text string) string {
+	loc := needsInfoRe.FindStringIndex(text)
+	if loc == nil {
+		return ""
+	}
+	if questions := strings.TrimSpace(text[loc[1]:]); questions != "" {
+		return questions
+	}
+	return strings.TrimSpace(text[:loc[0]])
+}
+
+// clarifications builds the {{clarifications}} block of the plan prompt: the
+// questions asked on the issue so far with their answers and, when the
+// project allows it, how to ask new ones.
+func (r *Runner) clarifications(ctx context.Context, jobID string, issue db.Issue, projectCfg *config.ProjectConfig) string {
+	var history strings.Builder
+	if artifacts, err := r.store.ListArtifactsByJob(ctx, jobID); err == nil {
+		for _, a := range artifacts {
+			if a.Kind != clarificationArtifactKind {
+				continue
+			}
+			switch a.Status {
+			case clarificationAsked:
+				fmt.Fprintf(&history, "You asked on the issue:\n%s\n\n", a.Content)
+			case clarificationAnswered:
+				var data ClarificationData
+				_ = json.Unmarshal([]byte(a.Data), &data)
+				fmt.Fprintf(&history, "@%s answered:\n%s\n\n", data.Author, SanitizeIssueContent(a.Content))
+			}
+		}
+	}
+
+	var parts []string
+	if history.Len() > 0 {
+		parts = append(parts, fmt.Sprintf("<clarifications>\n%s\n</clarifications>", strings.TrimSpace(history.String())))
+	}
+	if canClarify(issue, projectCfg) {
+		parts = append(parts, clarifyInstructions)
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// askClarifyingQuestions posts the planner's questions on the issue, records
+// them as a clarification artifact and parks the job in needs_info. The
+// syncer requeues it when the issue author or a maintainer replies.
+func (r *Runner) askClarifyingQuestions(ctx context.Context, job db.Job, issue db.Issue, projectCfg *config.ProjectConfig, questions string) error {
+	body := fmt.Sprintf("AutoPR needs some answers before it can plan this issue:\n\n%s\n\nReply in a comment and planning resumes with your answer.", questions)
+	comment, err := r.postIssueCommentFn(ctx, r.cfg, projectCfg, issue, body)
+	if err != nil {
+		return fmt.Errorf("post clarifying questions: %w", err)
+	}
+	if comment.CreatedAt.IsZero() {
+		comment.CreatedAt = time.Now().UTC()
+	}
+
+	data, _ := json.Marshal(ClarificationData{CommentID: comment.ID, PostedAt: comment.CreatedAt})
+	if _, err := r.store.InsertArtifact(ctx, db.Artifact{
+		JobID:         job.ID,
+		AutoPRIssueID: issue.AutoPRIssueID,
+		Kind:          clarificationArtifactKind,
+		Content:       questions,
+		Iteration:     job.Iteration,
+		Status:        clarificationAsked,
+		Data:          string(data),
+	}); err != nil {
+		return fmt.Errorf("store clarifying questions: %w", err)
+	}
+
+	if err := r.store.TransitionState(ctx, job.ID, "planning", "needs_info"); err != nil {
+		if r.jobCancelled(job.ID) {
+			return errJobCancelled
+		}
+		return err
+	}
+	slog.Info("asked clarifying questions, waiting for a reply", "job", job.ID)
+	return errNeedsInfo
+}
+
+// planAwaitsAnswers reports whether the latest plan session asked questions
+// instead of planning, so the plan step must run again with the answers.
+func (r *Runner) planAwaitsAnswers(ctx context.Context, jobID string) bool {
+	asked, err := r.store.GetLatestArtifact(ctx, jobID, clarificationArtifactKind)
+	if err != nil {
+		return false
+	}
+	plan, err := r.store.GetLatestArtifact(ctx, jobID, "plan")
+	return err != nil || plan.ID < asked.ID
+}
+
+// PendingClarification returns the comment with the questions a needs_info
+// job waits on. The syncer looks for replies posted after it.
+func PendingClarification(ctx context.Context, store *db.Store, jobID string) (ClarificationData, error) {
+	asked, err := store.GetLatestArtifact(ctx, jobID, clarificationArtifactKind)
+	if err != nil {
+		return ClarificationData{}, fmt.Errorf("get clarifying questions: %w", err)
+	}
+	if asked.Status != clarificationAsked {
+		return ClarificationData{}, fmt.Errorf("job %s has no unanswered clarifying questions", jobID)
+	}
+	var data ClarificationData
+	if err := json.Unmarshal([]byte(asked.Data), &data); err != nil {
+		return ClarificationData{}, fmt.Errorf("decode clarifying questions: %w", err)
+	}
+	return data, nil
+}
+
+// AnswerClarification records a reply to a needs_info job's questions and
+// requeues the job. The worker re-runs the plan step with the answer in the
+// prompt.
+func AnswerClarification(ctx context.Context, store *db.Store, jobID string, reply git.IssueComment) error {
+	job, err := store.GetJob(ctx, jobID)
+	if err != nil {
+		return err
+	}
+	if job.State != "needs_info" {
+		return fmt.Errorf("job %s is in state %q, must be 'needs_info'", jobID, job.State)
+	}
+	data, _ := json.Marshal(ClarificationData{CommentID: reply.ID, Author: reply.Author, PostedAt: reply.CreatedAt})
+	if _, err := store.InsertArtifact(ctx, db.Artifact{
+		JobID:         jobID,
+		AutoPRIssueID: job.AutoPRIssueID,
+		Kind:          clarificationArtifactKind,
+		Content:       reply.Body,
+		Iteration:     job.Iteration,
+		Status:        clarificationAnswered,
+		Data:          string(data),
+	}); err != nil {
+		return fmt.Errorf("store clarification answer: %w", err)
+	}
+	return store.TransitionState(ctx, jobID, "needs_info", "queued")
+}
+
+// PostIssueComment comments on the job's source issue.
+func PostIssueComment(ctx context.Context, cfg *config.Config, proj *config.ProjectConfig, issue db.Issue, body string) (git.IssueComment, error) {
+	switch {
+	case issue.Source == "github" && proj.GitHub != nil:
+		if cfg.Tokens.GitHub == "" {
+			return git.IssueComment{}, fmt.Errorf("GITHUB_TOKEN required to comment on issues")
+		}
+		return git.PostGitHubIssueComment(ctx, cfg.Tokens.GitHub, proj.GitHub.Owner, proj.GitHub.Repo, issue.SourceIssueID, body)
+
+	case issue.Source == "gitlab" && proj.GitLab != nil:
+		if cfg.Tokens.GitLab == "" {
+			return git.IssueComment{}, fmt.Errorf("GITLAB_TOKEN required to comment on issues")
+		}
+		return git.PostGitLabIssueNote(ctx, cfg.Tokens.GitLab, proj.GitLab.BaseURL, proj.GitLab.ProjectID, issue.SourceIssueID, body)
+
+	default:
+		return git.IssueComment{}, fmt.Errorf("cannot comment on %s issues of project %q", issue.Source, proj.Name)
+	}
+}
@@ -0,0 +1,116 @@
+package pipeline
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"autopr/internal/config"
+	"autopr/internal/db"
+	"autopr/internal/git"
+	"autopr/internal/llm"
+)
+
+// clarifyProvider asks questions on the first plan and plans afterwards.
+type clarifyProvider struct {
+	mu          sync.Mutex
+	planPrompts []string
+}
+
+func (p *clarifyProvider) Name() string { return "codex" }
+
+func (p *clarifyProvider) Run(ctx context.Context, workDir, prompt, jsonlPath string) (llm.Response, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	text := "done"
+	if strings.Contains(prompt, "create a detailed implementation plan") {
+		p.planPrompts = append(p.planPrompts, prompt)
+		text = "the plan"
+		if len(p.planPrompts) == 1 {
+			text = "**NEEDS_INFO**\n1. Should the export include archived rows?"
+		}
+	}
+	return llm.Response{Text: text, InputTokens: 1, OutputTokens: 1, DurationMS: 1}, nil
+}
+
+func TestParseClarifyingQuestions(t *testing.T) {
+	t.Parallel()
+	cases := map[string]string{
+		"NEEDS_INFO\n- Which format?":           "- Which format?",
+		"- Which format?\n\n**NEEDS_INFO**":     "- Which format?",
+		"## Plan\n1. Mention NEEDS_INFO inline": "",
+		"A regular plan.":                       "",
+	}
+	for text, want := range cases {
+		if got := parseClarifyingQuestions(text); got != want {
+			t.Errorf("parseClarifyingQuestions(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestRunStepsAsksClarifyingQuestionsThenResumes(t *testing.T) {
+	t.Parallel()
+	provider := &clarifyProvider{}
+	runner, store, issue, jobID := setupRunStepsJob(t, provider, "planning")
+	ctx := context.Background()
+	projectCfg := testProjectConfigWithoutRebase()
+	projectCfg.GitLab = &config.ProjectGitLab{BaseURL: "https://gitlab.example.com", ProjectID: "1"}
+	projectCfg.Clarify = &config.ProjectClarify{Enabled: true, Timeout: "72h"}
+
+	var posted []string
+	runner.postIssueCommentFn = func(ctx context.Context, cfg *config.Config, proj *config.ProjectConfig, issue db.Issue, body string) (git.IssueComment, error) {
+		posted = append(posted, body)
+		return git.IssueComment{ID: 41, CreatedAt: time.Now().UTC()}, nil
+	}
+
+	if err := runner.runSteps(ctx, jobID, "planning", issue, projectCfg, t.TempDir()); err != nil {
+		t.Fatalf("runSteps: %v", err)
+	}
+	job, err := store.GetJob(ctx, jobID)
+	if err != nil {
+		t.Fatalf("get job: %v", err)
+	}
+	if job.State != "needs_info" {
+		t.Fatalf("expected job to wait in needs_info, got %q", job.State)
+	}
+	if len(posted) != 1 || !strings.Contains(posted[0], "1. Should the export include archived rows?") {
+		t.Fatalf("expected the questions to be posted on the issue, got %q", posted)
+	}
+	if !strings.Contains(provider.planPrompts[0], "NEEDS_INFO") {
+		t.Fatalf("expected the plan prompt to offer clarifying questions, got:\n%s", provider.planPrompts[0])
+	}
+	pending, err := PendingClarification(ctx, store, jobID)
+	if err != nil || pending.CommentID != 41 {
+		t.Fatalf("expected pending questions for comment 41, got %+v (err %v)", pending, err)
+	}
+
+	reply := git.IssueComment{ID: 42, Author: "alice", Body: "No, only active rows.", CreatedAt: time.Now().UTC()}
+	if err := AnswerClarification(ctx, store, jobID, reply); err != nil {
+		t.Fatalf("answer clarification: %v", err)
+	}
+	if _, err := PendingClarification(ctx, store, jobID); err == nil {
+		t.Fatalf("expected no pending questions after the answer")
+	}
+	claimAgain(t, store, jobID)
+	// The stub provider's code review has no verdict, so the job fails there.
+	if err := runner.runSteps(ctx, jobID, "planning", issue, projectCfg, t.TempDir()); err == nil {
+		t.Fatalf("expected a code review failure")
+	}
+
+	if got := sessionCountForStep(t, store, ctx, jobID, "plan"); got != 2 {
+		t.Fatalf("expected the plan to run again after the answer, got %d plan sessions", got)
+	}
+	if got := sessionCountForStep(t, store, ctx, jobID, "implement"); got != 1 {
+		t.Fatalf("expected implement after the answer, got %d sessions", got)
+	}
+	prompt := provider.planPrompts[1]
+	if !strings.Contains(prompt, "@alice answered:\nNo, only active rows.") || !strings.Contains(prompt, "archived rows?") {
+		t.Fatalf("expected the re-plan prompt to carry the questions and answer, got:\n%s", prompt)
+	}
+	plan, err := store.GetLatestArtifact(ctx, jobID, "plan")
+	if err != nil || plan.Content != "the plan" {
+		t.Fatalf("expected only the real plan to be stored, got %q (err %v)", plan.Content, err)
+	}
+}
@@ -0,0 +1,106 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"autopr/internal/config"
+	"autopr/internal/db"
+)
+
+// errCommandFailed signals that a command step with on_failure = "loop"
+// failed and the job should retry from implementing.
+var errCommandFailed = errors.New("command step failed")
+
+// Command step artifact statuses.
+const (
+	commandPassed = "passed"
+	commandFailed = "failed"
+	commandWarned = "warned"
+)
+
+// commandStepRunner returns the run function of a pipeline command step. The
+// command runs like the test commands (same parsing, isolation, sandbox and
+// timeout) and its output is stored as the step's artifact kind. A cmd that
+// does not parse fails the job whatever on_failure says: another iteration
+// cannot fix the config.
+func (r *Runner) commandStepRunner(step config.PipelineStep) func(ctx context.Context, jobID string, issue db.Issue, projectCfg *config.ProjectConfig, workDir string) error {
+	return func(ctx context.Context, jobID string, issue db.Issue, projectCfg *config.ProjectConfig, workDir string) error {
+		job, err := r.store.GetJob(ctx, jobID)
+		if err != nil {
+			return err
+		}
+
+		args, parseErr := config.ParseTestCommand(step.Cmd)
+		if parseErr == nil {
+			parseErr = config.ValidateTestCommandArgs(args)
+		}
+		if parseErr != nil {
+			r.storeCommandOutput(ctx, jobID, issue, job.Iteration, step, parseErr.Error(), commandFailed)
+			return fmt.Errorf("command step %s: %w", step.Name, parseErr)
+		}
+
+		iso := isolation(ctx, projectCfg, workDir)
+		output, cmdErr := runTestArgv(ctx, workDir, config.TestCommand{Argv: args, Timeout: step.Timeout}, &iso)
+		if cmdErr != nil && (errors.Is(cmdErr, context.Canceled) || ctx.Err() != nil) {
+			return context.Canceled
+		}
+
+		status := commandPassed
+		if cmdErr != nil {
+			status = commandFailed
+			if step.OnFailure == config.OnFailureWarn {
+				status = commandWarned
+			}
+		}
+		r.storeCommandOutput(ctx, jobID, issue, job.Iteration, step, output, status)
+
+		if cmdErr == nil {
+			slog.Info("command step passed", "job", jobID, "step", step.Name)
+			return nil
+		}
+		switch step.OnFailure {
+		case config.OnFailureWarn:
+			slog.Warn("command step failed, continuing", "job", jobID, "step", step.Name, "err", cmdErr)
+			return nil
+		case config.OnFailureFail:
+			return fmt.Errorf("command step %s: %w", step.Name, cmdErr)
+		default:
+			slog.Info("command step failed", "job", jobID, "step", step.Name, "err", cmdErr)
+			return fmt.Errorf("%w: %s", errCommandFailed, step.Name)
+		}
+	}
+}
+
+// storeCommandOutput stores a command step's output as its artifact.
+func (r *Runner) storeCommandOutput(ctx context.Context, jobID string, issue db.Issue, iteration int, step config.PipelineStep, output, status string) {
+	if _, err := r.store.InsertArtifact(ctx, db.Artifact{
+		JobID:         jobID,
+		AutoPRIssueID: issue.AutoPRIssueID,
+		Kind:          step.ArtifactKind(),
+		Content:       fmt.Sprintf("$ %s\n\n%s", step.Cmd, output),
+		Iteration:     iteration,
+		Status:        status,
+	}); err != nil {
+		slog.Warn("failed to store command step artifact", "job", jobID, "step", step.Name, "err", err)
+	}
+}
+
+// commandFeedback returns the latest output of the project's command steps
+// that failed, for the implement prompt. Warned output is not fed back.
+func (r *Runner) commandFeedback(ctx context.Context, jobID string, projectCfg *config.ProjectConfig) string {
+	var feedback string
+	for _, step := range projectCfg.PipelineSteps() {
+		if !step.IsCommand() {
+			continue
+		}
+		art, err := r.store.GetLatestArtifact(ctx, jobID, step.ArtifactKind())
+		if err != nil || art.Status != commandFailed {
+			continue
+		}
+		feedback += fmt.Sprintf("\n\n<previous_command_output step=%q>\n%s\n</previous_command_output>", step.Name, art.Content)
+	}
+	return feedback
+}
@@ -0,0 +1,152 @@
+package pipeline
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"autopr/internal/config"
+	"autopr/internal/llm"
+)
+
+// commandStepProject runs plan, implement and the given command steps, with
+// no code review and no tests.
+func commandStepProject(commands ...config.PipelineStep) *config.ProjectConfig {
+	projectCfg := testProjectConfigWithoutRebase()
+	steps := []config.PipelineStep{{Name: config.StepPlan}, {Name: config.StepImplement}}
+	projectCfg.Pipeline = &config.ProjectPipeline{Steps: append(steps, commands...)}
+	return projectCfg
+}
+
+func TestRunStepsCommandStepLoopsBackToImplement(t *testing.T) {
+	t.Parallel()
+	var mu sync.Mutex
+	var implementPrompts []string
+	// The second implement "fixes" the lint failure by creating the file the
+	// lint command checks for.
+	provider := stubProvider{run: func(ctx context.Context, workDir, prompt string) (llm.Response, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if strings.Contains(prompt, "Implement the changes") {
+			implementPrompts = append(implementPrompts, prompt)
+			if len(implementPrompts) == 2 {
+				if err := os.WriteFile(filepath.Join(workDir, "fixed"), nil, 0o644); err != nil {
+					return llm.Response{}, err
+				}
+			}
+		}
+		return llm.Response{Text: "done", InputTokens: 1, OutputTokens: 1, DurationMS: 1}, nil
+	}}
+	runner, store, issue, jobID := setupRunStepsJob(t, provider, "planning")
+	ctx := context.Background()
+	projectCfg := commandStepProject(
+		config.PipelineStep{Name: "lint", Cmd: "test -f fixed", OnFailure: config.OnFailureLoop},
+		config.PipelineStep{Name: "spellcheck", Cmd: "false", OnFailure: config.OnFailureWarn},
+	)
+
+	if err := runner.runSteps(ctx, jobID, "planning", issue, projectCfg, t.TempDir()); err == nil {
+		t.Fatalf("expected rebase-stage failure")
+	}
+
+	if len(implementPrompts) != 2 {
+		t.Fatalf("expected lint failure to loop back to implement once, got %d implements", len(implementPrompts))
+	}
+	if !strings.Contains(implementPrompts[1], `<previous_command_output step="lint">`) {
+		t.Fatalf("expected lint output in the re-implement prompt, got:\n%s", implementPrompts[1])
+	}
+	if got := sessionCountForStep(t, store, ctx, jobID, "code_review"); got != 0 {
+		t.Fatalf("expected code review to be skipped, got %d sessions", got)
+	}
+
+	lint, err := store.GetLatestArtifact(ctx, jobID, "command:lint")
+	if err != nil || lint.Status != commandPassed || lint.Iteration != 1 {
+		t.Fatalf("expected passing lint artifact in iteration 1, got %+v (err %v)", lint, err)
+	}
+	spell, err := store.GetLatestArtifact(ctx, jobID, "command:spellcheck")
+	if err != nil || spell.Status != commandWarned || !strings.HasPrefix(spell.Content, "$ false") {
+		t.Fatalf("expected warned spellcheck artifact, got %+v (err %v)", spell, err)
+	}
+	if _, err := store.GetLatestArtifact(ctx, jobID, "test_output"); err == nil {
+		t.Fatalf("expected tests to be skipped")
+	}
+}
+
+func TestRunStepsCommandStepFailsJob(t *testing.T) {
+	t.Parallel()
+	provider := stubProvider{run: func(ctx context.Context, workDir, prompt string) (llm.Response, error) {
+		return llm.Response{Text: "done", InputTokens: 1, OutputTokens: 1, DurationMS: 1}, nil
+	}}
+	runner, store, issue, jobID := setupRunStepsJob(t, provider, "planning")
+	ctx := context.Background()
+	projectCfg := commandStepProject(config.PipelineStep{Name: "build", Cmd: "false", OnFailure: config.OnFailureFail})
+
+	if err := runner.runSteps(ctx, jobID, "planning", issue, projectCfg, t.TempDir()); err == nil {
+		t.Fatalf("expected command step failure")
+	}
+
+	job, err := store.GetJob(ctx, jobID)
+	if err != nil {
+		t.Fatalf("get job: %v", err)
+	}
+	if job.State != "failed" || job.CommandStep != "build" || !strings.Contains(job.ErrorMessage, "command step build") {
+		t.Fatalf("expected job failed in build step, got state %q step %q error %q", job.State, job.CommandStep, job.ErrorMessage)
+	}
+	if got := sessionCountForStep(t, store, ctx, jobID, "implement"); got != 1 {
+		t.Fatalf("expected no retry after a failing fail-step, got %d implements", got)
+	}
+}
+
+func TestRunStepsCommandStepWithInvalidCmdFailsInsteadOfLooping(t *testing.T) {
+	t.Parallel()
+	provider := stubProvider{run: func(ctx context.Context, workDir, prompt string) (llm.Response, error) {
+		return llm.Response{Text: "done", InputTokens: 1, OutputTokens: 1, DurationMS: 1}, nil
+	}}
+	runner, store, issue, jobID := setupRunStepsJob(t, provider, "planning")
+	ctx := context.Background()
+	projectCfg := commandStepProject(config.PipelineStep{Name: "lint", Cmd: "make lint && make vet", OnFailure: config.OnFailureLoop})
+
+	if err := runner.runSteps(ctx, jobID, "planning", issue, projectCfg, t.TempDir()); err == nil {
+		t.Fatalf("expected command step failure")
+	}
+
+	job, err := store.GetJob(ctx, jobID)
+	if err != nil {
+		t.Fatalf("get job: %v", err)
+	}
+	if job.State != "failed" || !strings.Contains(job.ErrorMessage, "invalid test_cmd") {
+		t.Fatalf("expected the job to fail on the invalid cmd, got state %q error %q", job.State, job.ErrorMessage)
+	}
+	if got := sessionCountForStep(t, store, ctx, jobID, "implement"); got != 1 {
+		t.Fatalf("expected no retry for an invalid cmd, got %d implements", got)
+	}
+	lint, err := store.GetLatestArtifact(ctx, jobID, "command:lint")
+	if err != nil || lint.Status != commandFailed || !strings.Contains(lint.Content, "disallowed token '&&'") {
+		t.Fatalf("expected the parse error as the lint artifact, got %+v (err %v)", lint, err)
+	}
+}
+
+func TestRunStepsCommandStepTimesOut(t *testing.T) {
+	t.Parallel()
+	provider := stubProvider{run: func(ctx context.Context, workDir, prompt string) (llm.Response, error) {
+		return llm.Response{Text: "done", InputTokens: 1, OutputTokens: 1, DurationMS: 1}, nil
+	}}
+	runner, store, issue, jobID := setupRunStepsJob(t, provider, "planning")
+	ctx := context.Background()
+	projectCfg := commandStepProject(config.PipelineStep{Name: "slow", Cmd: "sleep 5", OnFailure: config.OnFailureFail, Timeout: "100ms"})
+
+	start := time.Now()
+	if err := runner.runSteps(ctx, jobID, "planning", issue, projectCfg, t.TempDir()); err == nil {
+		t.Fatalf("expected command step failure")
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Fatalf("expected the command to time out, took %s", elapsed)
+	}
+	slow, err := store.GetLatestArtifact(ctx, jobID, "command:slow")
+	if err != nil || slow.Status != commandFailed || !strings.Contains(slow.Content, "timed out after 100ms") {
+		t.Fatalf("expected a timed out slow artifact, got %+v (err %v)", slow, err)
+	}
+}
@@ -0,0 +1,108 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"autopr/internal/config"
+	"autopr/internal/db"
+	"autopr/internal/diffpolicy"
+	"autopr/internal/git"
+)
+
+// errDiffPolicyViolated signals that the job's changes broke the project's
+// diff policy and the job should retry from implementing.
+var errDiffPolicyViolated = errors.New("diff policy violated")
+
+// checkDiffPolicy checks the job's changes against the project's diff policy
+// and stores the outcome as a diff_policy artifact. A violation loops back to
+// implementing, or fails the job with on_violation = "fail" or once the job
+// is out of iterations, so a violating diff never becomes ready.
+func (r *Runner) checkDiffPolicy(ctx context.Context, jobID string, issue db.Issue, projectCfg *config.ProjectConfig, workDir string) error {
+	if projectCfg == nil || projectCfg.DiffPolicy == nil {
+		return nil
+	}
+	policy := *projectCfg.DiffPolicy
+	job, err := r.store.GetJob(ctx, jobID)
+	if err != nil {
+		return err
+	}
+
+	filesText, err := git.DiffFilesAgainstBase(ctx, workDir, projectCfg.BaseBranch)
+	if err != nil {
+		return r.failJob(ctx, jobID, "testing", "diff policy: "+err.Error())
+	}
+	stat, err := git.DiffStatAgainstBase(ctx, workDir, projectCfg.BaseBranch)
+	if err != nil {
+		return r.failJob(ctx, jobID, "testing", "diff policy: "+err.Error())
+	}
+	var files []string
+	for _, line := range strings.Split(filesText, "\n") {
+		if line != "" {
+			files = append(files, line)
+		}
+	}
+	diff := diffpolicy.Diff{Files: files, Lines: diffpolicy.ParseStatLines(stat)}
+	var owners []diffpolicy.OwnerRule
+	if len(policy.CodeOwners) > 0 {
+		owners = codeOwners(ctx, workDir, projectCfg.BaseBranch)
+	}
+	violations := diffpolicy.Check(policy, diff, owners)
+
+	content := fmt.Sprintf("%d files, %d lines changed", len(diff.Files), diff.Lines)
+	status := commandPassed
+	if len(violations) > 0 {
+		content += "\n\n" + diffpolicy.Format(violations)
+		status = commandFailed
+	}
+	artifact := db.Artifact{
+		JobID:         jobID,
+		AutoPRIssueID: issue.AutoPRIssueID,
+		Kind:          "diff_policy",
+		Content:       content,
+		Iteration:     job.Iteration,
+		Status:        status,
+	}
+	if data, err := json.Marshal(violations); err == nil && len(violations) > 0 {
+		artifact.Data = string(data)
+	}
+	if _, err := r.store.InsertArtifact(ctx, artifact); err != nil {
+		slog.Warn("failed to store diff policy artifact", "job", jobID, "err", err)
+	}
+
+	if len(violations) == 0 {
+		slog.Info("diff policy passed", "job", jobID, "files", len(diff.Files), "lines", diff.Lines)
+		return nil
+	}
+	reason := "diff policy violated:\n" + diffpolicy.Format(violations)
+	if policy.OnViolation == config.OnFailureFail || job.Iteration >= job.MaxIterations {
+		return r.failJob(ctx, jobID, "testing", reason)
+	}
+	slog.Info("diff policy violated", "job", jobID, "violations", len(violations))
+	return errDiffPolicyViolated
+}
+
+// codeOwners returns the CODEOWNERS rules of the base branch. The job's own
+// copy is not trusted, since the job may have changed it.
+func codeOwners(ctx context.Context, workDir, baseBranch string) []diffpolicy.OwnerRule {
+	for _, path := range diffpolicy.CodeOwnersPaths {
+		if text, err := git.ShowFile(ctx, workDir, "origin/"+baseBranch, path); err == nil {
+			return diffpolicy.ParseCodeOwners(text)
+		}
+	}
+	return nil
+}
+
+// diffPolicyFeedback returns the latest diff policy violations for the
+// implement prompt, or "" when the last check passed.
+func (r *Runner) diffPolicyFeedback(ctx context.Context, jobID string) string {
+	art, err := r.store.GetLatestArtifact(ctx, jobID, "diff_policy")
+	if err != nil || art.Status != commandFailed {
+		return ""
+	}
+	return fmt.Sprintf("\n\n<diff_policy_violations>\nYour changes broke the project's diff policy. Revert or narrow them so that none of these apply:\n%s\n</diff_policy_violations>", art.Content)
+}
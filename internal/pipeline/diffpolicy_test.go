@@ -0,0 +1,109 @@
+package pipeline
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"autopr/internal/config"
+	"autopr/internal/llm"
+)
+
+// diffPolicyRepo clones a remote whose main branch has a CODEOWNERS file
+// into a job worktree on its own branch.
+func diffPolicyRepo(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	remote := createBareRemoteWithMain(t, root)
+	seed := filepath.Join(root, "seed")
+	if err := os.WriteFile(filepath.Join(seed, "CODEOWNERS"), []byte("/auth/ @org/security\n"), 0o644); err != nil {
+		t.Fatalf("write CODEOWNERS: %v", err)
+	}
+	runGitCmdLocal(t, seed, "add", "CODEOWNERS")
+	runGitCmdLocal(t, seed, "commit", "-m", "add owners")
+	runGitCmdLocal(t, seed, "push", "origin", "main")
+
+	workDir := filepath.Join(root, "job")
+	runGitCmdLocal(t, "", "clone", "--branch", "main", remote, workDir)
+	runGitCmdLocal(t, workDir, "checkout", "-b", "autopr/job")
+	return workDir
+}
+
+func TestRunStepsDiffPolicyLoopsBackToImplement(t *testing.T) {
+	t.Parallel()
+	var mu sync.Mutex
+	var implementPrompts []string
+	// The first implement touches a workflow; the second reverts it.
+	provider := stubProvider{run: func(ctx context.Context, workDir, prompt string) (llm.Response, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if strings.Contains(prompt, "Implement the changes") {
+			implementPrompts = append(implementPrompts, prompt)
+			workflow := filepath.Join(workDir, ".github", "workflows", "ci.yml")
+			if len(implementPrompts) == 1 {
+				if err := os.MkdirAll(filepath.Dir(workflow), 0o755); err != nil {
+					return llm.Response{}, err
+				}
+				if err := os.WriteFile(workflow, []byte("on: push\n"), 0o644); err != nil {
+					return llm.Response{}, err
+				}
+			} else if err := os.RemoveAll(filepath.Join(workDir, ".github")); err != nil {
+				return llm.Response{}, err
+			}
+			if err := os.WriteFile(filepath.Join(workDir, "feature.go"), []byte("package feature\n"), 0o644); err != nil {
+				return llm.Response{}, err
+			}
+		}
+		return llm.Response{Text: "done", InputTokens: 1, OutputTokens: 1, DurationMS: 1}, nil
+	}}
+	runner, store, issue, jobID := setupRunStepsJob(t, provider, "planning")
+	ctx := context.Background()
+	projectCfg := commandStepProject()
+	projectCfg.DiffPolicy = &config.ProjectDiffPolicy{Deny: []string{".github/workflows/"}, OnViolation: config.OnFailureLoop}
+
+	_ = runner.runSteps(ctx, jobID, "planning", issue, projectCfg, diffPolicyRepo(t))
+
+	if len(implementPrompts) != 2 {
+		t.Fatalf("expected the violation to loop back to implement once, got %d implements", len(implementPrompts))
+	}
+	if !strings.Contains(implementPrompts[1], "<diff_policy_violations>") || !strings.Contains(implementPrompts[1], "changes denied paths: .github/workflows/ci.yml") {
+		t.Fatalf("expected the violation in the re-implement prompt, got:\n%s", implementPrompts[1])
+	}
+	check, err := store.GetLatestArtifact(ctx, jobID, "diff_policy")
+	if err != nil || check.Status != commandPassed || check.Iteration != 1 || check.Content != "1 files, 1 lines changed" {
+		t.Fatalf("expected passing diff policy artifact in iteration 1, got %+v (err %v)", check, err)
+	}
+}
+
+func TestCheckDiffPolicyFailsJobOnCodeOwnersPath(t *testing.T) {
+	t.Parallel()
+	runner, store, issue, jobID := setupRunStepsJob(t, nil, "testing")
+	ctx := context.Background()
+	workDir := diffPolicyRepo(t)
+	if err := os.MkdirAll(filepath.Join(workDir, "auth"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(workDir, "auth", "token.go"), []byte("package auth\n"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	// Dropping the owners from the job's own CODEOWNERS does not help.
+	if err := os.WriteFile(filepath.Join(workDir, "CODEOWNERS"), nil, 0o644); err != nil {
+		t.Fatalf("write CODEOWNERS: %v", err)
+	}
+	projectCfg := testProjectConfigWithoutRebase()
+	projectCfg.DiffPolicy = &config.ProjectDiffPolicy{CodeOwners: []string{"@org/security"}, OnViolation: config.OnFailureFail}
+
+	if err := runner.checkDiffPolicy(ctx, jobID, issue, projectCfg, workDir); err == nil {
+		t.Fatalf("expected the job to fail")
+	}
+	job, err := store.GetJob(ctx, jobID)
+	if err != nil {
+		t.Fatalf("get job: %v", err)
+	}
+	if job.State != "failed" || !strings.Contains(job.ErrorMessage, "owned by @org/security, which need human approval: auth/token.go") {
+		t.Fatalf("expected failed job with the owners violation, got %q: %q", job.State, job.ErrorMessage)
+	}
+}
@@ -2,18 +2,24 @@ package pipeline
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
+	"autopr/internal/budget"
 	"autopr/internal/config"
 	"autopr/internal/db"
 	"autopr/internal/git"
 	"autopr/internal/llm"
+	"autopr/internal/sandbox"
+	"autopr/internal/testreport"
 )
 
 // errReviewChangesRequested signals that code review requested changes.
@@ -25,32 +31,45 @@ var errTestsFailed = errors.New("tests failed")
 // errJobCancelled signals that a job was explicitly cancelled by the user.
 var errJobCancelled = errors.New("job cancelled")
 
+// errLLMTimeout signals that an LLM session hit its configured wall-clock limit.
+var errLLMTimeout = errors.New("llm session timed out")
+
 // Runner orchestrates the full pipeline for a job.
 type Runner struct {
 	store                       *db.Store
 	provider                    llm.Provider
+	providerFor                 func(route config.LLMRoute) (llm.Provider, error)
 	cfg                         *config.Config
+	budget                      *budget.Checker
 	cloneForJob                 func(ctx context.Context, repoURL, token, destPath, branchName, baseBranch string) error
 	prepareGitHubPushTarget     func(ctx context.Context, projectCfg *config.ProjectConfig, branchName, worktreePath, token string) (string, string, error)
 	pushBranchWithLeaseToRemote func(ctx context.Context, dir, remoteName, branchName, token string) error
 	createPRForProjectFn        func(ctx context.Context, cfg *config.Config, proj *config.ProjectConfig, job db.Job, head, title, body string, draft bool) (string, error)
+	postIssueCommentFn          func(ctx context.Context, cfg *config.Config, proj *config.ProjectConfig, issue db.Issue, body string) (git.IssueComment, error)
+	postPRCommentFn             func(ctx context.Context, cfg *config.Config, proj *config.ProjectConfig, prURL, body string) (git.IssueComment, error)
 }
 
 func New(store *db.Store, provider llm.Provider, cfg *config.Config) *Runner {
 	return &Runner{
 		store:                   store,
 		provider:                provider,
+		providerFor:             llm.NewRouter(cfg.LLM).Provider,
 		cfg:                     cfg,
+		budget:                  budget.New(store, cfg),
 		cloneForJob:             git.CloneForJob,
 		prepareGitHubPushTarget: ResolveGitHubPushTarget,
 		pushBranchWithLeaseToRemote: func(ctx context.Context, dir, remoteName, branchName, token string) error {
 			return git.PushBranchWithLeaseToRemoteWithToken(ctx, dir, remoteName, branchName, token)
 		},
 		createPRForProjectFn: CreatePRForProject,
+		postIssueCommentFn:   PostIssueComment,
+		postPRCommentFn:      PostPRComment,
 	}
 }
 
-// Run processes a job through the pipeline: plan -> implement <-> review -> tests -> ready.
+// Run processes a job through the project's pipeline, by default:
+// plan -> [plan review] -> [plan approval] -> implement <-> review -> tests -> ready.
+// Jobs requeued for PR review comments start at implement instead.
 func (r *Runner) Run(ctx context.Context, jobID string) error {
 	runCtx, cancelRun := context.WithCancel(ctx)
 	defer cancelRun()
@@ -106,6 +125,20 @@ func (r *Runner) Run(ctx context.Context, jobID string) error {
 		branchName = job.BranchName
 	}
 
+	// A requeued job with an open PR addresses the review comments on it in
+	// the existing worktree; a retry starts over from a fresh clone.
+	if job.State == "planning" && job.PRURL != "" && job.WorktreePath != "" {
+		if feedback, ok := r.pendingReviewFeedback(ctx, jobID); ok {
+			if err := r.runReviewRound(runCtx, jobID, issue, projectCfg, worktreePath, feedback); err != nil {
+				if errors.Is(err, errJobCancelled) {
+					return r.onJobCancelled(jobID)
+				}
+				return err
+			}
+			return nil
+		}
+	}
+
 	// Run pipeline steps based on current state.
 	if err := r.runSteps(runCtx, jobID, job.State, issue, projectCfg, worktreePath); err != nil {
 		if errors.Is(err, errJobCancelled) {
@@ -122,61 +155,101 @@ func (r *Runner) Run(ctx context.Context, jobID string) error {
 	return nil
 }
 
-func (r *Runner) runSteps(ctx context.Context, jobID, currentState string, issue db.Issue, projectCfg *config.ProjectConfig, workDir string) error {
-	type pipelineStep struct {
-		state string
-		next  string
-		run   func(ctx context.Context, jobID string, issue db.Issue, projectCfg *config.ProjectConfig, workDir string) error
-		// true for steps that own their own failure transitions.
-		// Caller should not call failJob() automatically.
-		skipDefaultFailure bool
+type pipelineStep struct {
+	state string
+	// command is the pipeline step name of a command step; all command
+	// steps share the running_command state.
+	command string
+	next    string
+	run     func(ctx context.Context, jobID string, issue db.Issue, projectCfg *config.ProjectConfig, workDir string) error
+	// true for steps that own their own failure transitions.
+	// Caller should not call failJob() automatically.
+	skipDefaultFailure bool
+}
+
+// pipelineSteps maps the project's configured steps to job states. The
+// testing state always runs last: it also rebases and marks the job ready,
+// and only runs the test command when the pipeline includes tests.
+func (r *Runner) pipelineSteps(projectCfg *config.ProjectConfig) []pipelineStep {
+	var steps []pipelineStep
+	for _, s := range projectCfg.PipelineSteps() {
+		switch {
+		case s.IsCommand():
+			steps = append(steps, pipelineStep{state: "running_command", command: s.Name, run: r.commandStepRunner(s)})
+		case s.Name == config.StepPlan:
+			steps = append(steps, pipelineStep{state: "planning", run: r.runPlan})
+		case s.Name == config.StepPlanReview:
+			steps = append(steps, pipelineStep{state: "reviewing_plan", run: r.runPlanReview, skipDefaultFailure: true})
+		case s.Name == config.StepImplement:
+			steps = append(steps, pipelineStep{state: "implementing", run: r.runImplement})
+		case s.Name == config.StepCodeReview:
+			steps = append(steps, pipelineStep{state: "reviewing", run: r.runCodeReview})
+		}
 	}
+	if projectCfg != nil && projectCfg.PlanApproval {
+		// The checkpoint sits between the plan phase and implementing, which
+		// every pipeline has.
+		at := slices.IndexFunc(steps, func(s pipelineStep) bool { return s.state == "implementing" })
+		steps = slices.Insert(steps, at, pipelineStep{state: "awaiting_plan_approval", run: r.runPlanApproval, skipDefaultFailure: true})
+	}
+	steps = append(steps, pipelineStep{state: "testing", run: r.runTestingAndReadiness, skipDefaultFailure: true})
+	for i := range steps[:len(steps)-1] {
+		steps[i].next = steps[i+1].state
+	}
+	return steps
+}
+
+func (r *Runner) runSteps(ctx context.Context, jobID, currentState string, issue db.Issue, projectCfg *config.ProjectConfig, workDir string) error {
 	job, err := r.store.GetJob(ctx, jobID)
 	if err != nil {
 		return err
 	}
 	iteration := job.Iteration
 
-	steps := []pipelineStep{
-		{state: "planning", next: "implementing", run: r.runPlan},
-		{state: "implementing", next: "reviewing", run: r.runImplement},
-		{state: "reviewing", next: "testing", run: r.runCodeReview},
-		{state: "testing", next: "", run: r.runTestingAndReadiness, skipDefaultFailure: true},
+	steps := r.pipelineSteps(projectCfg)
+	start := slices.IndexFunc(steps, func(s pipelineStep) bool {
+		return s.state == currentState && (s.command == "" || job.CommandStep == "" || s.command == job.CommandStep)
+	})
+	if start < 0 {
+		return nil
 	}
 
-	for _, step := range steps {
-		if currentState != step.state {
-			continue
-		}
+	// Once a step runs, the later steps work on its new output and run too.
+	ran := false
+	for _, step := range steps[start:] {
 		if r.jobCancelled(jobID) {
 			return errJobCancelled
 		}
 		stepName := db.StepForState(step.state)
-		if stepName != "" {
+		if stepName != "" && !ran {
 			completed, err := r.store.HasCompletedSessionForStep(ctx, jobID, iteration, stepName)
 			if err != nil {
 				return err
 			}
+			// A plan session that asked clarifying questions produced no plan.
+			if completed && stepName == "plan" && r.planAwaitsAnswers(ctx, jobID) {
+				completed = false
+			}
 			if completed {
 				slog.Info("skipping completed step", "job", jobID, "step", stepName)
-				if r.jobCancelled(jobID) {
-					return errJobCancelled
-				}
-				if step.next == "" {
-					continue
-				}
-				if err := r.store.TransitionState(ctx, jobID, step.state, step.next); err != nil {
-					if r.jobCancelled(jobID) {
-						return errJobCancelled
-					}
+				if err := r.advanceStep(ctx, jobID, step); err != nil {
 					return err
 				}
-				currentState = step.next
 				continue
 			}
 		}
 
-		slog.Info("running step", "job", jobID, "step", db.StepForState(step.state))
+		if step.command != "" {
+			stepName = step.command
+			if err := r.store.UpdateJobField(ctx, jobID, "command_step", step.command); err != nil {
+				if r.jobCancelled(jobID) {
+					return errJobCancelled
+				}
+				return err
+			}
+		}
+		slog.Info("running step", "job", jobID, "step", stepName)
+		ran = true
 
 		if err := step.run(ctx, jobID, issue, projectCfg, workDir); err != nil {
 			if r.isJobCancelledError(ctx, jobID, err) {
@@ -192,10 +265,20 @@ func (r *Runner) runSteps(ctx context.Context, jobID, currentState string, issue
 				}
 				return r.handleRetryLoop(ctx, jobID, issue, projectCfg, workDir)
 			}
-			// Tests failed — loop back to implementing so LLM can fix.
-			if errors.Is(err, errTestsFailed) {
-				slog.Info("tests failed, looping back to implement", "job", jobID)
-				if err := r.store.TransitionState(ctx, jobID, "testing", "implementing"); err != nil {
+			// The plan waits for a human decision or for answers on the issue — park the job.
+			if errors.Is(err, errAwaitingPlanApproval) || errors.Is(err, errNeedsInfo) {
+				return nil
+			}
+			// Plan review judged the issue infeasible — stop for a human. The
+			// review also runs for revised plans at the approval checkpoint,
+			// so the job is in reviewing_plan whichever step this is.
+			if errors.Is(err, errPlanInfeasible) {
+				return r.stopForHuman(ctx, jobID, "reviewing_plan", err.Error())
+			}
+			// Tests, a command step or the diff policy failed — loop back to implementing so LLM can fix.
+			if errors.Is(err, errTestsFailed) || errors.Is(err, errCommandFailed) || errors.Is(err, errDiffPolicyViolated) {
+				slog.Info("checks failed, looping back to implement", "job", jobID, "step", stepName)
+				if err := r.store.TransitionState(ctx, jobID, step.state, "implementing"); err != nil {
 					if r.jobCancelled(jobID) {
 						return errJobCancelled
 					}
@@ -206,25 +289,31 @@ func (r *Runner) runSteps(ctx context.Context, jobID, currentState string, issue
 			if step.skipDefaultFailure {
 				return err
 			}
-			return r.failJob(ctx, jobID, step.state, err.Error())
+			return r.failJob(ctx, jobID, step.state, failureReason(err))
 		}
-		if step.next == "" {
-			continue
+		if err := r.advanceStep(ctx, jobID, step); err != nil {
+			return err
 		}
+	}
+
+	return nil
+}
+
+// advanceStep moves the job on to the state of the step after step.
+// Consecutive command steps share a state, so no transition is needed.
+func (r *Runner) advanceStep(ctx context.Context, jobID string, step pipelineStep) error {
+	if r.jobCancelled(jobID) {
+		return errJobCancelled
+	}
+	if step.next == "" || step.next == step.state {
+		return nil
+	}
+	if err := r.store.TransitionState(ctx, jobID, step.state, step.next); err != nil {
 		if r.jobCancelled(jobID) {
 			return errJobCancelled
 		}
-
-		// Transition to next state.
-		if err := r.store.TransitionState(ctx, jobID, step.state, step.next); err != nil {
-			if r.jobCancelled(jobID) {
-				return errJobCancelled
-			}
-			return err
-		}
-		currentState = step.next
+		return err
 	}
-
 	return nil
 }
 
@@ -238,6 +327,10 @@ func (r *Runner) handleRetryLoop(ctx context.Context, jobID string, issue db.Iss
 	}
 
 	if job.Iteration >= job.MaxIterations {
+		// A review round would push to an open PR; it stops instead.
+		if _, ok := r.pendingReviewFeedback(ctx, jobID); ok {
+			return r.stopForHuman(ctx, jobID, job.State, fmt.Sprintf("review round: code review or tests still failing after iteration %d", job.Iteration))
+		}
 		slog.Info("max iterations reached, moving to ready for human review", "job", jobID, "iterations", job.Iteration)
 		if err := r.store.TransitionState(ctx, jobID, job.State, "ready"); err != nil && !r.jobCancelled(jobID) {
 			return err
@@ -256,6 +349,17 @@ func (r *Runner) handleRetryLoop(ctx context.Context, jobID string, issue db.Iss
 	return r.runSteps(ctx, jobID, "implementing", issue, projectCfg, workDir)
 }
 
+// failureReason returns the error message recorded on a failed job. A budget
+// stop is recorded without the step's error context so it reads the same
+// whichever step hit the limit.
+func failureReason(err error) string {
+	var exceeded *budget.ExceededError
+	if errors.As(err, &exceeded) {
+		return exceeded.Error()
+	}
+	return err.Error()
+}
+
 func (r *Runner) failJob(ctx context.Context, jobID, fromState, errMsg string) error {
 	slog.Error("job failed", "job", jobID, "state", fromState, "error", errMsg)
 	_ = r.store.TransitionState(ctx, jobID, fromState, "failed")
@@ -263,19 +367,72 @@ func (r *Runner) failJob(ctx context.Context, jobID, fromState, errMsg string) e
 	return fmt.Errorf("job %s failed in %s: %s", jobID, fromState, errMsg)
 }
 
+// invokeProvider runs prompt on the provider routed to step. Transient
+// failures (rate limits, outages, expired auth) are retried on the configured
+// fallback providers, and every attempt is recorded as its own session. The
+// job's budget is checked before and after every attempt.
 func (r *Runner) invokeProvider(ctx context.Context, jobID, step string, iteration int, workDir, prompt string) (llm.Response, error) {
+	projectCfg, routes, err := r.routesForStep(ctx, jobID, step)
+	if err != nil {
+		return llm.Response{}, err
+	}
+	return r.invokeRoutes(ctx, jobID, step, iteration, workDir, prompt, projectCfg, routes)
+}
+
+// invokeRoutes runs prompt on the first of routes, falling back to the rest
+// on transient failures.
+func (r *Runner) invokeRoutes(ctx context.Context, jobID, step string, iteration int, workDir, prompt string, projectCfg *config.ProjectConfig, routes []config.LLMRoute) (llm.Response, error) {
+	if err := r.checkBudget(ctx, jobID); err != nil {
+		return llm.Response{}, err
+	}
+	iso := isolation(ctx, projectCfg, workDir)
+
+	var resp llm.Response
+	var err error
+	for i, route := range routes {
+		provider, providerErr := r.providerForRoute(route)
+		if providerErr != nil {
+			if err != nil {
+				return resp, fmt.Errorf("%w; fallback %s unavailable: %v", err, route.Provider, providerErr)
+			}
+			return llm.Response{}, providerErr
+		}
+		if isolator, ok := provider.(llm.Isolator); ok {
+			provider = isolator.WithIsolation(iso)
+		}
+		resp, err = r.runSession(ctx, jobID, step, iteration, workDir, prompt, provider, route)
+		if budgetErr := r.checkBudget(ctx, jobID); budgetErr != nil {
+			return resp, budgetErr
+		}
+		reason, transient := llm.IsTransient(err)
+		if !transient || i == len(routes)-1 || ctx.Err() != nil || r.jobCancelled(jobID) {
+			return resp, err
+		}
+		slog.Warn("llm provider failed transiently, trying fallback",
+			"job", jobID, "step", step, "provider", provider.Name(), "reason", reason, "next", routes[i+1].Provider)
+	}
+	return resp, err
+}
+
+// checkBudget returns an *budget.ExceededError when the job is over its
+// budget. Failures to price the job are logged rather than stopping it.
+func (r *Runner) checkBudget(ctx context.Context, jobID string) error {
+	return r.budget.CheckJob(ctx, jobID)
+}
+
+// runSession records one provider attempt as an llm_sessions row.
+func (r *Runner) runSession(ctx context.Context, jobID, step string, iteration int, workDir, prompt string, provider llm.Provider, route config.LLMRoute) (resp llm.Response, err error) {
 	// Generate JSONL path before session creation so it's stored in the DB
 	// and discoverable by `ap logs --follow`.
 	jsonlDir := filepath.Join(filepath.Dir(workDir), "sessions")
 	_ = os.MkdirAll(jsonlDir, 0o755)
 	jsonlPath := filepath.Join(jsonlDir, fmt.Sprintf("session-%d.jsonl", time.Now().UnixNano()))
 
-	sessionID, err := r.store.CreateSession(ctx, jobID, step, iteration, r.provider.Name(), jsonlPath)
+	sessionID, err := r.store.CreateSession(ctx, jobID, step, iteration, provider.Name(), jsonlPath)
 	if err != nil {
 		return llm.Response{}, fmt.Errorf("create session: %w", err)
 	}
 
-	var resp llm.Response
 	defer func() {
 		status := "completed"
 		errMsg := ""
@@ -296,6 +453,23 @@ func (r *Runner) invokeProvider(ctx context.Context, jobID, step string, iterati
 
 		completeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
 		defer cancel()
+		model := resp.Model
+		if model == "" {
+			model = route.Model
+		}
+		if model != "" {
+			if modelErr := r.store.SetSessionModel(completeCtx, sessionID, model); modelErr != nil {
+				slog.Warn("failed to record session model", "job", jobID, "session_id", sessionID, "err", modelErr)
+			}
+		}
+		if resp.CacheReadTokens != 0 || resp.CacheCreationTokens != 0 {
+			if cacheErr := r.store.SetSessionCacheTokens(completeCtx, sessionID, resp.CacheReadTokens, resp.CacheCreationTokens); cacheErr != nil {
+				slog.Warn("failed to record session cache tokens", "job", jobID, "session_id", sessionID, "err", cacheErr)
+			}
+		}
+		if eventsErr := r.store.InsertSessionEvents(completeCtx, sessionID, jobID, sessionEvents(resp.Events)); eventsErr != nil {
+			slog.Warn("failed to record session events", "job", jobID, "session_id", sessionID, "err", eventsErr)
+		}
 		if completeErr := r.store.CompleteSession(completeCtx, sessionID, status, resp.Text, prompt, "", resp.JSONLPath, resp.CommitSHA, errMsg, resp.InputTokens, resp.OutputTokens, resp.DurationMS); completeErr != nil {
 			slog.Warn("failed to complete llm session", "job", jobID, "session_id", sessionID, "status", status, "err", completeErr)
 		}
@@ -305,10 +479,138 @@ func (r *Runner) invokeProvider(ctx context.Context, jobID, step string, iterati
 		}
 	}()
 
-	resp, err = r.provider.Run(ctx, workDir, prompt, jsonlPath)
+	runCtx := ctx
+	timeout := route.TimeoutDuration()
+	if timeout > 0 {
+		var cancelRun context.CancelFunc
+		runCtx, cancelRun = context.WithTimeout(ctx, timeout)
+		defer cancelRun()
+	}
+	resp, err = provider.Run(runCtx, workDir, prompt, jsonlPath)
+	if err != nil && ctx.Err() == nil && errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("%w after %s: %v", errLLMTimeout, timeout, err)
+	}
 	return resp, err
 }
 
+// sessionEvents converts provider events to their stored form.
+func sessionEvents(events []llm.Event) []db.SessionEvent {
+	out := make([]db.SessionEvent, 0, len(events))
+	for _, ev := range events {
+		out = append(out, db.SessionEvent{
+			EventType: ev.Type,
+			Tool:      ev.Tool,
+			Path:      ev.Path,
+			Command:   ev.Command,
+			ExitCode:  ev.ExitCode,
+			CreatedAt: ev.Timestamp.UTC().Format(time.RFC3339),
+		})
+	}
+	return out
+}
+
+// routesForStep returns the job's project config and the primary route for
+// step followed by its fallbacks.
+func (r *Runner) routesForStep(ctx context.Context, jobID, step string) (*config.ProjectConfig, []config.LLMRoute, error) {
+	if r.cfg == nil {
+		return nil, []config.LLMRoute{{}}, nil
+	}
+	job, err := r.store.GetJob(ctx, jobID)
+	if err != nil {
+		return nil, nil, err
+	}
+	projectCfg, _ := r.cfg.ProjectByName(job.ProjectName)
+	routes := []config.LLMRoute{r.cfg.LLMRouteForStep(projectCfg, step)}
+	return projectCfg, append(routes, r.cfg.LLMFallbackRoutes(projectCfg, step)...), nil
+}
+
+// routesForReviewer is routesForStep for one of the project's code
+// reviewers. Fallbacks on the reviewer's own provider are skipped.
+func (r *Runner) routesForReviewer(projectCfg *config.ProjectConfig, reviewer config.CodeReviewer) []config.LLMRoute {
+	if r.cfg == nil {
+		return []config.LLMRoute{{}}
+	}
+	route := r.cfg.CodeReviewerRoute(projectCfg, reviewer)
+	routes := []config.LLMRoute{route}
+	for _, fallback := range r.cfg.LLMFallbackRoutes(projectCfg, config.StepCodeReview) {
+		if fallback.Provider != route.Provider {
+			routes = append(routes, fallback)
+		}
+	}
+	return routes
+}
+
+// isolation returns how the project's agent and test subprocesses run in
+// workDir: with an allow-listed environment (no forge tokens or webhook
+// secret), a per-job HOME, the project's env additions and its sandbox.
+func isolation(ctx context.Context, projectCfg *config.ProjectConfig, workDir string) llm.Isolation {
+	set := gitIdentityEnv(ctx, workDir)
+	if projectCfg != nil {
+		for name, value := range projectCfg.Env {
+			set[name] = value
+		}
+	}
+	return llm.Isolation{
+		Env:     sandbox.Env{Home: jobHome(workDir), Set: set},
+		Sandbox: sandboxOptions(projectCfg),
+	}
+}
+
+// jobHome returns the per-job HOME directory. It lives inside the clone's .git
+// directory so it is never committed and is removed with the worktree. It is
+// empty when workDir is not a git clone.
+func jobHome(workDir string) string {
+	gitDir := filepath.Join(workDir, ".git")
+	if info, err := os.Stat(gitDir); err != nil || !info.IsDir() {
+		return ""
+	}
+	return filepath.Join(gitDir, "autopr-home")
+}
+
+// gitIdentityEnv passes the daemon's git identity to subprocesses explicitly,
+// since a per-job HOME hides the user's ~/.gitconfig.
+func gitIdentityEnv(ctx context.Context, workDir string) map[string]string {
+	env := make(map[string]string)
+	for key, vars := range map[string][]string{
+		"user.name":  {"GIT_AUTHOR_NAME", "GIT_COMMITTER_NAME"},
+		"user.email": {"GIT_AUTHOR_EMAIL", "GIT_COMMITTER_EMAIL"},
+	} {
+		cmd := exec.CommandContext(ctx, "git", "config", "--get", key)
+		cmd.Dir = workDir
+		out, err := cmd.Output()
+		value := strings.TrimSpace(string(out))
+		if err != nil || value == "" {
+			continue
+		}
+		for _, name := range vars {
+			if os.Getenv(name) == "" {
+				env[name] = value
+			}
+		}
+	}
+	return env
+}
+
+// sandboxOptions returns the sandbox for the project's agent and test
+// subprocesses, or nil when the project does not enable one.
+func sandboxOptions(projectCfg *config.ProjectConfig) *sandbox.Options {
+	if !projectCfg.SandboxEnabled() {
+		return nil
+	}
+	sb := projectCfg.Sandbox
+	return &sandbox.Options{ReadOnly: sb.ReadOnly, ReadWrite: sb.ReadWrite, DenyNetwork: sb.DenyNetwork}
+}
+
+// providerForRoute returns the provider for route. The runner's default
+// provider serves the plain [llm] route and runners without config.
+func (r *Runner) providerForRoute(route config.LLMRoute) (llm.Provider, error) {
+	if r.cfg == nil || r.providerFor == nil || route == r.cfg.LLMRouteForStep(nil, "") {
+		return r.provider, nil
+	}
+	slog.Debug("llm route", "provider", route.Provider, "model", route.Model)
+	return r.providerFor(route)
+}
+
 func sessionErrorMessage(err error) string {
 	switch {
 	case errors.Is(err, context.Canceled):
@@ -410,6 +712,9 @@ func (r *Runner) maybeAutoPR(ctx context.Context, jobID string, issue db.Issue,
 	if err := RebaseBeforePush(ctx, r.store, job.ID, issue.AutoPRIssueID, projectCfg.BaseBranch, job.WorktreePath, job.Iteration, r.cfg.GitTokenForProject(projectCfg)); err != nil {
 		return fmt.Errorf("rebase before auto-PR push: %w", err)
 	}
+	if err := ScanBeforePush(ctx, r.store, job.ID, issue.AutoPRIssueID, projectCfg.BaseBranch, job.WorktreePath, job.Iteration); err != nil {
+		return fmt.Errorf("scan before auto-PR push: %w", err)
+	}
 
 	remoteName := "origin"
 	head := job.BranchName
@@ -512,6 +817,18 @@ func BuildPRContent(ctx context.Context, store *db.Store, job db.Job, issue db.I
 		}
 	}
 
+	if tests, err := store.GetLatestArtifact(ctx, job.ID, "test_output"); err == nil && tests.Data != "" {
+		var report testreport.Report
+		if json.Unmarshal([]byte(tests.Data), &report) == nil && len(report.Preexisting) > 0 {
+			fmt.Fprintf(&body, "<details>\n<summary>Pre-existing test failures (%d)</summary>\n\n", len(report.Preexisting))
+			fmt.Fprintf(&body, "These tests also fail on the base branch at %s and are unrelated to this change:\n\n", report.Baseline)
+			for _, f := range report.Preexisting {
+				fmt.Fprintf(&body, "- `%s`\n", f.Title())
+			}
+			body.WriteString("\n</details>\n\n")
+		}
+	}
+
 	body.WriteString(fmt.Sprintf("_Generated by [AutoPR](https://github.com/ashwath-ramesh/autopr) from job `%s`_\n", db.ShortID(job.ID)))
 
 	return title, body.String()
@@ -82,7 +82,7 @@ func TestRun_StaleWorktreeDirectoryIsReplacedBeforeClone(t *testing.T) {
 			case 2:
 				return llm.Response{Text: "Implemented"}, nil
 			case 3:
-				return llm.Response{Text: "APPROVED"}, nil
+				return llm.Response{Text: approvedReview}, nil
 			default:
 				return llm.Response{}, nil
 			}
@@ -0,0 +1,109 @@
+package pipeline
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"autopr/internal/config"
+	"autopr/internal/db"
+	"autopr/internal/llm"
+)
+
+func TestRun_ReplaysRecordedSessionsToReady(t *testing.T) {
+	// The safety-net commit after implement needs an identity in the clone.
+	for _, key := range []string{"GIT_AUTHOR_NAME", "GIT_COMMITTER_NAME"} {
+		t.Setenv(key, "Test User")
+	}
+	for _, key := range []string{"GIT_AUTHOR_EMAIL", "GIT_COMMITTER_EMAIL"} {
+		t.Setenv(key, "test@example.com")
+	}
+
+	ctx := context.Background()
+	tmp := t.TempDir()
+
+	store, err := db.Open(filepath.Join(tmp, "autopr.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer store.Close()
+
+	replayDir := filepath.Join(tmp, "recording")
+	writeRecording(t, replayDir, map[string]string{
+		"001.jsonl": `{"type":"result","result":"1. Add hello.txt"}` + "\n",
+		"002.jsonl": `{"type":"assistant","message":{"content":[{"type":"tool_use","id":"t1","name":"Write","input":{"file_path":"hello.txt"}}]}}` + "\n" +
+			`{"type":"user","message":{"content":[{"type":"tool_result","tool_use_id":"t1","content":"ok"}]}}` + "\n" +
+			`{"type":"result","result":"Added hello.txt"}` + "\n",
+		"002.patch": "diff --git a/hello.txt b/hello.txt\nnew file mode 100644\n--- /dev/null\n+++ b/hello.txt\n@@ -0,0 +1 @@\n+hello\n",
+		"003.jsonl": `{"type":"result","result":"{\"decision\":\"approve\",\"confidence\":1,\"findings\":[]}"}` + "\n",
+	})
+
+	remote := createBareRemoteWithMain(t, tmp)
+	cfg := &config.Config{
+		ReposRoot: filepath.Join(tmp, "repos"),
+		LLM:       config.LLMConfig{Provider: config.ProviderReplay, ReplayDir: replayDir},
+		Projects: []config.ProjectConfig{{
+			Name:       "myproject",
+			RepoURL:    remote,
+			BaseBranch: "main",
+			TestCmd:    "test -f hello.txt",
+			GitHub:     &config.ProjectGitHub{Owner: "org", Repo: "repo"},
+		}},
+	}
+
+	issueID, err := store.UpsertIssue(ctx, db.IssueUpsert{
+		ProjectName:   "myproject",
+		Source:        "github",
+		SourceIssueID: "202",
+		Title:         "add hello",
+		URL:           "https://github.com/org/repo/issues/202",
+		State:         "open",
+	})
+	if err != nil {
+		t.Fatalf("upsert issue: %v", err)
+	}
+	jobID, err := store.CreateJob(ctx, issueID, "myproject", 3)
+	if err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+	if _, err := store.ClaimJob(ctx); err != nil {
+		t.Fatalf("claim job: %v", err)
+	}
+
+	runner := New(store, llm.NewReplayProvider(replayDir), cfg)
+	if err := runner.Run(ctx, jobID); err != nil {
+		t.Fatalf("run pipeline: %v", err)
+	}
+
+	job, err := store.GetJob(ctx, jobID)
+	if err != nil {
+		t.Fatalf("get job: %v", err)
+	}
+	if job.State != "ready" {
+		t.Fatalf("expected ready state, got %q (error: %s)", job.State, job.ErrorMessage)
+	}
+	plan, err := store.GetLatestArtifact(ctx, jobID, "plan")
+	if err != nil || plan.Content != "1. Add hello.txt" {
+		t.Fatalf("expected replayed plan artifact, got %q err=%v", plan.Content, err)
+	}
+	if data, err := os.ReadFile(filepath.Join(job.WorktreePath, "hello.txt")); err != nil || string(data) != "hello\n" {
+		t.Fatalf("expected replayed patch in worktree, got %q err=%v", data, err)
+	}
+	events, err := store.ListSessionEventsByJob(ctx, jobID)
+	if err != nil || len(events) != 1 || events[0].Path != "hello.txt" {
+		t.Fatalf("expected replayed implement event, got %+v err=%v", events, err)
+	}
+}
+
+func writeRecording(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("mkdir recording: %v", err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+}
@@ -2,16 +2,22 @@ package pipeline
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 
+	"autopr/internal/budget"
 	"autopr/internal/db"
 	"autopr/internal/config"
 	"autopr/internal/llm"
 )
 
+// approvedReview is a code review reply with an approving verdict block.
+const approvedReview = "Looks good.\n```json\n{\"decision\": \"approve\", \"confidence\": 0.9, \"findings\": []}\n```"
+
 type stubProvider struct {
 	run func(ctx context.Context, workDir, prompt string) (llm.Response, error)
 }
@@ -187,6 +193,219 @@ func TestInvokeProviderMarksSessionFailedOnProviderError(t *testing.T) {
 	}
 }
 
+type namedStubProvider struct {
+	name  string
+	model string
+}
+
+func (p namedStubProvider) Name() string { return p.name }
+
+func (p namedStubProvider) Run(ctx context.Context, workDir, prompt, jsonlPath string) (llm.Response, error) {
+	return llm.Response{Text: "ok", Model: p.model}, nil
+}
+
+func TestInvokeProviderRoutesStepsToConfiguredProvider(t *testing.T) {
+	// The default provider reports the concrete model it ran.
+	runner, store, jobID := setupInvokeProviderTest(t, namedStubProvider{name: "claude", model: "claude-sonnet-x"})
+	runner.cfg = &config.Config{
+		LLM: config.LLMConfig{
+			Provider: "claude",
+			Model:    "sonnet",
+			Steps: map[string]config.LLMRoute{
+				"code_review": {Provider: "codex", Model: "gpt-5"},
+			},
+		},
+		Projects: []config.ProjectConfig{{Name: "myproject"}},
+	}
+	var routes []config.LLMRoute
+	runner.providerFor = func(route config.LLMRoute) (llm.Provider, error) {
+		routes = append(routes, route)
+		return namedStubProvider{name: route.Provider}, nil
+	}
+
+	ctx := context.Background()
+	for _, step := range []string{"plan", "code_review"} {
+		if _, err := runner.invokeProvider(ctx, jobID, step, 0, t.TempDir(), "prompt"); err != nil {
+			t.Fatalf("invoke %s: %v", step, err)
+		}
+	}
+
+	if len(routes) != 1 || routes[0].Provider != "codex" {
+		t.Fatalf("expected only code_review to be routed, got %+v", routes)
+	}
+	sessions, err := store.ListSessionsByJob(ctx, jobID)
+	if err != nil {
+		t.Fatalf("list sessions: %v", err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("expected 2 sessions, got %d", len(sessions))
+	}
+	if sessions[0].LLMProvider != "claude" || sessions[0].Model != "claude-sonnet-x" {
+		t.Fatalf("expected plan session on claude-sonnet-x, got %s/%s", sessions[0].LLMProvider, sessions[0].Model)
+	}
+	if sessions[1].LLMProvider != "codex" || sessions[1].Model != "gpt-5" {
+		t.Fatalf("expected review session on codex/gpt-5, got %s/%s", sessions[1].LLMProvider, sessions[1].Model)
+	}
+}
+
+type isolationRecordingProvider struct {
+	namedStubProvider
+	got *llm.Isolation
+}
+
+func (p *isolationRecordingProvider) WithIsolation(iso llm.Isolation) llm.Provider {
+	p.got = &iso
+	return p.namedStubProvider
+}
+
+func TestInvokeProviderAppliesProjectIsolation(t *testing.T) {
+	provider := &isolationRecordingProvider{namedStubProvider: namedStubProvider{name: "claude"}}
+	runner, _, jobID := setupInvokeProviderTest(t, provider)
+	runner.cfg = &config.Config{
+		LLM: config.LLMConfig{Provider: "claude"},
+		Projects: []config.ProjectConfig{{
+			Name:    "myproject",
+			Sandbox: &config.ProjectSandbox{Enabled: true, DenyNetwork: true, ReadWrite: []string{"/home/agent/.claude"}},
+			Env:     map[string]string{"GOFLAGS": "-mod=mod"},
+		}},
+	}
+	workDir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(workDir, ".git"), 0o755); err != nil {
+		t.Fatalf("mkdir .git: %v", err)
+	}
+
+	if _, err := runner.invokeProvider(context.Background(), jobID, "plan", 0, workDir, "prompt"); err != nil {
+		t.Fatalf("invoke: %v", err)
+	}
+	got := provider.got
+	if got == nil || got.Sandbox == nil || !got.Sandbox.DenyNetwork || len(got.Sandbox.ReadWrite) != 1 {
+		t.Fatalf("expected project sandbox to be applied, got %+v", got)
+	}
+	if got.Env.Set["GOFLAGS"] != "-mod=mod" {
+		t.Fatalf("expected project env to be applied, got %+v", got.Env.Set)
+	}
+	if want := filepath.Join(workDir, ".git", "autopr-home"); got.Env.Home != want {
+		t.Fatalf("expected job home %q, got %q", want, got.Env.Home)
+	}
+
+	provider.got = nil
+	runner.cfg.Projects[0].Sandbox.Enabled = false
+	if _, err := runner.invokeProvider(context.Background(), jobID, "plan", 0, t.TempDir(), "prompt"); err != nil {
+		t.Fatalf("invoke: %v", err)
+	}
+	if provider.got == nil || provider.got.Sandbox != nil || provider.got.Env.Home != "" {
+		t.Fatalf("expected env-only isolation outside a git clone, got %+v", provider.got)
+	}
+}
+
+type funcProvider struct {
+	name string
+	run  func() (llm.Response, error)
+}
+
+func (p funcProvider) Name() string { return p.name }
+
+func (p funcProvider) Run(ctx context.Context, workDir, prompt, jsonlPath string) (llm.Response, error) {
+	return p.run()
+}
+
+func TestInvokeProviderFallsBackOnTransientFailure(t *testing.T) {
+	rateLimited := funcProvider{name: "claude", run: func() (llm.Response, error) {
+		return llm.Response{}, &llm.TransientError{Reason: llm.TransientRateLimit, Err: fmt.Errorf("claude exited with error: API Error: 429")}
+	}}
+	runner, store, jobID := setupInvokeProviderTest(t, rateLimited)
+	runner.cfg = &config.Config{
+		LLM:      config.LLMConfig{Provider: "claude", Fallback: []string{"claude", "codex", "openai"}},
+		Projects: []config.ProjectConfig{{Name: "myproject"}},
+	}
+	var attempted []string
+	runner.providerFor = func(route config.LLMRoute) (llm.Provider, error) {
+		attempted = append(attempted, route.Provider)
+		return funcProvider{name: route.Provider, run: func() (llm.Response, error) {
+			return llm.Response{Text: "planned by " + route.Provider}, nil
+		}}, nil
+	}
+
+	resp, err := runner.invokeProvider(context.Background(), jobID, "plan", 0, t.TempDir(), "prompt")
+	if err != nil {
+		t.Fatalf("invoke: %v", err)
+	}
+	if resp.Text != "planned by codex" {
+		t.Fatalf("expected codex fallback response, got %q", resp.Text)
+	}
+	if strings.Join(attempted, ",") != "codex" {
+		t.Fatalf("expected only codex to be tried after claude, got %v", attempted)
+	}
+
+	sessions, err := store.ListSessionsByJob(context.Background(), jobID)
+	if err != nil {
+		t.Fatalf("list sessions: %v", err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("expected 2 sessions, got %d", len(sessions))
+	}
+	if sessions[0].LLMProvider != "claude" || sessions[0].Status != "failed" || !strings.Contains(sessions[0].ErrorMessage, "transient: rate_limit") {
+		t.Fatalf("unexpected first attempt: %+v", sessions[0])
+	}
+	if sessions[1].LLMProvider != "codex" || sessions[1].Status != "completed" {
+		t.Fatalf("unexpected fallback attempt: %+v", sessions[1])
+	}
+}
+
+func TestInvokeProviderDoesNotFallBackOnPermanentFailure(t *testing.T) {
+	broken := funcProvider{name: "claude", run: func() (llm.Response, error) {
+		return llm.Response{}, fmt.Errorf("claude exited with error: exit status 2")
+	}}
+	runner, store, jobID := setupInvokeProviderTest(t, broken)
+	runner.cfg = &config.Config{
+		LLM:      config.LLMConfig{Provider: "claude", Fallback: []string{"codex"}},
+		Projects: []config.ProjectConfig{{Name: "myproject"}},
+	}
+	runner.providerFor = func(route config.LLMRoute) (llm.Provider, error) {
+		t.Fatalf("unexpected fallback to %s", route.Provider)
+		return nil, nil
+	}
+
+	if _, err := runner.invokeProvider(context.Background(), jobID, "plan", 0, t.TempDir(), "prompt"); err == nil {
+		t.Fatalf("expected permanent failure to be returned")
+	}
+	sessions, err := store.ListSessionsByJob(context.Background(), jobID)
+	if err != nil {
+		t.Fatalf("list sessions: %v", err)
+	}
+	if len(sessions) != 1 {
+		t.Fatalf("expected 1 session, got %d", len(sessions))
+	}
+}
+
+func TestInvokeProviderEnforcesRouteTimeout(t *testing.T) {
+	hung := stubProvider{run: func(ctx context.Context, workDir, prompt string) (llm.Response, error) {
+		<-ctx.Done()
+		return llm.Response{}, fmt.Errorf("codex exited with error: signal: killed\nstderr (tail):\nstuck")
+	}}
+	runner, store, jobID := setupInvokeProviderTest(t, hung)
+	runner.cfg = &config.Config{
+		LLM:      config.LLMConfig{Provider: "codex", Timeout: "50ms"},
+		Projects: []config.ProjectConfig{{Name: "myproject"}},
+	}
+
+	_, err := runner.invokeProvider(context.Background(), jobID, "implement", 0, t.TempDir(), "prompt")
+	if !errors.Is(err, errLLMTimeout) {
+		t.Fatalf("expected timeout error, got %v", err)
+	}
+	sessions, err := store.ListSessionsByJob(context.Background(), jobID)
+	if err != nil {
+		t.Fatalf("list sessions: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].Status != "failed" {
+		t.Fatalf("expected one failed session, got %+v", sessions)
+	}
+	msg := sessions[0].ErrorMessage
+	if !strings.Contains(msg, "timed out after 50ms") || !strings.Contains(msg, "stuck") {
+		t.Fatalf("expected timeout and stderr tail in session error, got %q", msg)
+	}
+}
+
 func TestInvokeProviderCompletesSessionWhenContextCanceled(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	provider := stubProvider{
@@ -262,6 +481,47 @@ func TestInvokeProviderMarksSessionFailedOnPanic(t *testing.T) {
 	}
 }
 
+func TestRunStepsFailsJobOverBudget(t *testing.T) {
+	t.Parallel()
+	provider := stubProvider{
+		run: func(ctx context.Context, workDir, prompt string) (llm.Response, error) {
+			return llm.Response{Text: "plan", InputTokens: 1_000_000, DurationMS: 1}, nil
+		},
+	}
+	runner, store, issue, jobID := setupRunStepsJob(t, provider, "planning")
+	runner.budget = budget.New(store, &config.Config{Budget: config.BudgetConfig{Job: 1, NotifyAt: 0.8}})
+	ctx := context.Background()
+
+	if err := runner.runSteps(ctx, jobID, "planning", issue, testProjectConfigWithoutRebase(), t.TempDir()); err == nil {
+		t.Fatalf("expected runSteps to fail over budget")
+	}
+
+	job, err := store.GetJob(ctx, jobID)
+	if err != nil {
+		t.Fatalf("get job: %v", err)
+	}
+	if job.State != "failed" {
+		t.Fatalf("expected failed job, got %q", job.State)
+	}
+	if job.ErrorMessage != "budget exceeded: job spent $3.00 of its $1.00 limit" {
+		t.Fatalf("unexpected error message %q", job.ErrorMessage)
+	}
+	if got := sessionCountForStep(t, store, ctx, jobID, "implement"); got != 0 {
+		t.Fatalf("expected no implement session after budget stop, got %d", got)
+	}
+	events, err := store.ListNotificationEvents(ctx, "", 0)
+	if err != nil {
+		t.Fatalf("list notification events: %v", err)
+	}
+	found := false
+	for _, ev := range events {
+		found = found || ev.EventType == db.NotificationEventBudget
+	}
+	if !found {
+		t.Fatalf("expected budget_threshold notification, got %+v", events)
+	}
+}
+
 func TestRunStepsSkipsCompletedPlanAndStartsFromImplementing(t *testing.T) {
 	t.Parallel()
 	provider := stubProvider{
@@ -270,7 +530,7 @@ func TestRunStepsSkipsCompletedPlanAndStartsFromImplementing(t *testing.T) {
 				InputTokens:  1,
 				OutputTokens: 1,
 				DurationMS:   1,
-				Text:         "approved",
+				Text:         approvedReview,
 			}, nil
 		},
 	}
@@ -308,7 +568,7 @@ func TestRunStepsSkipsCompletedPlanImplementAndReviewAndStartsFromTesting(t *tes
 				InputTokens:  1,
 				OutputTokens: 1,
 				DurationMS:   1,
-				Text:         "approved",
+				Text:         approvedReview,
 			}, nil
 		},
 	}
@@ -350,7 +610,7 @@ func TestRunStepsSkipsCompletedPrefixAndStartsFromFirstIncompleteStep(t *testing
 				InputTokens:  1,
 				OutputTokens: 1,
 				DurationMS:   1,
-				Text:         "approved",
+				Text:         approvedReview,
 			}, nil
 		},
 	}
@@ -0,0 +1,151 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"autopr/internal/config"
+	"autopr/internal/db"
+)
+
+const planApprovalArtifactKind = "plan_approval"
+
+// Plan approval decisions, stored as the status of a plan_approval artifact.
+const (
+	planApproved = "approved"
+	planRevise   = "revise"
+)
+
+// maxPlanNotificationChars caps the plan text sent with the plan_approval
+// notification; chat webhooks reject long messages.
+const maxPlanNotificationChars = 3000
+
+// errAwaitingPlanApproval signals that the job is parked in
+// awaiting_plan_approval until a human decides on the plan.
+var errAwaitingPlanApproval = errors.New("awaiting plan approval")
+
+// runPlanApproval is the checkpoint of projects with plan_approval set. The
+// first time it runs it sends the plan in a plan_approval notification and
+// parks the job. `ap approve-plan` and `ap revise-plan` record a decision as a
+// plan_approval artifact and requeue the job; the worker skips the completed
+// plan steps and lands here again. An approval moves on to implementing, a
+// revision re-plans with the human notes, reviews the new plan when the
+// pipeline has plan_review and parks the job again.
+func (r *Runner) runPlanApproval(ctx context.Context, jobID string, issue db.Issue, projectCfg *config.ProjectConfig, workDir string) error {
+	decision, err := r.planDecision(ctx, jobID)
+	if err != nil {
+		return r.failPlanReview(ctx, jobID, "awaiting_plan_approval", err)
+	}
+
+	switch decision {
+	case planApproved:
+		slog.Info("plan approved", "job", jobID)
+		return nil
+	case planRevise:
+		slog.Info("plan revision requested, re-planning", "job", jobID)
+		if err := r.store.TransitionState(ctx, jobID, "awaiting_plan_approval", "planning"); err != nil {
+			if r.jobCancelled(jobID) {
+				return errJobCancelled
+			}
+			return err
+		}
+		if err := r.runPlan(ctx, jobID, issue, projectCfg, workDir); err != nil {
+			if errors.Is(err, errNeedsInfo) {
+				return err
+			}
+			return r.failPlanReview(ctx, jobID, "planning", err)
+		}
+		from := "planning"
+		if projectCfg.HasPipelineStep(config.StepPlanReview) {
+			// The revised plan is reviewed like the first one.
+			if err := r.store.TransitionState(ctx, jobID, "planning", "reviewing_plan"); err != nil {
+				if r.jobCancelled(jobID) {
+					return errJobCancelled
+				}
+				return err
+			}
+			if err := r.runPlanReview(ctx, jobID, issue, projectCfg, workDir); err != nil {
+				return err
+			}
+			from = "reviewing_plan"
+		}
+		if err := r.store.TransitionState(ctx, jobID, from, "awaiting_plan_approval"); err != nil {
+			if r.jobCancelled(jobID) {
+				return errJobCancelled
+			}
+			return err
+		}
+	}
+
+	if err := r.notifyPlanApproval(ctx, jobID); err != nil {
+		slog.Warn("failed to enqueue plan approval notification", "job", jobID, "err", err)
+	}
+	slog.Info("waiting for plan approval", "job", jobID)
+	return errAwaitingPlanApproval
+}
+
+// planDecision returns the decision recorded on the latest plan, or "" when
+// the plan has not been decided on yet.
+func (r *Runner) planDecision(ctx context.Context, jobID string) (string, error) {
+	plan, err := r.store.GetLatestArtifact(ctx, jobID, "plan")
+	if err != nil {
+		return "", fmt.Errorf("get plan for plan approval: %w", err)
+	}
+	decision, err := r.store.GetLatestArtifact(ctx, jobID, planApprovalArtifactKind)
+	if err != nil || decision.ID < plan.ID {
+		return "", nil
+	}
+	return decision.Status, nil
+}
+
+func (r *Runner) notifyPlanApproval(ctx context.Context, jobID string) error {
+	plan, err := r.store.GetLatestArtifact(ctx, jobID, "plan")
+	if err != nil {
+		return err
+	}
+	text := strings.TrimSpace(plan.Content)
+	if len(text) > maxPlanNotificationChars {
+		text = text[:maxPlanNotificationChars] + "\n…(truncated; see `ap logs " + jobID + "`)"
+	}
+	message := fmt.Sprintf("Plan ready for approval:\n\n%s\n\nRun `ap approve-plan %s` or `ap revise-plan %s -n \"notes\"`.", text, jobID, jobID)
+	_, err = r.store.EnqueueNotificationEventMessage(ctx, jobID, db.NotificationEventPlanApproval, message)
+	return err
+}
+
+// ApprovePlan records a human approval of a job's latest plan and requeues
+// the job to implement it. Shared by the CLI and the TUI.
+func ApprovePlan(ctx context.Context, store *db.Store, jobID string) error {
+	return decidePlan(ctx, store, jobID, planApproved, "")
+}
+
+// RevisePlan records notes for a job's latest plan and requeues the job to
+// re-plan with them. Like `ap retry -n`, the notes are stored as the job's
+// human_notes, which the plan prompt includes.
+func RevisePlan(ctx context.Context, store *db.Store, jobID, notes string) error {
+	notes = strings.TrimSpace(notes)
+	if notes == "" {
+		return fmt.Errorf("revision notes are required")
+	}
+	return decidePlan(ctx, store, jobID, planRevise, notes)
+}
+
+func decidePlan(ctx context.Context, store *db.Store, jobID, decision, notes string) error {
+	job, err := store.GetJob(ctx, jobID)
+	if err != nil {
+		return err
+	}
+	if job.State != "awaiting_plan_approval" {
+		return fmt.Errorf("job %s is in state %q, must be 'awaiting_plan_approval'", jobID, job.State)
+	}
+	return store.RecordPlanDecision(ctx, db.Artifact{
+		JobID:         jobID,
+		AutoPRIssueID: job.AutoPRIssueID,
+		Kind:          planApprovalArtifactKind,
+		Content:       notes,
+		Iteration:     job.Iteration,
+		Status:        decision,
+	}, notes)
+}
@@ -0,0 +1,158 @@
+package pipeline
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"autopr/internal/config"
+	"autopr/internal/db"
+)
+
+func planApprovalProjectConfig(planReview bool) *config.ProjectConfig {
+	projectCfg := testProjectConfigWithoutRebase()
+	if planReview {
+		projectCfg = planReviewProjectConfig(2)
+	}
+	projectCfg.PlanApproval = true
+	return projectCfg
+}
+
+// parkForPlanApproval runs the job up to the plan approval checkpoint.
+func parkForPlanApproval(t *testing.T, runner *Runner, store *db.Store, issue db.Issue, jobID string, projectCfg *config.ProjectConfig) {
+	t.Helper()
+	ctx := context.Background()
+	if err := runner.runSteps(ctx, jobID, "planning", issue, projectCfg, t.TempDir()); err != nil {
+		t.Fatalf("runSteps: %v", err)
+	}
+	job, err := store.GetJob(ctx, jobID)
+	if err != nil {
+		t.Fatalf("get job: %v", err)
+	}
+	if job.State != "awaiting_plan_approval" {
+		t.Fatalf("expected job to wait for plan approval, got %q", job.State)
+	}
+}
+
+// claimAgain stands in for the worker picking up a requeued job.
+func claimAgain(t *testing.T, store *db.Store, jobID string) {
+	t.Helper()
+	claimedID, err := store.ClaimJob(context.Background())
+	if err != nil || claimedID != jobID {
+		t.Fatalf("expected to claim job %q, got %q (err %v)", jobID, claimedID, err)
+	}
+}
+
+func TestRunStepsPlanApprovalWaitsThenImplements(t *testing.T) {
+	t.Parallel()
+	provider := &planReviewProvider{verdicts: []string{"APPROVE"}}
+	runner, store, issue, jobID := setupRunStepsJob(t, provider, "planning")
+	ctx := context.Background()
+	projectCfg := planApprovalProjectConfig(false)
+
+	parkForPlanApproval(t, runner, store, issue, jobID, projectCfg)
+	if got := sessionCountForStep(t, store, ctx, jobID, "implement"); got != 0 {
+		t.Fatalf("expected no implement session before approval, got %d", got)
+	}
+	events, err := store.ListNotificationEvents(ctx, db.NotificationStatusPending, 10)
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	if len(events) != 1 || events[0].EventType != db.NotificationEventPlanApproval || !strings.Contains(events[0].Message, "the plan") {
+		t.Fatalf("expected a plan_approval notification with the plan, got %+v", events)
+	}
+
+	if err := ApprovePlan(ctx, store, jobID); err != nil {
+		t.Fatalf("approve plan: %v", err)
+	}
+	if err := ApprovePlan(ctx, store, jobID); err == nil {
+		t.Fatalf("expected approving a queued job to fail")
+	}
+	claimAgain(t, store, jobID)
+	if err := runner.runSteps(ctx, jobID, "planning", issue, projectCfg, t.TempDir()); err == nil {
+		t.Fatalf("expected testing-stage failure")
+	}
+
+	if got := sessionCountForStep(t, store, ctx, jobID, "plan"); got != 1 {
+		t.Fatalf("expected the approved plan to be kept, got %d plan sessions", got)
+	}
+	if got := sessionCountForStep(t, store, ctx, jobID, "implement"); got != 1 {
+		t.Fatalf("expected implement after approval, got %d sessions", got)
+	}
+}
+
+func TestRunStepsPlanApprovalRevisesWithNotes(t *testing.T) {
+	t.Parallel()
+	provider := &planReviewProvider{verdicts: []string{"APPROVE"}}
+	runner, store, issue, jobID := setupRunStepsJob(t, provider, "planning")
+	ctx := context.Background()
+	projectCfg := planApprovalProjectConfig(true)
+
+	parkForPlanApproval(t, runner, store, issue, jobID, projectCfg)
+	if err := RevisePlan(ctx, store, jobID, "  "); err == nil {
+		t.Fatalf("expected revise-plan without notes to fail")
+	}
+	if err := RevisePlan(ctx, store, jobID, "keep the v1 API"); err != nil {
+		t.Fatalf("revise plan: %v", err)
+	}
+	claimAgain(t, store, jobID)
+	if err := runner.runSteps(ctx, jobID, "planning", issue, projectCfg, t.TempDir()); err != nil {
+		t.Fatalf("runSteps after revise: %v", err)
+	}
+
+	job, err := store.GetJob(ctx, jobID)
+	if err != nil {
+		t.Fatalf("get job: %v", err)
+	}
+	if job.State != "awaiting_plan_approval" {
+		t.Fatalf("expected the revised plan to wait for approval, got %q", job.State)
+	}
+	if got := sessionCountForStep(t, store, ctx, jobID, "plan"); got != 2 {
+		t.Fatalf("expected one re-plan, got %d plan sessions", got)
+	}
+	if got := sessionCountForStep(t, store, ctx, jobID, "plan_review"); got != 2 {
+		t.Fatalf("expected the revised plan to be reviewed, got %d plan_review sessions", got)
+	}
+	if got := sessionCountForStep(t, store, ctx, jobID, "implement"); got != 0 {
+		t.Fatalf("expected no implement session, got %d", got)
+	}
+	prompt := provider.planPrompts[1]
+	if !strings.Contains(prompt, "<human_notes>\nkeep the v1 API\n</human_notes>") {
+		t.Fatalf("expected the re-plan prompt to carry the notes, got:\n%s", prompt)
+	}
+	if strings.Contains(prompt, "<plan_review_feedback>") {
+		t.Fatalf("an approving plan review should not be fed back, got:\n%s", prompt)
+	}
+	events, err := store.ListNotificationEvents(ctx, db.NotificationStatusPending, 10)
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected a notification for each plan, got %d", len(events))
+	}
+}
+
+func TestRunStepsPlanApprovalStopsWhenRevisedPlanIsInfeasible(t *testing.T) {
+	t.Parallel()
+	provider := &planReviewProvider{verdicts: []string{"APPROVE", "INFEASIBLE"}}
+	runner, store, issue, jobID := setupRunStepsJob(t, provider, "planning")
+	ctx := context.Background()
+	projectCfg := planApprovalProjectConfig(true)
+
+	parkForPlanApproval(t, runner, store, issue, jobID, projectCfg)
+	if err := RevisePlan(ctx, store, jobID, "drop the database"); err != nil {
+		t.Fatalf("revise plan: %v", err)
+	}
+	claimAgain(t, store, jobID)
+	if err := runner.runSteps(ctx, jobID, "planning", issue, projectCfg, t.TempDir()); err != nil {
+		t.Fatalf("runSteps after revise: %v", err)
+	}
+
+	job, err := store.GetJob(ctx, jobID)
+	if err != nil {
+		t.Fatalf("get job: %v", err)
+	}
+	if job.State != "needs_human" {
+		t.Fatalf("expected an infeasible revised plan to stop for a human, got %q", job.State)
+	}
+}
@@ -0,0 +1,155 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+
+	"autopr/internal/config"
+	"autopr/internal/db"
+)
+
+// Plan review verdicts.
+const (
+	planVerdictApprove    = "APPROVE"
+	planVerdictReplan     = "REPLAN"
+	planVerdictInfeasible = "INFEASIBLE"
+)
+
+// errPlanInfeasible signals that plan review judged the issue infeasible and
+// the job should stop for a human instead of implementing.
+var errPlanInfeasible = errors.New("plan review judged the issue infeasible; see the plan_review artifact")
+
+var planVerdictRe = regexp.MustCompile(`(?im)^\W*VERDICT\W*(APPROVE|REPLAN|INFEASIBLE)\b`)
+
+// parsePlanVerdict returns the last verdict line in a plan review. A review
+// without a verdict is treated as a re-plan request.
+func parsePlanVerdict(text string) string {
+	matches := planVerdictRe.FindAllStringSubmatch(text, -1)
+	if len(matches) == 0 {
+		return planVerdictReplan
+	}
+	return strings.ToUpper(matches[len(matches)-1][1])
+}
+
+// runPlanReview critiques the latest plan. A REPLAN verdict re-runs the plan
+// step and reviews the new plan, up to max_replans times per iteration; after
+// that the latest plan is implemented anyway. It owns its failure transitions
+// because a failed re-plan leaves the job in planning.
+func (r *Runner) runPlanReview(ctx context.Context, jobID string, issue db.Issue, projectCfg *config.ProjectConfig, workDir string) error {
+	maxReplans := config.DefaultMaxReplans
+	if projectCfg.PlanReview != nil && projectCfg.PlanReview.MaxReplans > 0 {
+		maxReplans = projectCfg.PlanReview.MaxReplans
+	}
+
+	for {
+		verdict, err := r.reviewPlan(ctx, jobID, issue, projectCfg, workDir)
+		if err != nil {
+			return r.failPlanReview(ctx, jobID, "reviewing_plan", err)
+		}
+		switch verdict {
+		case planVerdictApprove:
+			slog.Info("plan review approved", "job", jobID)
+			return nil
+		case planVerdictInfeasible:
+			slog.Info("plan review judged issue infeasible", "job", jobID)
+			return errPlanInfeasible
+		}
+
+		job, err := r.store.GetJob(ctx, jobID)
+		if err != nil {
+			return err
+		}
+		plans, err := r.store.CountCompletedSessionsForStep(ctx, jobID, job.Iteration, "plan")
+		if err != nil {
+			return err
+		}
+		if plans-1 >= maxReplans {
+			slog.Info("max re-plans reached, implementing latest plan", "job", jobID, "replans", plans-1)
+			return nil
+		}
+
+		slog.Info("plan review requested a re-plan", "job", jobID, "replan", plans)
+		if err := r.store.TransitionState(ctx, jobID, "reviewing_plan", "planning"); err != nil {
+			if r.jobCancelled(jobID) {
+				return errJobCancelled
+			}
+			return err
+		}
+		if err := r.runPlan(ctx, jobID, issue, projectCfg, workDir); err != nil {
+			if errors.Is(err, errNeedsInfo) {
+				return err
+			}
+			return r.failPlanReview(ctx, jobID, "planning", err)
+		}
+		if err := r.store.TransitionState(ctx, jobID, "planning", "reviewing_plan"); err != nil {
+			if r.jobCancelled(jobID) {
+				return errJobCancelled
+			}
+			return err
+		}
+	}
+}
+
+// reviewPlan runs one plan_review session on the latest plan, stores the
+// review as an artifact and returns its verdict.
+func (r *Runner) reviewPlan(ctx context.Context, jobID string, issue db.Issue, projectCfg *config.ProjectConfig, workDir string) (string, error) {
+	job, err := r.store.GetJob(ctx, jobID)
+	if err != nil {
+		return "", err
+	}
+
+	planArtifact, err := r.store.GetLatestArtifact(ctx, jobID, "plan")
+	if err != nil {
+		return "", fmt.Errorf("get plan for plan review: %w", err)
+	}
+
+	template := defaultPlanReviewPrompt
+	if projectCfg.Prompts != nil && projectCfg.Prompts.PlanReview != "" {
+		if custom := LoadTemplate(projectCfg.Prompts.PlanReview); custom != "" {
+			template = custom
+		}
+	}
+
+	prompt := BuildPrompt(template, map[string]string{
+		"title": issue.Title,
+		"body":  SanitizeIssueContent(issue.Body),
+		"plan":  planArtifact.Content,
+	})
+
+	resp, err := r.invokeProvider(ctx, jobID, "plan_review", job.Iteration, workDir, prompt)
+	if err != nil {
+		return "", fmt.Errorf("plan review step: %w", err)
+	}
+
+	_, err = r.store.CreateArtifact(ctx, jobID, issue.AutoPRIssueID, "plan_review", resp.Text, job.Iteration, "")
+	if err != nil {
+		return "", fmt.Errorf("store plan review artifact: %w", err)
+	}
+	return parsePlanVerdict(resp.Text), nil
+}
+
+func (r *Runner) failPlanReview(ctx context.Context, jobID, state string, err error) error {
+	if r.isJobCancelledError(ctx, jobID, err) {
+		return errJobCancelled
+	}
+	return r.failJob(ctx, jobID, state, failureReason(err))
+}
+
+// stopForHuman moves a job to the terminal needs_human state, recording why.
+func (r *Runner) stopForHuman(ctx context.Context, jobID, fromState, reason string) error {
+	if err := r.store.TransitionState(ctx, jobID, fromState, "needs_human"); err != nil {
+		if r.jobCancelled(jobID) {
+			return errJobCancelled
+		}
+		return err
+	}
+	if err := r.store.UpdateJobField(ctx, jobID, "error_message", reason); err != nil {
+		return err
+	}
+	slog.Info("job needs a human", "job", jobID, "reason", reason)
+	return nil
+}
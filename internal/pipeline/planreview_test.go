@@ -0,0 +1,149 @@
+package pipeline
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"autopr/internal/config"
+	"autopr/internal/llm"
+)
+
+// planReviewProvider answers plan review prompts with the scripted verdicts in
+// order (repeating the last one) and every other prompt with an approving
+// code review.
+type planReviewProvider struct {
+	mu          sync.Mutex
+	verdicts    []string
+	planPrompts []string
+}
+
+func (p *planReviewProvider) Name() string { return "codex" }
+
+func (p *planReviewProvider) Run(ctx context.Context, workDir, prompt, jsonlPath string) (llm.Response, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	text := approvedReview
+	switch {
+	case strings.Contains(prompt, "VERDICT: APPROVE"):
+		text = "looks fine\nVERDICT: " + p.verdicts[0]
+		if len(p.verdicts) > 1 {
+			p.verdicts = p.verdicts[1:]
+		}
+	case strings.Contains(prompt, "create a detailed implementation plan"):
+		p.planPrompts = append(p.planPrompts, prompt)
+		text = "the plan"
+	}
+	return llm.Response{Text: text, InputTokens: 1, OutputTokens: 1, DurationMS: 1}, nil
+}
+
+func planReviewProjectConfig(maxReplans int) *config.ProjectConfig {
+	projectCfg := testProjectConfigWithoutRebase()
+	projectCfg.PlanReview = &config.ProjectPlanReview{Enabled: true, MaxReplans: maxReplans}
+	return projectCfg
+}
+
+func TestParsePlanVerdict(t *testing.T) {
+	t.Parallel()
+	cases := map[string]string{
+		"The plan is solid.\nVERDICT: APPROVE":                  planVerdictApprove,
+		"Missing tests.\n**Verdict:** replan":                   planVerdictReplan,
+		"VERDICT: APPROVE would be wrong.\nVERDICT: INFEASIBLE": planVerdictInfeasible,
+		"No verdict given.":                                     planVerdictReplan,
+	}
+	for text, want := range cases {
+		if got := parsePlanVerdict(text); got != want {
+			t.Errorf("parsePlanVerdict(%q) = %q, want %q", text, got, want)
+		}
+	}
+}
+
+func TestRunStepsPlanReviewApprovesAndImplements(t *testing.T) {
+	t.Parallel()
+	provider := &planReviewProvider{verdicts: []string{"APPROVE"}}
+	runner, store, issue, jobID := setupRunStepsJob(t, provider, "planning")
+	ctx := context.Background()
+
+	if err := runner.runSteps(ctx, jobID, "planning", issue, planReviewProjectConfig(2), t.TempDir()); err == nil {
+		t.Fatalf("expected testing-stage failure")
+	}
+
+	if got := sessionCountForStep(t, store, ctx, jobID, "plan_review"); got != 1 {
+		t.Fatalf("expected one plan review, got %d", got)
+	}
+	if got := sessionCountForStep(t, store, ctx, jobID, "implement"); got != 1 {
+		t.Fatalf("expected implement after approved plan, got %d sessions", got)
+	}
+	review, err := store.GetLatestArtifact(ctx, jobID, "plan_review")
+	if err != nil || !strings.HasSuffix(review.Content, "VERDICT: APPROVE") {
+		t.Fatalf("expected stored plan_review artifact, got %+v (err %v)", review, err)
+	}
+}
+
+func TestRunStepsPlanReviewReplansWithFeedback(t *testing.T) {
+	t.Parallel()
+	provider := &planReviewProvider{verdicts: []string{"REPLAN", "APPROVE"}}
+	runner, store, issue, jobID := setupRunStepsJob(t, provider, "planning")
+	ctx := context.Background()
+
+	if err := runner.runSteps(ctx, jobID, "planning", issue, planReviewProjectConfig(2), t.TempDir()); err == nil {
+		t.Fatalf("expected testing-stage failure")
+	}
+
+	if got := sessionCountForStep(t, store, ctx, jobID, "plan"); got != 2 {
+		t.Fatalf("expected one re-plan, got %d plan sessions", got)
+	}
+	if got := sessionCountForStep(t, store, ctx, jobID, "plan_review"); got != 2 {
+		t.Fatalf("expected the new plan to be reviewed, got %d reviews", got)
+	}
+	if strings.Contains(provider.planPrompts[0], "<plan_review_feedback>") {
+		t.Fatalf("first plan prompt should not carry review feedback")
+	}
+	if !strings.Contains(provider.planPrompts[1], "looks fine\nVERDICT: REPLAN") {
+		t.Fatalf("expected re-plan prompt to include the review, got:\n%s", provider.planPrompts[1])
+	}
+}
+
+func TestRunStepsPlanReviewImplementsAfterMaxReplans(t *testing.T) {
+	t.Parallel()
+	provider := &planReviewProvider{verdicts: []string{"REPLAN"}}
+	runner, store, issue, jobID := setupRunStepsJob(t, provider, "planning")
+	ctx := context.Background()
+
+	if err := runner.runSteps(ctx, jobID, "planning", issue, planReviewProjectConfig(1), t.TempDir()); err == nil {
+		t.Fatalf("expected testing-stage failure")
+	}
+
+	if got := sessionCountForStep(t, store, ctx, jobID, "plan"); got != 2 {
+		t.Fatalf("expected re-plans to stop at max_replans, got %d plan sessions", got)
+	}
+	if got := sessionCountForStep(t, store, ctx, jobID, "implement"); got != 1 {
+		t.Fatalf("expected the latest plan to be implemented, got %d sessions", got)
+	}
+}
+
+func TestRunStepsPlanReviewInfeasibleNeedsHuman(t *testing.T) {
+	t.Parallel()
+	provider := &planReviewProvider{verdicts: []string{"INFEASIBLE"}}
+	runner, store, issue, jobID := setupRunStepsJob(t, provider, "planning")
+	ctx := context.Background()
+
+	if err := runner.runSteps(ctx, jobID, "planning", issue, planReviewProjectConfig(2), t.TempDir()); err != nil {
+		t.Fatalf("runSteps: %v", err)
+	}
+
+	job, err := store.GetJob(ctx, jobID)
+	if err != nil {
+		t.Fatalf("get job: %v", err)
+	}
+	if job.State != "needs_human" || job.ErrorMessage != errPlanInfeasible.Error() {
+		t.Fatalf("expected needs_human with reason, got state %q error %q", job.State, job.ErrorMessage)
+	}
+	if got := sessionCountForStep(t, store, ctx, jobID, "implement"); got != 0 {
+		t.Fatalf("expected no implement session, got %d", got)
+	}
+	if err := store.ResetJobForRetry(ctx, jobID, "clarified the issue"); err != nil {
+		t.Fatalf("expected needs_human job to be retryable: %v", err)
+	}
+}
@@ -193,7 +193,7 @@ func (r *Runner) runRebaseBeforeReady(ctx context.Context, jobID string, issue d
 		if r.isJobCancelledError(ctx, jobID, err) {
 			return errJobCancelled
 		}
-		return r.failJob(ctx, jobID, "resolving_conflicts", err.Error())
+		return r.failJob(ctx, jobID, "resolving_conflicts", failureReason(err))
 	}
 
 	return r.rerunTestsAndMarkReady(ctx, jobID, issue, projectCfg, workDir, "resolving_conflicts")
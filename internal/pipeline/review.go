@@ -0,0 +1,183 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"autopr/internal/config"
+)
+
+// Code review decisions.
+const (
+	ReviewApprove        = "approve"
+	ReviewRequestChanges = "request_changes"
+)
+
+// ReviewSeverities are the finding severities, most severe first. A blocker
+// finding keeps a review from approving whatever its decision says.
+var ReviewSeverities = []string{"blocker", "major", "minor", "nit"}
+
+// ReviewFinding is one problem raised by code review.
+type ReviewFinding struct {
+	Severity string `json:"severity"`
+	File     string `json:"file,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Message  string `json:"message"`
+	Reviewer string `json:"reviewer,omitempty"` // set in a combined verdict
+}
+
+// ReviewVerdict is the machine-readable verdict block that ends a code
+// review. It is stored as the code_review artifact's data. With several
+// reviewers, each review records its reviewer and the combined verdict
+// records the policy that produced its decision.
+type ReviewVerdict struct {
+	Decision   string          `json:"decision"`
+	Confidence float64         `json:"confidence"`
+	Summary    string          `json:"summary,omitempty"`
+	Findings   []ReviewFinding `json:"findings"`
+	Reviewer   string          `json:"reviewer,omitempty"`
+	Policy     string          `json:"policy,omitempty"`
+}
+
+// Approved reports whether the verdict lets the job move on to testing. A
+// combined verdict's decision has already weighed the findings.
+func (v ReviewVerdict) Approved() bool {
+	if v.Decision != ReviewApprove {
+		return false
+	}
+	return v.Policy != "" || !v.hasBlocker()
+}
+
+func (v ReviewVerdict) hasBlocker() bool {
+	return slices.ContainsFunc(v.Findings, func(f ReviewFinding) bool { return f.Severity == "blocker" })
+}
+
+// combineReviews merges the verdicts of several reviewers under policy: all
+// must approve, a majority must approve, or no blocker finding (veto). The
+// combined verdict carries every finding, tagged with its reviewer.
+func combineReviews(policy string, reviews []ReviewVerdict) ReviewVerdict {
+	combined := ReviewVerdict{Policy: policy, Findings: []ReviewFinding{}}
+	approvals := 0
+	vetoed := false
+	for _, v := range reviews {
+		if v.Approved() {
+			approvals++
+		}
+		vetoed = vetoed || v.hasBlocker()
+		combined.Confidence += v.Confidence / float64(len(reviews))
+		for _, f := range v.Findings {
+			f.Reviewer = v.Reviewer
+			combined.Findings = append(combined.Findings, f)
+		}
+	}
+
+	var approved bool
+	switch policy {
+	case config.ReviewPolicyMajority:
+		approved = approvals*2 > len(reviews)
+	case config.ReviewPolicyVeto:
+		approved = !vetoed
+	default:
+		approved = approvals == len(reviews)
+	}
+	combined.Decision = ReviewRequestChanges
+	if approved {
+		combined.Decision = ReviewApprove
+	}
+	combined.Summary = fmt.Sprintf("%d of %d reviewers approved (policy: %s)", approvals, len(reviews), policy)
+	return combined
+}
+
+// reviewPersonaFocus narrows a reviewer to one concern. Other concerns are
+// left to the other reviewers.
+var reviewPersonaFocus = map[string]string{
+	"security":    "Focus on security: injection, authentication or authorization bypasses, secret exposure, and unsafe file, process or network access.",
+	"correctness": "Focus on correctness: whether the changes solve the issue, and bugs, unhandled errors, edge cases or data loss.",
+	"tests":       "Focus on tests: whether the changes are covered by tests that would fail without them, including edge cases and failure paths.",
+}
+
+// reviewFocus returns the {{review_focus}} block for a reviewer persona.
+func reviewFocus(persona string) string {
+	focus, ok := reviewPersonaFocus[persona]
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("<review_focus>\n%s Other reviewers cover the remaining concerns.\n</review_focus>", focus)
+}
+
+var reviewVerdictBlockRe = regexp.MustCompile("(?s)```json\\s*(\\{.*?\\})\\s*```")
+
+// parseReviewVerdict extracts and validates the last ```json verdict block in
+// a review. A reply that is only the JSON object is accepted too.
+func parseReviewVerdict(text string) (ReviewVerdict, error) {
+	raw := strings.TrimSpace(text)
+	if matches := reviewVerdictBlockRe.FindAllStringSubmatch(text, -1); len(matches) > 0 {
+		raw = matches[len(matches)-1][1]
+	} else if !strings.HasPrefix(raw, "{") {
+		return ReviewVerdict{}, errors.New("no ```json verdict block found")
+	}
+
+	var v ReviewVerdict
+	if err := json.Unmarshal([]byte(raw), &v); err != nil {
+		return ReviewVerdict{}, fmt.Errorf("invalid verdict JSON: %w", err)
+	}
+	v.Decision = strings.ToLower(strings.TrimSpace(v.Decision))
+	if v.Decision != ReviewApprove && v.Decision != ReviewRequestChanges {
+		return ReviewVerdict{}, fmt.Errorf("decision must be %q or %q, got %q", ReviewApprove, ReviewRequestChanges, v.Decision)
+	}
+	if v.Confidence < 0 || v.Confidence > 1 {
+		return ReviewVerdict{}, fmt.Errorf("confidence must be between 0 and 1, got %v", v.Confidence)
+	}
+	for i := range v.Findings {
+		f := &v.Findings[i]
+		f.Severity = strings.ToLower(strings.TrimSpace(f.Severity))
+		f.File = strings.TrimSpace(f.File)
+		f.Message = strings.TrimSpace(f.Message)
+		if !slices.Contains(ReviewSeverities, f.Severity) {
+			return ReviewVerdict{}, fmt.Errorf("finding %d: severity must be one of %s, got %q", i+1, strings.Join(ReviewSeverities, ", "), f.Severity)
+		}
+		if f.Message == "" {
+			return ReviewVerdict{}, fmt.Errorf("finding %d: message is required", i+1)
+		}
+		if f.Line < 0 || (f.Line > 0 && f.File == "") {
+			return ReviewVerdict{}, fmt.Errorf("finding %d: line needs a file and must be positive", i+1)
+		}
+	}
+	if v.Decision == ReviewRequestChanges && len(v.Findings) == 0 {
+		return ReviewVerdict{}, errors.New("request_changes needs at least one finding")
+	}
+	return v, nil
+}
+
+// FormatReviewChecklist renders a verdict as a checklist of findings, most
+// severe first.
+func FormatReviewChecklist(v ReviewVerdict) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Decision: %s (confidence %.2f)\n", strings.ReplaceAll(v.Decision, "_", " "), v.Confidence)
+	if v.Summary != "" {
+		fmt.Fprintf(&b, "%s\n", v.Summary)
+	}
+	findings := slices.Clone(v.Findings)
+	slices.SortStableFunc(findings, func(a, b ReviewFinding) int {
+		return slices.Index(ReviewSeverities, a.Severity) - slices.Index(ReviewSeverities, b.Severity)
+	})
+	for _, f := range findings {
+		location := ""
+		switch {
+		case f.File != "" && f.Line > 0:
+			location = fmt.Sprintf(" %s:%d", f.File, f.Line)
+		case f.File != "":
+			location = " " + f.File
+		}
+		reviewer := ""
+		if f.Reviewer != "" {
+			reviewer = fmt.Sprintf(" (%s)", f.Reviewer)
+		}
+		fmt.Fprintf(&b, "- [ ] [%s]%s — %s%s\n", f.Severity, location, f.Message, reviewer)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
@@ -0,0 +1,244 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"autopr/internal/config"
+	"autopr/internal/llm"
+)
+
+func TestParseReviewVerdict(t *testing.T) {
+	t.Parallel()
+	valid := "The diff misses a nil check.\n```json\n" +
+		`{"decision": "Request_Changes", "confidence": 0.8, "findings": [{"severity": "Major", "file": "main.go", "line": 12, "message": "check err"}]}` +
+		"\n```"
+	v, err := parseReviewVerdict(valid)
+	if err != nil {
+		t.Fatalf("parse valid verdict: %v", err)
+	}
+	if v.Decision != ReviewRequestChanges || v.Approved() || v.Findings[0].Severity != "major" || v.Findings[0].Line != 12 {
+		t.Fatalf("unexpected verdict: %+v", v)
+	}
+
+	bare, err := parseReviewVerdict(`{"decision": "approve", "confidence": 1, "findings": []}`)
+	if err != nil || !bare.Approved() {
+		t.Fatalf("expected bare JSON approval, got %+v (err %v)", bare, err)
+	}
+
+	blocker, err := parseReviewVerdict("```json\n" + `{"decision": "approve", "confidence": 0.5, "findings": [{"severity": "blocker", "message": "drops data"}]}` + "\n```")
+	if err != nil || blocker.Approved() {
+		t.Fatalf("expected a blocker finding to prevent approval, got %+v (err %v)", blocker, err)
+	}
+
+	malformed := map[string]string{
+		"This would be approved if the tests passed. APPROVED":                                                                "no ```json verdict block",
+		"```json\n{\"decision\": \"lgtm\"}\n```":                                                                              "decision must be",
+		"```json\n{\"decision\": \"approve\", \"confidence\": 2}\n```":                                                        "confidence",
+		"```json\n{\"decision\": \"request_changes\", \"findings\": [{\"severity\": \"huge\", \"message\": \"x\"}]}\n```":     "severity",
+		"```json\n{\"decision\": \"request_changes\", \"findings\": []}\n```":                                                 "at least one finding",
+		"```json\n{\"decision\": \"approve\", \"findings\": [{\"severity\": \"nit\", \"line\": 3, \"message\": \"x\"}]}\n```": "line needs a file",
+	}
+	for text, want := range malformed {
+		if _, err := parseReviewVerdict(text); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("parseReviewVerdict(%q) error = %v, want %q", text, err, want)
+		}
+	}
+}
+
+func TestFormatReviewChecklistOrdersBySeverity(t *testing.T) {
+	t.Parallel()
+	got := FormatReviewChecklist(ReviewVerdict{
+		Decision:   ReviewRequestChanges,
+		Confidence: 0.75,
+		Findings: []ReviewFinding{
+			{Severity: "nit", Message: "typo"},
+			{Severity: "blocker", File: "db.go", Line: 7, Message: "drops rows"},
+			{Severity: "minor", File: "README.md", Message: "stale docs"},
+		},
+	})
+	want := "Decision: request changes (confidence 0.75)\n" +
+		"- [ ] [blocker] db.go:7 — drops rows\n" +
+		"- [ ] [minor] README.md — stale docs\n" +
+		"- [ ] [nit] — typo"
+	if got != want {
+		t.Fatalf("unexpected checklist:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRunCodeReviewRepairsMalformedVerdict(t *testing.T) {
+	t.Parallel()
+	var prompts []string
+	provider := stubProvider{run: func(ctx context.Context, workDir, prompt string) (llm.Response, error) {
+		prompts = append(prompts, prompt)
+		text := "Looks approved to me."
+		if len(prompts) == 2 {
+			text = "```json\n" + `{"decision": "request_changes", "confidence": 0.6, "findings": [{"severity": "major", "file": "a.go", "line": 3, "message": "handle the error"}]}` + "\n```"
+		}
+		return llm.Response{Text: text, InputTokens: 1, OutputTokens: 1, DurationMS: 1}, nil
+	}}
+	runner, store, issue, jobID := setupRunStepsJob(t, provider, "reviewing")
+	ctx := context.Background()
+	setupArtifactPrefix(t, store, jobID, issue.AutoPRIssueID)
+
+	err := runner.runCodeReview(ctx, jobID, issue, testProjectConfigWithoutRebase(), t.TempDir())
+	if !errors.Is(err, errReviewChangesRequested) {
+		t.Fatalf("expected changes requested after repair, got %v", err)
+	}
+	if len(prompts) != 2 || !strings.Contains(prompts[1], "no ```json verdict block found") {
+		t.Fatalf("expected a repair prompt naming the problem, got %d prompts", len(prompts))
+	}
+
+	review, err := store.GetLatestArtifact(ctx, jobID, "code_review")
+	if err != nil {
+		t.Fatalf("get review artifact: %v", err)
+	}
+	var verdict ReviewVerdict
+	if err := json.Unmarshal([]byte(review.Data), &verdict); err != nil || len(verdict.Findings) != 1 {
+		t.Fatalf("expected structured findings in artifact data, got %q (err %v)", review.Data, err)
+	}
+	if review.Content != "Looks approved to me." {
+		t.Fatalf("expected the original review as artifact content, got %q", review.Content)
+	}
+	if got := reviewFeedbackText(review); got != "Decision: request changes (confidence 0.60)\n- [ ] [major] a.go:3 — handle the error" {
+		t.Fatalf("unexpected implement feedback:\n%s", got)
+	}
+}
+
+func TestRunCodeReviewFailsWhenRepairIsMalformed(t *testing.T) {
+	t.Parallel()
+	provider := stubProvider{run: func(ctx context.Context, workDir, prompt string) (llm.Response, error) {
+		return llm.Response{Text: "APPROVED", InputTokens: 1, OutputTokens: 1, DurationMS: 1}, nil
+	}}
+	runner, store, issue, jobID := setupRunStepsJob(t, provider, "reviewing")
+	ctx := context.Background()
+	setupArtifactPrefix(t, store, jobID, issue.AutoPRIssueID)
+
+	err := runner.runCodeReview(ctx, jobID, issue, testProjectConfigWithoutRebase(), t.TempDir())
+	if err == nil || errors.Is(err, errReviewChangesRequested) || !strings.Contains(err.Error(), "malformed after repair") {
+		t.Fatalf("expected malformed verdict error, got %v", err)
+	}
+	if got := sessionCountForStep(t, store, ctx, jobID, "code_review"); got != 2 {
+		t.Fatalf("expected review and one repair session, got %d", got)
+	}
+	review, err := store.GetLatestArtifact(ctx, jobID, "code_review")
+	if err != nil || review.Data != "" {
+		t.Fatalf("expected review stored without data, got %+v (err %v)", review, err)
+	}
+}
+
+func TestCombineReviews(t *testing.T) {
+	t.Parallel()
+	approve := ReviewVerdict{Reviewer: "correctness", Decision: ReviewApprove, Confidence: 0.9, Findings: []ReviewFinding{}}
+	changes := ReviewVerdict{Reviewer: "tests", Decision: ReviewRequestChanges, Confidence: 0.6, Findings: []ReviewFinding{{Severity: "major", Message: "no test for the error path"}}}
+	blocker := ReviewVerdict{Reviewer: "security", Decision: ReviewApprove, Confidence: 0.9, Findings: []ReviewFinding{{Severity: "blocker", File: "auth.go", Message: "token logged"}}}
+
+	cases := []struct {
+		policy  string
+		reviews []ReviewVerdict
+		want    bool
+	}{
+		{config.ReviewPolicyAll, []ReviewVerdict{approve, approve}, true},
+		{config.ReviewPolicyAll, []ReviewVerdict{approve, changes}, false},
+		{config.ReviewPolicyMajority, []ReviewVerdict{approve, approve, changes}, true},
+		{config.ReviewPolicyMajority, []ReviewVerdict{approve, changes}, false},
+		{config.ReviewPolicyMajority, []ReviewVerdict{approve, approve, blocker}, true},
+		{config.ReviewPolicyVeto, []ReviewVerdict{approve, changes}, true},
+		{config.ReviewPolicyVeto, []ReviewVerdict{approve, approve, blocker}, false},
+	}
+	for i, tc := range cases {
+		got := combineReviews(tc.policy, tc.reviews)
+		if got.Approved() != tc.want {
+			t.Errorf("case %d (%s): approved = %v, want %v (%s)", i, tc.policy, got.Approved(), tc.want, got.Summary)
+		}
+	}
+
+	combined := combineReviews(config.ReviewPolicyVeto, []ReviewVerdict{approve, changes, blocker})
+	want := "Decision: request changes (confidence 0.80)\n" +
+		"1 of 3 reviewers approved (policy: veto)\n" +
+		"- [ ] [blocker] auth.go — token logged (security)\n" +
+		"- [ ] [major] — no test for the error path (tests)"
+	if got := FormatReviewChecklist(combined); got != want {
+		t.Fatalf("unexpected combined checklist:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRunCodeReviewWithSeveralReviewers(t *testing.T) {
+	t.Parallel()
+	var mu sync.Mutex
+	prompts := map[string]string{}
+	runner, store, issue, jobID := setupRunStepsJob(t, namedStubProvider{name: "claude"}, "reviewing")
+	runner.cfg = &config.Config{
+		LLM:      config.LLMConfig{Provider: "claude"},
+		Projects: []config.ProjectConfig{{Name: "myproject"}},
+	}
+	runner.providerFor = func(route config.LLMRoute) (llm.Provider, error) {
+		return stubProvider{run: func(ctx context.Context, workDir, prompt string) (llm.Response, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			prompts[route.Provider+"/"+route.Model] = prompt
+			text := approvedReview
+			if route.Provider == "codex" {
+				text = "```json\n" + `{"decision": "request_changes", "confidence": 0.5, "findings": [{"severity": "major", "file": "a.go", "line": 3, "message": "handle the error"}]}` + "\n```"
+			}
+			return llm.Response{Text: text, InputTokens: 1, OutputTokens: 1, DurationMS: 1}, nil
+		}}, nil
+	}
+	ctx := context.Background()
+	setupArtifactPrefix(t, store, jobID, issue.AutoPRIssueID)
+
+	projectCfg := testProjectConfigWithoutRebase()
+	projectCfg.CodeReview = &config.ProjectCodeReview{
+		Policy: config.ReviewPolicyAll,
+		Reviewers: []config.CodeReviewer{
+			{Name: "security", Persona: "security", Model: "opus"},
+			{Name: "second-opinion", Provider: "codex", Model: "gpt-5"},
+		},
+	}
+
+	err := runner.runCodeReview(ctx, jobID, issue, projectCfg, t.TempDir())
+	if !errors.Is(err, errReviewChangesRequested) {
+		t.Fatalf("expected the codex reviewer to block an all-must-approve policy, got %v", err)
+	}
+	if !strings.Contains(prompts["claude/opus"], "Focus on security") || strings.Contains(prompts["codex/gpt-5"], "<review_focus>") {
+		t.Fatalf("expected only the security reviewer to get a focus, got %v", prompts)
+	}
+
+	sessions, err := store.ListSessionsByJob(ctx, jobID)
+	if err != nil {
+		t.Fatalf("list sessions: %v", err)
+	}
+	if len(sessions) != 2 || sessions[0].Step != "code_review" || sessions[0].Model != "opus" || sessions[1].LLMProvider != "codex" {
+		t.Fatalf("expected one code_review session per reviewer, got %+v", sessions)
+	}
+
+	artifacts, err := store.ListArtifactsByJob(ctx, jobID)
+	if err != nil {
+		t.Fatalf("list artifacts: %v", err)
+	}
+	var reviewers []string
+	for _, a := range artifacts {
+		var v ReviewVerdict
+		if a.Kind == "code_review" && json.Unmarshal([]byte(a.Data), &v) == nil {
+			reviewers = append(reviewers, v.Reviewer+v.Policy)
+		}
+	}
+	if strings.Join(reviewers, ",") != "security,second-opinion,all" {
+		t.Fatalf("expected an artifact per reviewer and a combined one, got %v", reviewers)
+	}
+
+	review, err := store.GetLatestArtifact(ctx, jobID, "code_review")
+	if err != nil {
+		t.Fatalf("get review artifact: %v", err)
+	}
+	want := "Decision: request changes (confidence 0.70)\n" +
+		"1 of 2 reviewers approved (policy: all)\n" +
+		"- [ ] [major] a.go:3 — handle the error (second-opinion)"
+	if got := reviewFeedbackText(review); got != want {
+		t.Fatalf("unexpected combined feedback:\n%s\nwant:\n%s", got, want)
+	}
+}
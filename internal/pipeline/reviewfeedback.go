@@ -0,0 +1,338 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"autopr/internal/config"
+	"autopr/internal/db"
+	"autopr/internal/git"
+)
+
+// reviewFeedbackArtifactKind artifacts record the PR review comments a round
+// addresses (status requested) and the reply posted on the PR once the
+// round's changes are pushed (status addressed).
+const reviewFeedbackArtifactKind = "review_feedback"
+
+// Review feedback statuses, stored as the status of a review_feedback artifact.
+const (
+	reviewFeedbackRequested = "requested"
+	reviewFeedbackAddressed = "addressed"
+)
+
+// ReviewReplyMarker tags AutoPR's replies on a PR/MR so the syncer does not
+// take them for review feedback.
+const ReviewReplyMarker = "<!-- autopr:review-reply -->"
+
+// ReviewFeedbackData is the JSON payload of review_feedback artifacts.
+type ReviewFeedbackData struct {
+	// CommentIDs and Until describe requested feedback: the comments of the
+	// round and the creation time of the newest one. The syncer looks for new
+	// feedback after Until.
+	CommentIDs []int64   `json:"comment_ids,omitempty"`
+	Until      time.Time `json:"until,omitzero"`
+	// ReplyID is the PR comment posted for addressed feedback.
+	ReplyID int64 `json:"reply_id,omitempty"`
+}
+
+// ReviewRounds returns how many review rounds a job has been requeued for
+// and the creation time of the newest review comment they covered.
+func ReviewRounds(ctx context.Context, store *db.Store, jobID string) (int, time.Time, error) {
+	artifacts, err := store.ListArtifactsByJob(ctx, jobID)
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("list review feedback: %w", err)
+	}
+	rounds := 0
+	var until time.Time
+	for _, a := range artifacts {
+		if a.Kind != reviewFeedbackArtifactKind || a.Status != reviewFeedbackRequested {
+			continue
+		}
+		rounds++
+		var data ReviewFeedbackData
+		if json.Unmarshal([]byte(a.Data), &data) == nil && data.Until.After(until) {
+			until = data.Until
+		}
+	}
+	return rounds, until, nil
+}
+
+// RequestReviewRound records new review comments on an approved job's PR and
+// requeues the job. The worker addresses them in a new iteration on the same
+// branch, pushes and replies on the PR.
+func RequestReviewRound(ctx context.Context, store *db.Store, jobID string, comments []git.ReviewComment) error {
+	if len(comments) == 0 {
+		return fmt.Errorf("no review comments to address")
+	}
+	job, err := store.GetJob(ctx, jobID)
+	if err != nil {
+		return err
+	}
+	if job.State != "approved" || job.PRURL == "" {
+		return fmt.Errorf("job %s is in state %q, must be 'approved' with a PR", jobID, job.State)
+	}
+
+	data := ReviewFeedbackData{}
+	for _, c := range comments {
+		data.CommentIDs = append(data.CommentIDs, c.ID)
+		if c.CreatedAt.After(data.Until) {
+			data.Until = c.CreatedAt
+		}
+	}
+	payload, _ := json.Marshal(data)
+	if _, err := store.InsertArtifact(ctx, db.Artifact{
+		JobID:         jobID,
+		AutoPRIssueID: job.AutoPRIssueID,
+		Kind:          reviewFeedbackArtifactKind,
+		Content:       formatReviewComments(comments),
+		Iteration:     job.Iteration + 1,
+		Status:        reviewFeedbackRequested,
+		Data:          string(payload),
+	}); err != nil {
+		return fmt.Errorf("store review feedback: %w", err)
+	}
+	return store.TransitionState(ctx, jobID, "approved", "queued")
+}
+
+// formatReviewComments renders review comments for the artifact and the
+// implement prompt.
+func formatReviewComments(comments []git.ReviewComment) string {
+	var b strings.Builder
+	for _, c := range comments {
+		switch {
+		case c.Path != "" && c.Line > 0:
+			fmt.Fprintf(&b, "@%s on %s:%d:\n", c.Author, c.Path, c.Line)
+		case c.Path != "":
+			fmt.Fprintf(&b, "@%s on %s:\n", c.Author, c.Path)
+		default:
+			fmt.Fprintf(&b, "@%s:\n", c.Author)
+		}
+		fmt.Fprintf(&b, "%s\n\n", strings.TrimSpace(c.Body))
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// pendingReviewFeedback returns the review comments a requeued job has to
+// address, if the latest review round has not been addressed yet.
+func (r *Runner) pendingReviewFeedback(ctx context.Context, jobID string) (db.Artifact, bool) {
+	a, err := r.store.GetLatestArtifact(ctx, jobID, reviewFeedbackArtifactKind)
+	if err != nil || a.Status != reviewFeedbackRequested {
+		return db.Artifact{}, false
+	}
+	return a, true
+}
+
+// prReviewFeedback returns the PR review comments block of the implement
+// prompt while a review round is in progress.
+func (r *Runner) prReviewFeedback(ctx context.Context, jobID string) string {
+	a, ok := r.pendingReviewFeedback(ctx, jobID)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("\n\n<pr_review_comments>\nReviewers left these comments on the pull request. Address each of them:\n%s\n</pr_review_comments>", SanitizeIssueContent(a.Content))
+}
+
+// runReviewRound addresses the review comments of a requeued job with an open
+// PR: it re-enters implementing in a new iteration on the same branch, with
+// its own allowance of max_iterations. Once the round reaches ready with its
+// code review and tests passed it pushes with lease, replies on the PR with
+// what changed and returns the job to awaiting_checks (GitHub) or approved.
+// A round that does not get there is not pushed, and the reply on the PR says
+// so.
+func (r *Runner) runReviewRound(ctx context.Context, jobID string, issue db.Issue, projectCfg *config.ProjectConfig, workDir string, feedback db.Artifact) error {
+	pushed, err := r.pushReviewRound(ctx, jobID, issue, projectCfg, workDir, feedback)
+	if !pushed && !errors.Is(err, errJobCancelled) && !r.jobCancelled(jobID) {
+		r.replyReviewRoundFailed(ctx, jobID, projectCfg, err)
+	}
+	return err
+}
+
+func (r *Runner) pushReviewRound(ctx context.Context, jobID string, issue db.Issue, projectCfg *config.ProjectConfig, workDir string, feedback db.Artifact) (bool, error) {
+	job, err := r.store.GetJob(ctx, jobID)
+	if err != nil {
+		return false, err
+	}
+	baseSHA, err := git.LatestCommit(ctx, workDir)
+	if err != nil {
+		return false, r.failJob(ctx, jobID, "planning", "review round: "+err.Error())
+	}
+	iterations := job.MaxIterations
+	if r.cfg != nil && r.cfg.Daemon.MaxIterations > 0 {
+		iterations = r.cfg.Daemon.MaxIterations
+	}
+	if err := r.store.StartReviewRound(ctx, jobID, iterations); err != nil {
+		if r.jobCancelled(jobID) {
+			return false, errJobCancelled
+		}
+		return false, err
+	}
+	if err := r.store.TransitionState(ctx, jobID, "planning", "implementing"); err != nil {
+		if r.jobCancelled(jobID) {
+			return false, errJobCancelled
+		}
+		return false, err
+	}
+	slog.Info("addressing PR review comments", "job", jobID)
+
+	if err := r.runSteps(ctx, jobID, "implementing", issue, projectCfg, workDir); err != nil {
+		return false, err
+	}
+	job, err = r.store.GetJob(ctx, jobID)
+	if err != nil {
+		return false, err
+	}
+	if job.State != "ready" {
+		return false, nil
+	}
+	// At max_iterations the retry loop moves the job to ready whatever the
+	// outcome, so the round's own review and test results decide the push.
+	if reason := r.reviewRoundFailure(ctx, job, projectCfg); reason != "" {
+		return false, r.stopForHuman(ctx, jobID, "ready", reason)
+	}
+
+	if err := ScanBeforePush(ctx, r.store, job.ID, issue.AutoPRIssueID, projectCfg.BaseBranch, workDir, job.Iteration); err != nil {
+		return false, fmt.Errorf("scan before review round push: %w", err)
+	}
+	token := r.cfg.GitTokenForProject(projectCfg)
+	remoteName := "origin"
+	if projectCfg.GitHub != nil {
+		if remoteName, _, err = r.prepareGitHubPushTarget(ctx, projectCfg, job.BranchName, workDir, token); err != nil {
+			return false, fmt.Errorf("resolve review round push target: %w", err)
+		}
+	}
+	if err := r.pushBranchWithLeaseToRemote(ctx, workDir, remoteName, job.BranchName, token); err != nil {
+		return false, fmt.Errorf("push review round: %w", err)
+	}
+	if sha, err := git.LatestCommit(ctx, workDir); err == nil {
+		_ = r.store.UpdateJobField(ctx, jobID, "commit_sha", sha)
+	}
+
+	nextState := "approved"
+	if projectCfg.GitHub != nil {
+		nextState = "awaiting_checks"
+	}
+	if err := r.store.TransitionState(ctx, jobID, "ready", nextState); err != nil {
+		return true, err
+	}
+
+	var requested ReviewFeedbackData
+	_ = json.Unmarshal([]byte(feedback.Data), &requested)
+	body := r.reviewReply(ctx, job, workDir, baseSHA, len(requested.CommentIDs))
+	addressed := ReviewFeedbackData{}
+	if reply, err := r.postPRCommentFn(ctx, r.cfg, projectCfg, job.PRURL, body); err != nil {
+		slog.Warn("failed to reply on the PR", "job", jobID, "err", err)
+	} else {
+		addressed.ReplyID = reply.ID
+	}
+	data, _ := json.Marshal(addressed)
+	if _, err := r.store.InsertArtifact(ctx, db.Artifact{
+		JobID:         jobID,
+		AutoPRIssueID: issue.AutoPRIssueID,
+		Kind:          reviewFeedbackArtifactKind,
+		Content:       body,
+		Iteration:     job.Iteration,
+		Status:        reviewFeedbackAddressed,
+		Data:          string(data),
+	}); err != nil {
+		return true, fmt.Errorf("store review reply: %w", err)
+	}
+
+	slog.Info("review round pushed", "job", jobID, "pr_url", job.PRURL, "next_state", nextState)
+	return true, nil
+}
+
+// reviewRoundFailure returns why a review round that reached ready must not
+// be pushed: its latest code review did not approve or its tests did not
+// pass. It returns "" when the round passed the checks its pipeline runs.
+func (r *Runner) reviewRoundFailure(ctx context.Context, job db.Job, projectCfg *config.ProjectConfig) string {
+	if projectCfg.HasPipelineStep(config.StepCodeReview) {
+		review, err := r.store.GetLatestArtifact(ctx, job.ID, "code_review")
+		if err != nil || review.Iteration != job.Iteration {
+			return fmt.Sprintf("review round: no code review in iteration %d", job.Iteration)
+		}
+		var verdict ReviewVerdict
+		if json.Unmarshal([]byte(review.Data), &verdict) != nil || !verdict.Approved() {
+			return fmt.Sprintf("review round: code review did not approve iteration %d", job.Iteration)
+		}
+	}
+	if projectCfg.HasPipelineStep(config.StepTests) {
+		tests, err := r.store.GetLatestArtifact(ctx, job.ID, "test_output")
+		if err != nil || tests.Iteration != job.Iteration {
+			return fmt.Sprintf("review round: no test run in iteration %d", job.Iteration)
+		}
+		if tests.Status != commandPassed {
+			return fmt.Sprintf("review round: tests failed in iteration %d", job.Iteration)
+		}
+	}
+	return ""
+}
+
+// replyReviewRoundFailed tells the PR's reviewers that AutoPR did not push a
+// review round, and why.
+func (r *Runner) replyReviewRoundFailed(ctx context.Context, jobID string, projectCfg *config.ProjectConfig, runErr error) {
+	job, err := r.store.GetJob(ctx, jobID)
+	if err != nil {
+		slog.Warn("failed to load job for the review round reply", "job", jobID, "err", err)
+		return
+	}
+	reason := job.ErrorMessage
+	if reason == "" && runErr != nil {
+		reason = runErr.Error()
+	}
+	if reason == "" {
+		reason = "the round did not pass its checks"
+	}
+	body := fmt.Sprintf("AutoPR could not address the review comments in iteration %d and pushed nothing: %s\n\nThe job stopped in `%s`; a maintainer needs to take a look.\n\n_Job `%s`_\n%s\n",
+		job.Iteration, reason, db.DisplayJobState(job), db.ShortID(job.ID), ReviewReplyMarker)
+	if _, err := r.postPRCommentFn(ctx, r.cfg, projectCfg, job.PRURL, body); err != nil {
+		slog.Warn("failed to reply on the PR", "job", jobID, "err", err)
+	}
+}
+
+// reviewReply summarizes a pushed review round for the PR: the commits it
+// added on top of baseSHA.
+func (r *Runner) reviewReply(ctx context.Context, job db.Job, workDir, baseSHA string, comments int) string {
+	var b strings.Builder
+	noun := "comments"
+	if comments == 1 {
+		noun = "comment"
+	}
+	fmt.Fprintf(&b, "AutoPR addressed %d review %s in iteration %d and pushed:\n\n", comments, noun, job.Iteration)
+	subjects, err := git.CommitSubjects(ctx, workDir, baseSHA, "HEAD")
+	if err != nil || len(subjects) == 0 {
+		b.WriteString("- no new commits; the review comments needed no code changes\n")
+	}
+	for _, s := range subjects {
+		fmt.Fprintf(&b, "- %s\n", s)
+	}
+	if tests, err := r.store.GetLatestArtifact(ctx, job.ID, "test_output"); err == nil && tests.Iteration == job.Iteration && tests.Status != "" {
+		fmt.Fprintf(&b, "\nTests: %s\n", tests.Status)
+	}
+	fmt.Fprintf(&b, "\n_Job `%s`_\n%s\n", db.ShortID(job.ID), ReviewReplyMarker)
+	return b.String()
+}
+
+// PostPRComment replies on the job's PR or MR.
+func PostPRComment(ctx context.Context, cfg *config.Config, proj *config.ProjectConfig, prURL, body string) (git.IssueComment, error) {
+	switch {
+	case proj.GitHub != nil:
+		if cfg.Tokens.GitHub == "" {
+			return git.IssueComment{}, fmt.Errorf("GITHUB_TOKEN required to comment on PRs")
+		}
+		return git.PostGitHubPRComment(ctx, cfg.Tokens.GitHub, prURL, body)
+
+	case proj.GitLab != nil:
+		if cfg.Tokens.GitLab == "" {
+			return git.IssueComment{}, fmt.Errorf("GITLAB_TOKEN required to comment on MRs")
+		}
+		return git.PostGitLabMRNote(ctx, cfg.Tokens.GitLab, proj.GitLab.BaseURL, prURL, body)
+
+	default:
+		return git.IssueComment{}, fmt.Errorf("project %q has no GitHub or GitLab config to comment on", proj.Name)
+	}
+}
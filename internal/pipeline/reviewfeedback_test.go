@@ -0,0 +1,252 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"autopr/internal/config"
+	"autopr/internal/db"
+	"autopr/internal/git"
+	"autopr/internal/llm"
+)
+
+// setupReviewRoundJob creates an approved job with an open MR whose review
+// comments have been requested, claimed by the worker again.
+func setupReviewRoundJob(t *testing.T, testCmd string, maxIterations int) (*db.Store, *config.Config, string, []git.ReviewComment) {
+	t.Helper()
+	ctx := context.Background()
+	tmp := t.TempDir()
+
+	store, err := db.Open(filepath.Join(tmp, "autopr.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	remote := createBareRemoteWithMain(t, tmp)
+	cfg := &config.Config{
+		ReposRoot: filepath.Join(tmp, "repos"),
+		LLM:       config.LLMConfig{Provider: "codex"},
+		Daemon:    config.DaemonConfig{MaxIterations: maxIterations},
+		Projects: []config.ProjectConfig{{
+			Name:       "myproject",
+			RepoURL:    remote,
+			BaseBranch: "main",
+			TestCmd:    testCmd,
+			GitLab:     &config.ProjectGitLab{BaseURL: "https://gitlab.example.com", ProjectID: "1"},
+			Pipeline: &config.ProjectPipeline{Steps: []config.PipelineStep{
+				{Name: config.StepPlan}, {Name: config.StepImplement}, {Name: config.StepTests},
+			}},
+			ReviewFeedback: &config.ProjectReviewFeedback{Enabled: true, MaxRounds: 3},
+		}},
+	}
+
+	issueID, err := store.UpsertIssue(ctx, db.IssueUpsert{
+		ProjectName:   "myproject",
+		Source:        "gitlab",
+		SourceIssueID: "7",
+		Title:         "export rows",
+		URL:           "https://gitlab.example.com/org/repo/-/issues/7",
+		State:         "open",
+	})
+	if err != nil {
+		t.Fatalf("upsert issue: %v", err)
+	}
+	jobID, err := store.CreateJob(ctx, issueID, "myproject", 3)
+	if err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+	if _, err := store.CreateArtifact(ctx, jobID, issueID, "plan", "export the rows", 0, ""); err != nil {
+		t.Fatalf("seed plan: %v", err)
+	}
+
+	worktree := filepath.Join(cfg.ReposRoot, "worktrees", jobID)
+	runGitCmdLocal(t, "", "clone", "--branch", "main", remote, worktree)
+	runGitCmdLocal(t, worktree, "checkout", "-b", "autopr/export")
+	runGitCmdLocal(t, worktree, "config", "user.email", "test@example.com")
+	runGitCmdLocal(t, worktree, "config", "user.name", "AutoPR Test")
+	if _, err := store.Writer.ExecContext(ctx, `
+		UPDATE jobs SET state = 'approved', branch_name = ?, worktree_path = ?, pr_url = ? WHERE id = ?`,
+		"autopr/export", worktree, reviewRoundPRURL, jobID); err != nil {
+		t.Fatalf("setup approved job: %v", err)
+	}
+
+	comments := []git.ReviewComment{
+		{ID: 11, Author: "carol", Body: "Rename export to dump.", Path: "export.go", Line: 4, CreatedAt: time.Now().UTC()},
+	}
+	if err := RequestReviewRound(ctx, store, jobID, comments); err != nil {
+		t.Fatalf("request review round: %v", err)
+	}
+	if err := RequestReviewRound(ctx, store, jobID, comments); err == nil {
+		t.Fatalf("expected requesting a round for a queued job to fail")
+	}
+	claimAgain(t, store, jobID)
+	return store, cfg, jobID, comments
+}
+
+const reviewRoundPRURL = "https://gitlab.example.com/org/repo/-/merge_requests/3"
+
+func TestRunAddressesReviewCommentsOnOpenPR(t *testing.T) {
+	ctx := context.Background()
+	store, cfg, jobID, comments := setupReviewRoundJob(t, "true", 0)
+	prURL := reviewRoundPRURL
+
+	var mu sync.Mutex
+	var implementPrompts []string
+	provider := stubProvider{run: func(ctx context.Context, workDir, prompt string) (llm.Response, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if strings.Contains(prompt, "Implement the changes") {
+			implementPrompts = append(implementPrompts, prompt)
+			if err := os.WriteFile(filepath.Join(workDir, "dump.go"), []byte("package dump\n"), 0o644); err != nil {
+				return llm.Response{}, err
+			}
+		}
+		return llm.Response{Text: "done", InputTokens: 1, OutputTokens: 1, DurationMS: 1}, nil
+	}}
+	runner := New(store, provider, cfg)
+	runner.providerFor = func(route config.LLMRoute) (llm.Provider, error) { return provider, nil }
+	var pushedBranch string
+	runner.pushBranchWithLeaseToRemote = func(ctx context.Context, dir, remoteName, branchName, token string) error {
+		pushedBranch = branchName
+		return nil
+	}
+	var replies []string
+	runner.postPRCommentFn = func(ctx context.Context, cfg *config.Config, proj *config.ProjectConfig, url, body string) (git.IssueComment, error) {
+		if url != prURL {
+			t.Errorf("expected the reply on %s, got %s", prURL, url)
+		}
+		replies = append(replies, body)
+		return git.IssueComment{ID: 99}, nil
+	}
+
+	if err := runner.Run(ctx, jobID); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	job, err := store.GetJob(ctx, jobID)
+	if err != nil {
+		t.Fatalf("get job: %v", err)
+	}
+	if job.State != "approved" || job.Iteration != 1 {
+		t.Fatalf("expected the job back in approved after one new iteration, got %q at iteration %d", job.State, job.Iteration)
+	}
+	if got := sessionCountForStep(t, store, ctx, jobID, "plan"); got != 0 {
+		t.Fatalf("expected the round to skip planning, got %d plan sessions", got)
+	}
+	if len(implementPrompts) != 1 || !strings.Contains(implementPrompts[0], "@carol on export.go:4:\nRename export to dump.") {
+		t.Fatalf("expected the review comments in the implement prompt, got %q", implementPrompts)
+	}
+	if pushedBranch != "autopr/export" {
+		t.Fatalf("expected the PR branch to be pushed, got %q", pushedBranch)
+	}
+	if len(replies) != 1 || !strings.Contains(replies[0], "autopr: implement changes for export rows") || !strings.Contains(replies[0], ReviewReplyMarker) {
+		t.Fatalf("expected a reply listing the new commit, got %q", replies)
+	}
+
+	addressed, err := store.GetLatestArtifact(ctx, jobID, reviewFeedbackArtifactKind)
+	if err != nil || addressed.Status != reviewFeedbackAddressed || addressed.Iteration != 1 {
+		t.Fatalf("expected an addressed review_feedback artifact, got %+v (err %v)", addressed, err)
+	}
+	rounds, until, err := ReviewRounds(ctx, store, jobID)
+	if err != nil || rounds != 1 || !until.Equal(comments[0].CreatedAt) {
+		t.Fatalf("expected one round until the comment, got %d until %v (err %v)", rounds, until, err)
+	}
+	events, err := store.ListNotificationEvents(ctx, db.NotificationStatusPending, 10)
+	if err != nil {
+		t.Fatalf("list events: %v", err)
+	}
+	if len(events) != 0 {
+		t.Fatalf("expected no needs_pr or pr_created events for a review round, got %+v", events)
+	}
+}
+
+func TestRunStopsReviewRoundThatFailsTestsWithoutPushing(t *testing.T) {
+	ctx := context.Background()
+	store, cfg, jobID, _ := setupReviewRoundJob(t, "false", 2)
+	// The first run used up the job's iterations; the round gets its own.
+	if _, err := store.Writer.ExecContext(ctx, `UPDATE jobs SET iteration = 3 WHERE id = ?`, jobID); err != nil {
+		t.Fatalf("use up iterations: %v", err)
+	}
+
+	provider := stubProvider{run: func(ctx context.Context, workDir, prompt string) (llm.Response, error) {
+		return llm.Response{Text: "done", InputTokens: 1, OutputTokens: 1, DurationMS: 1}, nil
+	}}
+	runner := New(store, provider, cfg)
+	runner.providerFor = func(route config.LLMRoute) (llm.Provider, error) { return provider, nil }
+	pushed := false
+	runner.pushBranchWithLeaseToRemote = func(ctx context.Context, dir, remoteName, branchName, token string) error {
+		pushed = true
+		return nil
+	}
+	var replies []string
+	runner.postPRCommentFn = func(ctx context.Context, cfg *config.Config, proj *config.ProjectConfig, url, body string) (git.IssueComment, error) {
+		replies = append(replies, body)
+		return git.IssueComment{ID: 99}, nil
+	}
+
+	if err := runner.Run(ctx, jobID); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	job, err := store.GetJob(ctx, jobID)
+	if err != nil {
+		t.Fatalf("get job: %v", err)
+	}
+	if job.State != "needs_human" || job.ErrorMessage != "review round: code review or tests still failing after iteration 6" {
+		t.Fatalf("expected the failing round to stop for a human, got %q (%q)", job.State, job.ErrorMessage)
+	}
+	if got := sessionCountForStep(t, store, ctx, jobID, "implement"); got != 3 {
+		t.Fatalf("expected the round's own 2 retries after its first iteration, got %d implement sessions", got)
+	}
+	if pushed {
+		t.Fatalf("expected a failing round not to be pushed")
+	}
+	if len(replies) != 1 || !strings.Contains(replies[0], "could not address the review comments") || !strings.Contains(replies[0], "tests still failing") || !strings.Contains(replies[0], ReviewReplyMarker) {
+		t.Fatalf("expected a reply saying the round failed, got %q", replies)
+	}
+	if feedback, err := store.GetLatestArtifact(ctx, jobID, reviewFeedbackArtifactKind); err != nil || feedback.Status != reviewFeedbackRequested {
+		t.Fatalf("expected the review comments to stay unaddressed, got %+v (err %v)", feedback, err)
+	}
+}
+
+func TestRunRepliesWhenReviewRoundFailsEarly(t *testing.T) {
+	ctx := context.Background()
+	store, cfg, jobID, _ := setupReviewRoundJob(t, "true", 0)
+
+	provider := stubProvider{run: func(ctx context.Context, workDir, prompt string) (llm.Response, error) {
+		return llm.Response{}, errors.New("model crashed")
+	}}
+	runner := New(store, provider, cfg)
+	runner.providerFor = func(route config.LLMRoute) (llm.Provider, error) { return provider, nil }
+	runner.pushBranchWithLeaseToRemote = func(ctx context.Context, dir, remoteName, branchName, token string) error {
+		t.Fatalf("expected a failed round not to be pushed")
+		return nil
+	}
+	var replies []string
+	runner.postPRCommentFn = func(ctx context.Context, cfg *config.Config, proj *config.ProjectConfig, url, body string) (git.IssueComment, error) {
+		replies = append(replies, body)
+		return git.IssueComment{ID: 99}, nil
+	}
+
+	if err := runner.Run(ctx, jobID); err == nil {
+		t.Fatalf("expected the round to fail")
+	}
+
+	job, err := store.GetJob(ctx, jobID)
+	if err != nil {
+		t.Fatalf("get job: %v", err)
+	}
+	if job.State != "failed" {
+		t.Fatalf("expected the round to fail the job, got %q", job.State)
+	}
+	if len(replies) != 1 || !strings.Contains(replies[0], "model crashed") || !strings.Contains(replies[0], "stopped in `failed`") {
+		t.Fatalf("expected a reply saying the round failed, got %q", replies)
+	}
+}
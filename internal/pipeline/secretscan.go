@@ -0,0 +1,64 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"autopr/internal/db"
+	"autopr/internal/git"
+	"autopr/internal/secretscan"
+)
+
+const secretScanArtifactKind = "secret_scan"
+
+// secretScanBlocked is the status of a secret_scan artifact whose findings
+// blocked the push.
+const secretScanBlocked = "blocked"
+
+// ErrSecretsFound signals that the secret scan blocked a push.
+var ErrSecretsFound = errors.New("push blocked: the diff contains suspected secrets")
+
+// ScanBeforePush scans the commits a push would send for credentials. Like
+// RebaseBeforePush it is called from all three approval paths (TUI, CLI,
+// daemon auto_pr), right after the rebase. Findings are stored redacted as a
+// secret_scan artifact and the ready job moves to blocked, which only
+// `ap approve --allow-secrets` pushes.
+func ScanBeforePush(ctx context.Context, store *db.Store, jobID, issueAPID, baseBranch, workDir string, iteration int) error {
+	diff, err := git.CommitDiffsAgainstBase(ctx, workDir, baseBranch)
+	if err != nil {
+		return fmt.Errorf("secret scan: %w", err)
+	}
+	findings := secretscan.Scan(diff)
+	if len(findings) == 0 {
+		return nil
+	}
+
+	content := fmt.Sprintf("%d suspected secrets in the commits against %s:\n%s", len(findings), baseBranch, secretscan.Format(findings))
+	artifact := db.Artifact{
+		JobID:         jobID,
+		AutoPRIssueID: issueAPID,
+		Kind:          secretScanArtifactKind,
+		Content:       content,
+		Iteration:     iteration,
+		Status:        secretScanBlocked,
+	}
+	if sha, err := git.LatestCommit(ctx, workDir); err == nil {
+		artifact.CommitSHA = sha
+	}
+	if data, err := json.Marshal(findings); err == nil {
+		artifact.Data = string(data)
+	}
+	if _, err := store.InsertArtifact(ctx, artifact); err != nil {
+		slog.Warn("failed to store secret scan artifact", "job", jobID, "err", err)
+	}
+
+	slog.Warn("secret scan blocked push", "job", jobID, "findings", len(findings))
+	if err := store.TransitionState(ctx, jobID, "ready", "blocked"); err != nil {
+		return fmt.Errorf("block job: %w", err)
+	}
+	_ = store.UpdateJobField(ctx, jobID, "error_message", content)
+	return fmt.Errorf("%w (%d findings; see the secret_scan artifact)", ErrSecretsFound, len(findings))
+}
@@ -0,0 +1,117 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"autopr/internal/config"
+	"autopr/internal/db"
+)
+
+func TestMaybeAutoPRBlocksPushOnSecrets(t *testing.T) {
+	ctx := context.Background()
+	tmp := t.TempDir()
+
+	store, err := db.Open(filepath.Join(tmp, "autopr.db"))
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	defer store.Close()
+
+	remote := createBareRemoteWithMain(t, tmp)
+	cfg := &config.Config{
+		ReposRoot: filepath.Join(tmp, "repos"),
+		LLM:       config.LLMConfig{Provider: "codex"},
+		Projects: []config.ProjectConfig{{
+			Name:       "myproject",
+			RepoURL:    remote,
+			BaseBranch: "main",
+			TestCmd:    "true",
+			GitLab:     &config.ProjectGitLab{BaseURL: "https://gitlab.example.com", ProjectID: "1"},
+		}},
+	}
+
+	issue := db.IssueUpsert{
+		ProjectName:   "myproject",
+		Source:        "gitlab",
+		SourceIssueID: "7",
+		Title:         "leaky change",
+		URL:           "https://gitlab.example.com/org/repo/-/issues/7",
+		State:         "open",
+	}
+	issueID, err := store.UpsertIssue(ctx, issue)
+	if err != nil {
+		t.Fatalf("upsert issue: %v", err)
+	}
+	jobID, err := store.CreateJob(ctx, issueID, "myproject", 3)
+	if err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+
+	worktree := filepath.Join(cfg.ReposRoot, "worktrees", jobID)
+	runGitCmdLocal(t, "", "clone", remote, worktree)
+	runGitCmdLocal(t, worktree, "checkout", "-b", "autopr/leaky")
+	runGitCmdLocal(t, worktree, "config", "user.email", "test@example.com")
+	runGitCmdLocal(t, worktree, "config", "user.name", "AutoPR Test")
+	secret := "AKIA" + "IOSFODNN7EXAMPLE"
+	if err := os.WriteFile(filepath.Join(worktree, "deploy.env"), []byte("AWS_ACCESS_KEY_ID="+secret+"\n"), 0o644); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	runGitCmdLocal(t, worktree, "add", "deploy.env")
+	runGitCmdLocal(t, worktree, "commit", "-m", "add deploy env")
+
+	if _, err := store.Writer.ExecContext(ctx, `
+		UPDATE jobs SET state = 'ready', branch_name = ?, worktree_path = ? WHERE id = ?`,
+		"autopr/leaky", worktree, jobID); err != nil {
+		t.Fatalf("setup ready job: %v", err)
+	}
+
+	runner := New(store, nil, cfg)
+	pushed := false
+	runner.pushBranchWithLeaseToRemote = func(ctx context.Context, dir, remoteName, branchName, token string) error {
+		pushed = true
+		return nil
+	}
+	runner.createPRForProjectFn = func(ctx context.Context, cfg *config.Config, proj *config.ProjectConfig, job db.Job, head, title, body string, draft bool) (string, error) {
+		t.Fatal("expected no PR to be created")
+		return "", nil
+	}
+
+	err = runner.maybeAutoPR(ctx, jobID, db.Issue{AutoPRIssueID: issueID}, &cfg.Projects[0])
+	if !errors.Is(err, ErrSecretsFound) {
+		t.Fatalf("expected ErrSecretsFound, got %v", err)
+	}
+	if pushed {
+		t.Fatal("expected the push to be blocked")
+	}
+
+	job, err := store.GetJob(ctx, jobID)
+	if err != nil {
+		t.Fatalf("get job: %v", err)
+	}
+	if job.State != "blocked" {
+		t.Fatalf("expected job blocked, got %q", job.State)
+	}
+	art, err := store.GetLatestArtifact(ctx, jobID, "secret_scan")
+	if err != nil {
+		t.Fatalf("expected secret_scan artifact: %v", err)
+	}
+	if art.Status != secretScanBlocked {
+		t.Fatalf("expected a blocked secret_scan artifact, got status %q", art.Status)
+	}
+	if !strings.Contains(art.Content, "deploy.env:1: aws_access_key AKIA…(20 chars)") {
+		t.Fatalf("unexpected artifact content:\n%s", art.Content)
+	}
+	if strings.Contains(art.Content+art.Data+job.ErrorMessage, secret) {
+		t.Fatal("expected the secret to be redacted")
+	}
+
+	// A blocked job only moves on through an explicit override.
+	if err := runner.maybeAutoPR(ctx, jobID, db.Issue{AutoPRIssueID: issueID}, &cfg.Projects[0]); err != nil || pushed {
+		t.Fatalf("expected auto_pr to skip the blocked job, got err %v, pushed %v", err, pushed)
+	}
+}
@@ -2,15 +2,25 @@ package pipeline
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
 	"autopr/internal/config"
 	"autopr/internal/db"
 	"autopr/internal/git"
+	"autopr/internal/llm"
+	"autopr/internal/safepath"
+	"autopr/internal/sandbox"
+	"autopr/internal/testreport"
 )
 
 // Default prompt templates.
@@ -25,6 +35,10 @@ Title: {{title}}
 
 {{human_notes}}
 
+{{plan_feedback}}
+
+{{clarifications}}
+
 Create a step-by-step implementation plan that includes:
 1. Which files need to be modified or created
 2. The specific changes needed in each file
@@ -33,6 +47,29 @@ Create a step-by-step implementation plan that includes:
 
 Output your plan in a clear, structured format.`
 
+	defaultPlanReviewPrompt = `You are an expert software engineer. Critique the following implementation plan against the issue and the repository in this working directory.
+
+<issue>
+Title: {{title}}
+
+{{body}}
+</issue>
+
+<plan>
+{{plan}}
+</plan>
+
+Check that the plan:
+1. Addresses everything the issue asks for, and nothing it does not
+2. Names files, functions and APIs that actually exist in the repository
+3. Fits the project's architecture and conventions
+4. Covers risks, edge cases and tests
+
+End your response with exactly one verdict line:
+VERDICT: APPROVE - the plan is ready to implement
+VERDICT: REPLAN - the plan must be revised; list the specific problems above the verdict
+VERDICT: INFEASIBLE - the issue cannot be implemented as written (unclear, contradictory or out of scope for this repository); explain why above the verdict`
+
 	defaultImplementPrompt = `You are an expert software engineer. Implement the changes described in the following plan.
 
 <issue>
@@ -65,6 +102,8 @@ Title: {{title}}
 {{plan}}
 </plan>
 
+{{review_focus}}
+
 Review the code changes for:
 1. Correctness - does the code solve the issue?
 2. Code quality - is it clean, readable, maintainable?
@@ -72,8 +111,32 @@ Review the code changes for:
 4. Security - are there any vulnerabilities?
 5. Performance - any obvious performance issues?
 
-If the code is acceptable, respond with: APPROVED
-If changes are needed, list the specific issues that must be fixed.`
+Explain your review, then end your response with a verdict block in exactly this format:
+
+` + "```json" + `
+{
+  "decision": "approve" or "request_changes",
+  "confidence": number from 0 to 1,
+  "summary": "one sentence",
+  "findings": [
+    {"severity": "blocker" | "major" | "minor" | "nit", "file": "path/to/file.go", "line": 42, "message": "what is wrong and how to fix it"}
+  ]
+}
+` + "```" + `
+
+Use "request_changes" only with at least one finding. A blocker finding means the changes must not be merged as they are.`
+
+	reviewRepairPrompt = `Your code review ended without a valid verdict block: {{error}}
+
+<review>
+{{review}}
+</review>
+
+Reply with only the verdict block for this review, in exactly this format:
+
+` + "```json" + `
+{"decision": "approve" or "request_changes", "confidence": number from 0 to 1, "summary": "one sentence", "findings": [{"severity": "blocker" | "major" | "minor" | "nit", "file": "path", "line": 1, "message": "..."}]}
+` + "```"
 )
 
 func (r *Runner) runPlan(ctx context.Context, jobID string, issue db.Issue, projectCfg *config.ProjectConfig, workDir string) error {
@@ -94,10 +157,19 @@ func (r *Runner) runPlan(ctx context.Context, jobID string, issue db.Issue, proj
 		humanNotes = fmt.Sprintf("<human_notes>\n%s\n</human_notes>", job.HumanNotes)
 	}
 
+	// Re-plans within an iteration address the plan review's critique. An
+	// approved plan is only re-planned on a human's revise-plan notes.
+	planFeedback := ""
+	if review, err := r.store.GetLatestArtifact(ctx, jobID, "plan_review"); err == nil && review.Iteration == job.Iteration && parsePlanVerdict(review.Content) != planVerdictApprove {
+		planFeedback = fmt.Sprintf("<plan_review_feedback>\nA reviewer asked for a revised plan:\n%s\n</plan_review_feedback>", review.Content)
+	}
+
 	prompt := BuildPrompt(template, map[string]string{
-		"title":       issue.Title,
-		"body":        SanitizeIssueContent(issue.Body),
-		"human_notes": humanNotes,
+		"title":          issue.Title,
+		"body":           SanitizeIssueContent(issue.Body),
+		"human_notes":    humanNotes,
+		"plan_feedback":  planFeedback,
+		"clarifications": r.clarifications(ctx, jobID, issue, projectCfg),
 	})
 
 	resp, err := r.invokeProvider(ctx, jobID, "plan", job.Iteration, workDir, prompt)
@@ -105,6 +177,12 @@ func (r *Runner) runPlan(ctx context.Context, jobID string, issue db.Issue, proj
 		return fmt.Errorf("plan step: %w", err)
 	}
 
+	if canClarify(issue, projectCfg) {
+		if questions := parseClarifyingQuestions(resp.Text); questions != "" {
+			return r.askClarifyingQuestions(ctx, job, issue, projectCfg, questions)
+		}
+	}
+
 	// Store the plan as an artifact.
 	_, err = r.store.CreateArtifact(ctx, jobID, issue.AutoPRIssueID, "plan", resp.Text, job.Iteration, "")
 	if err != nil {
@@ -131,12 +209,15 @@ func (r *Runner) runImplement(ctx context.Context, jobID string, issue db.Issue,
 	reviewFeedback := ""
 	if job.Iteration > 0 {
 		if reviewArtifact, err := r.store.GetLatestArtifact(ctx, jobID, "code_review"); err == nil {
-			reviewFeedback = fmt.Sprintf("<previous_review_feedback>\n%s\n</previous_review_feedback>", reviewArtifact.Content)
+			reviewFeedback = fmt.Sprintf("<previous_review_feedback>\n%s\n</previous_review_feedback>", reviewFeedbackText(reviewArtifact))
 		}
 		// Also include test output if available.
 		if testArtifact, err := r.store.GetLatestArtifact(ctx, jobID, "test_output"); err == nil {
-			reviewFeedback += fmt.Sprintf("\n\n<previous_test_output>\n%s\n</previous_test_output>", testArtifact.Content)
+			reviewFeedback += fmt.Sprintf("\n\n<previous_test_output>\n%s\n</previous_test_output>", testFeedbackText(testArtifact))
 		}
+		reviewFeedback += r.commandFeedback(ctx, jobID, projectCfg)
+		reviewFeedback += r.diffPolicyFeedback(ctx, jobID)
+		reviewFeedback += r.prReviewFeedback(ctx, jobID)
 	}
 
 	template := defaultImplementPrompt
@@ -182,41 +263,148 @@ func (r *Runner) runCodeReview(ctx context.Context, jobID string, issue db.Issue
 		return fmt.Errorf("get plan for review: %w", err)
 	}
 
+	reviewers := projectCfg.CodeReviewers()
+	if len(reviewers) == 0 {
+		verdict, err := r.reviewCode(ctx, job, issue, projectCfg, workDir, planArtifact.Content, config.CodeReviewer{})
+		if err != nil {
+			return err
+		}
+		if !verdict.Approved() {
+			slog.Info("code review requested changes", "job", jobID, "iteration", job.Iteration, "findings", len(verdict.Findings))
+			return errReviewChangesRequested
+		}
+		slog.Info("code review approved", "job", jobID, "confidence", verdict.Confidence, "findings", len(verdict.Findings))
+		return nil
+	}
+
+	// Several reviewers: each review is its own session and artifact, and the
+	// combined verdict is stored last so it is the latest code_review.
+	reviews := make([]ReviewVerdict, 0, len(reviewers))
+	var content strings.Builder
+	for _, reviewer := range reviewers {
+		verdict, err := r.reviewCode(ctx, job, issue, projectCfg, workDir, planArtifact.Content, reviewer)
+		if err != nil {
+			return fmt.Errorf("reviewer %s: %w", reviewer.Name, err)
+		}
+		reviews = append(reviews, verdict)
+		fmt.Fprintf(&content, "## %s\n%s\n\n", reviewer.Name, FormatReviewChecklist(verdict))
+	}
+	combined := combineReviews(projectCfg.CodeReview.Policy, reviews)
+	data, err := json.Marshal(combined)
+	if err != nil {
+		return fmt.Errorf("encode review verdict: %w", err)
+	}
+	if _, err := r.store.InsertArtifact(ctx, db.Artifact{
+		JobID:         jobID,
+		AutoPRIssueID: issue.AutoPRIssueID,
+		Kind:          "code_review",
+		Content:       strings.TrimSpace(content.String()),
+		Iteration:     job.Iteration,
+		Data:          string(data),
+	}); err != nil {
+		return fmt.Errorf("store review artifact: %w", err)
+	}
+
+	if !combined.Approved() {
+		slog.Info("code reviewers requested changes", "job", jobID, "iteration", job.Iteration, "summary", combined.Summary, "findings", len(combined.Findings))
+		return errReviewChangesRequested
+	}
+	slog.Info("code reviewers approved", "job", jobID, "summary", combined.Summary, "findings", len(combined.Findings))
+	return nil
+}
+
+// reviewCode runs one code review and stores it as a code_review artifact,
+// with the parsed verdict as its data. A zero reviewer is the project's
+// single code review. A malformed verdict gets one repair attempt.
+func (r *Runner) reviewCode(ctx context.Context, job db.Job, issue db.Issue, projectCfg *config.ProjectConfig, workDir, plan string, reviewer config.CodeReviewer) (ReviewVerdict, error) {
 	template := defaultCodeReviewPrompt
 	if projectCfg.Prompts != nil && projectCfg.Prompts.CodeReview != "" {
 		if custom := LoadTemplate(projectCfg.Prompts.CodeReview); custom != "" {
 			template = custom
 		}
 	}
+	if reviewer.Prompt != "" {
+		if custom := LoadTemplate(reviewer.Prompt); custom != "" {
+			template = custom
+		}
+	}
+	focus := reviewFocus(reviewer.Persona)
+	if focus != "" && !strings.Contains(template, "{{review_focus}}") {
+		template += "\n\n{{review_focus}}"
+	}
 
 	prompt := BuildPrompt(template, map[string]string{
-		"title": issue.Title,
-		"body":  SanitizeIssueContent(issue.Body),
-		"plan":  planArtifact.Content,
+		"title":        issue.Title,
+		"body":         SanitizeIssueContent(issue.Body),
+		"plan":         plan,
+		"review_focus": focus,
 	})
 
-	resp, err := r.invokeProvider(ctx, jobID, "code_review", job.Iteration, workDir, prompt)
-	if err != nil {
-		return fmt.Errorf("code review step: %w", err)
+	invoke := func(prompt string) (llm.Response, error) {
+		if reviewer.Name == "" {
+			return r.invokeProvider(ctx, job.ID, "code_review", job.Iteration, workDir, prompt)
+		}
+		return r.invokeRoutes(ctx, job.ID, "code_review", job.Iteration, workDir, prompt, projectCfg, r.routesForReviewer(projectCfg, reviewer))
 	}
 
-	// Store the review as an artifact.
-	_, err = r.store.CreateArtifact(ctx, jobID, issue.AutoPRIssueID, "code_review", resp.Text, job.Iteration, "")
+	resp, err := invoke(prompt)
 	if err != nil {
-		return fmt.Errorf("store review artifact: %w", err)
+		return ReviewVerdict{}, fmt.Errorf("code review step: %w", err)
 	}
 
-	// Check if review approved or needs changes.
-	if !isApproved(resp.Text) {
-		slog.Info("code review requested changes", "job", jobID, "iteration", job.Iteration)
-		return errReviewChangesRequested
+	verdict, parseErr := parseReviewVerdict(resp.Text)
+	if parseErr != nil {
+		// One repair attempt: ask for just the verdict block.
+		slog.Info("code review verdict malformed, asking for a repair", "job", job.ID, "reviewer", reviewer.Name, "err", parseErr)
+		repairPrompt := BuildPrompt(reviewRepairPrompt, map[string]string{
+			"error":  parseErr.Error(),
+			"review": resp.Text,
+		})
+		repaired, err := invoke(repairPrompt)
+		if err != nil {
+			return ReviewVerdict{}, fmt.Errorf("code review repair: %w", err)
+		}
+		verdict, parseErr = parseReviewVerdict(repaired.Text)
+	}
+	verdict.Reviewer = reviewer.Name
+
+	review := db.Artifact{
+		JobID:         job.ID,
+		AutoPRIssueID: issue.AutoPRIssueID,
+		Kind:          "code_review",
+		Content:       resp.Text,
+		Iteration:     job.Iteration,
 	}
+	if parseErr == nil {
+		data, err := json.Marshal(verdict)
+		if err != nil {
+			return ReviewVerdict{}, fmt.Errorf("encode review verdict: %w", err)
+		}
+		review.Data = string(data)
+	}
+	if _, err := r.store.InsertArtifact(ctx, review); err != nil {
+		return ReviewVerdict{}, fmt.Errorf("store review artifact: %w", err)
+	}
+	if parseErr != nil {
+		return ReviewVerdict{}, fmt.Errorf("code review verdict malformed after repair: %w", parseErr)
+	}
+	return verdict, nil
+}
 
-	slog.Info("code review approved", "job", jobID)
-	return nil
+// reviewFeedbackText returns a code review for the implement prompt: the
+// findings checklist when the verdict was parsed, the raw review otherwise.
+func reviewFeedbackText(a db.Artifact) string {
+	var v ReviewVerdict
+	if a.Data == "" || json.Unmarshal([]byte(a.Data), &v) != nil {
+		return a.Content
+	}
+	return FormatReviewChecklist(v)
 }
 
 func (r *Runner) runTestingAndReadiness(ctx context.Context, jobID string, issue db.Issue, projectCfg *config.ProjectConfig, workDir string) error {
+	if err := r.checkDiffPolicy(ctx, jobID, issue, projectCfg, workDir); err != nil {
+		return err
+	}
 	if err := r.runTests(ctx, jobID, issue, projectCfg, workDir); err != nil {
 		return err
 	}
@@ -229,12 +417,72 @@ func (r *Runner) runTests(ctx context.Context, jobID string, issue db.Issue, pro
 		return err
 	}
 
-	// Run the project's test command.
-	testOutput, testErr := runTestCommand(ctx, workDir, projectCfg.TestCmd)
+	if !projectCfg.HasPipelineStep(config.StepTests) {
+		slog.Info("test step not in pipeline, skipping", "job", jobID)
+		return nil
+	}
+
+	// Run the project's test commands.
+	iso := isolation(ctx, projectCfg, workDir)
+	runs := runTestCommands(ctx, workDir, projectCfg, &iso)
 
-	// Store test output as artifact.
-	_, err = r.store.CreateArtifact(ctx, jobID, issue.AutoPRIssueID, "test_output", testOutput, job.Iteration, "")
-	if err != nil {
+	// Each test_cmds entry gets its own artifact; the combined output and
+	// results of the required commands are stored as test_output.
+	for _, run := range runs {
+		if run.cmd.Name == "" {
+			continue
+		}
+		a := db.Artifact{
+			JobID:         jobID,
+			AutoPRIssueID: issue.AutoPRIssueID,
+			Kind:          run.cmd.ArtifactKind(),
+			Content:       run.cmd.String() + "\n\n" + run.output,
+			Iteration:     job.Iteration,
+			Status:        run.status(),
+		}
+		if run.parsed {
+			if data, err := json.Marshal(run.report); err == nil {
+				a.Data = string(data)
+			}
+		}
+		if _, err := r.store.InsertArtifact(ctx, a); err != nil {
+			slog.Warn("failed to store test command artifact", "job", jobID, "cmd", run.cmd.Name, "err", err)
+		}
+	}
+	output, report, parsed, testErr := combineTestRuns(runs)
+
+	// Store test output as artifact, with the parsed results as its data.
+	artifact := db.Artifact{
+		JobID:         jobID,
+		AutoPRIssueID: issue.AutoPRIssueID,
+		Kind:          "test_output",
+		Content:       output,
+		Iteration:     job.Iteration,
+		Status:        commandPassed,
+	}
+	if testErr != nil {
+		artifact.Status = commandFailed
+	}
+	if parsed {
+		if testErr != nil && ctx.Err() == nil && projectCfg.BaselineTests && len(report.Failures) > 0 {
+			sha, baseline, err := r.testBaseline(ctx, projectCfg, workDir)
+			if err != nil {
+				slog.Warn("baseline test run failed", "job", jobID, "err", err)
+			} else {
+				report.Subtract(baseline, sha)
+				if report.Failed == 0 && !hasUnparsedFailure(runs) {
+					slog.Info("failing tests also fail on the base branch", "job", jobID, "base", sha, "preexisting", len(report.Preexisting))
+					testErr = nil
+					artifact.Status = commandPassed
+				}
+			}
+		}
+		slog.Info("test results", "job", jobID, "format", report.Format, "passed", report.Passed, "failed", report.Failed, "skipped", report.Skipped)
+		if data, err := json.Marshal(report); err == nil {
+			artifact.Data = string(data)
+		}
+	}
+	if _, err := r.store.InsertArtifact(ctx, artifact); err != nil {
 		slog.Warn("failed to store test artifact", "err", err)
 	}
 
@@ -250,30 +498,323 @@ func (r *Runner) runTests(ctx context.Context, jobID string, issue db.Issue, pro
 	return nil
 }
 
-func isApproved(text string) bool {
-	upper := strings.ToUpper(text)
-	// Reject if it explicitly says NOT APPROVED.
-	if strings.Contains(upper, "NOT APPROVED") || strings.Contains(upper, "NOT YET APPROVED") {
-		return false
+// testRun is the outcome of one test command.
+type testRun struct {
+	cmd    config.TestCommand
+	output string
+	err    error
+	report testreport.Report
+	parsed bool
+}
+
+// failed reports whether the run fails the test step. Advisory commands
+// never do.
+func (t testRun) failed() bool {
+	return t.err != nil && !t.cmd.Advisory
+}
+
+func (t testRun) status() string {
+	switch {
+	case t.err == nil:
+		return commandPassed
+	case t.cmd.Advisory:
+		return commandWarned
+	default:
+		return commandFailed
+	}
+}
+
+// testCommands returns the commands the test step runs: test_cmds, or
+// test_cmd parsed into a single unnamed command.
+func testCommands(projectCfg *config.ProjectConfig) ([]config.TestCommand, error) {
+	if len(projectCfg.TestCmds) > 0 {
+		return projectCfg.TestCmds, nil
+	}
+	if projectCfg.TestCmd == "" {
+		return nil, nil
+	}
+	args, err := config.ParseTestCommand(projectCfg.TestCmd)
+	if err != nil {
+		return nil, err
+	}
+	return []config.TestCommand{{Argv: args}}, nil
+}
+
+// runTestCommands runs the project's test commands in workDir one after
+// another and parses the results of each. It stops early when ctx is done.
+func runTestCommands(ctx context.Context, workDir string, projectCfg *config.ProjectConfig, iso *llm.Isolation) []testRun {
+	cmds, err := testCommands(projectCfg)
+	if err != nil {
+		return []testRun{{output: err.Error(), err: err}}
+	}
+	if len(cmds) == 0 {
+		return []testRun{{output: "no test command configured"}}
+	}
+	var runs []testRun
+	for _, c := range cmds {
+		run := testRun{cmd: c}
+		// Truncated so reports on filesystems with coarse mtimes still count.
+		started := time.Now().Truncate(time.Second)
+		run.output, run.err = runTestArgv(ctx, workDir, c, iso)
+		run.report, run.parsed = parseTestResults(workDir, projectCfg.TestReport, run.output, started)
+		runs = append(runs, run)
+		if ctx.Err() != nil {
+			break
+		}
 	}
-	return strings.Contains(upper, "APPROVED")
+	return runs
 }
 
-func runTestCommand(ctx context.Context, dir, testCmd string) (string, error) {
+// combineTestRuns joins the output of runs and merges the results of the
+// required ones. err is the first required command's failure.
+func combineTestRuns(runs []testRun) (output string, report testreport.Report, parsed bool, err error) {
+	var sections []string
+	for _, run := range runs {
+		if run.cmd.Name == "" {
+			sections = append(sections, run.output)
+		} else {
+			sections = append(sections, run.cmd.String()+"\n\n"+run.output)
+		}
+		if run.cmd.Advisory {
+			continue
+		}
+		if run.err != nil && err == nil {
+			err = run.err
+		}
+		if run.parsed {
+			report.Merge(run.report)
+			parsed = true
+		}
+	}
+	if report.Failures == nil {
+		report.Failures = []testreport.Failure{}
+	}
+	return strings.Join(sections, "\n\n"), report, parsed, err
+}
+
+// hasUnparsedFailure reports whether a required command failed without a
+// parsed failing test (a build error, crash or unknown runner), which a
+// baseline cannot explain.
+func hasUnparsedFailure(runs []testRun) bool {
+	return slices.ContainsFunc(runs, func(t testRun) bool {
+		return t.failed() && (!t.parsed || len(t.report.Failures) == 0)
+	})
+}
+
+// testCommandsKey identifies the project's test commands in the baseline
+// cache.
+func testCommandsKey(projectCfg *config.ProjectConfig) string {
+	if len(projectCfg.TestCmds) == 0 {
+		return projectCfg.TestCmd
+	}
+	var lines []string
+	for _, c := range projectCfg.TestCmds {
+		if !c.Advisory {
+			lines = append(lines, c.String())
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// testBaseline returns the test results of the base branch at its merge base
+// with the job branch. Results are cached per commit, so only the first job
+// after the base branch moves pays for the run. The baseline is checked out
+// as a worktree inside the clone's .git directory and removed afterwards.
+func (r *Runner) testBaseline(ctx context.Context, projectCfg *config.ProjectConfig, workDir string) (string, testreport.Report, error) {
+	var report testreport.Report
+	sha, err := git.MergeBase(ctx, workDir, "HEAD", "origin/"+projectCfg.BaseBranch)
+	if err != nil {
+		return "", report, fmt.Errorf("find merge base: %w", err)
+	}
+
+	key := testCommandsKey(projectCfg)
+	cached, err := r.store.GetTestBaseline(ctx, projectCfg.Name, sha, key)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal([]byte(cached.Data), &report); err != nil {
+			return "", report, fmt.Errorf("decode cached baseline: %w", err)
+		}
+		slog.Info("using cached test baseline", "project", projectCfg.Name, "base", sha, "failed", report.Failed)
+		return sha, report, nil
+	case !errors.Is(err, db.ErrNoTestBaseline):
+		return "", report, err
+	}
+
+	dir := filepath.Join(workDir, ".git", "autopr-baseline")
+	if err := git.AddDetachedWorktree(ctx, workDir, dir, sha); err != nil {
+		return "", report, err
+	}
+	defer func() {
+		if err := git.RemoveWorktree(context.WithoutCancel(ctx), workDir, dir); err != nil {
+			slog.Warn("failed to remove baseline worktree", "path", dir, "err", err)
+		}
+	}()
+
+	// The job's HOME lives outside the baseline worktree, so the sandbox
+	// needs it bound explicitly.
+	iso := isolation(ctx, projectCfg, workDir)
+	if iso.Sandbox != nil && iso.Env.Home != "" {
+		iso.Sandbox.ReadWrite = append(slices.Clip(iso.Sandbox.ReadWrite), iso.Env.Home)
+	}
+	slog.Info("running baseline tests", "project", projectCfg.Name, "base", sha)
+	runs := runTestCommands(ctx, dir, projectCfg, &iso)
+	if ctx.Err() != nil {
+		return "", report, context.Canceled
+	}
+	output, report, parsed, testErr := combineTestRuns(runs)
+	if !parsed {
+		return "", report, errors.New("baseline test output could not be parsed")
+	}
+
+	baseline := db.TestBaseline{ProjectName: projectCfg.Name, BaseSHA: sha, TestCmd: key, Content: output, Status: commandPassed}
+	if testErr != nil {
+		baseline.Status = commandFailed
+	}
+	if data, err := json.Marshal(report); err == nil {
+		baseline.Data = string(data)
+	}
+	if err := r.store.SaveTestBaseline(ctx, baseline); err != nil {
+		slog.Warn("failed to cache test baseline", "err", err)
+	}
+	return sha, report, nil
+}
+
+// parseTestResults parses the JUnit XML reports matching reportGlob that the
+// test run wrote after started, falling back to the test output. Report
+// paths are resolved inside workDir without following symlinks out of it.
+func parseTestResults(workDir, reportGlob, output string, started time.Time) (testreport.Report, bool) {
+	if reportGlob != "" {
+		matches, _ := filepath.Glob(filepath.Join(workDir, reportGlob))
+		var report testreport.Report
+		found := false
+		for _, path := range matches {
+			resolved, err := safepath.ResolveNoSymlinkPath(workDir, path)
+			if err != nil {
+				continue
+			}
+			info, err := os.Stat(resolved)
+			if err != nil || info.IsDir() || info.ModTime().Before(started) {
+				continue
+			}
+			data, err := os.ReadFile(resolved)
+			if err != nil {
+				continue
+			}
+			parsed, err := testreport.ParseJUnit(data)
+			if err != nil {
+				slog.Warn("failed to parse test report", "path", path, "err", err)
+				continue
+			}
+			report.Merge(parsed)
+			found = true
+		}
+		if found {
+			return report, true
+		}
+	}
+	return testreport.Parse(output)
+}
+
+// testFeedbackText returns test output for the implement prompt: a summary
+// of the failing tests when the output was parsed, the raw output otherwise
+// (build errors, crashes and unknown runners).
+func testFeedbackText(a db.Artifact) string {
+	var report testreport.Report
+	if a.Data == "" || json.Unmarshal([]byte(a.Data), &report) != nil || len(report.Failures) == 0 {
+		return a.Content
+	}
+	return report.Summary()
+}
+
+// runTestCommand runs testCmd in dir. A nil iso inherits the daemon's
+// environment and runs unsandboxed.
+func runTestCommand(ctx context.Context, dir, testCmd string, iso *llm.Isolation) (string, error) {
 	if testCmd == "" {
 		return "no test command configured", nil
 	}
 
-	args, err := parseTestCommand(testCmd)
+	args, err := config.ParseTestCommand(testCmd)
 	if err != nil {
 		return err.Error(), err
 	}
-	if err := validateTestCommandArgs(args); err != nil {
+	if err := config.ValidateTestCommandArgs(args); err != nil {
+		return err.Error(), err
+	}
+	return runArgv(ctx, dir, args, nil, iso)
+}
+
+// runTestArgv runs c in its directory under workDir with its extra env and
+// timeout. A command that times out fails like any other. Command steps run
+// through here too.
+func runTestArgv(ctx context.Context, workDir string, c config.TestCommand, iso *llm.Isolation) (string, error) {
+	if err := config.ValidateTestCommandArgs(c.Argv); err != nil {
 		return err.Error(), err
 	}
+	dir := workDir
+	if c.Dir != "" {
+		resolved, err := safepath.ResolveNoSymlinkPath(workDir, filepath.Join(workDir, c.Dir))
+		if err != nil {
+			err = fmt.Errorf("invalid test command dir %q: %w", c.Dir, err)
+			return err.Error(), err
+		}
+		dir = resolved
+		// The sandbox only binds the command's directory; keep the rest of
+		// the worktree writable too.
+		if iso != nil && iso.Sandbox != nil {
+			sb := *iso.Sandbox
+			sb.ReadWrite = append(slices.Clip(sb.ReadWrite), workDir)
+			withWorktree := *iso
+			withWorktree.Sandbox = &sb
+			iso = &withWorktree
+		}
+	}
 
+	runCtx := ctx
+	if d := c.TimeoutDuration(); d > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+	output, err := runArgv(runCtx, dir, c.Argv, c.Env, iso)
+	if err != nil && ctx.Err() == nil && runCtx.Err() != nil {
+		err = fmt.Errorf("command timed out after %s", c.Timeout)
+		return output + "\n... (" + err.Error() + ")", err
+	}
+	return output, err
+}
+
+// runArgv runs args in dir with env added to the environment. A nil iso
+// inherits the daemon's environment and runs unsandboxed.
+func runArgv(ctx context.Context, dir string, args []string, env map[string]string, iso *llm.Isolation) (string, error) {
 	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
 	cmd.Dir = dir
+	if iso == nil && len(env) > 0 {
+		cmd.Env = os.Environ()
+		for name, value := range env {
+			cmd.Env = append(cmd.Env, name+"="+value)
+		}
+	}
+	if iso != nil {
+		e := iso.Env
+		if len(env) > 0 {
+			e.Set = make(map[string]string, len(e.Set)+len(env))
+			maps.Copy(e.Set, iso.Env.Set)
+			maps.Copy(e.Set, env)
+		}
+		environ, err := e.Environ()
+		if err != nil {
+			return err.Error(), err
+		}
+		cmd.Env = environ
+		if iso.Sandbox != nil {
+			if err := sandbox.Wrap(cmd, *iso.Sandbox); err != nil {
+				return err.Error(), err
+			}
+		}
+	}
+	// Test suites fork servers and watchers that would otherwise keep the
+	// output pipe open past the timeout.
+	llm.KillProcessGroupOnCancel(cmd)
 	out, err := cmd.CombinedOutput()
 	output := string(out)
 
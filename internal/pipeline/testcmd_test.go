@@ -3,190 +3,53 @@ package pipeline
 import (
 	"context"
 	"errors"
-	"reflect"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"autopr/internal/config"
+	"autopr/internal/db"
+	"autopr/internal/git"
+	"autopr/internal/llm"
+	"autopr/internal/sandbox"
 )
 
-func TestParseTestCommandSimple(t *testing.T) {
-	t.Parallel()
-
-	got, err := parseTestCommand("go test ./...")
-	if err != nil {
-		t.Fatalf("parseTestCommand returned error: %v", err)
-	}
-	want := []string{"go", "test", "./..."}
-	if !reflect.DeepEqual(got, want) {
-		t.Fatalf("unexpected argv: got=%v want=%v", got, want)
-	}
-}
-
-func TestParseTestCommandQuotedArg(t *testing.T) {
-	t.Parallel()
-
-	got, err := parseTestCommand(`go test -run "Test Foo"`)
-	if err != nil {
-		t.Fatalf("parseTestCommand returned error: %v", err)
-	}
-	want := []string{"go", "test", "-run", "Test Foo"}
-	if !reflect.DeepEqual(got, want) {
-		t.Fatalf("unexpected argv: got=%v want=%v", got, want)
-	}
-}
-
-func TestParseTestCommandSingleQuotedArg(t *testing.T) {
-	t.Parallel()
-
-	got, err := parseTestCommand("go test -run 'Test Foo'")
-	if err != nil {
-		t.Fatalf("parseTestCommand returned error: %v", err)
-	}
-	want := []string{"go", "test", "-run", "Test Foo"}
-	if !reflect.DeepEqual(got, want) {
-		t.Fatalf("unexpected argv: got=%v want=%v", got, want)
-	}
-}
-
-func TestParseTestCommandEscapedWhitespace(t *testing.T) {
-	t.Parallel()
-
-	got, err := parseTestCommand(`go test -run Test\ Foo`)
-	if err != nil {
-		t.Fatalf("parseTestCommand returned error: %v", err)
-	}
-	want := []string{"go", "test", "-run", "Test Foo"}
-	if !reflect.DeepEqual(got, want) {
-		t.Fatalf("unexpected argv: got=%v want=%v", got, want)
-	}
-}
-
-func TestParseTestCommandMixedQuoting(t *testing.T) {
-	t.Parallel()
-
-	got, err := parseTestCommand(`go test -run "Test 'Foo'"`)
-	if err != nil {
-		t.Fatalf("parseTestCommand returned error: %v", err)
-	}
-	want := []string{"go", "test", "-run", "Test 'Foo'"}
-	if !reflect.DeepEqual(got, want) {
-		t.Fatalf("unexpected argv: got=%v want=%v", got, want)
-	}
-}
-
-func TestParseTestCommandEmptyQuotedArg(t *testing.T) {
-	t.Parallel()
-
-	got, err := parseTestCommand(`go test -run ""`)
-	if err != nil {
-		t.Fatalf("parseTestCommand returned error: %v", err)
-	}
-	want := []string{"go", "test", "-run", ""}
-	if !reflect.DeepEqual(got, want) {
-		t.Fatalf("unexpected argv: got=%v want=%v", got, want)
-	}
-}
-
-func TestParseTestCommandAllowsUnsafeTextInsideQuotes(t *testing.T) {
+func TestRunTestCommandExecutesWithoutShell(t *testing.T) {
 	t.Parallel()
 
-	got, err := parseTestCommand(`go test -run "A && B; C | D $(echo x)"`)
+	output, err := runTestCommand(context.Background(), t.TempDir(), "go version", nil)
 	if err != nil {
-		t.Fatalf("parseTestCommand returned error: %v", err)
-	}
-	want := []string{"go", "test", "-run", "A && B; C | D $(echo x)"}
-	if !reflect.DeepEqual(got, want) {
-		t.Fatalf("unexpected argv: got=%v want=%v", got, want)
-	}
-}
-
-func TestParseTestCommandRejectsUnsafeConstructs(t *testing.T) {
-	t.Parallel()
-
-	tests := []struct {
-		name       string
-		cmd        string
-		wantReason string
-	}{
-		{name: "and and", cmd: "go test && echo bad", wantReason: "'&&'"},
-		{name: "semicolon", cmd: "go test; echo bad", wantReason: "';'"},
-		{name: "backticks", cmd: "go test `echo bad`", wantReason: "'`'"},
-		{name: "subshell", cmd: "go test $(echo bad)", wantReason: "'$('"},
-		{name: "pipe", cmd: "go test | cat", wantReason: "'|'"},
-		{name: "or or", cmd: "go test || echo bad", wantReason: "'||'"},
-		{name: "ampersand", cmd: "go test & cat", wantReason: "'&'"},
-		{name: "input redirect", cmd: "go test < file", wantReason: "'<'"},
-		{name: "output redirect", cmd: "go test > file", wantReason: "'>'"},
-		{name: "bare dollar", cmd: "go test $TEST_DB", wantReason: "'$'"},
-	}
-
-	for _, tc := range tests {
-		t.Run(tc.name, func(t *testing.T) {
-			t.Parallel()
-
-			_, err := parseTestCommand(tc.cmd)
-			if err == nil {
-				t.Fatalf("expected parse error for %q", tc.cmd)
-			}
-			if !strings.Contains(err.Error(), "invalid test_cmd") {
-				t.Fatalf("expected invalid test_cmd prefix, got: %v", err)
-			}
-			if !strings.Contains(err.Error(), tc.wantReason) {
-				t.Fatalf("expected error to include %s, got: %v", tc.wantReason, err)
-			}
-		})
+		t.Fatalf("runTestCommand returned error: %v", err)
 	}
-}
-
-func TestParseTestCommandRejectsMalformedSyntax(t *testing.T) {
-	t.Parallel()
-
-	tests := []struct {
-		name       string
-		cmd        string
-		wantReason string
-	}{
-		{name: "unterminated single quote", cmd: "go test -run 'Test Foo", wantReason: "unterminated quote"},
-		{name: "unterminated double quote", cmd: `go test -run "Test Foo`, wantReason: "unterminated quote"},
-		{name: "trailing escape", cmd: `go test \`, wantReason: "trailing escape"},
-		{name: "whitespace only", cmd: "   ", wantReason: "empty command"},
-	}
-
-	for _, tc := range tests {
-		t.Run(tc.name, func(t *testing.T) {
-			t.Parallel()
-
-			_, err := parseTestCommand(tc.cmd)
-			if err == nil {
-				t.Fatalf("expected parse error for %q", tc.cmd)
-			}
-			if !strings.Contains(err.Error(), "invalid test_cmd") {
-				t.Fatalf("expected invalid test_cmd prefix, got: %v", err)
-			}
-			if !strings.Contains(err.Error(), tc.wantReason) {
-				t.Fatalf("expected error to include %q, got: %v", tc.wantReason, err)
-			}
-		})
+	if !strings.Contains(output, "go version") {
+		t.Fatalf("expected go version output, got: %q", output)
 	}
 }
 
-func TestRunTestCommandExecutesWithoutShell(t *testing.T) {
-	t.Parallel()
+func TestRunTestCommandScrubsForgeCredentials(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "ghp_should_not_leak")
+	t.Setenv("AUTOPR_WEBHOOK_SECRET", "hook_should_not_leak")
+	home := filepath.Join(t.TempDir(), "home")
+	iso := &llm.Isolation{Env: sandbox.Env{Home: home, Set: map[string]string{"AUTOPR_TEST_VAR": "set"}}}
 
-	output, err := runTestCommand(context.Background(), t.TempDir(), "go version")
+	output, err := runTestCommand(context.Background(), t.TempDir(), "env", iso)
 	if err != nil {
 		t.Fatalf("runTestCommand returned error: %v", err)
 	}
-	if !strings.Contains(output, "go version") {
-		t.Fatalf("expected go version output, got: %q", output)
+	if strings.Contains(output, "should_not_leak") {
+		t.Fatalf("expected credentials to be scrubbed, got: %q", output)
+	}
+	if !strings.Contains(output, "HOME="+home+"\n") || !strings.Contains(output, "AUTOPR_TEST_VAR=set") {
+		t.Fatalf("expected job HOME and project env, got: %q", output)
 	}
 }
 
 func TestRunTestCommandRejectsUnsafeCommand(t *testing.T) {
 	t.Parallel()
 
-	output, err := runTestCommand(context.Background(), t.TempDir(), "go version && echo bad")
+	output, err := runTestCommand(context.Background(), t.TempDir(), "go version && echo bad", nil)
 	if err == nil {
 		t.Fatal("expected runTestCommand error")
 	}
@@ -201,7 +64,7 @@ func TestRunTestCommandRejectsUnsafeCommand(t *testing.T) {
 func TestRunTestCommandRejectsShellExecutable(t *testing.T) {
 	t.Parallel()
 
-	output, err := runTestCommand(context.Background(), t.TempDir(), "sh -c 'echo hi'")
+	output, err := runTestCommand(context.Background(), t.TempDir(), "sh -c 'echo hi'", nil)
 	if err == nil {
 		t.Fatal("expected runTestCommand error")
 	}
@@ -216,7 +79,7 @@ func TestRunTestCommandRejectsShellExecutable(t *testing.T) {
 func TestRunTestCommandRejectsShellViaEnv(t *testing.T) {
 	t.Parallel()
 
-	output, err := runTestCommand(context.Background(), t.TempDir(), "env sh -c 'echo hi'")
+	output, err := runTestCommand(context.Background(), t.TempDir(), "env sh -c 'echo hi'", nil)
 	if err == nil {
 		t.Fatal("expected runTestCommand error")
 	}
@@ -231,7 +94,7 @@ func TestRunTestCommandRejectsShellViaEnv(t *testing.T) {
 func TestRunTestCommandRejectsShellViaEnvAssignment(t *testing.T) {
 	t.Parallel()
 
-	output, err := runTestCommand(context.Background(), t.TempDir(), "env FOO=bar sh -c 'echo hi'")
+	output, err := runTestCommand(context.Background(), t.TempDir(), "env FOO=bar sh -c 'echo hi'", nil)
 	if err == nil {
 		t.Fatal("expected runTestCommand error")
 	}
@@ -246,7 +109,7 @@ func TestRunTestCommandRejectsShellViaEnvAssignment(t *testing.T) {
 func TestRunTestCommandRejectsShellViaBusybox(t *testing.T) {
 	t.Parallel()
 
-	output, err := runTestCommand(context.Background(), t.TempDir(), "busybox sh -c 'echo hi'")
+	output, err := runTestCommand(context.Background(), t.TempDir(), "busybox sh -c 'echo hi'", nil)
 	if err == nil {
 		t.Fatal("expected runTestCommand error")
 	}
@@ -283,3 +146,213 @@ func TestRunTestsStoresValidationErrorArtifact(t *testing.T) {
 		t.Fatalf("expected artifact to include validation error, got: %q", artifact.Content)
 	}
 }
+
+func TestRunTestsStoresParsedJUnitReport(t *testing.T) {
+	t.Parallel()
+
+	runner, store, issue, jobID := setupRunStepsJob(t, nil, "testing")
+	ctx := context.Background()
+	workDir := t.TempDir()
+	junit := `<testsuite name="calc"><testcase name="adds"/><testcase name="divides" file="calc.py" line="9"><failure message="ZeroDivisionError"/></testcase></testsuite>`
+	if err := os.WriteFile(filepath.Join(workDir, "fresh.xml"), []byte(junit), 0o644); err != nil {
+		t.Fatalf("write report: %v", err)
+	}
+	// A report left over from an earlier run is ignored.
+	stale := filepath.Join(workDir, "report-stale.xml")
+	if err := os.WriteFile(stale, []byte(`<testsuite><testcase name="old"><failure message="old"/></testcase></testsuite>`), 0o644); err != nil {
+		t.Fatalf("write stale report: %v", err)
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(stale, old, old); err != nil {
+		t.Fatalf("age stale report: %v", err)
+	}
+	projectCfg := &config.ProjectConfig{
+		Name:       "project",
+		RepoURL:    "https://example.com/org/repo.git",
+		BaseBranch: "main",
+		TestCmd:    "cp fresh.xml report-new.xml",
+		TestReport: "report-*.xml",
+	}
+
+	if err := runner.runTests(ctx, jobID, issue, projectCfg, workDir); err != nil {
+		t.Fatalf("run tests: %v", err)
+	}
+	artifact, err := store.GetLatestArtifact(ctx, jobID, "test_output")
+	if err != nil {
+		t.Fatalf("get test artifact: %v", err)
+	}
+	if artifact.Status != commandPassed {
+		t.Fatalf("expected passed status, got %q", artifact.Status)
+	}
+	want := "Tests: 1 passed, 1 failed (junit)\n\nFAIL divides (calc) at calc.py:9\n    ZeroDivisionError"
+	if got := testFeedbackText(artifact); got != want {
+		t.Fatalf("unexpected test feedback:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestTestFeedbackTextFallsBackToRawOutput(t *testing.T) {
+	t.Parallel()
+	raw := db.Artifact{Content: "make: *** [test] Error 2"}
+	if got := testFeedbackText(raw); got != raw.Content {
+		t.Fatalf("expected raw output for unparsed tests, got %q", got)
+	}
+	passing := db.Artifact{Content: "ok  \texample.com/calc\t0.01s", Data: `{"format":"go test","passed":0,"failed":0,"failures":[]}`}
+	if got := testFeedbackText(passing); got != passing.Content {
+		t.Fatalf("expected raw output when no test failed, got %q", got)
+	}
+}
+
+func TestRunTestsIgnoresFailuresFromBaseBranch(t *testing.T) {
+	t.Parallel()
+
+	runner, store, issue, jobID := setupRunStepsJob(t, nil, "testing")
+	ctx := context.Background()
+	root := t.TempDir()
+	remote := createBareRemoteWithMain(t, root)
+
+	// The "tests" print results.txt and fail because "missing" does not exist.
+	oldFailure := "--- FAIL: TestOld (0.00s)\n    calc_test.go:5: broken on main\nFAIL\nFAIL\texample.com/calc\t0.01s\n"
+	seed := filepath.Join(root, "seed")
+	if err := os.WriteFile(filepath.Join(seed, "results.txt"), []byte(oldFailure), 0o644); err != nil {
+		t.Fatalf("write results: %v", err)
+	}
+	runGitCmdLocal(t, seed, "add", "results.txt")
+	runGitCmdLocal(t, seed, "commit", "-m", "break TestOld")
+	runGitCmdLocal(t, seed, "push", "origin", "main")
+
+	workDir := filepath.Join(root, "job")
+	runGitCmdLocal(t, "", "clone", "--branch", "main", remote, workDir)
+	runGitCmdLocal(t, workDir, "checkout", "-b", "autopr/job")
+	projectCfg := &config.ProjectConfig{
+		Name:          "project",
+		BaseBranch:    "main",
+		TestCmd:       "cat results.txt missing",
+		BaselineTests: true,
+	}
+
+	if err := runner.runTests(ctx, jobID, issue, projectCfg, workDir); err != nil {
+		t.Fatalf("expected pre-existing failures to pass the step, got %v", err)
+	}
+	artifact, err := store.GetLatestArtifact(ctx, jobID, "test_output")
+	if err != nil {
+		t.Fatalf("get test artifact: %v", err)
+	}
+	if artifact.Status != commandPassed || !strings.Contains(artifact.Data, `"preexisting":[{"name":"TestOld"`) {
+		t.Fatalf("expected passed artifact with TestOld pre-existing, got %q %s", artifact.Status, artifact.Data)
+	}
+	if _, err := os.Stat(filepath.Join(workDir, ".git", "autopr-baseline")); !os.IsNotExist(err) {
+		t.Fatalf("expected baseline worktree to be removed, stat err=%v", err)
+	}
+	job, err := store.GetJob(ctx, jobID)
+	if err != nil {
+		t.Fatalf("get job: %v", err)
+	}
+	_, body := BuildPRContent(ctx, store, job, issue)
+	if !strings.Contains(body, "Pre-existing test failures (1)") || !strings.Contains(body, "- `TestOld (example.com/calc) at calc_test.go:5`") {
+		t.Fatalf("expected PR body to list the pre-existing failure, got:\n%s", body)
+	}
+
+	// A new failure still fails the step; the baseline comes from the cache.
+	newFailure := "--- FAIL: TestNew (0.00s)\n    calc_test.go:9: broken here\n" + oldFailure
+	if err := os.WriteFile(filepath.Join(workDir, "results.txt"), []byte(newFailure), 0o644); err != nil {
+		t.Fatalf("write results: %v", err)
+	}
+	base, err := git.MergeBase(ctx, workDir, "HEAD", "origin/main")
+	if err != nil {
+		t.Fatalf("merge base: %v", err)
+	}
+	if _, err := store.GetTestBaseline(ctx, "project", base, projectCfg.TestCmd); err != nil {
+		t.Fatalf("expected cached baseline, got %v", err)
+	}
+	if err := runner.runTests(ctx, jobID, issue, projectCfg, workDir); !errors.Is(err, errTestsFailed) {
+		t.Fatalf("expected errTestsFailed, got %v", err)
+	}
+	artifact, err = store.GetLatestArtifact(ctx, jobID, "test_output")
+	if err != nil {
+		t.Fatalf("get test artifact: %v", err)
+	}
+	feedback := testFeedbackText(artifact)
+	if !strings.HasPrefix(feedback, "Tests: 0 passed, 1 failed, 1 pre-existing (go test)\n\nFAIL TestNew") ||
+		!strings.Contains(feedback, "unrelated to this change:\n- TestOld") {
+		t.Fatalf("unexpected test feedback:\n%s", feedback)
+	}
+}
+
+func TestRunTestsStoresArtifactPerTestCommand(t *testing.T) {
+	t.Parallel()
+
+	runner, store, issue, jobID := setupRunStepsJob(t, nil, "testing")
+	ctx := context.Background()
+	workDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(workDir, "sub"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(workDir, "sub", "results.txt"), []byte("ok  \texample.com/calc\t0.01s\n"), 0o644); err != nil {
+		t.Fatalf("write results: %v", err)
+	}
+	projectCfg := &config.ProjectConfig{
+		Name:       "project",
+		BaseBranch: "main",
+		TestCmds: []config.TestCommand{
+			{Name: "unit", Argv: []string{"cat", "results.txt"}, Dir: "sub"},
+			{Name: "slow", Argv: []string{"sleep", "5"}, Timeout: "100ms", Advisory: true},
+			{Name: "env", Argv: []string{"env"}, Env: map[string]string{"AUTOPR_TEST_FLAG": "on"}},
+		},
+	}
+
+	start := time.Now()
+	if err := runner.runTests(ctx, jobID, issue, projectCfg, workDir); err != nil {
+		t.Fatalf("expected advisory failure to pass the step, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Fatalf("expected the slow command to time out, took %s", elapsed)
+	}
+
+	for kind, status := range map[string]string{"test_output:unit": commandPassed, "test_output:slow": commandWarned, "test_output:env": commandPassed, "test_output": commandPassed} {
+		a, err := store.GetLatestArtifact(ctx, jobID, kind)
+		if err != nil {
+			t.Fatalf("get %s artifact: %v", kind, err)
+		}
+		if a.Status != status {
+			t.Fatalf("%s: expected status %q, got %q", kind, status, a.Status)
+		}
+	}
+	slow, _ := store.GetLatestArtifact(ctx, jobID, "test_output:slow")
+	if !strings.HasPrefix(slow.Content, "$ sleep 5\n\n") || !strings.Contains(slow.Content, "timed out after 100ms") {
+		t.Fatalf("unexpected slow command output:\n%s", slow.Content)
+	}
+	env, _ := store.GetLatestArtifact(ctx, jobID, "test_output:env")
+	if !strings.Contains(env.Content, "AUTOPR_TEST_FLAG=on") {
+		t.Fatalf("expected command env in output, got:\n%s", env.Content)
+	}
+	combined, _ := store.GetLatestArtifact(ctx, jobID, "test_output")
+	if !strings.HasPrefix(combined.Content, "sub$ cat results.txt\n\nok  \texample.com/calc") {
+		t.Fatalf("unexpected combined output:\n%s", combined.Content)
+	}
+
+	// A failing required command fails the step.
+	projectCfg.TestCmds[0].Argv = []string{"cat", "missing.txt"}
+	if err := runner.runTests(ctx, jobID, issue, projectCfg, workDir); !errors.Is(err, errTestsFailed) {
+		t.Fatalf("expected errTestsFailed, got %v", err)
+	}
+	unit, _ := store.GetLatestArtifact(ctx, jobID, "test_output:unit")
+	if unit.Status != commandFailed {
+		t.Fatalf("expected failed unit artifact, got %q", unit.Status)
+	}
+}
+
+func TestRunArgvKillsGrandchildrenOnTimeout(t *testing.T) {
+	t.Parallel()
+
+	// sh forks sleep, which inherits the output pipe and outlives sh.
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	output, err := runArgv(ctx, t.TempDir(), []string{"sh", "-c", "sleep 6; echo done"}, nil, nil)
+	if err == nil || strings.Contains(output, "done") {
+		t.Fatalf("expected the command to be killed, got %q (err %v)", output, err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Fatalf("expected the timeout to stop the grandchild, took %s", elapsed)
+	}
+}
@@ -0,0 +1,121 @@
+package sandbox
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// BaseVars are the host variables every confined subprocess inherits. A
+// trailing "*" matches a prefix. Forge tokens (GITHUB_TOKEN, GITLAB_TOKEN,
+// SENTRY_TOKEN) and AUTOPR_WEBHOOK_SECRET are deliberately absent.
+var BaseVars = []string{
+	"PATH", "HOME", "USER", "LOGNAME", "SHELL", "TERM", "TZ", "TMPDIR",
+	"LANG", "LANGUAGE", "LC_*",
+	"SSL_CERT_FILE", "SSL_CERT_DIR",
+	"HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY", "http_proxy", "https_proxy", "no_proxy",
+	"GIT_AUTHOR_NAME", "GIT_AUTHOR_EMAIL", "GIT_COMMITTER_NAME", "GIT_COMMITTER_EMAIL",
+}
+
+// AgentVars are additionally inherited by LLM CLI tools, which need their own
+// API credentials and settings.
+var AgentVars = []string{"ANTHROPIC_*", "CLAUDE_*", "OPENAI_*", "CODEX_*"}
+
+// AgentHomeLinks are entries of the real home directory linked into a per-job
+// HOME for LLM CLI tools, so their login state keeps working.
+var AgentHomeLinks = []string{".claude", ".claude.json", ".codex"}
+
+// Env describes the environment of a confined subprocess.
+type Env struct {
+	Home  string            // per-job HOME with XDG base dirs beneath it; empty keeps the host HOME
+	Pass  []string          // host variables inherited in addition to BaseVars
+	Links []string          // entries of the host home linked into Home
+	Set   map[string]string // explicit variables; they override inherited ones
+}
+
+// Environ builds the environment from the current process environment,
+// creating the per-job home directories as needed.
+func (e Env) Environ() ([]string, error) {
+	return e.environ(os.Environ())
+}
+
+func (e Env) environ(host []string) ([]string, error) {
+	allow := append(append([]string(nil), BaseVars...), e.Pass...)
+	vars := make(map[string]string)
+	for _, kv := range host {
+		name, value, ok := strings.Cut(kv, "=")
+		if ok && allowed(name, allow) {
+			vars[name] = value
+		}
+	}
+
+	if e.Home != "" {
+		hostHome := vars["HOME"]
+		xdg := map[string]string{
+			"XDG_CONFIG_HOME": filepath.Join(e.Home, ".config"),
+			"XDG_CACHE_HOME":  filepath.Join(e.Home, ".cache"),
+			"XDG_DATA_HOME":   filepath.Join(e.Home, ".local", "share"),
+			"XDG_STATE_HOME":  filepath.Join(e.Home, ".local", "state"),
+		}
+		for name, dir := range xdg {
+			if err := os.MkdirAll(dir, 0o700); err != nil {
+				return nil, fmt.Errorf("create job home: %w", err)
+			}
+			vars[name] = dir
+		}
+		vars["HOME"] = e.Home
+		if hostHome != "" {
+			if err := linkHomeEntries(hostHome, e.Home, e.Links); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for name, value := range e.Set {
+		vars[name] = value
+	}
+
+	env := make([]string, 0, len(vars))
+	for name, value := range vars {
+		env = append(env, name+"="+value)
+	}
+	sort.Strings(env)
+	return env, nil
+}
+
+func allowed(name string, allow []string) bool {
+	for _, pattern := range allow {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// linkHomeEntries symlinks the given entries of hostHome into home, skipping
+// entries that do not exist on the host or are already present.
+func linkHomeEntries(hostHome, home string, entries []string) error {
+	for _, entry := range entries {
+		src := filepath.Join(hostHome, entry)
+		if _, err := os.Lstat(src); err != nil {
+			continue
+		}
+		dst := filepath.Join(home, entry)
+		if _, err := os.Lstat(dst); err == nil {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(dst), 0o700); err != nil {
+			return fmt.Errorf("create job home: %w", err)
+		}
+		if err := os.Symlink(src, dst); err != nil {
+			return fmt.Errorf("link %s into job home: %w", entry, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package sandbox
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestEnvironAllowListsHostVariables(t *testing.T) {
+	t.Parallel()
+	host := []string{
+		"PATH=/usr/bin", "HOME=/home/agent", "LC_ALL=C",
+		"GITHUB_TOKEN=ghp_secret", "GITLAB_TOKEN=glpat", "SENTRY_TOKEN=sntrys", "AUTOPR_WEBHOOK_SECRET=hook",
+		"ANTHROPIC_API_KEY=sk-ant",
+	}
+
+	env, err := Env{Set: map[string]string{"GOFLAGS": "-mod=mod", "PATH": "/opt/bin"}}.environ(host)
+	if err != nil {
+		t.Fatalf("environ: %v", err)
+	}
+	want := []string{"GOFLAGS=-mod=mod", "HOME=/home/agent", "LC_ALL=C", "PATH=/opt/bin"}
+	if !slices.Equal(env, want) {
+		t.Fatalf("unexpected env:\n got %v\nwant %v", env, want)
+	}
+
+	env, err = Env{Pass: AgentVars}.environ(host)
+	if err != nil {
+		t.Fatalf("environ: %v", err)
+	}
+	if !slices.Contains(env, "ANTHROPIC_API_KEY=sk-ant") || slices.Contains(env, "GITHUB_TOKEN=ghp_secret") {
+		t.Fatalf("expected agent key but no forge token, got %v", env)
+	}
+}
+
+func TestEnvironCreatesJobHome(t *testing.T) {
+	t.Parallel()
+	hostHome := t.TempDir()
+	if err := os.Mkdir(filepath.Join(hostHome, ".claude"), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	home := filepath.Join(t.TempDir(), "home")
+
+	env, err := Env{Home: home, Links: []string{".claude", ".codex"}}.environ([]string{"HOME=" + hostHome})
+	if err != nil {
+		t.Fatalf("environ: %v", err)
+	}
+	for _, want := range []string{
+		"HOME=" + home,
+		"XDG_CONFIG_HOME=" + filepath.Join(home, ".config"),
+		"XDG_CACHE_HOME=" + filepath.Join(home, ".cache"),
+		"XDG_DATA_HOME=" + filepath.Join(home, ".local", "share"),
+		"XDG_STATE_HOME=" + filepath.Join(home, ".local", "state"),
+	} {
+		if !slices.Contains(env, want) {
+			t.Fatalf("expected %q in %v", want, env)
+		}
+	}
+	if info, err := os.Stat(filepath.Join(home, ".cache")); err != nil || !info.IsDir() {
+		t.Fatalf("expected XDG dirs to be created, err=%v", err)
+	}
+	if target, err := os.Readlink(filepath.Join(home, ".claude")); err != nil || target != filepath.Join(hostHome, ".claude") {
+		t.Fatalf("expected .claude to link to host home, got %q err=%v", target, err)
+	}
+	if _, err := os.Lstat(filepath.Join(home, ".codex")); !os.IsNotExist(err) {
+		t.Fatalf("expected missing host entry to be skipped, err=%v", err)
+	}
+
+	// A second run reuses the existing home and links.
+	if _, err := (Env{Home: home, Links: []string{".claude"}}).environ([]string{"HOME=" + hostHome}); err != nil {
+		t.Fatalf("environ again: %v", err)
+	}
+}
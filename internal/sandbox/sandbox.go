@@ -0,0 +1,109 @@
+// Package sandbox runs subprocesses inside a bubblewrap (bwrap) namespace
+// sandbox on Linux. Only the command's working directory (the job worktree)
+// and explicitly listed paths are writable, the toolchain is mounted
+// read-only, /tmp and $HOME are private, and the network can be cut off.
+package sandbox
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+)
+
+// Options configures the sandbox for one command.
+type Options struct {
+	ReadOnly    []string // extra read-only binds, e.g. a toolchain under $HOME
+	ReadWrite   []string // extra writable binds besides the working directory
+	DenyNetwork bool     // run in an empty network namespace
+}
+
+// ToolchainPaths are bound read-only when they exist on the host.
+var ToolchainPaths = []string{
+	"/usr", "/bin", "/sbin", "/lib", "/lib32", "/lib64", "/etc", "/opt", "/nix",
+	"/run/systemd/resolve", // resolv.conf target on systemd-resolved hosts
+}
+
+// ErrUnavailable reports that sandboxing is not possible on this host.
+var ErrUnavailable = errors.New("sandbox unavailable")
+
+// Wrap rewrites cmd to run inside a bwrap sandbox. It must be called after
+// cmd.Dir is set and before cmd.Start.
+func Wrap(cmd *exec.Cmd, opts Options) error {
+	if cmd.Err != nil {
+		return cmd.Err
+	}
+	if runtime.GOOS != "linux" {
+		return fmt.Errorf("%w: requires Linux, running on %s", ErrUnavailable, runtime.GOOS)
+	}
+	bwrap, err := exec.LookPath("bwrap")
+	if err != nil {
+		return fmt.Errorf("%w: bwrap not found in PATH (install bubblewrap)", ErrUnavailable)
+	}
+	args, err := buildArgs(cmd, opts)
+	if err != nil {
+		return err
+	}
+	cmd.Path = bwrap
+	cmd.Args = append([]string{"bwrap"}, args...)
+	return nil
+}
+
+// buildArgs returns the bwrap arguments that run cmd. Later binds are
+// mounted on top of earlier ones, so writable paths come last.
+func buildArgs(cmd *exec.Cmd, opts Options) ([]string, error) {
+	if cmd.Dir == "" {
+		return nil, errors.New("sandbox: command working directory is required")
+	}
+	workDir, err := filepath.Abs(cmd.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("sandbox: %w", err)
+	}
+
+	args := []string{"--die-with-parent", "--new-session", "--unshare-all"}
+	if !opts.DenyNetwork {
+		args = append(args, "--share-net")
+	}
+	for _, p := range ToolchainPaths {
+		args = append(args, "--ro-bind-try", p, p)
+	}
+	args = append(args, "--dev", "/dev", "--proc", "/proc", "--tmpfs", "/tmp")
+	if home := os.Getenv("HOME"); home != "" && home != "/" {
+		args = append(args, "--tmpfs", home)
+	}
+
+	// The executable may live outside the toolchain paths (e.g. ~/.local/bin
+	// or a node_modules tree behind a symlink).
+	for _, dir := range programDirs(cmd.Path) {
+		args = append(args, "--ro-bind-try", dir, dir)
+	}
+	for _, p := range opts.ReadOnly {
+		args = append(args, "--ro-bind-try", p, p)
+	}
+	for _, p := range opts.ReadWrite {
+		args = append(args, "--bind-try", p, p)
+	}
+	args = append(args,
+		"--bind", workDir, workDir,
+		"--chdir", workDir,
+		"--setenv", "TMPDIR", "/tmp",
+		"--", cmd.Path)
+	return append(args, cmd.Args[1:]...), nil
+}
+
+// programDirs returns the directory of path and, if path is a symlink, the
+// directory of its target.
+func programDirs(path string) []string {
+	if !filepath.IsAbs(path) {
+		return nil
+	}
+	dirs := []string{filepath.Dir(path)}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		if dir := filepath.Dir(resolved); dir != dirs[0] {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
@@ -0,0 +1,128 @@
+package sandbox
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestBuildArgsBindsWorktreeLast(t *testing.T) {
+	t.Setenv("HOME", "/home/agent")
+	work := t.TempDir()
+	cmd := exec.Command("/usr/bin/env", "go", "test", "./...")
+	cmd.Dir = work
+
+	args, err := buildArgs(cmd, Options{ReadOnly: []string{"/home/agent/go"}, ReadWrite: []string{"/home/agent/.claude"}})
+	if err != nil {
+		t.Fatalf("buildArgs: %v", err)
+	}
+	joined := strings.Join(args, " ")
+	for _, want := range []string{
+		"--unshare-all --share-net",
+		"--ro-bind-try /usr /usr",
+		"--tmpfs /tmp",
+		"--tmpfs /home/agent",
+		"--ro-bind-try /home/agent/go /home/agent/go",
+		"--bind-try /home/agent/.claude /home/agent/.claude",
+		"--bind " + work + " " + work,
+		"--chdir " + work,
+		"-- /usr/bin/env go test ./...",
+	} {
+		if !strings.Contains(joined, want) {
+			t.Fatalf("expected %q in args: %s", want, joined)
+		}
+	}
+	// Writable binds must be mounted over the private home, not under it.
+	if strings.Index(joined, "--tmpfs /home/agent") > strings.Index(joined, "--bind-try /home/agent/.claude") {
+		t.Fatalf("home tmpfs must precede writable binds: %s", joined)
+	}
+}
+
+func TestBuildArgsDenyNetwork(t *testing.T) {
+	cmd := exec.Command("/bin/true")
+	cmd.Dir = t.TempDir()
+	args, err := buildArgs(cmd, Options{DenyNetwork: true})
+	if err != nil {
+		t.Fatalf("buildArgs: %v", err)
+	}
+	if slices.Contains(args, "--share-net") {
+		t.Fatalf("expected network to stay unshared: %v", args)
+	}
+}
+
+func TestBuildArgsBindsSymlinkedProgramTarget(t *testing.T) {
+	t.Parallel()
+	root := t.TempDir()
+	realDir := filepath.Join(root, "pkg", "bin")
+	linkDir := filepath.Join(root, "bin")
+	for _, dir := range []string{realDir, linkDir} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(realDir, "agent"), []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(linkDir, "agent")
+	if err := os.Symlink(filepath.Join(realDir, "agent"), link); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(link)
+	cmd.Dir = t.TempDir()
+	args, err := buildArgs(cmd, Options{})
+	if err != nil {
+		t.Fatalf("buildArgs: %v", err)
+	}
+	joined := strings.Join(args, " ")
+	for _, dir := range []string{linkDir, realDir} {
+		if !strings.Contains(joined, "--ro-bind-try "+dir+" "+dir) {
+			t.Fatalf("expected program dir %s to be bound: %s", dir, joined)
+		}
+	}
+}
+
+func TestBuildArgsRequiresWorkDir(t *testing.T) {
+	t.Parallel()
+	if _, err := buildArgs(exec.Command("/bin/true"), Options{}); err == nil {
+		t.Fatal("expected error without working directory")
+	}
+}
+
+func TestWrapWithoutBwrapIsUnavailable(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	cmd := exec.Command("/bin/true")
+	cmd.Dir = t.TempDir()
+	if err := Wrap(cmd, Options{}); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("expected ErrUnavailable, got %v", err)
+	}
+	if cmd.Path != "/bin/true" {
+		t.Fatalf("command must be left untouched, got %s", cmd.Path)
+	}
+}
+
+func TestWrapConfinesWritesToWorkDir(t *testing.T) {
+	if _, err := exec.LookPath("bwrap"); err != nil {
+		t.Skip("bwrap not installed")
+	}
+	work := t.TempDir()
+	outside := t.TempDir()
+	cmd := exec.Command("/bin/sh", "-c", "touch inside && touch "+filepath.Join(outside, "escaped"))
+	cmd.Dir = work
+	if err := Wrap(cmd, Options{DenyNetwork: true}); err != nil {
+		t.Fatalf("wrap: %v", err)
+	}
+	if out, err := cmd.CombinedOutput(); err == nil {
+		t.Fatalf("expected write outside the worktree to fail, output: %s", out)
+	}
+	if _, err := os.Stat(filepath.Join(work, "inside")); err != nil {
+		t.Fatalf("expected write inside the worktree: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "escaped")); !os.IsNotExist(err) {
+		t.Fatalf("expected no file outside the worktree, stat err=%v", err)
+	}
+}
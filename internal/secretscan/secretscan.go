@@ -0,0 +1,197 @@
+// Package secretscan looks for credentials in the lines a diff adds: known
+// token formats (GitHub tokens, AWS access keys, private key blocks, Slack
+// webhooks) and high-entropy string literals.
+package secretscan
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Finding is one suspected secret. Match is redacted so findings can be
+// stored and shown without leaking the secret itself.
+type Finding struct {
+	Rule  string `json:"rule"`
+	File  string `json:"file"`
+	Line  int    `json:"line"`
+	Match string `json:"match"`
+}
+
+// String renders the finding for artifacts and error messages, e.g.
+// "config/prod.go:12: github_token ghp_…(40 chars)".
+func (f Finding) String() string {
+	return fmt.Sprintf("%s:%d: %s %s", f.File, f.Line, f.Rule, f.Match)
+}
+
+type rule struct {
+	name string
+	re   *regexp.Regexp
+	// keep is true for matches that are not secret themselves, like the
+	// header of a private key block, and are shown unredacted.
+	keep bool
+}
+
+var rules = []rule{
+	{name: "private_key", re: regexp.MustCompile(`-----BEGIN[A-Z0-9 ]* PRIVATE KEY( BLOCK)?-----`), keep: true},
+	{name: "github_token", re: regexp.MustCompile(`\b(gh[pousr]_[A-Za-z0-9]{36,}|github_pat_[A-Za-z0-9_]{22,})\b`)},
+	{name: "aws_access_key", re: regexp.MustCompile(`\b(AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{name: "slack_webhook", re: regexp.MustCompile(`https://hooks\.slack\.com/(services|workflows)/[A-Za-z0-9_/]+`)},
+}
+
+// Entropy candidates are quoted strings and unquoted values assigned at the
+// end of a line (KEY=value, key: value), as in detect-secrets.
+var (
+	quotedRe   = regexp.MustCompile("[\"'`]([A-Za-z0-9+/=_.-]{20,})[\"'`]")
+	assignedRe = regexp.MustCompile(`[=:]\s*([A-Za-z0-9+/=_.-]{20,})\s*$`)
+	hexRe      = regexp.MustCompile(`^[0-9a-fA-F]+$`)
+)
+
+// Shannon entropy, in bits per character, above which a string looks random.
+const (
+	base64EntropyLimit = 4.5
+	hexEntropyLimit    = 3.0
+)
+
+// lockFiles hold checksums that look random by design.
+var lockFiles = map[string]bool{
+	"go.sum":            true,
+	"package-lock.json": true,
+	"yarn.lock":         true,
+	"pnpm-lock.yaml":    true,
+	"Cargo.lock":        true,
+	"poetry.lock":       true,
+	"Gemfile.lock":      true,
+	"composer.lock":     true,
+	"uv.lock":           true,
+}
+
+var hunkRe = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+(\d+)(?:,\d+)? @@`)
+
+// Scan returns the suspected secrets on the lines diff adds. diff is unified
+// diff output and may span several commits, as `git log -p` prints it; a
+// secret added in several commits is reported once.
+func Scan(diff string) []Finding {
+	var findings []Finding
+	seen := make(map[Finding]bool)
+	add := func(f Finding) {
+		key := Finding{Rule: f.Rule, File: f.File, Match: f.Match}
+		if !seen[key] {
+			seen[key] = true
+			findings = append(findings, f)
+		}
+	}
+
+	file := ""
+	line := 0
+	inHunk := false
+	for _, text := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(text, "diff --git "):
+			inHunk = false
+			continue
+		case !inHunk && strings.HasPrefix(text, "+++ "):
+			file = strings.TrimPrefix(strings.TrimPrefix(text, "+++ "), "b/")
+			continue
+		case strings.HasPrefix(text, "@@"):
+			if m := hunkRe.FindStringSubmatch(text); m != nil {
+				line, _ = strconv.Atoi(m[1])
+				inHunk = true
+			}
+			continue
+		case !inHunk, strings.HasPrefix(text, "-"), strings.HasPrefix(text, `\`):
+			continue
+		case !strings.HasPrefix(text, "+"):
+			line++
+			continue
+		}
+		added := text[1:]
+		for _, r := range rules {
+			for _, m := range r.re.FindAllString(added, -1) {
+				if !r.keep {
+					m = Redact(m)
+				}
+				add(Finding{Rule: r.name, File: file, Line: line, Match: m})
+			}
+		}
+		if !lockFiles[path.Base(file)] {
+			for _, s := range entropyCandidates(added) {
+				add(Finding{Rule: "high_entropy", File: file, Line: line, Match: Redact(s)})
+			}
+		}
+		line++
+	}
+	return findings
+}
+
+// entropyCandidates returns the high-entropy strings on line that no token
+// rule already matched.
+func entropyCandidates(line string) []string {
+	var out []string
+	var matches [][]string
+	matches = append(matches, quotedRe.FindAllStringSubmatch(line, -1)...)
+	matches = append(matches, assignedRe.FindAllStringSubmatch(line, -1)...)
+	for _, m := range matches {
+		s := m[1]
+		if knownToken(s) || !looksRandom(s) {
+			continue
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+func knownToken(s string) bool {
+	for _, r := range rules {
+		if r.re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+func looksRandom(s string) bool {
+	if hexRe.MatchString(s) {
+		return entropy(s) > hexEntropyLimit
+	}
+	if !strings.ContainsAny(s, "0123456789") {
+		return false
+	}
+	return entropy(s) > base64EntropyLimit
+}
+
+// entropy returns the Shannon entropy of s in bits per character.
+func entropy(s string) float64 {
+	counts := make(map[rune]int)
+	for _, c := range s {
+		counts[c]++
+	}
+	var h float64
+	n := float64(len(s))
+	for _, c := range counts {
+		p := float64(c) / n
+		h -= p * math.Log2(p)
+	}
+	return h
+}
+
+// Redact keeps the first four characters of secret, which identify its
+// kind (ghp_, AKIA, ...), and replaces the rest with its length.
+func Redact(secret string) string {
+	if len(secret) <= 8 {
+		return fmt.Sprintf("…(%d chars)", len(secret))
+	}
+	return fmt.Sprintf("%s…(%d chars)", secret[:4], len(secret))
+}
+
+// Format renders findings as one line each.
+func Format(findings []Finding) string {
+	lines := make([]string, 0, len(findings))
+	for _, f := range findings {
+		lines = append(lines, "- "+f.String())
+	}
+	return strings.Join(lines, "\n")
+}
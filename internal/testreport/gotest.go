@@ -0,0 +1,208 @@
+package testreport
+
+import (
+	"bufio"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// goTestEvent is one line of `go test -json` output (test2json).
+type goTestEvent struct {
+	Action  string `json:"Action"`
+	Package string `json:"Package"`
+	Test    string `json:"Test"`
+	Output  string `json:"Output"`
+}
+
+// goLocationRe matches the "file_test.go:12:" prefix of t.Error output and
+// compiler errors.
+var goLocationRe = regexp.MustCompile(`(?m)^\s*([\w./\\-]+\.go):(\d+):`)
+
+// parseGoJSON parses `go test -json` output.
+func parseGoJSON(output string) (Report, bool) {
+	type testKey struct{ pkg, test string }
+	outputs := make(map[testKey][]string)
+	var failures []Failure
+	var failedPkgs []string
+	report := Report{Format: FormatGoJSON}
+	events := 0
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+		var ev goTestEvent
+		if json.Unmarshal([]byte(line), &ev) != nil || ev.Action == "" {
+			continue
+		}
+		events++
+		key := testKey{ev.Package, ev.Test}
+		switch ev.Action {
+		case "output":
+			outputs[key] = append(outputs[key], strings.TrimRight(ev.Output, "\n"))
+		case "pass":
+			if ev.Test != "" {
+				report.Passed++
+			}
+		case "skip":
+			if ev.Test != "" {
+				report.Skipped++
+			}
+		case "fail":
+			if ev.Test == "" {
+				failedPkgs = append(failedPkgs, ev.Package)
+				continue
+			}
+			failures = append(failures, goFailure(ev.Package, ev.Test, goTestMessage(outputs[key])))
+		}
+	}
+	if events == 0 {
+		return Report{}, false
+	}
+
+	report.Failures = leafFailures(failures)
+	// A package that failed without a failing test did not build or
+	// crashed outside a test.
+	for _, pkg := range failedPkgs {
+		if !hasSuite(report.Failures, pkg) {
+			report.Failures = append(report.Failures, goFailure(pkg, "(package)", goTestMessage(outputs[testKey{pkg, ""}])))
+		}
+	}
+	report.Failed = len(report.Failures)
+	return report, true
+}
+
+// goResultRe matches the "--- FAIL: TestName (0.01s)" result lines of plain
+// go test output.
+var goResultRe = regexp.MustCompile(`^(\s*)--- (PASS|FAIL|SKIP): (\S+)`)
+
+// goPackageRe matches per-package summary lines: "ok  pkg 0.1s",
+// "FAIL pkg 0.1s" or "FAIL pkg [build failed]".
+var goPackageRe = regexp.MustCompile(`^(ok|FAIL)\s+(\S+)\s+(\(cached\)|[\d.]+s|\[[^\]]+\])`)
+
+// parseGo parses plain `go test` output. Passing tests are only counted
+// with -v, which prints a result line for each of them.
+func parseGo(output string) (Report, bool) {
+	lines := strings.Split(output, "\n")
+	report := Report{Format: FormatGo}
+	var failures []Failure
+	pending := 0 // failures not yet assigned a package
+	buildErrors := make(map[string][]string)
+	var buildPkg string
+	recognized := false
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if strings.HasPrefix(line, "# ") {
+			buildPkg = strings.TrimSpace(strings.TrimPrefix(line, "# "))
+			continue
+		}
+		if buildPkg != "" && strings.TrimSpace(line) != "" && goLocationRe.MatchString(line) {
+			buildErrors[buildPkg] = append(buildErrors[buildPkg], line)
+			continue
+		}
+		buildPkg = ""
+
+		if m := goResultRe.FindStringSubmatch(line); m != nil {
+			recognized = true
+			switch m[2] {
+			case "PASS":
+				report.Passed++
+			case "SKIP":
+				report.Skipped++
+			case "FAIL":
+				// The message is the following lines indented deeper than
+				// the result line, up to the next result line.
+				var msg []string
+				for i+1 < len(lines) {
+					next := lines[i+1]
+					if goResultRe.MatchString(next) || indentOf(next) <= len(m[1]) && strings.TrimSpace(next) != "" {
+						break
+					}
+					msg = append(msg, next)
+					i++
+				}
+				failures = append(failures, goFailure("", m[3], dedent(msg)))
+				pending++
+			}
+			continue
+		}
+		if m := goPackageRe.FindStringSubmatch(line); m != nil {
+			recognized = true
+			pkg := m[2]
+			for j := len(failures) - pending; j < len(failures); j++ {
+				failures[j].Suite = pkg
+			}
+			if m[1] == "FAIL" && pending == 0 {
+				failures = append(failures, goFailure(pkg, "(package)", strings.Join(buildErrors[pkg], "\n")))
+			}
+			pending = 0
+		}
+	}
+	if !recognized {
+		return Report{}, false
+	}
+	report.Failures = leafFailures(failures)
+	report.Failed = len(report.Failures)
+	return report, true
+}
+
+func goFailure(pkg, test, msg string) Failure {
+	f := Failure{Name: test, Suite: pkg, Message: msg}
+	if m := goLocationRe.FindStringSubmatch(msg); m != nil {
+		f.File = m[1]
+		f.Line, _ = strconv.Atoi(m[2])
+	}
+	return f
+}
+
+// goTestMessage drops the run and result lines from a test's output.
+func goTestMessage(lines []string) string {
+	var msg []string
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "=== ") || strings.HasPrefix(trimmed, "--- ") ||
+			trimmed == "FAIL" || trimmed == "PASS" || goPackageRe.MatchString(trimmed) {
+			continue
+		}
+		msg = append(msg, line)
+	}
+	return dedent(msg)
+}
+
+// leafFailures drops tests whose failure is explained by a failing subtest
+// (go reports "TestA" as failed when "TestA/case" fails).
+func leafFailures(failures []Failure) []Failure {
+	out := make([]Failure, 0, len(failures))
+	for _, f := range failures {
+		parent := false
+		for _, other := range failures {
+			if other.Suite == f.Suite && strings.HasPrefix(other.Name, f.Name+"/") {
+				parent = true
+				break
+			}
+		}
+		if !parent {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+func hasSuite(failures []Failure, suite string) bool {
+	for _, f := range failures {
+		if f.Suite == suite {
+			return true
+		}
+	}
+	return false
+}
+
+func indentOf(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
@@ -0,0 +1,72 @@
+package testreport
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// jestSummaryRe matches the "Tests:       1 failed, 5 passed, 6 total" line.
+	jestSummaryRe = regexp.MustCompile(`(?m)^Tests:\s+(.*\d+ total)\s*$`)
+	// jestFileRe matches the per-file "FAIL src/a.test.js" result lines.
+	jestFileRe = regexp.MustCompile(`^\s*(PASS|FAIL)\s+(\S+)`)
+	// jestHeaderRe matches failure headers: "  ● Suite › test name".
+	jestHeaderRe = regexp.MustCompile(`^\s*● (.+)$`)
+	// jestFrameRe matches stack frames: "at Object.<anonymous> (src/a.test.js:12:5)".
+	jestFrameRe = regexp.MustCompile(`^\s*at .*?\(?([^\s()]+):(\d+):\d+\)?\s*$`)
+)
+
+// parseJest parses jest (and vitest in its jest-compatible reporter) output.
+func parseJest(output string) (Report, bool) {
+	summaries := jestSummaryRe.FindAllStringSubmatch(output, -1)
+	if len(summaries) == 0 {
+		return Report{}, false
+	}
+	report := Report{Format: FormatJest}
+	parseCounts(summaries[len(summaries)-1][1], &report,
+		[]string{"passed"}, []string{"failed"}, []string{"skipped", "todo"})
+
+	lines := strings.Split(output, "\n")
+	seen := make(map[string]bool)
+	file := ""
+	for i := 0; i < len(lines); i++ {
+		if m := jestFileRe.FindStringSubmatch(lines[i]); m != nil {
+			file = m[2]
+			continue
+		}
+		m := jestHeaderRe.FindStringSubmatch(lines[i])
+		if m == nil {
+			continue
+		}
+		var body []string
+		for i+1 < len(lines) && !jestHeaderRe.MatchString(lines[i+1]) && !jestFileRe.MatchString(lines[i+1]) &&
+			!strings.HasPrefix(lines[i+1], "Test Suites:") && !strings.HasPrefix(lines[i+1], "Summary of all failing tests") {
+			body = append(body, lines[i+1])
+			i++
+		}
+
+		// jest repeats failures in its closing summary; keep the first.
+		key := file + "\x00" + m[1]
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		parts := strings.Split(m[1], " › ")
+		f := Failure{Name: parts[len(parts)-1], Suite: strings.Join(parts[:len(parts)-1], " › "), File: file}
+		var msg []string
+		for _, line := range body {
+			if fm := jestFrameRe.FindStringSubmatch(line); fm != nil {
+				if f.Line == 0 && !strings.Contains(fm[1], "node_modules") && (file == "" || strings.HasSuffix(fm[1], file)) {
+					f.Line, _ = strconv.Atoi(fm[2])
+				}
+				continue
+			}
+			msg = append(msg, line)
+		}
+		f.Message = dedent(msg)
+		report.Failures = append(report.Failures, f)
+	}
+	return report, true
+}
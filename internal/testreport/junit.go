@@ -0,0 +1,97 @@
+package testreport
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type junitSuite struct {
+	Name   string       `xml:"name,attr"`
+	File   string       `xml:"file,attr"`
+	Cases  []junitCase  `xml:"testcase"`
+	Suites []junitSuite `xml:"testsuite"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	File      string        `xml:"file,attr"`
+	Line      string        `xml:"line,attr"`
+	Failure   *junitProblem `xml:"failure"`
+	Error     *junitProblem `xml:"error"`
+	Skipped   *struct{}     `xml:"skipped"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// junitStart returns the offset of a JUnit XML document in output, or -1.
+func junitStart(output string) int {
+	i := strings.Index(output, "<testsuite")
+	if i < 0 {
+		return -1
+	}
+	if decl := strings.LastIndex(output[:i], "<?xml"); decl >= 0 {
+		return decl
+	}
+	return i
+}
+
+// ParseJUnit parses a JUnit XML report with a <testsuites> or <testsuite>
+// root, as written by most test runners' JUnit reporters.
+func ParseJUnit(data []byte) (Report, error) {
+	var root junitSuite
+	if err := xml.NewDecoder(bytes.NewReader(data)).Decode(&root); err != nil {
+		return Report{}, fmt.Errorf("parse junit xml: %w", err)
+	}
+	report := Report{Format: FormatJUnit, Failures: []Failure{}}
+	addJUnitSuite(&report, root)
+	return report, nil
+}
+
+func addJUnitSuite(report *Report, suite junitSuite) {
+	for _, c := range suite.Cases {
+		problem := c.Failure
+		if problem == nil {
+			problem = c.Error
+		}
+		switch {
+		case problem != nil:
+			f := Failure{Name: c.Name, Suite: c.Classname, File: c.File, Message: junitMessage(*problem)}
+			if f.Suite == "" {
+				f.Suite = suite.Name
+			}
+			if f.File == "" {
+				f.File = suite.File
+			}
+			f.Line, _ = strconv.Atoi(c.Line)
+			report.Failures = append(report.Failures, f)
+			report.Failed++
+		case c.Skipped != nil:
+			report.Skipped++
+		default:
+			report.Passed++
+		}
+	}
+	for _, child := range suite.Suites {
+		addJUnitSuite(report, child)
+	}
+}
+
+func junitMessage(p junitProblem) string {
+	text := strings.TrimSpace(p.Text)
+	msg := strings.TrimSpace(p.Message)
+	switch {
+	case text == "":
+		return msg
+	case msg == "" || strings.Contains(text, msg):
+		return text
+	default:
+		return msg + "\n" + text
+	}
+}
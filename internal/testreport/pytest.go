@@ -0,0 +1,82 @@
+package testreport
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// pytestSummaryRe matches the final "=== 2 failed, 10 passed in 0.12s ===" line.
+	pytestSummaryRe = regexp.MustCompile(`(?m)^=+ (.*\d+ \w+.*) in [\d.]+s(?: \([^)]*\))? =+\s*$`)
+	// pytestResultRe matches the short test summary "FAILED nodeid - message" lines.
+	pytestResultRe = regexp.MustCompile(`(?m)^(FAILED|ERROR) (\S+)(?: - (.*))?$`)
+	// pytestSectionRe matches the "____ TestClass.test_name ____" failure headers.
+	pytestSectionRe = regexp.MustCompile(`(?m)^_{3,} (.+?) _{3,}\s*$`)
+	// pytestLocationRe matches traceback locations: "tests/test_x.py:12: AssertionError".
+	pytestLocationRe = regexp.MustCompile(`(?m)^([\w./\\-]+\.py):(\d+): `)
+)
+
+// parsePytest parses pytest output. The failing tests come from the short
+// test summary, which pytest prints for failures and errors by default.
+func parsePytest(output string) (Report, bool) {
+	summaries := pytestSummaryRe.FindAllStringSubmatch(output, -1)
+	if len(summaries) == 0 {
+		return Report{}, false
+	}
+	report := Report{Format: FormatPytest}
+	parseCounts(summaries[len(summaries)-1][1], &report,
+		[]string{"passed", "xfailed"}, []string{"failed", "error", "errors"}, []string{"skipped", "xpassed", "deselected"})
+
+	sections := pytestSections(output)
+	for _, m := range pytestResultRe.FindAllStringSubmatch(output, -1) {
+		nodeID := m[2]
+		file, name, _ := strings.Cut(nodeID, "::")
+		if name == "" {
+			name = file
+		}
+		f := Failure{Name: name, File: file, Message: m[3]}
+		section := sections[strings.ReplaceAll(name, "::", ".")]
+		if f.Message == "" {
+			f.Message = pytestErrorLines(section)
+		}
+		// The last traceback entry in the test's own file is where it failed.
+		for _, loc := range pytestLocationRe.FindAllStringSubmatch(section, -1) {
+			if loc[1] == file {
+				f.Line, _ = strconv.Atoi(loc[2])
+			}
+		}
+		report.Failures = append(report.Failures, f)
+	}
+	return report, true
+}
+
+// pytestSections splits the FAILURES and ERRORS sections by test header.
+func pytestSections(output string) map[string]string {
+	sections := make(map[string]string)
+	headers := pytestSectionRe.FindAllStringSubmatchIndex(output, -1)
+	for i, h := range headers {
+		end := len(output)
+		if i+1 < len(headers) {
+			end = headers[i+1][0]
+		}
+		body := output[h[1]:end]
+		if j := strings.Index(body, "\n====="); j >= 0 {
+			body = body[:j]
+		}
+		name := strings.TrimPrefix(output[h[2]:h[3]], "ERROR at setup of ")
+		sections[name] = body
+	}
+	return sections
+}
+
+// pytestErrorLines returns the "E   ..." lines of a failure section.
+func pytestErrorLines(section string) string {
+	var lines []string
+	for _, line := range strings.Split(section, "\n") {
+		if strings.HasPrefix(line, "E ") {
+			lines = append(lines, strings.TrimSpace(strings.TrimPrefix(line, "E")))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
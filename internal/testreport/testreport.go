@@ -0,0 +1,236 @@
+// Package testreport turns test runner output into a structured report of
+// pass/fail counts and failing tests. It understands go test (plain and
+// -json), pytest, jest and JUnit XML.
+package testreport
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Report formats.
+const (
+	FormatGoJSON = "go test -json"
+	FormatGo     = "go test"
+	FormatPytest = "pytest"
+	FormatJest   = "jest"
+	FormatJUnit  = "junit"
+)
+
+// Report is the outcome of one test run. It is stored as the test_output
+// artifact's data. Preexisting holds failures that also fail on the base
+// branch at commit Baseline; they are not counted in Failed.
+type Report struct {
+	Format      string    `json:"format"`
+	Passed      int       `json:"passed"`
+	Failed      int       `json:"failed"`
+	Skipped     int       `json:"skipped"`
+	Failures    []Failure `json:"failures"`
+	Preexisting []Failure `json:"preexisting,omitempty"`
+	Baseline    string    `json:"baseline,omitempty"`
+}
+
+// Failure is one failing test. Suite is the package, module, class or
+// describe block the test belongs to; File and Line locate the failure when
+// the runner reports it.
+type Failure struct {
+	Name    string `json:"name"`
+	Suite   string `json:"suite,omitempty"`
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// Summary limits.
+const (
+	maxSummaryFailures     = 20
+	maxFailureMessageLines = 20
+	maxFailureMessageBytes = 2000
+)
+
+// Parse detects the runner that produced output and parses it. It reports
+// false when the output is in no known format.
+func Parse(output string) (Report, bool) {
+	if i := junitStart(output); i >= 0 {
+		if report, err := ParseJUnit([]byte(output[i:])); err == nil {
+			return report, true
+		}
+	}
+	parsers := []func(string) (Report, bool){parseGoJSON, parseJest, parsePytest, parseGo}
+	for _, parse := range parsers {
+		if report, ok := parse(output); ok {
+			return report, true
+		}
+	}
+	return Report{}, false
+}
+
+// Merge adds other's counts and failures to r.
+func (r *Report) Merge(other Report) {
+	if r.Format == "" {
+		r.Format = other.Format
+	}
+	r.Passed += other.Passed
+	r.Failed += other.Failed
+	r.Skipped += other.Skipped
+	r.Failures = append(r.Failures, other.Failures...)
+}
+
+// Subtract moves the failures that also fail in baseline, a run of the same
+// tests on the base branch at commit sha, from Failures to Preexisting.
+func (r *Report) Subtract(baseline Report, sha string) {
+	known := make(map[string]bool, len(baseline.Failures))
+	for _, f := range baseline.Failures {
+		known[f.key()] = true
+	}
+	r.Baseline = sha
+	kept := r.Failures[:0:0]
+	for _, f := range r.Failures {
+		if known[f.key()] {
+			r.Preexisting = append(r.Preexisting, f)
+			continue
+		}
+		kept = append(kept, f)
+	}
+	r.Failures = kept
+	r.Failed = max(r.Failed-len(r.Preexisting), len(kept))
+}
+
+// Counts renders the pass/fail counts, e.g. "12 passed, 2 failed, 1 skipped".
+func (r Report) Counts() string {
+	counts := fmt.Sprintf("%d passed, %d failed", r.Passed, r.Failed)
+	if r.Skipped > 0 {
+		counts += fmt.Sprintf(", %d skipped", r.Skipped)
+	}
+	if len(r.Preexisting) > 0 {
+		counts += fmt.Sprintf(", %d pre-existing", len(r.Preexisting))
+	}
+	return counts
+}
+
+// Summary renders the counts and the failing tests with their location and
+// a trimmed message, for the implement prompt and the TUI.
+func (r Report) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Tests: %s (%s)\n", r.Counts(), r.Format)
+	for i, f := range r.Failures {
+		if i == maxSummaryFailures {
+			fmt.Fprintf(&b, "\n... and %d more failing tests\n", len(r.Failures)-i)
+			break
+		}
+		fmt.Fprintf(&b, "\nFAIL %s\n", f.Title())
+		if msg := trimMessage(f.Message); msg != "" {
+			for _, line := range strings.Split(msg, "\n") {
+				fmt.Fprintf(&b, "    %s\n", line)
+			}
+		}
+	}
+	if len(r.Preexisting) > 0 {
+		fmt.Fprintf(&b, "\nAlso failing on the base branch (%s), unrelated to this change:\n", shortSHA(r.Baseline))
+		for i, f := range r.Preexisting {
+			if i == maxSummaryFailures {
+				fmt.Fprintf(&b, "... and %d more\n", len(r.Preexisting)-i)
+				break
+			}
+			fmt.Fprintf(&b, "- %s\n", f.Title())
+		}
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// Title returns the test name with its suite and location, e.g.
+// "TestDiv (example.com/calc) at calc_test.go:21".
+func (f Failure) Title() string {
+	title := f.Name
+	if f.Suite != "" {
+		title += " (" + f.Suite + ")"
+	}
+	if loc := f.Location(); loc != "" {
+		title += " at " + loc
+	}
+	return title
+}
+
+// key identifies a test across runs. The line is left out so that a test
+// still matches its baseline after unrelated edits shift it.
+func (f Failure) key() string {
+	return f.Suite + "\x00" + f.File + "\x00" + f.Name
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 12 {
+		return sha[:12]
+	}
+	return sha
+}
+
+// Location returns "file:line", "file" or "".
+func (f Failure) Location() string {
+	switch {
+	case f.File != "" && f.Line > 0:
+		return fmt.Sprintf("%s:%d", f.File, f.Line)
+	default:
+		return f.File
+	}
+}
+
+func trimMessage(msg string) string {
+	msg = strings.TrimSpace(msg)
+	truncated := false
+	if len(msg) > maxFailureMessageBytes {
+		msg = msg[:maxFailureMessageBytes]
+		truncated = true
+	}
+	if lines := strings.Split(msg, "\n"); len(lines) > maxFailureMessageLines {
+		msg = strings.Join(lines[:maxFailureMessageLines], "\n")
+		truncated = true
+	}
+	if truncated {
+		msg += "\n... (truncated)"
+	}
+	return msg
+}
+
+// countRe matches "<n> <word>" pairs in runner summary lines.
+var countRe = regexp.MustCompile(`(\d+) (\w+)`)
+
+// parseCounts reads "<n> passed, <n> failed, ..." style counts. Words in
+// failed count as failures, words in skipped as skips.
+func parseCounts(line string, r *Report, passed, failed, skipped []string) {
+	for _, m := range countRe.FindAllStringSubmatch(line, -1) {
+		n, _ := strconv.Atoi(m[1])
+		switch word := m[2]; {
+		case slices.Contains(passed, word):
+			r.Passed += n
+		case slices.Contains(failed, word):
+			r.Failed += n
+		case slices.Contains(skipped, word):
+			r.Skipped += n
+		}
+	}
+}
+
+// dedent removes the indentation common to all non-blank lines.
+func dedent(lines []string) string {
+	indent := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		n := len(line) - len(strings.TrimLeft(line, " \t"))
+		if indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	out := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if len(line) >= indent && indent > 0 {
+			line = line[indent:]
+		}
+		out = append(out, strings.TrimRight(line, " \t\r"))
+	}
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
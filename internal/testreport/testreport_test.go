@@ -0,0 +1,216 @@
+package testreport
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseGoJSON(t *testing.T) {
+	output := `{"Action":"start","Package":"example.com/calc"}
+{"Action":"run","Package":"example.com/calc","Test":"TestAdd"}
+{"Action":"output","Package":"example.com/calc","Test":"TestAdd","Output":"=== RUN   TestAdd\n"}
+{"Action":"output","Package":"example.com/calc","Test":"TestAdd","Output":"--- PASS: TestAdd (0.00s)\n"}
+{"Action":"pass","Package":"example.com/calc","Test":"TestAdd","Elapsed":0}
+{"Action":"run","Package":"example.com/calc","Test":"TestDiv"}
+{"Action":"run","Package":"example.com/calc","Test":"TestDiv/by_zero"}
+{"Action":"output","Package":"example.com/calc","Test":"TestDiv/by_zero","Output":"=== RUN   TestDiv/by_zero\n"}
+{"Action":"output","Package":"example.com/calc","Test":"TestDiv/by_zero","Output":"    calc_test.go:21: Div(1, 0) = 0, want error\n"}
+{"Action":"output","Package":"example.com/calc","Test":"TestDiv/by_zero","Output":"--- FAIL: TestDiv/by_zero (0.00s)\n"}
+{"Action":"fail","Package":"example.com/calc","Test":"TestDiv/by_zero","Elapsed":0}
+{"Action":"fail","Package":"example.com/calc","Test":"TestDiv","Elapsed":0}
+{"Action":"skip","Package":"example.com/calc","Test":"TestSlow","Elapsed":0}
+{"Action":"fail","Package":"example.com/calc","Elapsed":0.01}
+{"Action":"output","Package":"example.com/broken","Output":"FAIL\texample.com/broken [setup failed]\n"}
+{"Action":"fail","Package":"example.com/broken","Elapsed":0}
+`
+	report, ok := Parse(output)
+	if !ok || report.Format != FormatGoJSON {
+		t.Fatalf("expected go test -json report, got %+v (ok %v)", report, ok)
+	}
+	if report.Passed != 1 || report.Failed != 2 || report.Skipped != 1 {
+		t.Fatalf("unexpected counts: %s", report.Counts())
+	}
+	f := report.Failures[0]
+	if f.Name != "TestDiv/by_zero" || f.Suite != "example.com/calc" || f.File != "calc_test.go" || f.Line != 21 || f.Message != "calc_test.go:21: Div(1, 0) = 0, want error" {
+		t.Fatalf("unexpected failure: %+v", f)
+	}
+	if report.Failures[1].Name != "(package)" || report.Failures[1].Suite != "example.com/broken" {
+		t.Fatalf("expected the package without tests to fail as a whole, got %+v", report.Failures[1])
+	}
+}
+
+func TestParseGo(t *testing.T) {
+	output := `=== RUN   TestAdd
+--- PASS: TestAdd (0.00s)
+=== RUN   TestDiv
+=== RUN   TestDiv/by_zero
+    calc_test.go:21: Div(1, 0) = 0, want error
+        extra detail
+--- FAIL: TestDiv (0.00s)
+    --- FAIL: TestDiv/by_zero (0.00s)
+FAIL
+FAIL	example.com/calc	0.004s
+# example.com/broken
+broken/main.go:3:2: undefined: missing
+FAIL	example.com/broken [build failed]
+ok  	example.com/other	(cached)
+`
+	report, ok := Parse(output)
+	if !ok || report.Format != FormatGo {
+		t.Fatalf("expected go test report, got %+v (ok %v)", report, ok)
+	}
+	if report.Passed != 1 || report.Failed != 2 {
+		t.Fatalf("unexpected counts: %s", report.Counts())
+	}
+	if f := report.Failures[0]; f.Name != "TestDiv/by_zero" || f.Suite != "example.com/calc" || f.Line != 0 {
+		t.Fatalf("unexpected test failure: %+v", f)
+	}
+	if f := report.Failures[1]; f.Suite != "example.com/broken" || f.File != "broken/main.go" || f.Line != 3 || !strings.Contains(f.Message, "undefined: missing") {
+		t.Fatalf("unexpected build failure: %+v", f)
+	}
+}
+
+func TestParsePytest(t *testing.T) {
+	output := `============================= test session starts ==============================
+collected 4 items
+
+tests/test_calc.py .F.s                                                  [100%]
+
+=================================== FAILURES ===================================
+__________________________ TestCalc.test_div_by_zero ___________________________
+
+self = <tests.test_calc.TestCalc object at 0x1>
+
+    def test_div_by_zero(self):
+>       assert div(1, 0) is None
+E       ZeroDivisionError: division by zero
+
+tests/test_calc.py:12: ZeroDivisionError
+=========================== short test summary info ============================
+FAILED tests/test_calc.py::TestCalc::test_div_by_zero - ZeroDivisionError: division by zero
+==================== 1 failed, 2 passed, 1 skipped in 0.05s ====================
+`
+	report, ok := Parse(output)
+	if !ok || report.Format != FormatPytest {
+		t.Fatalf("expected pytest report, got %+v (ok %v)", report, ok)
+	}
+	if report.Passed != 2 || report.Failed != 1 || report.Skipped != 1 {
+		t.Fatalf("unexpected counts: %s", report.Counts())
+	}
+	f := report.Failures[0]
+	if f.Name != "TestCalc::test_div_by_zero" || f.File != "tests/test_calc.py" || f.Line != 12 || f.Message != "ZeroDivisionError: division by zero" {
+		t.Fatalf("unexpected failure: %+v", f)
+	}
+}
+
+func TestParseJest(t *testing.T) {
+	output := `PASS src/add.test.js
+FAIL src/div.test.js
+  ● div › rejects division by zero
+
+    expect(received).toThrow()
+
+    Received function did not throw
+
+      10 |   it('rejects division by zero', () => {
+    > 11 |     expect(() => div(1, 0)).toThrow();
+         |                             ^
+
+      at Object.<anonymous> (src/div.test.js:11:29)
+      at Promise.then.completed (node_modules/jest-circus/build/utils.js:298:28)
+
+Test Suites: 1 failed, 1 passed, 2 total
+Tests:       1 failed, 1 skipped, 4 passed, 6 total
+Snapshots:   0 total
+Time:        0.8 s
+`
+	report, ok := Parse(output)
+	if !ok || report.Format != FormatJest {
+		t.Fatalf("expected jest report, got %+v (ok %v)", report, ok)
+	}
+	if report.Passed != 4 || report.Failed != 1 || report.Skipped != 1 {
+		t.Fatalf("unexpected counts: %s", report.Counts())
+	}
+	f := report.Failures[0]
+	if f.Name != "rejects division by zero" || f.Suite != "div" || f.File != "src/div.test.js" || f.Line != 11 {
+		t.Fatalf("unexpected failure: %+v", f)
+	}
+	if !strings.HasPrefix(f.Message, "expect(received).toThrow()") || strings.Contains(f.Message, "node_modules") {
+		t.Fatalf("unexpected failure message:\n%s", f.Message)
+	}
+}
+
+func TestParseJUnit(t *testing.T) {
+	data := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="CalcTest" file="src/test/CalcTest.java">
+    <testcase name="adds" classname="com.example.CalcTest"/>
+    <testcase name="divides" classname="com.example.CalcTest" line="42">
+      <failure message="expected: 2 but was: 3">org.opentest4j.AssertionFailedError: expected: 2 but was: 3
+	at com.example.CalcTest.divides(CalcTest.java:42)</failure>
+    </testcase>
+    <testcase name="slow" classname="com.example.CalcTest"><skipped/></testcase>
+  </testsuite>
+  <testsuite name="IOTest">
+    <testcase name="reads" classname="com.example.IOTest"><error message="boom"/></testcase>
+  </testsuite>
+</testsuites>`
+	report, ok := Parse("BUILD FAILED\n" + data)
+	if !ok || report.Format != FormatJUnit {
+		t.Fatalf("expected junit report, got %+v (ok %v)", report, ok)
+	}
+	if report.Passed != 1 || report.Failed != 2 || report.Skipped != 1 {
+		t.Fatalf("unexpected counts: %s", report.Counts())
+	}
+	f := report.Failures[0]
+	if f.Name != "divides" || f.Suite != "com.example.CalcTest" || f.File != "src/test/CalcTest.java" || f.Line != 42 || !strings.HasPrefix(f.Message, "org.opentest4j") {
+		t.Fatalf("unexpected failure: %+v", f)
+	}
+	if report.Failures[1].Message != "boom" {
+		t.Fatalf("expected error message, got %+v", report.Failures[1])
+	}
+}
+
+func TestParseUnknownOutput(t *testing.T) {
+	if report, ok := Parse("make: *** [test] Error 1\n"); ok {
+		t.Fatalf("expected unknown output to be unparsed, got %+v", report)
+	}
+}
+
+func TestSummaryLimitsFailures(t *testing.T) {
+	report := Report{Format: FormatGo, Passed: 3}
+	for range maxSummaryFailures + 2 {
+		report.Failures = append(report.Failures, Failure{Name: "TestX", File: "x_test.go", Line: 7, Message: strings.Repeat("line\n", 30)})
+	}
+	report.Failed = len(report.Failures)
+
+	summary := report.Summary()
+	if !strings.HasPrefix(summary, "Tests: 3 passed, 22 failed (go test)\n\nFAIL TestX at x_test.go:7\n    line\n") {
+		t.Fatalf("unexpected summary start:\n%s", summary[:200])
+	}
+	if !strings.HasSuffix(summary, "... and 2 more failing tests") || !strings.Contains(summary, "... (truncated)") {
+		t.Fatalf("expected truncated messages and failures, got:\n%s", summary)
+	}
+}
+
+func TestSubtractBaseline(t *testing.T) {
+	report := Report{Format: FormatPytest, Passed: 5, Failed: 2, Failures: []Failure{
+		{Name: "test_old", File: "tests/test_a.py", Line: 10},
+		{Name: "test_new", File: "tests/test_a.py", Line: 20},
+	}}
+	baseline := Report{Failures: []Failure{{Name: "test_old", File: "tests/test_a.py", Line: 8}}}
+
+	report.Subtract(baseline, "0123456789abcdef")
+	if report.Failed != 1 || len(report.Failures) != 1 || report.Failures[0].Name != "test_new" {
+		t.Fatalf("expected only test_new to fail, got %+v", report)
+	}
+	if len(report.Preexisting) != 1 || report.Baseline != "0123456789abcdef" {
+		t.Fatalf("expected test_old to be pre-existing, got %+v", report)
+	}
+	if got, want := report.Counts(), "5 passed, 1 failed, 1 pre-existing"; got != want {
+		t.Fatalf("Counts() = %q, want %q", got, want)
+	}
+	if !strings.HasSuffix(report.Summary(), "Also failing on the base branch (0123456789ab), unrelated to this change:\n- test_old at tests/test_a.py:10") {
+		t.Fatalf("unexpected summary:\n%s", report.Summary())
+	}
+}
@@ -2,6 +2,7 @@ package tui
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
@@ -14,9 +15,11 @@ import (
 	"time"
 
 	"autopr/internal/config"
+	"autopr/internal/cost"
 	"autopr/internal/db"
 	"autopr/internal/git"
 	"autopr/internal/pipeline"
+	"autopr/internal/testreport"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/glamour"
@@ -40,10 +43,17 @@ var (
 	stateStyle    = map[string]lipgloss.Style{
 		"queued":              lipgloss.NewStyle().Foreground(lipgloss.Color("246")),
 		"planning":            lipgloss.NewStyle().Foreground(lipgloss.Color("33")),
+		"reviewing plan":      lipgloss.NewStyle().Foreground(lipgloss.Color("214")),
+		"reviewing_plan":      lipgloss.NewStyle().Foreground(lipgloss.Color("214")),
+		"plan approval":       lipgloss.NewStyle().Foreground(lipgloss.Color("51")),
+		"needs info":          lipgloss.NewStyle().Foreground(lipgloss.Color("51")),
 		"implementing":        lipgloss.NewStyle().Foreground(lipgloss.Color("33")),
+		"running command":     lipgloss.NewStyle().Foreground(lipgloss.Color("214")),
+		"running_command":     lipgloss.NewStyle().Foreground(lipgloss.Color("214")),
 		"reviewing":           lipgloss.NewStyle().Foreground(lipgloss.Color("214")),
 		"testing":             lipgloss.NewStyle().Foreground(lipgloss.Color("214")),
 		"ready":               lipgloss.NewStyle().Foreground(lipgloss.Color("46")),
+		"blocked":             lipgloss.NewStyle().Foreground(lipgloss.Color("196")),
 		"rebasing":            lipgloss.NewStyle().Foreground(lipgloss.Color("135")),
 		"resolving":           lipgloss.NewStyle().Foreground(lipgloss.Color("202")),
 		"resolving_conflicts": lipgloss.NewStyle().Foreground(lipgloss.Color("202")),
@@ -55,12 +65,17 @@ var (
 		"rejected":            lipgloss.NewStyle().Foreground(lipgloss.Color("196")),
 		"failed":              lipgloss.NewStyle().Foreground(lipgloss.Color("196")),
 		"cancelled":           lipgloss.NewStyle().Foreground(lipgloss.Color("244")),
+		"needs human":         lipgloss.NewStyle().Foreground(lipgloss.Color("208")),
+		"needs_human":         lipgloss.NewStyle().Foreground(lipgloss.Color("208")),
 	}
 	sessStatusStyle = map[string]lipgloss.Style{
 		"running":   lipgloss.NewStyle().Foreground(lipgloss.Color("33")),
 		"completed": lipgloss.NewStyle().Foreground(lipgloss.Color("46")),
 		"failed":    lipgloss.NewStyle().Foreground(lipgloss.Color("196")),
 		"cancelled": lipgloss.NewStyle().Foreground(lipgloss.Color("244")),
+		"warned":    lipgloss.NewStyle().Foreground(lipgloss.Color("214")),
+		"approved":  lipgloss.NewStyle().Foreground(lipgloss.Color("46")),
+		"changes":   lipgloss.NewStyle().Foreground(lipgloss.Color("214")),
 	}
 	diffAddStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("46"))
 	diffDelStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("196"))
@@ -86,11 +101,15 @@ var filterStateCycle = []string{
 	filterAllState,
 	"queued",
 	"active",
+	"awaiting_plan_approval",
+	"needs_info",
 	"awaiting_checks",
 	"rebasing",
 	"resolving_conflicts",
 	"ready",
+	"blocked",
 	"failed",
+	"needs_human",
 	"merged",
 	"rejected",
 	"cancelled",
@@ -110,6 +129,7 @@ var filterStateCycle = []string{
 type Model struct {
 	store *db.Store
 	cfg   *config.Config
+	costs *cost.Table
 
 	// Level 1: job list
 	jobs                []db.Job
@@ -131,11 +151,13 @@ type Model struct {
 	filterCursorBefore  int
 
 	// Level 2: job detail + session list
-	selected       *db.Job
-	sessions       []db.LLMSessionSummary
-	testArtifact   *db.Artifact // test_output artifact (nil if tests haven't run)
-	rebaseArtifact *db.Artifact // rebase_result or rebase_conflict artifact
-	sessCursor     int
+	selected         *db.Job
+	sessions         []db.LLMSessionSummary
+	reviewArtifact   *db.Artifact  // latest code_review artifact with a parsed verdict
+	testArtifact     *db.Artifact  // test_output artifact (nil if tests haven't run)
+	rebaseArtifact   *db.Artifact  // rebase_result or rebase_conflict artifact
+	commandArtifacts []db.Artifact // latest output of each pipeline command step
+	sessCursor       int
 
 	// Level 2: confirmation prompt and action feedback
 	confirmAction  string // "approve", "merge", "reject", "retry", "cancel", or "" (none)
@@ -166,6 +188,7 @@ func NewModel(store *db.Store, cfg *config.Config) Model {
 	return Model{
 		store:         store,
 		cfg:           cfg,
+		costs:         cost.NewTable(cfg.Cost),
 		sortColumn:    "updated_at",
 		sortAsc:       false,
 		filterState:   filterAllState,
@@ -184,11 +207,13 @@ type jobsMsg struct {
 }
 type issueSummaryMsg db.IssueSyncSummary
 type sessionsMsg struct {
-	jobID          string
-	job            db.Job
-	sessions       []db.LLMSessionSummary
-	testArtifact   *db.Artifact
-	rebaseArtifact *db.Artifact
+	jobID            string
+	job              db.Job
+	sessions         []db.LLMSessionSummary
+	reviewArtifact   *db.Artifact
+	testArtifact     *db.Artifact
+	rebaseArtifact   *db.Artifact
+	commandArtifacts []db.Artifact
 }
 type sessionMsg struct {
 	jobID   string
@@ -275,6 +300,9 @@ func (m Model) fetchSessions() tea.Msg {
 	activeStep := db.StepForState(job.State)
 	sessions = filterGhostSessions(sessions, activeStep)
 	msg := sessionsMsg{jobID: jobID, job: job, sessions: sessions}
+	if art, err := m.store.GetLatestArtifact(context.Background(), jobID, "code_review"); err == nil && art.Data != "" {
+		msg.reviewArtifact = &art
+	}
 	if art, err := m.store.GetLatestArtifact(context.Background(), jobID, "test_output"); err == nil {
 		msg.testArtifact = &art
 	}
@@ -283,9 +311,31 @@ func (m Model) fetchSessions() tea.Msg {
 	} else if art, err := m.store.GetLatestArtifact(context.Background(), jobID, "rebase_conflict"); err == nil {
 		msg.rebaseArtifact = &art
 	}
+	if artifacts, err := m.store.ListArtifactsByJob(context.Background(), jobID); err == nil {
+		msg.commandArtifacts = latestCommandArtifacts(artifacts)
+	}
 	return msg
 }
 
+// latestCommandArtifacts returns the latest artifact of each command step, in
+// the order the steps first ran.
+func latestCommandArtifacts(artifacts []db.Artifact) []db.Artifact {
+	var out []db.Artifact
+	index := make(map[string]int)
+	for _, a := range artifacts {
+		if !strings.HasPrefix(a.Kind, "command:") {
+			continue
+		}
+		if i, ok := index[a.Kind]; ok {
+			out[i] = a
+			continue
+		}
+		index[a.Kind] = len(out)
+		out = append(out, a)
+	}
+	return out
+}
+
 func filterGhostSessions(sessions []db.LLMSessionSummary, activeStep string) []db.LLMSessionSummary {
 	out := make([]db.LLMSessionSummary, 0, len(sessions))
 	for _, sess := range sessions {
@@ -407,6 +457,9 @@ func (m Model) executeApprove() tea.Msg {
 	if err := pipeline.RebaseBeforePush(ctx, m.store, job.ID, job.AutoPRIssueID, proj.BaseBranch, job.WorktreePath, job.Iteration, m.cfg.GitTokenForProject(proj)); err != nil {
 		return actionResultMsg{action: "approve", err: fmt.Errorf("rebase before push: %w", err)}
 	}
+	if err := pipeline.ScanBeforePush(ctx, m.store, job.ID, job.AutoPRIssueID, proj.BaseBranch, job.WorktreePath, job.Iteration); err != nil {
+		return actionResultMsg{action: "approve", err: err}
+	}
 
 	pushRemote := "origin"
 	pushHead := job.BranchName
@@ -453,6 +506,14 @@ func (m Model) executeApprove() tea.Msg {
 	return actionResultMsg{action: "approve", prURL: prURL}
 }
 
+// executeApprovePlan approves the selected job's plan, like `ap approve-plan`.
+func (m Model) executeApprovePlan() tea.Msg {
+	if err := pipeline.ApprovePlan(context.Background(), m.store, m.selected.ID); err != nil {
+		return actionResultMsg{action: "approve_plan", err: err}
+	}
+	return actionResultMsg{action: "approve_plan"}
+}
+
 func (m Model) executeReject() tea.Msg {
 	return m.executeRejectWith("")
 }
@@ -460,7 +521,7 @@ func (m Model) executeReject() tea.Msg {
 func (m Model) executeRejectWith(reason string) func() tea.Msg {
 	return func() tea.Msg {
 		ctx := context.Background()
-		if err := m.store.RejectJob(ctx, m.selected.ID, "ready", reason); err != nil {
+		if err := m.store.RejectJob(ctx, m.selected.ID, m.selected.State, reason); err != nil {
 			return actionResultMsg{action: "reject", err: err}
 		}
 		return actionResultMsg{action: "reject"}
@@ -611,8 +672,10 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				// Job disappeared (deleted); go back to list.
 				m.selected = nil
 				m.sessions = nil
+				m.reviewArtifact = nil
 				m.testArtifact = nil
 				m.rebaseArtifact = nil
+				m.commandArtifacts = nil
 				m.sessCursor = 0
 				m.confirmAction = ""
 				m.confirmJobID = ""
@@ -633,8 +696,10 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 		m.selected = &msg.job
 		m.sessions = msg.sessions
+		m.reviewArtifact = msg.reviewArtifact
 		m.testArtifact = msg.testArtifact
 		m.rebaseArtifact = msg.rebaseArtifact
+		m.commandArtifacts = msg.commandArtifacts
 		// Clamp cursor rather than resetting so auto-refresh doesn't jump.
 		maxIdx := len(m.sessions) + len(m.pipelineSyntheticRows())
 		if maxIdx > 0 && m.sessCursor >= maxIdx {
@@ -679,8 +744,10 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			// Other actions keep existing behavior: return to Level 1.
 			m.selected = nil
 			m.sessions = nil
+			m.reviewArtifact = nil
 			m.testArtifact = nil
 			m.rebaseArtifact = nil
+			m.commandArtifacts = nil
 			m.sessCursor = 0
 			return m, tea.Batch(m.fetchJobs, m.fetchIssueSummary)
 		}
@@ -781,6 +848,8 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			switch action {
 			case "approve":
 				return m, m.executeApprove
+			case "approve_plan":
+				return m, m.executeApprovePlan
 			case "merge":
 				return m, m.executeMerge
 			case "reject":
@@ -1035,6 +1104,8 @@ func (m Model) projectFilterOptions() []string {
 type pipelineRowKind string
 
 const (
+	pipelineRowReview     pipelineRowKind = "review"
+	pipelineRowCommand    pipelineRowKind = "command"
 	pipelineRowTest       pipelineRowKind = "test"
 	pipelineRowRebase     pipelineRowKind = "rebase"
 	pipelineRowCheckingCI pipelineRowKind = "checking_ci"
@@ -1052,6 +1123,22 @@ type pipelineSyntheticRow struct {
 	tokens      string
 	start       string
 	duration    string
+	artifact    *db.Artifact // command step output, for command rows
+}
+
+// sessionCost returns the estimated cost of a session, including its
+// prompt-cache tokens.
+func (m Model) sessionCost(s db.LLMSessionSummary) float64 {
+	costs := m.costs
+	if costs == nil {
+		costs = cost.NewTable(config.CostConfig{})
+	}
+	return costs.Cost(s.LLMProvider, s.Model, cost.Usage{
+		Input:      s.InputTokens,
+		Output:     s.OutputTokens,
+		CacheRead:  s.CacheReadTokens,
+		CacheWrite: s.CacheCreationTokens,
+	})
 }
 
 func (m Model) pipelineSyntheticRows() []pipelineSyntheticRow {
@@ -1059,15 +1146,49 @@ func (m Model) pipelineSyntheticRows() []pipelineSyntheticRow {
 	if job == nil {
 		return nil
 	}
-	rows := make([]pipelineSyntheticRow, 0, 6)
+	rows := make([]pipelineSyntheticRow, 0, 7+len(m.commandArtifacts))
+	if verdict, ok := m.reviewVerdict(); ok {
+		status := "changes"
+		if verdict.Approved() {
+			status = "approved"
+		}
+		rows = append(rows, pipelineSyntheticRow{
+			kind:        pipelineRowReview,
+			stepLabel:   "findings",
+			sessionStep: "review_findings",
+			status:      status,
+			provider:    "-",
+			tokens:      fmt.Sprintf("%d found", len(verdict.Findings)),
+			start:       m.reviewArtifact.CreatedAt,
+			duration:    "-",
+		})
+	}
+	for i := range m.commandArtifacts {
+		art := &m.commandArtifacts[i]
+		rows = append(rows, pipelineSyntheticRow{
+			kind:        pipelineRowCommand,
+			stepLabel:   strings.TrimPrefix(art.Kind, "command:"),
+			sessionStep: art.Kind,
+			status:      m.commandStatus(art),
+			provider:    "shell",
+			tokens:      "-",
+			start:       art.CreatedAt,
+			duration:    "-",
+			artifact:    art,
+		})
+	}
 	if m.testArtifact != nil {
+		tokens := "-"
+		if report, ok := m.testReport(); ok {
+			tokens = fmt.Sprintf("%d/%d passed", report.Passed, report.Passed+report.Failed)
+		}
 		rows = append(rows, pipelineSyntheticRow{
 			kind:        pipelineRowTest,
 			stepLabel:   "testing",
 			sessionStep: "tests",
 			status:      m.testStatus(),
 			provider:    "-",
-			tokens:      "-",
+			tokens:      tokens,
 			start:       m.testArtifact.CreatedAt,
 			duration:    "-",
 		})
@@ -1250,6 +1371,12 @@ func (m Model) handleKeyLevel2(key string) (tea.Model, tea.Cmd) {
 		idx := m.sessCursor - len(m.sessions)
 		if idx >= 0 && idx < len(synthRows) {
 			switch synthRows[idx].kind {
+			case pipelineRowReview:
+				m = m.enterReviewView()
+				return m, nil
+			case pipelineRowCommand:
+				m = m.enterCommandView(synthRows[idx].artifact)
+				return m, nil
 			case pipelineRowTest:
 				m = m.enterTestView()
 				return m, nil
@@ -1296,12 +1423,16 @@ func (m Model) handleKeyLevel2(key string) (tea.Model, tea.Cmd) {
 			m.confirmDraft = true
 			startConfirm(&m, "approve", m.selected.ID)
 		}
+	case "P":
+		if m.selected != nil && m.selected.State == "awaiting_plan_approval" {
+			startConfirm(&m, "approve_plan", m.selected.ID)
+		}
 	case "x":
-		if m.selected != nil && m.selected.State == "ready" {
+		if m.selected != nil && (m.selected.State == "ready" || m.selected.State == "blocked") {
 			startConfirm(&m, "reject", m.selected.ID)
 		}
 	case "R":
-		if m.selected != nil && (m.selected.State == "failed" || m.selected.State == "rejected" || m.selected.State == "cancelled") {
+		if m.selected != nil && (m.selected.State == "failed" || m.selected.State == "rejected" || m.selected.State == "cancelled" || m.selected.State == "needs_human") {
 			startConfirm(&m, "retry", m.selected.ID)
 		}
 	case "c":
@@ -1318,8 +1449,10 @@ func (m Model) handleKeyLevel2(key string) (tea.Model, tea.Cmd) {
 		m.confirmTextBuf = ""
 		m.selected = nil
 		m.sessions = nil
+		m.reviewArtifact = nil
 		m.testArtifact = nil
 		m.rebaseArtifact = nil
+		m.commandArtifacts = nil
 		m.sessCursor = 0
 		m.confirmAction = ""
 		m.confirmJobID = ""
@@ -1419,18 +1552,95 @@ func (m Model) testStatus() string {
 	}
 }
 
+// testReport decodes the parsed results of the latest test run.
+func (m Model) testReport() (testreport.Report, bool) {
+	var r testreport.Report
+	if m.testArtifact == nil || m.testArtifact.Data == "" || json.Unmarshal([]byte(m.testArtifact.Data), &r) != nil {
+		return r, false
+	}
+	return r, true
+}
+
+// reviewVerdict decodes the structured verdict of the latest code review.
+func (m Model) reviewVerdict() (pipeline.ReviewVerdict, bool) {
+	var v pipeline.ReviewVerdict
+	if m.reviewArtifact == nil || json.Unmarshal([]byte(m.reviewArtifact.Data), &v) != nil {
+		return v, false
+	}
+	return v, true
+}
+
+// enterReviewView enters Level 3 to display the latest code review findings.
+func (m Model) enterReviewView() Model {
+	verdict, _ := m.reviewVerdict()
+	status := "completed"
+	if !verdict.Approved() {
+		status = "failed"
+	}
+	m.selectedSession = &db.LLMSession{
+		Step:         "code_review",
+		Iteration:    m.reviewArtifact.Iteration,
+		LLMProvider:  "-",
+		Status:       status,
+		ResponseText: pipeline.FormatReviewChecklist(verdict),
+		CreatedAt:    m.reviewArtifact.CreatedAt,
+	}
+	m.showInput = false
+	m.scrollOffset = 0
+	m.lines = splitContent(m.selectedSession.ResponseText, m.selectedSession.Status, m.cw())
+	return m
+}
+
+// commandStatus derives a command step's status from its latest artifact,
+// showing it as running while the job runs that step again.
+func (m Model) commandStatus(art *db.Artifact) string {
+	if m.selected != nil && m.selected.State == "running_command" && "command:"+m.selected.CommandStep == art.Kind {
+		return "running"
+	}
+	if art.Status == "passed" {
+		return "completed"
+	}
+	return art.Status
+}
+
+// enterCommandView enters Level 3 to display a command step's output.
+func (m Model) enterCommandView(art *db.Artifact) Model {
+	m.selectedSession = &db.LLMSession{
+		Step:         art.Kind,
+		Iteration:    art.Iteration,
+		LLMProvider:  "shell",
+		Status:       m.commandStatus(art),
+		ResponseText: art.Content,
+		CreatedAt:    art.CreatedAt,
+	}
+	m.showInput = false
+	m.scrollOffset = 0
+	m.lines = splitContent(m.selectedSession.ResponseText, m.selectedSession.Status, m.cw())
+	return m
+}
+
 // enterTestView enters Level 3 to display the test artifact output.
 func (m Model) enterTestView() Model {
 	testCmd := "(no test command configured)"
-	if p, ok := m.cfg.ProjectByName(m.selected.ProjectName); ok && p.TestCmd != "" {
+	if p, ok := m.cfg.ProjectByName(m.selected.ProjectName); ok && len(p.TestCmds) > 0 {
+		var cmds []string
+		for _, c := range p.TestCmds {
+			cmds = append(cmds, c.String())
+		}
+		testCmd = strings.Join(cmds, "\n")
+	} else if ok && p.TestCmd != "" {
 		testCmd = fmt.Sprintf("$ %s", p.TestCmd)
 	}
+	output := m.testArtifact.Content
+	if report, ok := m.testReport(); ok {
+		output = report.Summary() + "\n\n" + output
+	}
 	m.selectedSession = &db.LLMSession{
 		Step:         "tests",
 		Iteration:    m.testArtifact.Iteration,
 		LLMProvider:  "shell",
 		Status:       m.testStatus(),
-		ResponseText: m.testArtifact.Content,
+		ResponseText: output,
 		PromptText:   testCmd,
 		CreatedAt:    m.testArtifact.CreatedAt,
 	}
@@ -1631,7 +1841,7 @@ func (m Model) listView() string {
 
 	// Job state counters.
 	counts := m.jobCounts()
-	active := counts["planning"] + counts["implementing"] + counts["reviewing"] + counts["testing"] +
+	active := counts["planning"] + counts["reviewing_plan"] + counts["implementing"] + counts["running_command"] + counts["reviewing"] + counts["testing"] +
 		counts["rebasing"] + counts["resolving_conflicts"] + counts["awaiting_checks"]
 	b.WriteString(fmt.Sprintf("  %s %d   %s %d   %s %d   %s %d   %s %d\n",
 		labelStyle.Render("queued"), counts["queued"],
@@ -1640,9 +1850,13 @@ func (m Model) listView() string {
 		stateStyle["failed"].Render("failed"), counts["failed"],
 		stateStyle["cancelled"].Render("cancelled"), counts["cancelled"],
 	))
-	b.WriteString(fmt.Sprintf("  %s %d   %s %d\n",
+	b.WriteString(fmt.Sprintf("  %s %d   %s %d   %s %d   %s %d   %s %d   %s %d\n",
 		stateStyle["rebasing"].Render("rebasing"), counts["rebasing"],
 		stateStyle["resolving_conflicts"].Render("resolving"), counts["resolving_conflicts"],
+		stateStyle["needs_human"].Render("needs human"), counts["needs_human"],
+		stateStyle["blocked"].Render("blocked"), counts["blocked"],
+		stateStyle["plan approval"].Render("plan approval"), counts["awaiting_plan_approval"],
+		stateStyle["needs info"].Render("needs info"), counts["needs_info"],
 	))
 	if m.filterState != filterAllState || m.filterProject != filterAllProject {
 		b.WriteString(dimStyle.Render(fmt.Sprintf("  Filter: state=%s  project=%s\n",
@@ -1712,7 +1926,7 @@ func (m Model) listView() string {
 				cursor = "> "
 			}
 
-			displayState := db.DisplayState(job.State, job.PRMergedAt, job.PRClosedAt)
+			displayState := db.DisplayJobState(job)
 			st, ok := stateStyle[displayState]
 			if !ok {
 				st, ok = stateStyle[job.State]
@@ -1793,7 +2007,7 @@ func (m Model) detailView() string {
 	w := m.cw()
 	job := m.selected
 
-	displayState := db.DisplayState(job.State, job.PRMergedAt, job.PRClosedAt)
+	displayState := db.DisplayJobState(*job)
 	st, ok := stateStyle[displayState]
 	if !ok {
 		st, ok = stateStyle[job.State]
@@ -1822,6 +2036,13 @@ func (m Model) detailView() string {
 		kv("Title", job.IssueTitle)
 	}
 	kv("Retry", fmt.Sprintf("%d/%d", job.Iteration, job.MaxIterations))
+	if len(m.sessions) > 0 {
+		total := 0.0
+		for _, s := range m.sessions {
+			total += m.sessionCost(s)
+		}
+		kv("Cost", cost.FormatUSD(total))
+	}
 	if job.BranchName != "" {
 		kv("Branch", job.BranchName)
 	}
@@ -1866,6 +2087,7 @@ func (m Model) detailView() string {
 		sColStatus   = 12
 		sColProvider = 10
 		sColTokens   = 16
+		sColCost     = 8
 		sColStart    = 20
 		sColDuration = 10
 	)
@@ -1880,6 +2102,7 @@ func (m Model) detailView() string {
 			headerStyle.Render(padRight("STATUS", sColStatus)) +
 			headerStyle.Render(padRight("PROVIDER", sColProvider)) +
 			headerStyle.Render(padRight("TOKENS", sColTokens)) +
+			headerStyle.Render(padRight("COST", sColCost)) +
 			headerStyle.Render(padRight("START", sColStart)) +
 			headerStyle.Render("DURATION")
 		b.WriteString(header)
@@ -1909,6 +2132,7 @@ func (m Model) detailView() string {
 				statusCell.Render(padRight(s.Status, sColStatus)) +
 				textStyle.Render(padRight(s.LLMProvider, sColProvider)) +
 				textStyle.Render(padRight(tokens, sColTokens)) +
+				textStyle.Render(padRight(cost.FormatUSD(m.sessionCost(s)), sColCost)) +
 				dimCell.Render(padRight(start, sColStart)) +
 				dimCell.Render(padRight(dur, sColDuration))
 			b.WriteString(line)
@@ -1942,6 +2166,7 @@ func (m Model) detailView() string {
 				statusCell.Render(padRight(row.status, sColStatus)) +
 				textStyle.Render(padRight(row.provider, sColProvider)) +
 				textStyle.Render(padRight(row.tokens, sColTokens)) +
+				textStyle.Render(padRight("-", sColCost)) +
 				dimCell.Render(padRight(formatTimestamp(row.start), sColStart)) +
 				dimCell.Render(padRight(row.duration, sColDuration))
 			b.WriteString(line)
@@ -1979,10 +2204,16 @@ func (m Model) detailView() string {
 	if job.State == "ready" {
 		hintParts = append(hintParts, "a approve", "A draft", "x reject")
 	}
+	if job.State == "blocked" {
+		hintParts = append(hintParts, "x reject")
+	}
+	if job.State == "awaiting_plan_approval" {
+		hintParts = append(hintParts, "P approve plan")
+	}
 	if canMergePR(job) {
 		hintParts = append(hintParts, "m merge")
 	}
-	if job.State == "failed" || job.State == "rejected" || job.State == "cancelled" {
+	if job.State == "failed" || job.State == "rejected" || job.State == "cancelled" || job.State == "needs_human" {
 		hintParts = append(hintParts, "R retry")
 	}
 	if db.IsCancellableState(job.State) {
@@ -2031,6 +2262,9 @@ func (m Model) sessionView() string {
 	}
 	kv("Status", sst.Render(sess.Status))
 	kv("Provider", sess.LLMProvider)
+	if sess.Model != "" {
+		kv("Model", sess.Model)
+	}
 	kv("Tokens", fmt.Sprintf("%d in / %d out", sess.InputTokens, sess.OutputTokens))
 	kv("Start Time", formatTimestamp(sess.CreatedAt))
 	kv("Duration", formatDuration(sess.DurationMS))
@@ -2204,6 +2438,8 @@ func (m Model) confirmPrompt() string {
 			return "Approve job " + short + " and create draft PR?"
 		}
 		return "Approve job " + short + " and create PR?"
+	case "approve_plan":
+		return "Approve the plan of job " + short + " and start implementing?"
 	case "merge":
 		return "Merge PR for job " + short + "?"
 	case "reject":
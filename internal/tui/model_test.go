@@ -816,7 +816,7 @@ func TestFilterModeCycleStateAndProject(t *testing.T) {
 	modelAny, _ := m.handleKey(keyRunes('f'))
 	m = modelAny.(Model)
 
-	expectedStates := []string{"queued", "active", "awaiting_checks", "rebasing", "resolving_conflicts", "ready", "failed", "merged", "rejected", "cancelled", "all"}
+	expectedStates := []string{"queued", "active", "awaiting_plan_approval", "needs_info", "awaiting_checks", "rebasing", "resolving_conflicts", "ready", "blocked", "failed", "needs_human", "merged", "rejected", "cancelled", "all"}
 	for _, state := range expectedStates {
 		modelAny, _ = m.handleKey(keyRunes('s'))
 		m = modelAny.(Model)
@@ -2273,3 +2273,70 @@ func TestSyntheticSessionViewsCarryStartTimes(t *testing.T) {
 		t.Fatalf("expected pr-closed markdown to format PR closed timestamp, got:\n%s", closedView.selectedSession.ResponseText)
 	}
 }
+
+func TestPipelineCommandStepRows(t *testing.T) {
+	artifacts := latestCommandArtifacts([]db.Artifact{
+		{Kind: "plan", Content: "plan"},
+		{Kind: "command:lint", Content: "lint v1", Status: "failed"},
+		{Kind: "command:build", Content: "build ok", Status: "passed"},
+		{Kind: "command:lint", Content: "lint v2", Status: "warned"},
+	})
+	m := Model{
+		selected:         &db.Job{ID: "ap-job-1234", State: "running_command", CommandStep: "build"},
+		commandArtifacts: artifacts,
+	}
+
+	rows := m.pipelineSyntheticRows()
+	if len(rows) != 2 || rows[0].stepLabel != "lint" || rows[1].stepLabel != "build" {
+		t.Fatalf("expected lint then build rows, got %+v", rows)
+	}
+	if rows[0].status != "warned" || rows[1].status != "running" {
+		t.Fatalf("expected warned lint and running build, got %q and %q", rows[0].status, rows[1].status)
+	}
+
+	view := m.enterCommandView(rows[0].artifact)
+	if view.selectedSession.ResponseText != "lint v2" || view.selectedSession.Step != "command:lint" {
+		t.Fatalf("expected latest lint output in command view, got %+v", view.selectedSession)
+	}
+}
+
+func TestPipelineReviewFindingsRow(t *testing.T) {
+	m := Model{
+		selected: &db.Job{ID: "ap-job-1234", State: "implementing"},
+		reviewArtifact: &db.Artifact{
+			Kind:    "code_review",
+			Content: "raw review",
+			Data:    `{"decision":"request_changes","confidence":0.7,"findings":[{"severity":"major","file":"a.go","line":3,"message":"handle the error"}]}`,
+		},
+	}
+
+	rows := m.pipelineSyntheticRows()
+	if len(rows) != 1 || rows[0].kind != pipelineRowReview || rows[0].status != "changes" || rows[0].tokens != "1 found" {
+		t.Fatalf("expected one review findings row, got %+v", rows)
+	}
+	view := m.enterReviewView()
+	if !strings.Contains(view.selectedSession.ResponseText, "- [ ] [major] a.go:3 — handle the error") {
+		t.Fatalf("expected findings checklist in review view, got:\n%s", view.selectedSession.ResponseText)
+	}
+}
+
+func TestPipelineTestRowShowsCounts(t *testing.T) {
+	m := Model{
+		selected: &db.Job{ID: "ap-job-1234", State: "implementing"},
+		testArtifact: &db.Artifact{
+			Kind:    "test_output",
+			Content: "raw output",
+			Data:    `{"format":"go test -json","passed":7,"failed":1,"failures":[{"name":"TestDiv","suite":"example.com/calc","file":"calc_test.go","line":21,"message":"want error"}]}`,
+		},
+		cfg: &config.Config{},
+	}
+
+	rows := m.pipelineSyntheticRows()
+	if len(rows) != 1 || rows[0].kind != pipelineRowTest || rows[0].tokens != "7/8 passed" {
+		t.Fatalf("expected test row with counts, got %+v", rows)
+	}
+	view := m.enterTestView()
+	if !strings.HasPrefix(view.selectedSession.ResponseText, "Tests: 7 passed, 1 failed (go test -json)\n\nFAIL TestDiv (example.com/calc) at calc_test.go:21") {
+		t.Fatalf("expected failure summary in test view, got:\n%s", view.selectedSession.ResponseText)
+	}
+}
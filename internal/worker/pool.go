@@ -7,6 +7,7 @@ import (
 	"sync"
 	"time"
 
+	"autopr/internal/budget"
 	"autopr/internal/db"
 	"autopr/internal/pipeline"
 )
@@ -16,16 +17,18 @@ type Pool struct {
 	n        int
 	store    *db.Store
 	pipeline *pipeline.Runner
+	budget   *budget.Checker
 	jobCh    <-chan string
 	wg       sync.WaitGroup
 	cancel   context.CancelFunc
 }
 
-func NewPool(n int, store *db.Store, pipeline *pipeline.Runner, jobCh <-chan string) *Pool {
+func NewPool(n int, store *db.Store, pipeline *pipeline.Runner, budget *budget.Checker, jobCh <-chan string) *Pool {
 	return &Pool{
 		n:        n,
 		store:    store,
 		pipeline: pipeline,
+		budget:   budget,
 		jobCh:    jobCh,
 	}
 }
@@ -82,8 +85,16 @@ func (p *Pool) processJob(ctx context.Context, workerID int, notifiedJobID strin
 		}
 	}()
 
+	// Projects over their daily budget wait for the next day; nothing is
+	// claimed once the monthly budget is spent.
+	exhausted, all := p.budget.Exhausted(ctx)
+	if all {
+		slog.Debug("monthly budget exhausted, not claiming jobs", "worker", workerID)
+		return
+	}
+
 	// Claim job atomically (the notified ID is a hint; we claim from DB).
-	jobID, err := p.store.ClaimJob(ctx)
+	jobID, err := p.store.ClaimJob(ctx, exhausted...)
 	if err != nil {
 		slog.Error("claim job failed", "err", err)
 		return